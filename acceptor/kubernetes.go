@@ -0,0 +1,34 @@
+// (c) Copyright IBM Corp. 2023
+
+package acceptor
+
+// KubernetesContainerLimits is used to send the pod container limits to the acceptor plugin
+type KubernetesContainerLimits struct {
+	CPU    float64 `json:"cpu,omitempty"`
+	Memory int64   `json:"memory,omitempty"`
+}
+
+// KubernetesPodData is a representation of a Kubernetes pod for com.instana.plugin.kubernetes.pod plugin
+type KubernetesPodData struct {
+	Runtime       string                    `json:"runtime,omitempty"`
+	Name          string                    `json:"name"`
+	Namespace     string                    `json:"namespace"`
+	UID           string                    `json:"uid,omitempty"`
+	NodeName      string                    `json:"nodeName,omitempty"`
+	ContainerName string                    `json:"containerName,omitempty"`
+	Labels        map[string]string         `json:"labels,omitempty"`
+	Limits        KubernetesContainerLimits `json:"limits"`
+	InstanaZone   string                    `json:"instanaZone,omitempty"`
+	Tags          map[string]interface{}    `json:"tags,omitempty"`
+}
+
+// NewKubernetesPodPluginPayload returns payload for the Kubernetes pod plugin of Instana acceptor
+func NewKubernetesPodPluginPayload(entityID string, data KubernetesPodData) PluginPayload {
+	const pluginName = "com.instana.plugin.kubernetes.pod"
+
+	return PluginPayload{
+		Name:     pluginName,
+		EntityID: entityID,
+		Data:     data,
+	}
+}
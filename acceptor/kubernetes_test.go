@@ -0,0 +1,29 @@
+// (c) Copyright IBM Corp. 2023
+
+package acceptor_test
+
+import (
+	"testing"
+
+	"github.com/instana/go-sensor/acceptor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewKubernetesPodPluginPayload(t *testing.T) {
+	data := acceptor.KubernetesPodData{
+		Name:      "test-pod",
+		Namespace: "test-namespace",
+		NodeName:  "test-node",
+		Labels:    map[string]string{"app": "test-app"},
+		Limits: acceptor.KubernetesContainerLimits{
+			CPU:    0.5,
+			Memory: 268435456,
+		},
+	}
+
+	assert.Equal(t, acceptor.PluginPayload{
+		Name:     "com.instana.plugin.kubernetes.pod",
+		EntityID: "id1",
+		Data:     data,
+	}, acceptor.NewKubernetesPodPluginPayload("id1", data))
+}
@@ -5,7 +5,7 @@ package acceptor
 
 import "strconv"
 
-// RuntimeInfo represents Go runtime info to be sent to com.insana.plugin.golang
+// RuntimeInfo represents Go runtime info to be sent to com.instana.plugin.golang
 type RuntimeInfo struct {
 	Name          string `json:"name"`
 	Version       string `json:"version"`
@@ -16,7 +16,7 @@ type RuntimeInfo struct {
 	SensorVersion string `json:"iv,omitempty"`
 }
 
-// MemoryStats represents Go runtime memory stats to be sent to com.insana.plugin.golang
+// MemoryStats represents Go runtime memory stats to be sent to com.instana.plugin.golang
 type MemoryStats struct {
 	Alloc         uint64  `json:"alloc"`
 	TotalAlloc    uint64  `json:"total_alloc"`
@@ -36,11 +36,30 @@ type MemoryStats struct {
 	GCCPUFraction float64 `json:"gc_cpu_fraction"`
 }
 
-// Metrics represents Go process metrics to be sent to com.insana.plugin.golang
+// CollectorMetrics represents the internal metrics of Instana Go collector to be sent to com.instana.plugin.golang
+type CollectorMetrics struct {
+	QueuedSpans          int    `json:"queued_spans"`
+	SentSpans            uint64 `json:"sent_spans"`
+	DroppedSpans         uint64 `json:"dropped_spans"`
+	SpooledSpans         uint64 `json:"spooled_spans"`
+	SendFailures         uint64 `json:"send_failures"`
+	Retries              uint64 `json:"retries"`
+	DroppedDelayedSpans  uint64 `json:"dropped_delayed_spans"`
+	RejectedPayloads     uint64 `json:"rejected_payloads"`
+	RejectedPayloadBytes uint64 `json:"rejected_payload_bytes"`
+	RejectedSpans        uint64 `json:"rejected_spans"`
+	OversizedSpans       uint64 `json:"oversized_spans"`
+	AgentState           string `json:"agent_state,omitempty"`
+	StateTransitions     uint64 `json:"state_transitions"`
+	AnnounceRetries      uint64 `json:"announce_retries"`
+}
+
+// Metrics represents Go process metrics to be sent to com.instana.plugin.golang
 type Metrics struct {
 	CgoCall     int64 `json:"cgo_call"`
 	Goroutine   int   `json:"goroutine"`
 	MemoryStats `json:"memory"`
+	Collector   *CollectorMetrics `json:"collector,omitempty"`
 }
 
 // GoProcessData is a representation of a Go process for com.instana.plugin.golang plugin
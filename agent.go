@@ -13,6 +13,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/instana/go-sensor/acceptor"
@@ -27,6 +28,9 @@ const (
 	agentDataURL      = "/com.instana.plugin.golang."
 	agentEventURL     = "/com.instana.plugin.generic.event"
 	agentProfilesURL  = "/com.instana.plugin.golang/profiles."
+	agentConfigURL    = "/com.instana.plugin.golang/config."
+	agentActionsURL   = "/com.instana.plugin.golang/actions."
+	agentResultsURL   = "/com.instana.plugin.golang/action-results."
 	agentDefaultHost  = "localhost"
 	agentDefaultPort  = 42699
 	agentHeader       = "Instana Agent"
@@ -35,11 +39,18 @@ const (
 	SnapshotPeriod             = 600
 	snapshotCollectionInterval = SnapshotPeriod * time.Second
 
+	// agentConfigPollPeriod is the interval between requests for the tracing configuration updates
+	agentConfigPollPeriod = 30 * time.Second
+
 	announceTimeout = 15 * time.Second
 	clientTimeout   = 5 * time.Second
 
 	maxContentLength      = 1024 * 1024 * 5
 	numberOfBigSpansToLog = 5
+
+	// maxConsecutiveSendFailures is the number of failed requests in a row after which the sensor
+	// starts over the agent lookup and announcement
+	maxConsecutiveSendFailures = 3
 )
 
 type agentResponse struct {
@@ -50,8 +61,17 @@ type agentResponse struct {
 		List    []string `json:"list"`
 	} `json:"secrets"`
 	ExtraHTTPHeaders []string `json:"extraHeaders"`
+	LogLevel         string   `json:"logLevel"`
 	Tracing          struct {
 		ExtraHTTPHeaders []string `json:"extra-http-headers"`
+		Filter           struct {
+			Deactivate bool         `json:"deactivate"`
+			Exclude    []SpanFilter `json:"exclude"`
+		} `json:"filter"`
+		Sampling struct {
+			Rate *float64 `json:"rate"`
+		} `json:"sampling"`
+		Disable []map[string]bool `json:"disable"`
 	} `json:"tracing"`
 }
 
@@ -63,6 +83,21 @@ func (a *agentResponse) getExtraHTTPHeaders() []string {
 	return a.Tracing.ExtraHTTPHeaders
 }
 
+// getDisabledInstrumentations returns the sorted list of instrumentations disabled in the agent configuration
+func (a *agentResponse) getDisabledInstrumentations() []string {
+	var disabled []string
+	for _, item := range a.Tracing.Disable {
+		for name, off := range item {
+			if off {
+				disabled = append(disabled, name)
+			}
+		}
+	}
+	sort.Strings(disabled)
+
+	return disabled
+}
+
 type discoveryS struct {
 	PID               int      `json:"pid"`
 	Name              string   `json:"name"`
@@ -107,9 +142,13 @@ type agentS struct {
 	logger   LeveledLogger
 
 	printPayloadTooLargeErrInfoOnce sync.Once
+
+	// sendFailures is the number of consecutive failed requests to the agent
+	sendFailures int32
 }
 
-func newAgent(serviceName, host string, port int, logger LeveledLogger) *agentS {
+func newAgent(s *sensorS) *agentS {
+	serviceName, opts, metrics, logger := s.serviceOrBinaryName(), s.options, s.metrics, s.logger
 	if logger == nil {
 		logger = defaultLogger
 	}
@@ -117,17 +156,38 @@ func newAgent(serviceName, host string, port int, logger LeveledLogger) *agentS
 	logger.Debug("initializing agent")
 
 	agent := &agentS{
-		agentComm: newAgentCommunicator(host, strconv.Itoa(port), &fromS{}, logger),
-		port:      strconv.Itoa(port),
+		agentComm: newAgentCommunicator(opts.AgentHost, strconv.Itoa(opts.AgentPort), &fromS{}, logger),
+		port:      strconv.Itoa(opts.AgentPort),
 		snapshot: &SnapshotCollector{
 			CollectionInterval: snapshotCollectionInterval,
 			ServiceName:        serviceName,
 		},
 		logger: logger,
 	}
+	agent.agentComm.gzip = opts.GzipAgentRequests
+	agent.agentComm.metrics = metrics
+
+	dial := dialContextFunc(opts.AgentDialContext)
+	if dial == nil && opts.AgentUnixSocket != "" {
+		dial = unixSocketDialer(opts.AgentUnixSocket)
+	}
+
+	if dial != nil || opts.AgentTLS.enabled() {
+		agent.agentComm.configureTransport(dial, opts.AgentTLS)
+	}
+
+	var actionsPollPeriod time.Duration
+	if opts.EnableAgentActions {
+		actionsPollPeriod = agentActionsPollPeriod
+	}
+
+	retryPolicy := DefaultRetryPolicy()
+	if opts.AgentRetryPolicy != nil {
+		retryPolicy = opts.AgentRetryPolicy.withDefaults()
+	}
 
 	agent.mu.Lock()
-	agent.fsm = newFSM(agent.agentComm, logger)
+	agent.fsm = newFSM(s, agent.agentComm, retryPolicy, actionsPollPeriod, logger)
 	agent.mu.Unlock()
 
 	return agent
@@ -158,11 +218,13 @@ func (agent *agentS) SendMetrics(data acceptor.Metrics) error {
 		}
 
 		agent.logger.Error("failed to send metrics to the host agent: ", err)
-		agent.reset()
+		agent.trackSendResult(err)
 
 		return err
 	}
 
+	agent.trackSendResult(nil)
+
 	return nil
 }
 
@@ -183,31 +245,138 @@ func (agent *agentS) SendEvent(event *EventData) error {
 	return nil
 }
 
-// SendSpans sends collected spans to the host agent
+// SendSpans sends collected spans to the host agent. The spans are sent in batches that fit into the agent
+// payload size limit. Spans that exceed this limit on their own are dropped.
 func (agent *agentS) SendSpans(spans []Span) error {
+	_, err := agent.sendSpans(spans)
+
+	return err
+}
+
+// sendSpans sends collected spans to the host agent and returns the number of delivered spans, which excludes
+// the dropped oversized ones
+func (agent *agentS) sendSpans(spans []Span) (int, error) {
 	for i := range spans {
 		spans[i].From = agent.agentComm.from
 	}
 
-	err := agent.agentComm.sendDataToAgent(agentTracesURL, spans)
-	if err != nil {
+	batches, indices, oversized := splitSpanBatches(spans, maxContentLength)
+
+	if len(oversized) > 0 {
+		agent.agentComm.metrics.SpansOversized(len(oversized))
+		agent.printPayloadTooLargeErrInfoOnce.Do(
+			func() {
+				agent.logDetailedInformationAboutDroppedSpans(numberOfBigSpansToLog, oversized, payloadTooLargeErr)
+			},
+		)
+	}
+
+	var sent, rejected int
+	for i, batch := range batches {
+		err := agent.agentComm.sendDataToAgent(agentTracesURL, batch)
 		if err == payloadTooLargeErr {
-			agent.printPayloadTooLargeErrInfoOnce.Do(
-				func() {
-					agent.logDetailedInformationAboutDroppedSpans(numberOfBigSpansToLog, spans, err)
-				},
-			)
+			// should not happen, since the batch size has been checked already
+			agent.logger.Warn("a batch of ", len(batch), " span(s) has been rejected because it is too large to be sent to the agent")
+			agent.agentComm.metrics.SpansRejected(len(batch))
+			rejected += len(batch)
+
+			continue
+		}
 
-			return nil
-		} else {
+		if err != nil {
 			agent.logger.Error("failed to send spans to the host agent: ", err)
-			agent.reset()
+			agent.trackSendResult(err)
+
+			// the batches sent before the failure should not be sent again
+			var (
+				unsent        []json.RawMessage
+				unsentIndices []int
+			)
+			for j, batch := range batches[i:] {
+				unsent = append(unsent, batch...)
+				unsentIndices = append(unsentIndices, indices[i+j]...)
+			}
+
+			return sent, &unsentSpansError{
+				err:           err,
+				Sent:          sent,
+				Unsent:        rawSpans(unsent),
+				UnsentIndices: unsentIndices,
+			}
 		}
 
-		return err
+		sent += len(batch)
 	}
 
-	return nil
+	if sent > 0 {
+		agent.trackSendResult(nil)
+	}
+
+	if rejected > 0 {
+		// there is no point in sending the rejected spans again
+		return sent, &unsentSpansError{
+			err:  fmt.Errorf("failed to send %d span(s) to the host agent: %s", rejected, payloadTooLargeErr),
+			Sent: sent,
+		}
+	}
+
+	return sent, nil
+}
+
+// unsentSpansError is returned by agentS.SendSpans if some of the span batches have not been delivered
+type unsentSpansError struct {
+	err error
+	// Sent is the number of spans delivered to the agent
+	Sent int
+	// Unsent contains the spans that could not be delivered and can be sent again
+	Unsent []Span
+	// UnsentIndices contains the positions of the Unsent spans in the slice passed to SendSpans. Since
+	// the oversized spans are dropped, these are not necessarily the last ones
+	UnsentIndices []int
+}
+
+func (e *unsentSpansError) Error() string { return e.err.Error() }
+
+// splitSpanBatches encodes spans and groups them into batches, so that each batch encoded as a JSON array
+// does not exceed the maxSize. The positions of the batched spans in the spans slice are returned alongside
+// the batches. The spans that are larger than maxSize are returned separately.
+func splitSpanBatches(spans []Span, maxSize int) ([][]json.RawMessage, [][]int, []Span) {
+	var (
+		batches      [][]json.RawMessage
+		batch        []json.RawMessage
+		indices      [][]int
+		batchIndices []int
+		batchSize    int
+		oversized    []Span
+	)
+
+	for i := range spans {
+		data, err := json.Marshal(spans[i])
+		if err != nil {
+			continue
+		}
+
+		// each span is followed by either a comma or a closing bracket
+		spanSize := len(data) + 1
+		if 1+spanSize > maxSize {
+			oversized = append(oversized, spans[i])
+			continue
+		}
+
+		if len(batch) > 0 && 1+batchSize+spanSize > maxSize {
+			batches, indices = append(batches, batch), append(indices, batchIndices)
+			batch, batchIndices, batchSize = nil, nil, 0
+		}
+
+		batch, batchIndices = append(batch, data), append(batchIndices, i)
+		batchSize += spanSize
+	}
+
+	if len(batch) > 0 {
+		batches, indices = append(batches, batch), append(indices, batchIndices)
+	}
+
+	return batches, indices, oversized
 }
 
 // Flush is a noop for host agent
@@ -232,11 +401,13 @@ func (agent *agentS) SendProfiles(profiles []autoprofile.Profile) error {
 		}
 
 		agent.logger.Error("failed to send profile data to the host agent: ", err)
-		agent.reset()
+		agent.trackSendResult(err)
 
 		return err
 	}
 
+	agent.trackSendResult(nil)
+
 	return nil
 }
 
@@ -244,12 +415,77 @@ func (agent *agentS) setLogger(l LeveledLogger) {
 	agent.logger = l
 }
 
-func (agent *agentS) reset() {
+// close stops the agent pollers and waits for them to return. The agent connection is not used by the sensor
+// anymore once it has been closed.
+func (agent *agentS) close() {
+	agent.mu.RLock()
+	fsm := agent.fsm
+	agent.mu.RUnlock()
+
+	fsm.shutdown()
+}
+
+// reset restarts the agent connection cycle, recording err as the last connection error if not nil
+func (agent *agentS) reset(err error) {
 	agent.mu.Lock()
+	agent.fsm.failed(err)
 	agent.fsm.reset()
 	agent.mu.Unlock()
 }
 
+// trackSendResult keeps count of consecutive failed requests to the agent and starts over the agent connection
+// cycle once there are maxConsecutiveSendFailures of them in a row or the agent responds with 404 Not Found, which
+// means that it does not know this process anymore, i.e. after the agent restart. The spans that have not been sent
+// are kept in the recorder queue until the sensor is announced to the new agent.
+func (agent *agentS) trackSendResult(err error) {
+	if err == nil {
+		atomic.StoreInt32(&agent.sendFailures, 0)
+		return
+	}
+
+	if err == payloadTooLargeErr {
+		return
+	}
+
+	if conn := agent.connection(); conn != nil {
+		conn.Failed(err)
+	}
+
+	failures := atomic.AddInt32(&agent.sendFailures, 1)
+	if failures < maxConsecutiveSendFailures && !isAgentNotFoundErr(err) {
+		return
+	}
+
+	// the connection cycle has been restarted already
+	if !agent.Ready() {
+		return
+	}
+
+	agent.logger.Warn("lost connection to the host agent, starting over the agent lookup: ", err)
+	atomic.StoreInt32(&agent.sendFailures, 0)
+	agent.reset(err)
+}
+
+// reconnecting returns whether the agent has been ready before and the connection is being re-established
+func (agent *agentS) reconnecting() bool {
+	agent.mu.RLock()
+	defer agent.mu.RUnlock()
+
+	return atomic.LoadInt32(&agent.fsm.wasReady) == 1 && agent.fsm.fsm.Current() != "ready"
+}
+
+// connection returns the agent connection status tracker
+func (agent *agentS) connection() *agentConnectionTracker {
+	agent.mu.RLock()
+	defer agent.mu.RUnlock()
+
+	if agent.fsm == nil {
+		return nil
+	}
+
+	return agent.fsm.conn
+}
+
 func (agent *agentS) logDetailedInformationAboutDroppedSpans(size int, spans []Span, err error) {
 	var marshaledSpans []string
 	for i := range spans {
@@ -0,0 +1,323 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"runtime/pprof"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/instana/go-sensor/autoprofile"
+	"github.com/instana/go-sensor/logger"
+)
+
+const (
+	// agentActionsPollPeriod is the interval between requests for the pending agent actions
+	agentActionsPollPeriod = 10 * time.Second
+	// agentActionTimeout limits the time an action may take in addition to the requested duration
+	agentActionTimeout = 30 * time.Second
+	// defaultProfileDuration is the sampling duration for CPU and block profiles requested without a duration
+	defaultProfileDuration = 10 * time.Second
+	// maxProfileDuration is the longest sampling duration an agent can request for CPU and block profiles
+	maxProfileDuration = 60 * time.Second
+	// maxConcurrentAgentActions is the maximum number of agent actions running at the same time
+	maxConcurrentAgentActions = 4
+)
+
+var errTooManyAgentActions = errors.New("too many actions are running, try again later")
+
+// Built-in agent actions
+const (
+	// AgentActionCPUProfile captures a CPU profile. The sampling duration in seconds can be provided via "duration" argument.
+	AgentActionCPUProfile = "profile.cpu"
+	// AgentActionHeapProfile captures a heap allocations profile
+	AgentActionHeapProfile = "profile.heap"
+	// AgentActionBlockProfile captures a blocking calls profile. The sampling duration in seconds can be provided via
+	// "duration" argument.
+	AgentActionBlockProfile = "profile.block"
+	// AgentActionGoroutines dumps the stacks of all running goroutines
+	AgentActionGoroutines = "goroutines"
+	// AgentActionConfig reports the effective sensor and tracer configuration
+	AgentActionConfig = "config"
+	// AgentActionDebugLogging enables or disables the debug logging depending on the "enabled" argument
+	AgentActionDebugLogging = "log.debug"
+	// AgentActionFlush sends all buffered spans to the agent
+	AgentActionFlush = "flush"
+)
+
+// AgentActionFunc executes an action requested by the host agent. The returned value is JSON-encoded and sent back
+// to the agent along with the error message if any.
+type AgentActionFunc func(ctx context.Context, args map[string]interface{}) (interface{}, error)
+
+var (
+	agentActionsMu sync.RWMutex
+	agentActions   = make(map[string]AgentActionFunc)
+	// agentActionSlots limits the number of concurrently running agent actions
+	agentActionSlots = make(chan struct{}, maxConcurrentAgentActions)
+)
+
+func init() {
+	RegisterAgentAction(AgentActionCPUProfile, profileAction(autoprofile.CPUProfile))
+	RegisterAgentAction(AgentActionHeapProfile, profileAction(autoprofile.HeapProfile))
+	RegisterAgentAction(AgentActionBlockProfile, profileAction(autoprofile.BlockProfile))
+	RegisterAgentAction(AgentActionGoroutines, goroutinesAction)
+	RegisterAgentAction(AgentActionConfig, configAction)
+	RegisterAgentAction(AgentActionDebugLogging, debugLoggingAction)
+	RegisterAgentAction(AgentActionFlush, flushAction)
+}
+
+// RegisterAgentAction makes the sensor execute fn whenever the host agent requests an action with the given name.
+// Registering an action with the name of a built-in one replaces it. Providing a nil fn removes the action.
+func RegisterAgentAction(name string, fn AgentActionFunc) {
+	agentActionsMu.Lock()
+	defer agentActionsMu.Unlock()
+
+	if fn == nil {
+		delete(agentActions, name)
+		return
+	}
+
+	agentActions[name] = fn
+}
+
+// RegisteredAgentActions returns the sorted list of action names that can be requested by the host agent
+func RegisteredAgentActions() []string {
+	agentActionsMu.RLock()
+	defer agentActionsMu.RUnlock()
+
+	names := make([]string, 0, len(agentActions))
+	for name := range agentActions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// agentActionRequest is an action requested by the host agent
+type agentActionRequest struct {
+	ID     string                 `json:"id"`
+	Action string                 `json:"action"`
+	Args   map[string]interface{} `json:"args,omitempty"`
+}
+
+// agentActionResult is the outcome of an action sent back to the host agent
+type agentActionResult struct {
+	ID     string      `json:"id"`
+	Action string      `json:"action"`
+	Data   interface{} `json:"data,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// runAgentAction executes the requested action and returns its result
+func runAgentAction(ctx context.Context, req agentActionRequest) agentActionResult {
+	res := agentActionResult{ID: req.ID, Action: req.Action}
+
+	agentActionsMu.RLock()
+	fn, ok := agentActions[req.Action]
+	agentActionsMu.RUnlock()
+
+	if !ok {
+		res.Error = fmt.Sprintf("unknown action %q", req.Action)
+		return res
+	}
+
+	// an invalid duration is reported by the action itself
+	d, _ := durationArg(req.Args, 0)
+
+	ctx, cancel := context.WithTimeout(ctx, d+agentActionTimeout)
+	defer cancel()
+
+	data, err := safeRunAgentAction(ctx, fn, req.Args)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	res.Data = data
+
+	return res
+}
+
+func safeRunAgentAction(ctx context.Context, fn AgentActionFunc, args map[string]interface{}) (data interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("action panicked: %v", r)
+		}
+	}()
+
+	return fn(ctx, args)
+}
+
+// durationArg returns the value of "duration" action argument in seconds capped at maxProfileDuration, or the
+// default if not set
+func durationArg(args map[string]interface{}, def time.Duration) (time.Duration, error) {
+	arg, ok := args["duration"]
+	if !ok {
+		return def, nil
+	}
+
+	v, ok := arg.(float64)
+	if !ok || !(v > 0) {
+		return 0, fmt.Errorf("duration must be a positive number of seconds, got %v", arg)
+	}
+
+	if v >= maxProfileDuration.Seconds() {
+		return maxProfileDuration, nil
+	}
+
+	return time.Duration(v * float64(time.Second)), nil
+}
+
+func profileAction(profileType string) AgentActionFunc {
+	return func(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+		d, err := durationArg(args, defaultProfileDuration)
+		if err != nil {
+			return nil, err
+		}
+
+		return autoprofile.Capture(ctx, profileType, d)
+	}
+}
+
+func goroutinesAction(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	buf := bytes.NewBuffer(nil)
+	if err := pprof.Lookup("goroutine").WriteTo(buf, 2); err != nil {
+		return nil, err
+	}
+
+	return buf.String(), nil
+}
+
+// effectiveConfig is the sensor configuration reported by the config action
+type effectiveConfig struct {
+	Service                     string       `json:"service"`
+	AgentHost                   string       `json:"agentHost"`
+	AgentPort                   int          `json:"agentPort"`
+	AgentUnixSocket             string       `json:"agentUnixSocket,omitempty"`
+	AgentTLS                    bool         `json:"agentTLS"`
+	GzipAgentRequests           bool         `json:"gzipAgentRequests"`
+	MaxBufferedSpans            int          `json:"maxBufferedSpans"`
+	ForceTransmissionStartingAt int          `json:"forceTransmissionStartingAt"`
+	LogLevel                    string       `json:"logLevel"`
+	EnableAutoProfile           bool         `json:"enableAutoProfile"`
+	SpoolDir                    string       `json:"spoolDir,omitempty"`
+	Tracer                      tracerConfig `json:"tracer"`
+	Actions                     []string     `json:"actions"`
+	Stats                       SensorStats  `json:"stats"`
+	Uptime                      string       `json:"uptime"`
+}
+
+type tracerConfig struct {
+	DropAllLogs              bool         `json:"dropAllLogs"`
+	MaxLogsPerSpan           int          `json:"maxLogsPerSpan"`
+	Secrets                  string       `json:"secrets"`
+	CollectableHTTPHeaders   []string     `json:"collectableHTTPHeaders"`
+	Sampler                  string       `json:"sampler,omitempty"`
+	Use128BitTraceIDs        bool         `json:"use128BitTraceIDs"`
+	Propagators              []string     `json:"propagators"`
+	SpanFilters              []SpanFilter `json:"spanFilters"`
+	DisabledInstrumentations []string     `json:"disabledInstrumentations"`
+}
+
+func configAction(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	s := sensor
+	if s == nil {
+		return nil, errors.New("sensor is not initialized")
+	}
+
+	opts, tracerOpts := s.options, s.tracerOptions()
+
+	conf := effectiveConfig{
+		Service:                     s.serviceOrBinaryName(),
+		AgentHost:                   opts.AgentHost,
+		AgentPort:                   opts.AgentPort,
+		AgentUnixSocket:             opts.AgentUnixSocket,
+		AgentTLS:                    opts.AgentTLS.enabled(),
+		GzipAgentRequests:           opts.GzipAgentRequests,
+		MaxBufferedSpans:            opts.MaxBufferedSpans,
+		ForceTransmissionStartingAt: opts.ForceTransmissionStartingAt,
+		EnableAutoProfile:           opts.EnableAutoProfile,
+		SpoolDir:                    opts.SpoolDir,
+		Tracer: tracerConfig{
+			DropAllLogs:              tracerOpts.DropAllLogs,
+			MaxLogsPerSpan:           tracerOpts.MaxLogsPerSpan,
+			CollectableHTTPHeaders:   tracerOpts.CollectableHTTPHeaders,
+			Use128BitTraceIDs:        tracerOpts.Use128BitTraceIDs,
+			Propagators:              tracerOpts.Propagators,
+			SpanFilters:              tracerOpts.SpanFilters,
+			DisabledInstrumentations: tracerOpts.DisabledInstrumentations,
+		},
+		Actions: RegisteredAgentActions(),
+		Stats:   s.Metrics().Stats(),
+		Uptime:  time.Since(processStartedAt).String(),
+	}
+
+	if tracerOpts.Secrets != nil {
+		conf.Tracer.Secrets = fmt.Sprintf("%T", tracerOpts.Secrets)
+	}
+
+	if tracerOpts.Sampler != nil {
+		conf.Tracer.Sampler = fmt.Sprintf("%T", tracerOpts.Sampler)
+	}
+
+	if l, ok := s.logger.(*logger.Logger); ok {
+		conf.LogLevel = l.Level().String()
+	}
+
+	return conf, nil
+}
+
+func debugLoggingAction(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	s := sensor
+	if s == nil {
+		return nil, errors.New("sensor is not initialized")
+	}
+
+	l, ok := s.logger.(*logger.Logger)
+	if !ok {
+		return nil, errors.New("log level cannot be changed for a custom logger")
+	}
+
+	enabled, ok := args["enabled"].(bool)
+	if !ok {
+		return nil, errors.New(`missing boolean "enabled" argument`)
+	}
+
+	if enabled {
+		l.SetLevel(logger.DebugLevel)
+	} else {
+		setLogLevel(l, s.options.LogLevel)
+	}
+
+	return map[string]string{"logLevel": l.Level().String()}, nil
+}
+
+func flushAction(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	s := sensor
+	if s == nil {
+		return nil, errors.New("sensor is not initialized")
+	}
+
+	recorders := s.Metrics().Recorders()
+	if len(recorders) == 0 {
+		return nil, errors.New("the tracer does not use an instana.Recorder that can be flushed")
+	}
+
+	var queued int
+	for _, rec := range recorders {
+		n := rec.QueuedSpansCount()
+		if err := rec.Flush(ctx); err != nil {
+			return nil, err
+		}
+
+		queued += n
+	}
+
+	return map[string]int{"flushedSpans": queued}, nil
+}
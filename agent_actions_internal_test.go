@@ -0,0 +1,185 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/instana/go-sensor/autoprofile"
+	"github.com/instana/go-sensor/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunAgentAction(t *testing.T) {
+	RegisterAgentAction("test.action", func(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+		if args["fail"] == true {
+			return nil, errors.New("something went wrong")
+		}
+
+		return map[string]interface{}{"args": args}, nil
+	})
+	defer RegisterAgentAction("test.action", nil)
+
+	RegisterAgentAction("test.panic", func(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+		panic("oops")
+	})
+	defer RegisterAgentAction("test.panic", nil)
+
+	assert.Contains(t, RegisteredAgentActions(), "test.action")
+
+	examples := map[string]struct {
+		Request  agentActionRequest
+		Expected agentActionResult
+	}{
+		"success": {
+			Request: agentActionRequest{ID: "1", Action: "test.action", Args: map[string]interface{}{"key": "value"}},
+			Expected: agentActionResult{
+				ID:     "1",
+				Action: "test.action",
+				Data:   map[string]interface{}{"args": map[string]interface{}{"key": "value"}},
+			},
+		},
+		"error": {
+			Request:  agentActionRequest{ID: "2", Action: "test.action", Args: map[string]interface{}{"fail": true}},
+			Expected: agentActionResult{ID: "2", Action: "test.action", Error: "something went wrong"},
+		},
+		"panic": {
+			Request:  agentActionRequest{ID: "3", Action: "test.panic"},
+			Expected: agentActionResult{ID: "3", Action: "test.panic", Error: "action panicked: oops"},
+		},
+		"unknown action": {
+			Request:  agentActionRequest{ID: "4", Action: "test.unknown"},
+			Expected: agentActionResult{ID: "4", Action: "test.unknown", Error: `unknown action "test.unknown"`},
+		},
+	}
+
+	for name, example := range examples {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, example.Expected, runAgentAction(context.Background(), example.Request))
+		})
+	}
+}
+
+func TestRunAgentAction_BuiltIn(t *testing.T) {
+	InitSensor(&Options{Service: "test-service", AgentClient: alwaysReadyClient{}})
+	defer ShutdownSensor()
+
+	t.Run(AgentActionGoroutines, func(t *testing.T) {
+		res := runAgentAction(context.Background(), agentActionRequest{Action: AgentActionGoroutines})
+		require.Empty(t, res.Error)
+
+		assert.Contains(t, res.Data, "TestRunAgentAction_BuiltIn")
+	})
+
+	t.Run(AgentActionHeapProfile, func(t *testing.T) {
+		res := runAgentAction(context.Background(), agentActionRequest{Action: AgentActionHeapProfile})
+		require.Empty(t, res.Error)
+
+		assert.IsType(t, autoprofile.Profile{}, res.Data)
+	})
+
+	t.Run(AgentActionConfig, func(t *testing.T) {
+		res := runAgentAction(context.Background(), agentActionRequest{Action: AgentActionConfig})
+		require.Empty(t, res.Error)
+
+		data, err := json.Marshal(res.Data)
+		require.NoError(t, err)
+
+		var conf struct {
+			Service string   `json:"service"`
+			Actions []string `json:"actions"`
+		}
+		require.NoError(t, json.Unmarshal(data, &conf))
+
+		assert.Equal(t, "test-service", conf.Service)
+		assert.Contains(t, conf.Actions, AgentActionConfig)
+	})
+
+	t.Run(AgentActionDebugLogging, func(t *testing.T) {
+		l := logger.New(nil)
+		sensor.setLogger(l)
+
+		res := runAgentAction(context.Background(), agentActionRequest{
+			Action: AgentActionDebugLogging,
+			Args:   map[string]interface{}{"enabled": true},
+		})
+		require.Empty(t, res.Error)
+		assert.Equal(t, logger.DebugLevel, l.Level())
+
+		res = runAgentAction(context.Background(), agentActionRequest{
+			Action: AgentActionDebugLogging,
+			Args:   map[string]interface{}{"enabled": false},
+		})
+		require.Empty(t, res.Error)
+		assert.Equal(t, logger.ErrorLevel, l.Level())
+
+		res = runAgentAction(context.Background(), agentActionRequest{Action: AgentActionDebugLogging})
+		assert.NotEmpty(t, res.Error)
+	})
+
+	t.Run(AgentActionFlush, func(t *testing.T) {
+		recorder := NewRecorder()
+		tracer := NewTracerWithEverything(sensor.options, recorder)
+
+		tracer.StartSpan("test-span").Finish()
+
+		res := runAgentAction(context.Background(), agentActionRequest{Action: AgentActionFlush})
+		require.Empty(t, res.Error)
+
+		assert.Equal(t, map[string]int{"flushedSpans": 1}, res.Data)
+		assert.Equal(t, 0, recorder.QueuedSpansCount())
+	})
+
+	t.Run(AgentActionFlush+" with a custom recorder", func(t *testing.T) {
+		opts := sensor.options
+		ShutdownSensor()
+		defer InitSensor(opts)
+
+		NewTracerWithEverything(opts, &capturingRecorder{})
+
+		res := runAgentAction(context.Background(), agentActionRequest{Action: AgentActionFlush})
+		assert.NotEmpty(t, res.Error)
+	})
+}
+
+func TestDurationArg(t *testing.T) {
+	examples := map[string]struct {
+		Args     map[string]interface{}
+		Expected time.Duration
+	}{
+		"not set":   {Expected: defaultProfileDuration},
+		"valid":     {Args: map[string]interface{}{"duration": 1.5}, Expected: 1500 * time.Millisecond},
+		"too large": {Args: map[string]interface{}{"duration": 1e12}, Expected: maxProfileDuration},
+	}
+
+	for name, example := range examples {
+		t.Run(name, func(t *testing.T) {
+			d, err := durationArg(example.Args, defaultProfileDuration)
+			require.NoError(t, err)
+			assert.Equal(t, example.Expected, d)
+		})
+	}
+
+	for _, v := range []interface{}{0.0, -1.0, math.NaN(), "10"} {
+		t.Run(fmt.Sprintf("invalid %v", v), func(t *testing.T) {
+			_, err := durationArg(map[string]interface{}{"duration": v}, defaultProfileDuration)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestRunAgentAction_SensorNotInitialized(t *testing.T) {
+	for _, action := range []string{AgentActionConfig, AgentActionDebugLogging, AgentActionFlush} {
+		t.Run(action, func(t *testing.T) {
+			res := runAgentAction(context.Background(), agentActionRequest{ID: "1", Action: action})
+			assert.Equal(t, agentActionResult{ID: "1", Action: action, Error: "sensor is not initialized"}, res)
+		})
+	}
+}
@@ -4,13 +4,19 @@ package instana
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 )
 
+// dialContextFunc establishes a network connection, see (*net.Dialer).DialContext
+type dialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
 // agentCommunicator is a collection of data and actions to be executed against the agent.
 type agentCommunicator struct {
 	// host is the agent host. It can be updated via default gateway or a new client announcement.
@@ -27,13 +33,30 @@ type agentCommunicator struct {
 	// client is an HTTP client
 	client httpClient
 
+	// tls makes the agent communicator use HTTPS
+	tls bool
+
+	// dial is a custom function used to connect to the agent. If nil, the agent is reached via TCP at host:port
+	dial dialContextFunc
+
+	// gzip enables the gzip compression of data sent to the agent
+	gzip bool
+
+	// metrics is the registry of the sensor internal metrics updated by both the agent and the fsm layer
+	metrics *collectorMetrics
+
 	// l is the Instana logger
 	l LeveledLogger
 }
 
 // buildURL builds an Agent URL based on the sufix for the different Agent services.
 func (a *agentCommunicator) buildURL(sufix string) string {
-	url := "http://" + a.host + ":" + a.port + sufix
+	scheme := "http://"
+	if a.tls {
+		scheme = "https://"
+	}
+
+	url := scheme + a.host + ":" + a.port + sufix
 
 	if sufix[len(sufix)-1:] == "." && a.from.EntityID != "" {
 		url += a.from.EntityID
@@ -124,6 +147,81 @@ func (a *agentCommunicator) agentResponse(d *discoveryS) *agentResponse {
 	return &resp
 }
 
+// agentConfig attempts to retrieve the current tracing configuration from the agent. The response has
+// the same format as the announce one
+func (a *agentCommunicator) agentConfig() *agentResponse {
+	u := a.buildURL(agentConfigURL)
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		a.l.Debug("Error creating request to the agent while attempting to get the configuration: ", err.Error())
+		return nil
+	}
+
+	res, err := a.client.Do(req)
+	if err != nil || res == nil {
+		a.l.Debug("No response from the agent while attempting to get the configuration: ", err)
+		return nil
+	}
+
+	defer func() {
+		io.CopyN(ioutil.Discard, res.Body, 256<<10)
+		res.Body.Close()
+	}()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		a.l.Debug("Unexpected response from the agent while attempting to get the configuration: ", res.Status)
+		return nil
+	}
+
+	var resp agentResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		a.l.Debug("Error unmarshaling body while attempting to get the configuration from the agent: ", err.Error())
+		return nil
+	}
+
+	return &resp
+}
+
+// agentActions retrieves the list of actions requested by the agent
+func (a *agentCommunicator) agentActions() []agentActionRequest {
+	u := a.buildURL(agentActionsURL)
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		a.l.Debug("Error creating request to the agent while attempting to get the requested actions: ", err.Error())
+		return nil
+	}
+
+	res, err := a.client.Do(req)
+	if err != nil || res == nil {
+		a.l.Debug("No response from the agent while attempting to get the requested actions: ", err)
+		return nil
+	}
+
+	defer func() {
+		io.CopyN(ioutil.Discard, res.Body, 256<<10)
+		res.Body.Close()
+	}()
+
+	if res.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		a.l.Debug("Unexpected response from the agent while attempting to get the requested actions: ", res.Status)
+		return nil
+	}
+
+	var actions []agentActionRequest
+	if err := json.NewDecoder(res.Body).Decode(&actions); err != nil {
+		a.l.Debug("Error unmarshaling body while attempting to get the requested actions from the agent: ", err.Error())
+		return nil
+	}
+
+	return actions
+}
+
 // pingAgent send a HEAD request to the agent and returns true if it receives a response from it
 func (a *agentCommunicator) pingAgent() bool {
 	u := a.buildURL(agentDataURL)
@@ -156,30 +254,26 @@ func (a *agentCommunicator) pingAgent() bool {
 	return true
 }
 
-// sendDataToAgent makes a POST to the agent sending some data as payload. eg: spans, events or metrics
+// sendDataToAgent makes a POST to the agent sending some data as payload. eg: spans, events or metrics.
+// The data is JSON-encoded and compressed with gzip if enabled. The request is not sent and payloadTooLargeErr
+// is returned as soon as the encoded payload exceeds maxContentLength.
 func (a *agentCommunicator) sendDataToAgent(suffix string, data interface{}) error {
 	url := a.buildURL(suffix)
 	ctx, cancel := context.WithTimeout(context.Background(), clientTimeout)
 	defer cancel()
 
-	var r *bytes.Buffer
-
+	var body io.Reader
 	if data != nil {
-		b, err := json.Marshal(data)
-
+		payload, err := a.encodePayload(data)
 		if err != nil {
-			a.l.Debug("Sending data to agent marshaling failed: ", err.Error())
 			return err
 		}
 
-		r = bytes.NewBuffer(b)
-
-		if r.Len() > maxContentLength {
-			return payloadTooLargeErr
-		}
+		// the request body can be replayed by the client if the request needs to be retried
+		body = bytes.NewReader(payload)
 	}
 
-	req, err := http.NewRequest(http.MethodPost, url, r)
+	req, err := http.NewRequest(http.MethodPost, url, body)
 
 	if err != nil {
 		a.l.Debug("Sending data to agent request creation failed: ", err.Error())
@@ -189,6 +283,9 @@ func (a *agentCommunicator) sendDataToAgent(suffix string, data interface{}) err
 	req = req.WithContext(ctx)
 
 	req.Header.Set("Content-Type", "application/json")
+	if data != nil && a.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
 
 	resp, err := a.client.Do(req)
 
@@ -198,8 +295,9 @@ func (a *agentCommunicator) sendDataToAgent(suffix string, data interface{}) err
 
 	if resp != nil {
 		respCode := resp.StatusCode
-		if respCode < 200 || respCode >= 300 {
+		if err == nil && (respCode < 200 || respCode >= 300) {
 			a.l.Debug("Sending data to agent: response code: ", resp.StatusCode, "-", resp.Status, "; ", url)
+			err = agentStatusError(respCode)
 		}
 
 		io.CopyN(ioutil.Discard, resp.Body, 256<<10)
@@ -213,6 +311,162 @@ func (a *agentCommunicator) sendDataToAgent(suffix string, data interface{}) err
 	return err
 }
 
+// agentStatusError is returned when the agent responds with a non-2xx status code
+type agentStatusError int
+
+func (e agentStatusError) Error() string {
+	return fmt.Sprintf("unexpected response from the agent: %d %s", int(e), http.StatusText(int(e)))
+}
+
+// isAgentNotFoundErr returns true if the agent responded with 404 Not Found
+func isAgentNotFoundErr(err error) bool {
+	code, ok := err.(agentStatusError)
+
+	return ok && int(code) == http.StatusNotFound
+}
+
+// encodePayload returns JSON-encoded data, optionally compressed with gzip. The batches of spans that have
+// already been encoded are written as is. The size limit is applied to the uncompressed payload.
+func (a *agentCommunicator) encodePayload(data interface{}) ([]byte, error) {
+	var (
+		buf bytes.Buffer
+		w   io.Writer = &buf
+		gz  *gzip.Writer
+	)
+
+	if a.gzip {
+		gz = gzip.NewWriter(&buf)
+		w = gz
+	}
+
+	lw := &limitedWriter{w: w, limit: maxContentLength}
+
+	var err error
+	if batch, ok := data.([]json.RawMessage); ok {
+		err = writeJSONArray(lw, batch)
+	} else {
+		err = json.NewEncoder(lw).Encode(data)
+	}
+
+	if lw.Exceeded() {
+		a.metrics.PayloadRejected(lw.Written())
+		return nil, payloadTooLargeErr
+	}
+
+	if err != nil {
+		a.l.Debug("Sending data to agent marshaling failed: ", err.Error())
+		return nil, err
+	}
+
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeJSONArray writes a JSON array of already encoded documents
+func writeJSONArray(w io.Writer, docs []json.RawMessage) error {
+	if _, err := w.Write([]byte{'['}); err != nil {
+		return err
+	}
+
+	for i, doc := range docs {
+		if i > 0 {
+			if _, err := w.Write([]byte{','}); err != nil {
+				return err
+			}
+		}
+
+		if _, err := w.Write(doc); err != nil {
+			return err
+		}
+	}
+
+	_, err := w.Write([]byte{']'})
+
+	return err
+}
+
+// limitedWriter is an io.Writer that fails with payloadTooLargeErr once the number of written bytes
+// exceeds the limit
+type limitedWriter struct {
+	w     io.Writer
+	limit int
+
+	written  int
+	exceeded bool
+}
+
+func (lw *limitedWriter) Write(p []byte) (int, error) {
+	lw.written += len(p)
+	if lw.written > lw.limit {
+		lw.exceeded = true
+	}
+
+	if lw.exceeded {
+		return 0, payloadTooLargeErr
+	}
+
+	return lw.w.Write(p)
+}
+
+// Exceeded returns whether there was an attempt to write more data than allowed
+func (lw *limitedWriter) Exceeded() bool {
+	return lw.exceeded
+}
+
+// Written returns the number of bytes attempted to be written
+func (lw *limitedWriter) Written() int {
+	return lw.written
+}
+
+// configureTransport makes the agent communicator use dial to establish connections to the agent and
+// communicate with it over TLS if enabled in tlsOpts
+func (a *agentCommunicator) configureTransport(dial dialContextFunc, tlsOpts AgentTLSOptions) {
+	var rt http.RoundTripper
+
+	if tlsOpts.enabled() {
+		rt = newAgentTLSTransport(tlsOpts, dial, a.l)
+		a.tls = true
+	} else if dial != nil {
+		tr := http.DefaultTransport.(*http.Transport).Clone()
+		tr.Proxy = nil
+		tr.DialContext = dial
+
+		rt = tr
+	}
+
+	a.dial = dial
+	a.client = &http.Client{
+		Timeout:   announceTimeout,
+		Transport: rt,
+	}
+}
+
+// dialAgent opens a new connection to the agent
+func (a *agentCommunicator) dialAgent(ctx context.Context) (net.Conn, error) {
+	addr := net.JoinHostPort(a.host, a.port)
+
+	if a.dial != nil {
+		return a.dial(ctx, "tcp", addr)
+	}
+
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", addr)
+}
+
+// unixSocketDialer returns a dialContextFunc that connects to the unix domain socket at path regardless
+// of the requested address
+func unixSocketDialer(path string) dialContextFunc {
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", path)
+	}
+}
+
 func newAgentCommunicator(host, port string, from *fromS, logger LeveledLogger) *agentCommunicator {
 	return &agentCommunicator{
 		host: host,
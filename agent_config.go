@@ -0,0 +1,86 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana
+
+import (
+	"os"
+	"strings"
+
+	"github.com/instana/go-sensor/logger"
+)
+
+// agentTracerOptions returns a copy of configured tracer options updated with the tracing configuration received
+// from the host agent. The values provided in code or via env variables take precedence over the agent configuration,
+// except for the secrets matcher that is always taken from the agent if provided. The span filters and disabled
+// instrumentations are combined. The sampler is the one built for the sampling rate received from the agent, if any.
+func agentTracerOptions(configured TracerOptions, resp agentResponse, sampler Sampler, l LeveledLogger) TracerOptions {
+	opts := configured
+
+	if resp.Secrets.Matcher != "" {
+		m, err := NamedMatcher(resp.Secrets.Matcher, resp.Secrets.List)
+		if err != nil {
+			l.Warn("failed to apply secrets matcher configuration: ", err)
+		} else {
+			opts.Secrets = m
+		}
+	}
+
+	if len(configured.CollectableHTTPHeaders) == 0 {
+		opts.CollectableHTTPHeaders = resp.getExtraHTTPHeaders()
+	}
+
+	if configured.Sampler == nil && sampler != nil {
+		opts.Sampler = sampler
+	}
+
+	if !resp.Tracing.Filter.Deactivate && len(resp.Tracing.Filter.Exclude) > 0 {
+		opts.SpanFilters = append(append([]SpanFilter(nil), configured.SpanFilters...), resp.Tracing.Filter.Exclude...)
+	}
+
+	if disabled := resp.getDisabledInstrumentations(); len(disabled) > 0 {
+		opts.DisabledInstrumentations = append(append([]string(nil), configured.DisabledInstrumentations...), disabled...)
+	}
+
+	return opts
+}
+
+// agentLogLevel returns the logger level that corresponds to the log level value in the agent configuration
+func agentLogLevel(s string) (logger.Level, bool) {
+	switch strings.ToLower(s) {
+	case "error":
+		return logger.ErrorLevel, true
+	case "warn", "warning":
+		return logger.WarnLevel, true
+	case "info":
+		return logger.InfoLevel, true
+	case "debug":
+		return logger.DebugLevel, true
+	default:
+		return logger.ErrorLevel, false
+	}
+}
+
+// applyAgentLogLevel changes the level of the sensor logger to the one set in the agent configuration. An empty
+// or unknown value restores the level configured via (instana.Options).LogLevel. The agent configuration is
+// ignored if the sensor uses a custom logger or the log level is set via INSTANA_LOG_LEVEL env variable.
+func (r *sensorS) applyAgentLogLevel(s string) {
+	if r == nil || r.options == nil {
+		return
+	}
+
+	l, ok := r.logger.(*logger.Logger)
+	if !ok {
+		return
+	}
+
+	if _, ok := os.LookupEnv("INSTANA_LOG_LEVEL"); ok {
+		return
+	}
+
+	if lvl, ok := agentLogLevel(s); ok {
+		l.SetLevel(lvl)
+		return
+	}
+
+	setLogLevel(l, r.options.LogLevel)
+}
@@ -0,0 +1,122 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/instana/go-sensor/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const agentConfigJSON = `{
+	"secrets": {"matcher": "contains-ignore-case", "list": ["key", "pass"]},
+	"logLevel": "debug",
+	"tracing": {
+		"extra-http-headers": ["x-request-id"],
+		"sampling": {"rate": 0.5},
+		"filter": {
+			"deactivate": false,
+			"exclude": [{
+				"name": "health checks",
+				"attributes": [{"key": "http.path", "values": ["/health"], "match_type": "strict"}]
+			}]
+		},
+		"disable": [{"kafka": true}, {"g.sql": false}, {"sqs": true}]
+	}
+}`
+
+func TestAgentTracerOptions(t *testing.T) {
+	var resp agentResponse
+	require.NoError(t, json.Unmarshal([]byte(agentConfigJSON), &resp))
+
+	opts := agentTracerOptions(DefaultTracerOptions(), resp, NewProbabilisticSampler(0.5), defaultLogger)
+
+	assert.True(t, opts.Secrets.Match("api_key"))
+	assert.Equal(t, []string{"x-request-id"}, opts.CollectableHTTPHeaders)
+	assert.Equal(t, NewProbabilisticSampler(0.5), opts.Sampler)
+	assert.Equal(t, []SpanFilter{{
+		Name: "health checks",
+		Attributes: []SpanFilterAttribute{
+			{Key: "http.path", Values: []string{"/health"}, MatchType: SpanFilterMatchStrict},
+		},
+	}}, opts.SpanFilters)
+	assert.Equal(t, []string{"kafka", "sqs"}, opts.DisabledInstrumentations)
+
+	lvl, ok := agentLogLevel(resp.LogLevel)
+	require.True(t, ok)
+	assert.Equal(t, logger.DebugLevel, lvl)
+}
+
+func TestAgentTracerOptions_ConfiguredValues(t *testing.T) {
+	var resp agentResponse
+	require.NoError(t, json.Unmarshal([]byte(agentConfigJSON), &resp))
+
+	configured := DefaultTracerOptions()
+	configured.CollectableHTTPHeaders = []string{"x-custom"}
+	configured.Sampler = AlwaysSample()
+	configured.SpanFilters = []SpanFilter{{Name: "configured"}}
+	configured.DisabledInstrumentations = []string{"redis"}
+
+	opts := agentTracerOptions(configured, resp, NewProbabilisticSampler(0.5), defaultLogger)
+
+	assert.Equal(t, []string{"x-custom"}, opts.CollectableHTTPHeaders)
+	assert.True(t, opts.Sampler.ShouldSample(SamplingParameters{TraceID: -1}))
+	require.Len(t, opts.SpanFilters, 2)
+	assert.Equal(t, "configured", opts.SpanFilters[0].Name)
+	assert.Equal(t, "health checks", opts.SpanFilters[1].Name)
+	assert.Equal(t, []string{"redis", "kafka", "sqs"}, opts.DisabledInstrumentations)
+
+	// the configured options are not modified
+	assert.Equal(t, []SpanFilter{{Name: "configured"}}, configured.SpanFilters)
+	assert.Equal(t, []string{"redis"}, configured.DisabledInstrumentations)
+}
+
+func TestAgentTracerOptions_FilterDeactivated(t *testing.T) {
+	var resp agentResponse
+	require.NoError(t, json.Unmarshal([]byte(agentConfigJSON), &resp))
+	resp.Tracing.Filter.Deactivate = true
+
+	opts := agentTracerOptions(DefaultTracerOptions(), resp, NewProbabilisticSampler(0.5), defaultLogger)
+	assert.Empty(t, opts.SpanFilters)
+}
+
+func TestSensor_UpdateTracerOptions(t *testing.T) {
+	var resp agentResponse
+	require.NoError(t, json.Unmarshal([]byte(agentConfigJSON), &resp))
+
+	s := &sensorS{options: DefaultOptions()}
+
+	s.updateTracerOptions(func(configured TracerOptions) TracerOptions {
+		return agentTracerOptions(configured, resp, NewProbabilisticSampler(0.5), defaultLogger)
+	})
+	assert.Equal(t, []string{"kafka", "sqs"}, s.tracerOptions().DisabledInstrumentations)
+
+	// an updated configuration without disabled instrumentations restores the configured value
+	s.updateTracerOptions(func(configured TracerOptions) TracerOptions {
+		return agentTracerOptions(configured, agentResponse{}, nil, defaultLogger)
+	})
+	assert.Empty(t, s.tracerOptions().DisabledInstrumentations)
+	assert.Empty(t, s.tracerOptions().CollectableHTTPHeaders)
+}
+
+func TestFSMS_AgentSampler(t *testing.T) {
+	r := &fsmS{}
+
+	rate := 0.5
+	assert.Equal(t, NewProbabilisticSampler(0.5), r.agentSampler(&rate))
+	assert.Equal(t, 0.5, *r.samplingRate)
+
+	// the sampler is kept as long as the rate remains the same
+	sameRate := 0.5
+	r.sampler = NewProbabilisticSampler(0.1)
+	assert.Equal(t, NewProbabilisticSampler(0.1), r.agentSampler(&sameRate))
+
+	newRate := 0.25
+	assert.Equal(t, NewProbabilisticSampler(0.25), r.agentSampler(&newRate))
+
+	assert.Nil(t, r.agentSampler(nil))
+	assert.Nil(t, r.samplingRate)
+}
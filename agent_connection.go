@@ -0,0 +1,315 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrNoHostAgent is returned when the host agent connection is controlled, while the sensor is either not
+// initialized or sends data to a serverless acceptor or an OTLP endpoint
+var ErrNoHostAgent = errors.New("instana: sensor is not connected to a host agent")
+
+// AgentConnectionState is the state of the connection between the sensor and the host agent
+type AgentConnectionState string
+
+// Host agent connection states
+const (
+	// AgentConnectionNone is the state before the connection has been initialized
+	AgentConnectionNone AgentConnectionState = "none"
+	// AgentConnectionInit is the state when the sensor is looking up the host agent
+	AgentConnectionInit AgentConnectionState = "init"
+	// AgentConnectionUnannounced is the state when the host agent has been found, and the sensor is announcing itself
+	AgentConnectionUnannounced AgentConnectionState = "unannounced"
+	// AgentConnectionAnnounced is the state when the sensor has been announced, and is waiting for the agent to become ready
+	AgentConnectionAnnounced AgentConnectionState = "announced"
+	// AgentConnectionReady is the state when the sensor is sending data to the host agent
+	AgentConnectionReady AgentConnectionState = "ready"
+)
+
+// AgentConnectionStatus describes the current state of the host agent connection
+type AgentConnectionStatus struct {
+	// State is the current connection state
+	State AgentConnectionState
+	// Since is the time of the last state change
+	Since time.Time
+	// LastError is the last error that occurred while connecting to or communicating with the agent
+	LastError error
+	// LastErrorTime is the time when the LastError occurred
+	LastErrorTime time.Time
+}
+
+// AgentConnectionEvent describes a host agent connection state change
+type AgentConnectionEvent struct {
+	// Previous is the state before the change
+	Previous AgentConnectionState
+	// Status is the connection status after the change
+	Status AgentConnectionStatus
+}
+
+// AgentConnection returns the status of the host agent connection. For serverless environments, the state is either
+// instana.AgentConnectionReady or instana.AgentConnectionNone depending on whether the sensor is ready to send data.
+func AgentConnection() AgentConnectionStatus {
+	if sensor == nil {
+		return AgentConnectionStatus{State: AgentConnectionNone}
+	}
+
+	agent, ok := sensor.Agent().(*agentS)
+	if !ok {
+		if sensor.Agent().Ready() {
+			return AgentConnectionStatus{State: AgentConnectionReady}
+		}
+
+		return AgentConnectionStatus{State: AgentConnectionNone}
+	}
+
+	return agent.connection().Status()
+}
+
+// SubscribeAgentConnection registers a function to be called on each host agent connection state change and returns
+// a function to cancel the subscription. The callbacks are called asynchronously in the order of state changes, so it's
+// safe to call back into the sensor, e.g. instana.ReannounceAgent(), from within the callback. A blocking callback
+// delays the delivery of subsequent events to all subscribers. The subscription has no effect if the sensor is not
+// connected to a host agent.
+func SubscribeAgentConnection(fn func(AgentConnectionEvent)) (cancel func()) {
+	if sensor == nil {
+		return func() {}
+	}
+
+	agent, ok := sensor.Agent().(*agentS)
+	if !ok {
+		return func() {}
+	}
+
+	return agent.connection().Subscribe(fn)
+}
+
+// ReannounceAgent makes the sensor start over the host agent connection cycle, looking up the agent host and
+// announcing itself again. It returns instana.ErrNoHostAgent if the sensor is not connected to a host agent.
+func ReannounceAgent() error {
+	if sensor == nil {
+		return ErrNoHostAgent
+	}
+
+	agent, ok := sensor.Agent().(*agentS)
+	if !ok {
+		return ErrNoHostAgent
+	}
+
+	agent.reset(nil)
+
+	return nil
+}
+
+// agentConnectionTracker keeps the status of the host agent connection and notifies the subscribers about its changes
+type agentConnectionTracker struct {
+	mu          sync.Mutex
+	status      AgentConnectionStatus
+	subscribers map[int]func(AgentConnectionEvent)
+	nextID      int
+	// pending contains the notifications to be delivered to the subscribers by the dispatching goroutine
+	pending     []agentConnectionNotification
+	dispatching bool
+}
+
+type agentConnectionNotification struct {
+	Event       AgentConnectionEvent
+	Subscribers []func(AgentConnectionEvent)
+}
+
+func newAgentConnectionTracker() *agentConnectionTracker {
+	return &agentConnectionTracker{
+		status:      AgentConnectionStatus{State: AgentConnectionNone, Since: time.Now()},
+		subscribers: make(map[int]func(AgentConnectionEvent)),
+	}
+}
+
+// Status returns the current connection status
+func (t *agentConnectionTracker) Status() AgentConnectionStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.status
+}
+
+// Subscribe registers fn to be called on each state change
+func (t *agentConnectionTracker) Subscribe(fn func(AgentConnectionEvent)) (cancel func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	id := t.nextID
+	t.nextID++
+	t.subscribers[id] = fn
+
+	return func() {
+		t.mu.Lock()
+		delete(t.subscribers, id)
+		t.mu.Unlock()
+	}
+}
+
+// StateChanged records the new connection state and notifies the subscribers. The subscribers are called from
+// a separate goroutine, since a state change happens within the fsm transition, which can't be started over
+// until the current one is finished.
+func (t *agentConnectionTracker) StateChanged(state string) {
+	t.mu.Lock()
+	ev := AgentConnectionEvent{Previous: t.status.State}
+
+	t.status.State = AgentConnectionState(state)
+	t.status.Since = time.Now()
+	ev.Status = t.status
+
+	if len(t.subscribers) == 0 {
+		t.mu.Unlock()
+		return
+	}
+
+	subscribers := make([]func(AgentConnectionEvent), 0, len(t.subscribers))
+	for _, fn := range t.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+
+	t.pending = append(t.pending, agentConnectionNotification{Event: ev, Subscribers: subscribers})
+	if !t.dispatching {
+		t.dispatching = true
+		go t.dispatch()
+	}
+	t.mu.Unlock()
+}
+
+// dispatch delivers pending notifications to the subscribers until there are none left
+func (t *agentConnectionTracker) dispatch() {
+	for {
+		t.mu.Lock()
+		if len(t.pending) == 0 {
+			t.dispatching = false
+			t.mu.Unlock()
+
+			return
+		}
+
+		n := t.pending[0]
+		t.pending = t.pending[1:]
+		t.mu.Unlock()
+
+		for _, fn := range n.Subscribers {
+			fn(n.Event)
+		}
+	}
+}
+
+// Failed records the last connection error
+func (t *agentConnectionTracker) Failed(err error) {
+	if err == nil {
+		return
+	}
+
+	t.mu.Lock()
+	t.status.LastError = err
+	t.status.LastErrorTime = time.Now()
+	t.mu.Unlock()
+}
+
+// RetryPolicy configures how the sensor retries to connect to the host agent. When used as Options.AgentRetryPolicy,
+// the fields left at their zero value are set to the values of instana.DefaultRetryPolicy().
+type RetryPolicy struct {
+	// MaxRetries is the number of attempts to announce the sensor and to wait for the agent to become ready
+	// before starting over with the agent host lookup. A negative value means unlimited retries, and 0 means
+	// the default number of retries.
+	MaxRetries int
+	// InitialDelay is the base delay between the announce attempts, which is multiplied by Multiplier
+	// after each failed attempt
+	InitialDelay time.Duration
+	// Multiplier is the factor the delay is multiplied by after each failed attempt
+	Multiplier float64
+	// MaxDelay limits the delay between the retries if set to a positive value
+	MaxDelay time.Duration
+	// Jitter is the fraction of the delay, between 0 and 1, that is randomized to avoid many processes
+	// reconnecting to the agent at the same time
+	Jitter float64
+	// LookupDelay is the delay between the agent host lookup attempts
+	LookupDelay time.Duration
+}
+
+// DefaultRetryPolicy returns the default host agent connection retry policy
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries:   maximumRetries,
+		InitialDelay: exponentialRetryPeriodBase,
+		Multiplier:   2,
+		MaxDelay:     5 * time.Minute,
+		LookupDelay:  retryPeriod,
+	}
+}
+
+// withDefaults returns a copy of the policy with zero fields set to the values of the default policy
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	def := DefaultRetryPolicy()
+
+	if p.MaxRetries == 0 {
+		p.MaxRetries = def.MaxRetries
+	}
+
+	if p.InitialDelay == 0 {
+		p.InitialDelay = def.InitialDelay
+	}
+
+	if p.Multiplier == 0 {
+		p.Multiplier = def.Multiplier
+	}
+
+	if p.MaxDelay == 0 {
+		p.MaxDelay = def.MaxDelay
+	}
+
+	if p.Jitter == 0 {
+		p.Jitter = def.Jitter
+	}
+
+	if p.LookupDelay == 0 {
+		p.LookupDelay = def.LookupDelay
+	}
+
+	return p
+}
+
+// Delay returns the delay before the n-th retry
+func (p RetryPolicy) Delay(retryNumber int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	if p.InitialDelay <= 0 {
+		return 0
+	}
+
+	d := float64(p.InitialDelay) * math.Pow(multiplier, float64(retryNumber-1))
+	if p.MaxDelay > 0 && d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+
+	// without the upper limit the delay grows beyond the time.Duration range after enough retries
+	if d >= math.MaxInt64 {
+		return p.jitter(time.Duration(math.MaxInt64))
+	}
+
+	return p.jitter(time.Duration(d))
+}
+
+func (p RetryPolicy) jitter(d time.Duration) time.Duration {
+	if p.Jitter <= 0 {
+		return d
+	}
+
+	j := math.Min(p.Jitter, 1) * rand.Float64() * float64(d)
+	if j >= float64(d) {
+		return 0
+	}
+
+	return d - time.Duration(j)
+}
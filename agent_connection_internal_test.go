@@ -0,0 +1,103 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAgentConnectionTracker(t *testing.T) {
+	tracker := newAgentConnectionTracker()
+	assert.Equal(t, AgentConnectionNone, tracker.Status().State)
+
+	events := make(chan AgentConnectionEvent, 3)
+	cancel := tracker.Subscribe(func(ev AgentConnectionEvent) {
+		events <- ev
+	})
+
+	tracker.StateChanged("init")
+	tracker.Failed(errors.New("something went wrong"))
+	tracker.StateChanged("unannounced")
+
+	cancel()
+	tracker.StateChanged("announced")
+
+	ev := receiveAgentConnectionEvent(t, events)
+	assert.Equal(t, AgentConnectionNone, ev.Previous)
+	assert.Equal(t, AgentConnectionInit, ev.Status.State)
+	assert.NoError(t, ev.Status.LastError)
+
+	ev = receiveAgentConnectionEvent(t, events)
+	assert.Equal(t, AgentConnectionInit, ev.Previous)
+	assert.Equal(t, AgentConnectionUnannounced, ev.Status.State)
+	assert.EqualError(t, ev.Status.LastError, "something went wrong")
+
+	// no events are delivered once the subscription is cancelled
+	select {
+	case ev := <-events:
+		t.Errorf("unexpected event: %+v", ev)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	st := tracker.Status()
+	assert.Equal(t, AgentConnectionAnnounced, st.State)
+	assert.EqualError(t, st.LastError, "something went wrong")
+	assert.False(t, st.LastErrorTime.IsZero())
+}
+
+func TestAgentConnectionTracker_Reentrant(t *testing.T) {
+	tracker := newAgentConnectionTracker()
+
+	// a subscriber changing the state from within the callback does not deadlock
+	done := make(chan struct{})
+	tracker.Subscribe(func(ev AgentConnectionEvent) {
+		switch ev.Status.State {
+		case AgentConnectionReady:
+			tracker.StateChanged("init")
+		case AgentConnectionInit:
+			close(done)
+		}
+	})
+
+	tracker.StateChanged("ready")
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the state change from within the callback has not been delivered")
+	}
+}
+
+func TestRetryPolicy_withDefaults(t *testing.T) {
+	p := RetryPolicy{MaxRetries: -1, Jitter: 0.1}.withDefaults()
+
+	def := DefaultRetryPolicy()
+	assert.Equal(t, RetryPolicy{
+		MaxRetries:   -1,
+		InitialDelay: def.InitialDelay,
+		Multiplier:   def.Multiplier,
+		MaxDelay:     def.MaxDelay,
+		Jitter:       0.1,
+		LookupDelay:  def.LookupDelay,
+	}, p)
+
+	assert.True(t, p.Delay(1) > 0)
+	assert.Equal(t, def, RetryPolicy{}.withDefaults())
+}
+
+func receiveAgentConnectionEvent(t *testing.T, events <-chan AgentConnectionEvent) AgentConnectionEvent {
+	t.Helper()
+
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("agent connection event has not been delivered")
+	}
+
+	return AgentConnectionEvent{}
+}
@@ -0,0 +1,79 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	instana "github.com/instana/go-sensor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	p := instana.RetryPolicy{
+		InitialDelay: time.Second,
+		Multiplier:   2,
+		MaxDelay:     5 * time.Second,
+	}
+
+	assert.Equal(t, time.Second, p.Delay(1))
+	assert.Equal(t, 2*time.Second, p.Delay(2))
+	assert.Equal(t, 4*time.Second, p.Delay(3))
+	assert.Equal(t, 5*time.Second, p.Delay(4))
+	assert.Equal(t, 5*time.Second, p.Delay(100))
+}
+
+func TestRetryPolicy_Delay_Jitter(t *testing.T) {
+	p := instana.RetryPolicy{
+		InitialDelay: 10 * time.Second,
+		Multiplier:   1,
+		Jitter:       0.5,
+	}
+
+	for i := 0; i < 100; i++ {
+		d := p.Delay(i + 1)
+		assert.True(t, d > 5*time.Second && d <= 10*time.Second, "unexpected delay %s", d)
+	}
+}
+
+func TestRetryPolicy_Delay_NoMaxDelay(t *testing.T) {
+	p := instana.RetryPolicy{
+		InitialDelay: time.Second,
+		Multiplier:   2,
+		MaxDelay:     -1,
+	}
+
+	// the delay is capped by the time.Duration range
+	assert.Equal(t, time.Duration(math.MaxInt64), p.Delay(10000))
+
+	p.Jitter = 1
+	for i := 0; i < 100; i++ {
+		assert.True(t, p.Delay(10000) >= 0, "negative delay")
+	}
+}
+
+func TestDefaultRetryPolicy(t *testing.T) {
+	p := instana.DefaultRetryPolicy()
+
+	assert.Equal(t, 3, p.MaxRetries)
+	assert.Equal(t, 20*time.Second, p.Delay(2))
+	assert.Equal(t, 40*time.Second, p.Delay(3))
+}
+
+func TestAgentConnection_Serverless(t *testing.T) {
+	instana.InitSensor(&instana.Options{AgentClient: alwaysReadyClient{}})
+	defer instana.ShutdownSensor()
+
+	assert.Equal(t, instana.AgentConnectionReady, instana.AgentConnection().State)
+	assert.Equal(t, instana.ErrNoHostAgent, instana.ReannounceAgent())
+
+	cancel := instana.SubscribeAgentConnection(func(instana.AgentConnectionEvent) {})
+	cancel()
+}
+
+func TestAgentConnection_NotInitialized(t *testing.T) {
+	assert.Equal(t, instana.AgentConnectionNone, instana.AgentConnection().State)
+	assert.Equal(t, instana.ErrNoHostAgent, instana.ReannounceAgent())
+}
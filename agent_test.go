@@ -4,17 +4,23 @@ package instana
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
 	"github.com/instana/go-sensor/acceptor"
 	"github.com/instana/go-sensor/autoprofile"
+	f "github.com/looplab/fsm"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_agentS_SendSpans(t *testing.T) {
@@ -78,6 +84,293 @@ func Test_agentS_SendSpans(t *testing.T) {
 	}
 }
 
+func Test_agentS_SendSpans_Batches(t *testing.T) {
+	client := &recordingHTTPClient{}
+	metrics := &collectorMetrics{}
+	agent := &agentS{
+		agentComm: &agentCommunicator{host: "", from: &fromS{}, client: client, metrics: metrics, l: defaultLogger},
+		logger:    defaultLogger,
+	}
+
+	spans := []Span{
+		{Data: HTTPSpanData{Tags: HTTPSpanTags{URL: strings.Repeat("1", maxContentLength/3)}}},
+		{Data: HTTPSpanData{Tags: HTTPSpanTags{URL: strings.Repeat("2", maxContentLength/3)}}},
+		{Data: HTTPSpanData{Tags: HTTPSpanTags{URL: strings.Repeat("3", maxContentLength)}}},
+		{Data: HTTPSpanData{Tags: HTTPSpanTags{URL: strings.Repeat("4", maxContentLength/3)}}},
+		{Data: HTTPSpanData{Tags: HTTPSpanTags{URL: strings.Repeat("5", maxContentLength/3)}}},
+	}
+
+	assert.NoError(t, agent.SendSpans(spans))
+	assert.Equal(t, uint64(1), metrics.Stats().OversizedSpans)
+
+	assert.Len(t, client.Bodies, 2)
+
+	var urls []string
+	for _, body := range client.Bodies {
+		assert.LessOrEqual(t, len(body), maxContentLength)
+
+		var sent []struct {
+			Data struct {
+				HTTP struct {
+					URL string `json:"url"`
+				} `json:"http"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(body, &sent))
+
+		for _, sp := range sent {
+			urls = append(urls, sp.Data.HTTP.URL[:1])
+		}
+	}
+
+	assert.Equal(t, []string{"1", "2", "4", "5"}, urls)
+}
+
+func Test_agentCommunicator_sendDataToAgent_Gzip(t *testing.T) {
+	client := &recordingHTTPClient{}
+	ad := &agentCommunicator{host: "", from: &fromS{}, client: client, gzip: true, l: defaultLogger}
+
+	assert.NoError(t, ad.sendDataToAgent(agentTracesURL, map[string]string{"key": "value"}))
+
+	assert.Equal(t, []string{"gzip"}, client.Encodings)
+	assert.Len(t, client.Bodies, 1)
+	assert.JSONEq(t, `{"key":"value"}`, string(client.Bodies[0]))
+}
+
+func Test_agentCommunicator_sendDataToAgent_SpanBatch(t *testing.T) {
+	client := &recordingHTTPClient{}
+	ad := &agentCommunicator{host: "", from: &fromS{}, client: client, gzip: true, l: defaultLogger}
+
+	batch := []json.RawMessage{json.RawMessage(`{"n":"span-1"}`), json.RawMessage(`{"n":"span-2"}`)}
+	assert.NoError(t, ad.sendDataToAgent(agentTracesURL, batch))
+
+	require.Len(t, client.Bodies, 1)
+	assert.Equal(t, `[{"n":"span-1"},{"n":"span-2"}]`, string(client.Bodies[0]))
+
+	// the body can be re-sent if the request is retried over a new connection
+	assert.Equal(t, []bool{true}, client.Replayable)
+}
+
+func Test_agentCommunicator_sendDataToAgent_PayloadTooLarge(t *testing.T) {
+	client := &recordingHTTPClient{}
+	ad := &agentCommunicator{host: "", from: &fromS{}, client: client, l: defaultLogger}
+
+	err := ad.sendDataToAgent(agentTracesURL, strings.Repeat("1", maxContentLength))
+	assert.Equal(t, payloadTooLargeErr, err)
+}
+
+func Test_agentCommunicator_configureTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var dialed []string
+
+	ad := newAgentCommunicator("agent.invalid", "42699", &fromS{}, defaultLogger)
+	ad.configureTransport(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+
+		var d net.Dialer
+		return d.DialContext(ctx, "tcp", server.Listener.Addr().String())
+	}, AgentTLSOptions{})
+
+	assert.True(t, ad.checkForSuccessResponse())
+	assert.NoError(t, ad.sendDataToAgent(agentTracesURL, []Span{}))
+
+	assert.NotEmpty(t, dialed)
+	for _, addr := range dialed {
+		assert.Equal(t, "agent.invalid:42699", addr)
+	}
+}
+
+func Test_agentS_SendSpans_PartialFailure(t *testing.T) {
+	agent, _ := newTestReadyAgent(&statusHTTPClient{
+		codes: []int{http.StatusOK, http.StatusInternalServerError},
+	})
+
+	spans := []Span{
+		{Data: HTTPSpanData{Tags: HTTPSpanTags{URL: strings.Repeat("1", maxContentLength/2)}}},
+		{Data: HTTPSpanData{Tags: HTTPSpanTags{URL: strings.Repeat("2", maxContentLength/2)}}},
+		{Data: HTTPSpanData{Tags: HTTPSpanTags{URL: strings.Repeat("3", maxContentLength/2)}}},
+	}
+
+	err := agent.SendSpans(spans)
+	require.Error(t, err)
+
+	// the first batch has been delivered, so only the remaining spans are returned
+	require.IsType(t, &unsentSpansError{}, err)
+	assert.Equal(t, 1, err.(*unsentSpansError).Sent)
+
+	unsent := err.(*unsentSpansError).Unsent
+	require.Len(t, unsent, 2)
+	assert.Equal(t, []int{1, 2}, err.(*unsentSpansError).UnsentIndices)
+
+	var sp struct {
+		Data struct {
+			HTTP struct {
+				URL string `json:"url"`
+			} `json:"http"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(unsent[0].raw, &sp))
+	assert.Equal(t, strings.Repeat("2", maxContentLength/2), sp.Data.HTTP.URL)
+}
+
+func Test_agentS_SendSpans_PartialFailure_Oversized(t *testing.T) {
+	agent, _ := newTestReadyAgent(&statusHTTPClient{
+		codes: []int{http.StatusOK, http.StatusInternalServerError},
+	})
+
+	spans := []Span{
+		{Data: HTTPSpanData{Tags: HTTPSpanTags{URL: strings.Repeat("1", maxContentLength/2)}}},
+		{Data: HTTPSpanData{Tags: HTTPSpanTags{URL: strings.Repeat("2", maxContentLength/2)}}},
+		{Data: HTTPSpanData{Tags: HTTPSpanTags{URL: strings.Repeat("x", maxContentLength)}}},
+		{Data: HTTPSpanData{Tags: HTTPSpanTags{URL: strings.Repeat("4", maxContentLength/2)}}},
+	}
+
+	err := agent.SendSpans(spans)
+	require.Error(t, err)
+
+	// the oversized span is dropped, so the unsent spans are not the last ones
+	require.IsType(t, &unsentSpansError{}, err)
+	assert.Equal(t, 1, err.(*unsentSpansError).Sent)
+	assert.Len(t, err.(*unsentSpansError).Unsent, 2)
+	assert.Equal(t, []int{1, 3}, err.(*unsentSpansError).UnsentIndices)
+}
+
+func Test_agentS_SendSpans_AgentNotFound(t *testing.T) {
+	agent, resets := newTestReadyAgent(&statusHTTPClient{codes: []int{http.StatusNotFound}})
+
+	assert.Error(t, agent.SendSpans([]Span{{}}))
+
+	assert.Equal(t, 1, len(resets))
+	assert.False(t, agent.Ready())
+	assert.True(t, agent.reconnecting())
+	assert.True(t, isAgentNotFoundErr(agent.connection().Status().LastError))
+}
+
+func Test_agentS_SendSpans_ConsecutiveFailures(t *testing.T) {
+	client := &statusHTTPClient{
+		codes: []int{
+			http.StatusInternalServerError,
+			http.StatusInternalServerError,
+			http.StatusOK,
+			http.StatusInternalServerError,
+			http.StatusInternalServerError,
+			http.StatusInternalServerError,
+		},
+	}
+	agent, resets := newTestReadyAgent(client)
+
+	for i := 0; i < 5; i++ {
+		agent.SendSpans([]Span{{}})
+		assert.Empty(t, resets, "unexpected reset after request #%d", i+1)
+	}
+
+	assert.Error(t, agent.SendSpans([]Span{{}}))
+	assert.Equal(t, 1, len(resets))
+	assert.False(t, agent.Ready())
+}
+
+func Test_agentS_SendMetrics_AgentNotFound(t *testing.T) {
+	agent, resets := newTestReadyAgent(&statusHTTPClient{codes: []int{http.StatusNotFound}})
+	agent.snapshot = &SnapshotCollector{}
+
+	assert.Error(t, agent.SendMetrics(acceptor.Metrics{}))
+	assert.Equal(t, 1, len(resets))
+}
+
+// newTestReadyAgent returns a host agent client in ready state that sends requests using the provided HTTP client.
+// The returned channel receives a value whenever the agent connection cycle is restarted.
+func newTestReadyAgent(client httpClient) (*agentS, chan struct{}) {
+	resets := make(chan struct{}, 10)
+
+	agent := &agentS{
+		agentComm: &agentCommunicator{host: "", from: &fromS{}, client: client, l: defaultLogger},
+		logger:    defaultLogger,
+		fsm: &fsmS{
+			fsm: f.NewFSM(
+				"ready",
+				f.Events{
+					{Name: eInit, Src: []string{"ready"}, Dst: "init"}},
+				f.Callbacks{
+					"init": func(_ context.Context, event *f.Event) {
+						resets <- struct{}{}
+					},
+				}),
+			maxRetries:  maximumRetries,
+			retriesLeft: maximumRetries,
+			wasReady:    1,
+			logger:      defaultLogger,
+			conn:        newAgentConnectionTracker(),
+		},
+	}
+
+	return agent, resets
+}
+
+// statusHTTPClient responds with status codes from the list, repeating the last one once the list is exhausted
+type statusHTTPClient struct {
+	codes []int
+	calls int
+}
+
+func (c *statusHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		io.Copy(ioutil.Discard, req.Body)
+	}
+
+	code := c.codes[len(c.codes)-1]
+	if c.calls < len(c.codes) {
+		code = c.codes[c.calls]
+	}
+	c.calls++
+
+	return &http.Response{
+		StatusCode: code,
+		Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+	}, nil
+}
+
+// recordingHTTPClient reads and decompresses request bodies, responding with 200 OK
+type recordingHTTPClient struct {
+	Bodies    [][]byte
+	Encodings []string
+	// Replayable contains whether the request body can be obtained again to retry the request
+	Replayable []bool
+}
+
+func (c *recordingHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	c.Replayable = append(c.Replayable, req.GetBody != nil)
+
+	var body io.Reader = req.Body
+
+	encoding := req.Header.Get("Content-Encoding")
+	c.Encodings = append(c.Encodings, encoding)
+
+	if encoding == "gzip" {
+		gz, err := gzip.NewReader(req.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		body = gz
+	}
+
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Bodies = append(c.Bodies, data)
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+	}, nil
+}
+
 type httpClientMock struct {
 	resp *http.Response
 	err  error
@@ -126,12 +419,8 @@ func Test_agentApplyHostSettings(t *testing.T) {
 	response := agentResponse{
 		Pid:    37892,
 		HostID: "myhost",
-		Tracing: struct {
-			ExtraHTTPHeaders []string `json:"extra-http-headers"`
-		}{
-			ExtraHTTPHeaders: []string{"my-unwanted-custom-headers"},
-		},
 	}
+	response.Tracing.ExtraHTTPHeaders = []string{"my-unwanted-custom-headers"}
 
 	opts := &Options{
 		Service: "test_service",
@@ -141,14 +430,10 @@ func Test_agentApplyHostSettings(t *testing.T) {
 		AgentClient: alwaysReadyClient{},
 	}
 
-	sensor = newSensor(opts)
-	defer func() {
-		sensor = nil
-	}()
-
+	fsm.sensor = newSensor(opts)
 	fsm.applyHostAgentSettings(response)
 
-	assert.NotContains(t, sensor.options.Tracer.CollectableHTTPHeaders, "my-unwanted-custom-headers")
+	assert.NotContains(t, fsm.sensor.options.Tracer.CollectableHTTPHeaders, "my-unwanted-custom-headers")
 }
 
 type alwaysReadyClient struct{}
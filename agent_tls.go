@@ -0,0 +1,184 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// agentTLSCheckPeriod is the minimal interval between the checks for updated TLS certificate files
+const agentTLSCheckPeriod = 10 * time.Second
+
+// AgentTLSOptions configures the TLS connection to the host agent
+type AgentTLSOptions struct {
+	// Enabled makes the sensor communicate with the host agent over HTTPS. TLS is also enabled if any of the
+	// certificate files is provided. This option can also be set via INSTANA_AGENT_TLS env variable.
+	Enabled bool
+	// CAFile is the path to a PEM-encoded CA bundle used to verify the agent certificate. If not set, the system
+	// certificate pool is used. This option can also be set via INSTANA_AGENT_TLS_CA_FILE env variable.
+	CAFile string
+	// CertFile is the path to a PEM-encoded client certificate presented to the agent for mutual TLS. This option
+	// can also be set via INSTANA_AGENT_TLS_CERT_FILE env variable.
+	CertFile string
+	// KeyFile is the path to a PEM-encoded private key of the client certificate. This option can also be set via
+	// INSTANA_AGENT_TLS_KEY_FILE env variable.
+	KeyFile string
+	// ServerName is used to verify the agent certificate instead of the agent host name. This option can also be
+	// set via INSTANA_AGENT_TLS_SERVER_NAME env variable.
+	ServerName string
+}
+
+func (opts AgentTLSOptions) enabled() bool {
+	return opts.Enabled || opts.CAFile != "" || opts.CertFile != "" || opts.KeyFile != ""
+}
+
+// tlsConfig reads the certificate files and returns the TLS configuration for agent connections
+func (opts AgentTLSOptions) tlsConfig() (*tls.Config, error) {
+	conf := &tls.Config{
+		ServerName: opts.ServerName,
+	}
+
+	if opts.CAFile != "" {
+		data, err := ioutil.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %s", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in %s", opts.CAFile)
+		}
+
+		conf.RootCAs = pool
+	}
+
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		if opts.CertFile == "" || opts.KeyFile == "" {
+			return nil, errors.New("both client certificate and key files are required for mutual TLS")
+		}
+
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %s", err)
+		}
+
+		conf.Certificates = []tls.Certificate{cert}
+	}
+
+	return conf, nil
+}
+
+// modTimes returns the modification times of the certificate files
+func (opts AgentTLSOptions) modTimes() []time.Time {
+	var res []time.Time
+
+	for _, fName := range []string{opts.CAFile, opts.CertFile, opts.KeyFile} {
+		var mt time.Time
+		if fName != "" {
+			if fi, err := os.Stat(fName); err == nil {
+				mt = fi.ModTime()
+			}
+		}
+
+		res = append(res, mt)
+	}
+
+	return res
+}
+
+// agentTLSTransport is an http.RoundTripper that sends requests to the agent over TLS. The underlying
+// transport is re-created with updated certificates once any of the certificate files changes.
+type agentTLSTransport struct {
+	opts        AgentTLSOptions
+	dial        dialContextFunc
+	checkPeriod time.Duration
+	logger      LeveledLogger
+
+	mu        sync.Mutex
+	tr        *http.Transport
+	modTimes  []time.Time
+	checkedAt time.Time
+}
+
+func newAgentTLSTransport(opts AgentTLSOptions, dial dialContextFunc, logger LeveledLogger) *agentTLSTransport {
+	return &agentTLSTransport{
+		opts:        opts,
+		dial:        dial,
+		checkPeriod: agentTLSCheckPeriod,
+		logger:      logger,
+	}
+}
+
+// RoundTrip implements http.RoundTripper for agentTLSTransport
+func (t *agentTLSTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tr, err := t.transport()
+	if err != nil {
+		return nil, err
+	}
+
+	return tr.RoundTrip(req)
+}
+
+func (t *agentTLSTransport) transport() (*http.Transport, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.tr != nil && time.Since(t.checkedAt) < t.checkPeriod {
+		return t.tr, nil
+	}
+	t.checkedAt = time.Now()
+
+	modTimes := t.opts.modTimes()
+	if t.tr != nil && equalTimes(t.modTimes, modTimes) {
+		return t.tr, nil
+	}
+
+	conf, err := t.opts.tlsConfig()
+	if err != nil {
+		if t.tr != nil {
+			// keep using the previous certificates until the files are fixed
+			t.logger.Warn("failed to reload agent TLS certificates: ", err)
+			return t.tr, nil
+		}
+
+		return nil, fmt.Errorf("failed to configure TLS connection to the agent: %s", err)
+	}
+
+	if t.tr != nil {
+		t.logger.Info("agent TLS certificates have been updated")
+		t.tr.CloseIdleConnections()
+	}
+
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.Proxy = nil
+	tr.TLSClientConfig = conf
+	if t.dial != nil {
+		tr.DialContext = t.dial
+	}
+
+	t.tr, t.modTimes = tr, modTimes
+
+	return t.tr, nil
+}
+
+func equalTimes(a, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
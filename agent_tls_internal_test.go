@@ -0,0 +1,182 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentCommunicator_TLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "instana-tls")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	caFile := filepath.Join(dir, "ca.pem")
+	writePEM(t, caFile, "CERTIFICATE", server.Certificate().Raw)
+
+	t.Run("trusted CA", func(t *testing.T) {
+		ad := newTestTLSAgentCommunicator(t, server.URL, AgentTLSOptions{CAFile: caFile})
+
+		assert.Equal(t, "https://", ad.buildURL("/")[:8])
+		assert.True(t, ad.checkForSuccessResponse())
+		assert.NoError(t, ad.sendDataToAgent(agentTracesURL, []Span{}))
+	})
+
+	t.Run("unknown CA", func(t *testing.T) {
+		ad := newTestTLSAgentCommunicator(t, server.URL, AgentTLSOptions{Enabled: true})
+
+		assert.False(t, ad.checkForSuccessResponse())
+	})
+
+	t.Run("missing CA file", func(t *testing.T) {
+		ad := newTestTLSAgentCommunicator(t, server.URL, AgentTLSOptions{CAFile: filepath.Join(dir, "missing.pem")})
+
+		assert.Error(t, ad.sendDataToAgent(agentTracesURL, []Span{}))
+	})
+}
+
+func TestAgentCommunicator_MutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "instana-tls")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	clientCert := writeClientCertificate(t, certFile, keyFile)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(dir, "ca.pem")
+	writePEM(t, caFile, "CERTIFICATE", server.Certificate().Raw)
+
+	t.Run("with client certificate", func(t *testing.T) {
+		ad := newTestTLSAgentCommunicator(t, server.URL, AgentTLSOptions{
+			CAFile:   caFile,
+			CertFile: certFile,
+			KeyFile:  keyFile,
+		})
+
+		assert.True(t, ad.checkForSuccessResponse())
+	})
+
+	t.Run("without client certificate", func(t *testing.T) {
+		ad := newTestTLSAgentCommunicator(t, server.URL, AgentTLSOptions{CAFile: caFile})
+
+		assert.False(t, ad.checkForSuccessResponse())
+	})
+
+	t.Run("key file missing", func(t *testing.T) {
+		ad := newTestTLSAgentCommunicator(t, server.URL, AgentTLSOptions{
+			CAFile:   caFile,
+			CertFile: certFile,
+		})
+
+		assert.Error(t, ad.sendDataToAgent(agentTracesURL, []Span{}))
+	})
+}
+
+func TestAgentCommunicator_TLS_Reload(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "instana-tls")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// start with a CA bundle that does not contain the server certificate
+	caFile := filepath.Join(dir, "ca.pem")
+	writeClientCertificate(t, caFile, filepath.Join(dir, "key.pem"))
+
+	ad := newTestTLSAgentCommunicator(t, server.URL, AgentTLSOptions{CAFile: caFile})
+	ad.client.(*http.Client).Transport.(*agentTLSTransport).checkPeriod = 0
+
+	assert.False(t, ad.checkForSuccessResponse())
+
+	writePEM(t, caFile, "CERTIFICATE", server.Certificate().Raw)
+
+	mt := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(caFile, mt, mt))
+
+	assert.True(t, ad.checkForSuccessResponse())
+}
+
+func newTestTLSAgentCommunicator(t *testing.T, serverURL string, opts AgentTLSOptions) *agentCommunicator {
+	u, err := url.Parse(serverURL)
+	require.NoError(t, err)
+
+	ad := newAgentCommunicator(u.Hostname(), u.Port(), &fromS{}, defaultLogger)
+	ad.configureTransport(nil, opts)
+
+	return ad
+}
+
+// writeClientCertificate generates a self-signed client certificate and writes it along with its key
+// into PEM files
+func writeClientCertificate(t *testing.T, certFile, keyFile string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "instana-go-sensor"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert
+}
+
+func writePEM(t *testing.T, fName, blockType string, data []byte) {
+	require.NoError(t, ioutil.WriteFile(fName, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data}), 0600))
+}
@@ -4,7 +4,10 @@
 package autoprofile
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"time"
 
 	"github.com/instana/go-sensor/autoprofile/internal"
 	"github.com/instana/go-sensor/autoprofile/internal/logger"
@@ -18,6 +21,16 @@ import (
 // due to the way we activate profiling, this would introduce a circular dependency.
 type Profile internal.AgentProfile
 
+// Profile types supported by autoprofile.Capture()
+const (
+	// CPUProfile is the CPU usage profile
+	CPUProfile = "cpu"
+	// HeapProfile is the heap allocations profile
+	HeapProfile = "heap"
+	// BlockProfile is the blocking calls profile
+	BlockProfile = "block"
+)
+
 // SendProfilesFunc is a function that submits profiles to the host agent
 type SendProfilesFunc func(profiles []Profile) error
 
@@ -124,6 +137,31 @@ func SetSendProfilesFunc(fn SendProfilesFunc) {
 	}
 }
 
+// Capture collects a one-off profile of the given type, regardless of whether the auto profiling is enabled. The CPU and
+// block profiles are sampled for the provided duration, while the heap profile is taken immediately. An error is
+// returned if another profile is being collected at the same time.
+func Capture(ctx context.Context, profileType string, duration time.Duration) (Profile, error) {
+	var samp internal.Sampler
+
+	switch profileType {
+	case CPUProfile:
+		samp = internal.NewCPUSampler()
+	case HeapProfile:
+		samp, duration = internal.NewAllocationSampler(), 0
+	case BlockProfile:
+		samp = internal.NewBlockSampler()
+	default:
+		return Profile{}, fmt.Errorf("unsupported profile type %q", profileType)
+	}
+
+	p, err := internal.Capture(ctx, samp, duration)
+	if err != nil {
+		return Profile{}, err
+	}
+
+	return Profile(internal.NewAgentProfile(p)), nil
+}
+
 // Options contains profiler configuration
 type Options struct {
 	IncludeProfilerFrames bool
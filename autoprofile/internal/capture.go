@@ -0,0 +1,52 @@
+// (c) Copyright IBM Corp. 2023
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrSamplerActive is returned when a profile is requested while another sampler is running
+var ErrSamplerActive = errors.New("another profile is being collected at the moment")
+
+// Capture runs the sampler for the given duration and returns the collected profile. The capture is
+// aborted if the context is cancelled before the duration has passed.
+func Capture(ctx context.Context, samp Sampler, duration time.Duration) (*Profile, error) {
+	if !samplerActive.SetIfUnset() {
+		return nil, ErrSamplerActive
+	}
+	defer samplerActive.Unset()
+
+	samp.Reset()
+
+	start := time.Now()
+	if err := samp.Start(); err != nil {
+		return nil, err
+	}
+
+	var ctxErr error
+	if duration > 0 {
+		timer := time.NewTimer(duration)
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			ctxErr = ctx.Err()
+		}
+	}
+
+	if err := samp.Stop(); err != nil {
+		return nil, err
+	}
+
+	if ctxErr != nil {
+		return nil, ctxErr
+	}
+
+	elapsed := time.Since(start)
+
+	return samp.Profile(elapsed.Nanoseconds(), int64(elapsed/time.Second))
+}
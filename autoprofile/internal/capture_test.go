@@ -0,0 +1,53 @@
+// (c) Copyright IBM Corp. 2023
+
+package internal_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/instana/go-sensor/autoprofile/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapture(t *testing.T) {
+	internal.IncludeProfilerFrames = true
+
+	done := make(chan struct{})
+	go func() {
+		simulateCPULoad(500 * time.Millisecond)
+		close(done)
+	}()
+
+	profile, err := internal.Capture(context.Background(), internal.NewCPUSampler(), 500*time.Millisecond)
+	require.NoError(t, err)
+	<-done
+
+	assert.Equal(t, internal.CategoryCPU, profile.Category)
+	assert.Contains(t, fmt.Sprintf("%v", internal.NewAgentProfile(profile)), "simulateCPULoad")
+}
+
+func TestCapture_SamplerActive(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	started := make(chan struct{})
+	captured := make(chan error)
+	go func() {
+		close(started)
+		_, err := internal.Capture(ctx, internal.NewBlockSampler(), time.Minute)
+		captured <- err
+	}()
+	<-started
+
+	// wait for the first capture to start
+	assert.Eventually(t, func() bool {
+		_, err := internal.Capture(context.Background(), internal.NewAllocationSampler(), 0)
+		return err == internal.ErrSamplerActive
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-captured)
+}
@@ -3,16 +3,11 @@
 package instana
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"net/http"
 	"os"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/instana/go-sensor/acceptor"
@@ -26,17 +21,12 @@ const (
 )
 
 type azureAgent struct {
-	Endpoint string
-	Key      string
-	PID      int
+	PID int
 
 	snapshot serverlessSnapshot
 
-	mu        sync.Mutex
-	spanQueue []Span
-
-	client *http.Client
-	logger LeveledLogger
+	transport *serverlessTransport
+	logger    LeveledLogger
 }
 
 func newAzureAgent(acceptorEndpoint, agentKey string, client *http.Client, logger LeveledLogger) *azureAgent {
@@ -44,19 +34,12 @@ func newAzureAgent(acceptorEndpoint, agentKey string, client *http.Client, logge
 		logger = defaultLogger
 	}
 
-	if client == nil {
-		client = http.DefaultClient
-		client.Timeout = 500 * time.Millisecond
-	}
-
 	logger.Debug("initializing azure agent")
 
 	agent := &azureAgent{
-		Endpoint: acceptorEndpoint,
-		Key:      agentKey,
-		PID:      os.Getpid(),
-		client:   client,
-		logger:   logger,
+		PID:       os.Getpid(),
+		transport: newServerlessTransport(acceptorEndpoint, agentKey, client, logger),
+		logger:    logger,
 	}
 
 	go func() {
@@ -80,7 +63,7 @@ func (a *azureAgent) SendMetrics(acceptor.Metrics) error { return nil }
 func (a *azureAgent) SendEvent(*EventData) error { return nil }
 
 func (a *azureAgent) SendSpans(spans []Span) error {
-	a.enqueueSpans(spans)
+	a.transport.Enqueue(spans)
 	return nil
 }
 
@@ -93,50 +76,14 @@ func (a *azureAgent) Flush(ctx context.Context) error {
 		return ErrAgentNotReady
 	}
 
-	from := newServerlessAgentFromS(a.snapshot.EntityID, "azure")
-
-	payload := struct {
-		Metrics metricsPayload `json:"metrics,omitempty"`
-		Spans   []Span         `json:"spans,omitempty"`
-	}{
-		Metrics: metricsPayload{
-			Plugins: []acceptor.PluginPayload{
-				acceptor.NewAzurePluginPayload(a.snapshot.EntityID),
-			},
+	if err := a.transport.SendBundle(
+		ctx,
+		a.snapshot.Host,
+		newServerlessAgentFromS(a.snapshot.EntityID, "azure"),
+		[]acceptor.PluginPayload{
+			acceptor.NewAzurePluginPayload(a.snapshot.EntityID),
 		},
-	}
-
-	a.mu.Lock()
-	payload.Spans = make([]Span, len(a.spanQueue))
-	copy(payload.Spans, a.spanQueue)
-	a.spanQueue = a.spanQueue[:0]
-	a.mu.Unlock()
-
-	for i := range payload.Spans {
-		payload.Spans[i].From = from
-	}
-
-	buf := bytes.NewBuffer(nil)
-	if err := json.NewEncoder(buf).Encode(payload); err != nil {
-		return fmt.Errorf("failed to marshal traces payload: %s", err)
-	}
-
-	payloadSize := buf.Len()
-	if payloadSize > maxContentLength {
-		a.logger.Warn(fmt.Sprintf("failed to send the spans. Payload size: %d exceeded max size: %d", payloadSize, maxContentLength))
-		return payloadTooLargeErr
-	}
-
-	req, err := http.NewRequest(http.MethodPost, a.Endpoint+"/bundle", buf)
-	if err != nil {
-		a.enqueueSpans(payload.Spans)
-		return fmt.Errorf("failed to prepare send traces request: %s", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	if err := a.sendRequest(req.WithContext(ctx)); err != nil {
-		a.enqueueSpans(payload.Spans)
+	); err != nil {
 		return fmt.Errorf("failed to send traces, will retry later: %dsec. Error details: %s",
 			flushPeriodInSec, err.Error())
 	}
@@ -144,40 +91,6 @@ func (a *azureAgent) Flush(ctx context.Context) error {
 	return nil
 }
 
-func (a *azureAgent) enqueueSpans(spans []Span) {
-	a.mu.Lock()
-	defer a.mu.Unlock()
-
-	a.spanQueue = append(a.spanQueue, spans...)
-}
-
-func (a *azureAgent) sendRequest(req *http.Request) error {
-	req.Header.Set("X-Instana-Host", a.snapshot.Host)
-	req.Header.Set("X-Instana-Key", a.Key)
-
-	resp, err := a.client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send request to the serverless agent: %s", err)
-	}
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= http.StatusBadRequest {
-		respBody, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			a.logger.Debug("failed to read serverless agent response: ", err.Error())
-			return err
-		}
-
-		a.logger.Info("serverless agent has responded with ", resp.Status, ": ", string(respBody))
-		return err
-	}
-
-	io.CopyN(ioutil.Discard, resp.Body, 1<<20)
-
-	return nil
-}
-
 func (a *azureAgent) collectSnapshot() {
 	if a.snapshot.EntityID != "" {
 		return
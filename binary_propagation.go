@@ -0,0 +1,252 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/instana/go-sensor/w3ctrace"
+	ot "github.com/opentracing/opentracing-go"
+)
+
+// The binary trace context encoding used to propagate the span context with ot.Binary format.
+// All integers are encoded in big-endian byte order, strings are prefixed with their length
+// encoded as an unsigned varint:
+//
+//	version     uint8  // binaryFormatVersion
+//	flags       uint8  // a combination of binaryFlag* values
+//	trace ID    uint64 // the higher 8 bytes of a 128-bit trace ID
+//	trace ID    uint64 // the lower 8 bytes of a trace ID
+//	span ID     uint64
+//	traceparent string // W3C traceparent header value, may be empty
+//	tracestate  string // W3C tracestate header value, may be empty
+//	baggage     uvarint // the number of baggage items followed by their key and value strings
+const binaryFormatVersion uint8 = 1
+
+// binary trace context flags
+const (
+	binaryFlagSuppressed uint8 = 1 << iota
+	binaryFlagSampled
+)
+
+const (
+	// maxBinaryStringLen is the maximum length of a string value in binary trace context
+	maxBinaryStringLen = 1 << 16
+	// maxBinaryBaggageItems is the maximum number of baggage items in binary trace context
+	maxBinaryBaggageItems = 1 << 10
+)
+
+// byteReader is a reader used to decode the binary trace context
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// singleByteReader implements io.ByteReader for a reader without buffering, so that the data
+// following the trace context remains in the carrier
+type singleByteReader struct {
+	io.Reader
+}
+
+func (r singleByteReader) ReadByte() (byte, error) {
+	var b [1]byte
+	if _, err := io.ReadFull(r.Reader, b[:]); err != nil {
+		return 0, err
+	}
+
+	return b[0], nil
+}
+
+var errBinaryContextTooLarge = errors.New("binary trace context value exceeds the size limit")
+
+// injectBinaryTraceContext writes the binary encoding of the span context to an io.Writer carrier. It returns
+// an error if the span context contains values that would be rejected by extractBinaryTraceContext()
+func injectBinaryTraceContext(sc SpanContext, opaqueCarrier interface{}) error {
+	w, ok := opaqueCarrier.(io.Writer)
+	if !ok {
+		return ot.ErrInvalidCarrier
+	}
+
+	if !binaryTraceContextFits(sc) {
+		return errBinaryContextTooLarge
+	}
+
+	_, err := w.Write(marshalBinaryTraceContext(sc))
+
+	return err
+}
+
+// extractBinaryTraceContext reads the span context from an io.Reader carrier
+func extractBinaryTraceContext(opaqueCarrier interface{}) (SpanContext, error) {
+	r, ok := opaqueCarrier.(io.Reader)
+	if !ok {
+		return SpanContext{}, ot.ErrInvalidCarrier
+	}
+
+	br, ok := r.(byteReader)
+	if !ok {
+		br = singleByteReader{r}
+	}
+
+	sc, err := unmarshalBinaryTraceContext(br)
+	if err != nil {
+		return SpanContext{}, err
+	}
+
+	if sc.IsZero() && len(sc.Baggage) == 0 {
+		return SpanContext{}, ot.ErrSpanContextNotFound
+	}
+
+	return sc, nil
+}
+
+// binaryTraceContextFits checks whether the span context values are within the binary encoding size limits
+func binaryTraceContextFits(sc SpanContext) bool {
+	if len(sc.W3CContext.RawParent) > maxBinaryStringLen || len(sc.W3CContext.RawState) > maxBinaryStringLen {
+		return false
+	}
+
+	if len(sc.Baggage) > maxBinaryBaggageItems {
+		return false
+	}
+
+	for k, v := range sc.Baggage {
+		if len(k) > maxBinaryStringLen || len(v) > maxBinaryStringLen {
+			return false
+		}
+	}
+
+	return true
+}
+
+func marshalBinaryTraceContext(sc SpanContext) []byte {
+	var flags uint8
+	if sc.Suppressed {
+		flags |= binaryFlagSuppressed
+	}
+
+	if sc.Sampled {
+		flags |= binaryFlagSampled
+	}
+
+	buf := make([]byte, 2+3*8, 64)
+	buf[0], buf[1] = binaryFormatVersion, flags
+	binary.BigEndian.PutUint64(buf[2:], uint64(sc.TraceIDHi))
+	binary.BigEndian.PutUint64(buf[10:], uint64(sc.TraceID))
+	binary.BigEndian.PutUint64(buf[18:], uint64(sc.SpanID))
+
+	buf = appendBinaryString(buf, sc.W3CContext.RawParent)
+	buf = appendBinaryString(buf, sc.W3CContext.RawState)
+
+	// sort baggage keys to have the same encoding for the same context
+	keys := make([]string, 0, len(sc.Baggage))
+	for k := range sc.Baggage {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	buf = appendUvarint(buf, uint64(len(keys)))
+	for _, k := range keys {
+		buf = appendBinaryString(buf, k)
+		buf = appendBinaryString(buf, sc.Baggage[k])
+	}
+
+	return buf
+}
+
+func unmarshalBinaryTraceContext(r byteReader) (SpanContext, error) {
+	var header [2 + 3*8]byte
+
+	if n, err := io.ReadFull(r, header[:]); err != nil {
+		if n == 0 && err == io.EOF {
+			return SpanContext{}, ot.ErrSpanContextNotFound
+		}
+
+		return SpanContext{}, ot.ErrSpanContextCorrupted
+	}
+
+	if header[0] != binaryFormatVersion {
+		return SpanContext{}, ot.ErrSpanContextCorrupted
+	}
+
+	sc := SpanContext{
+		TraceIDHi:  int64(binary.BigEndian.Uint64(header[2:])),
+		TraceID:    int64(binary.BigEndian.Uint64(header[10:])),
+		SpanID:     int64(binary.BigEndian.Uint64(header[18:])),
+		Suppressed: header[1]&binaryFlagSuppressed != 0,
+		Sampled:    header[1]&binaryFlagSampled != 0,
+	}
+
+	traceparent, err := readBinaryString(r)
+	if err != nil {
+		return SpanContext{}, ot.ErrSpanContextCorrupted
+	}
+
+	tracestate, err := readBinaryString(r)
+	if err != nil {
+		return SpanContext{}, ot.ErrSpanContextCorrupted
+	}
+
+	if traceparent != "" || tracestate != "" {
+		sc.W3CContext = w3ctrace.Context{
+			RawParent: traceparent,
+			RawState:  tracestate,
+		}
+	}
+
+	numItems, err := binary.ReadUvarint(r)
+	if err != nil || numItems > maxBinaryBaggageItems {
+		return SpanContext{}, ot.ErrSpanContextCorrupted
+	}
+
+	if numItems > 0 {
+		sc.Baggage = make(map[string]string, numItems)
+	}
+
+	for i := uint64(0); i < numItems; i++ {
+		k, err := readBinaryString(r)
+		if err != nil {
+			return SpanContext{}, ot.ErrSpanContextCorrupted
+		}
+
+		v, err := readBinaryString(r)
+		if err != nil {
+			return SpanContext{}, ot.ErrSpanContextCorrupted
+		}
+
+		sc.Baggage[k] = v
+	}
+
+	return sc, nil
+}
+
+func appendUvarint(buf []byte, n uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+
+	return append(buf, b[:binary.PutUvarint(b[:], n)]...)
+}
+
+func appendBinaryString(buf []byte, s string) []byte {
+	return append(appendUvarint(buf, uint64(len(s))), s...)
+}
+
+func readBinaryString(r byteReader) (string, error) {
+	l, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", err
+	}
+
+	if l > maxBinaryStringLen {
+		return "", errBinaryContextTooLarge
+	}
+
+	buf := make([]byte, l)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+
+	return string(buf), nil
+}
@@ -0,0 +1,80 @@
+// (c) Copyright IBM Corp. 2023
+
+//go:build go1.18
+// +build go1.18
+
+package instana
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/instana/go-sensor/w3ctrace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func FuzzBinaryTraceContext_RoundTrip(f *testing.F) {
+	f.Add(int64(0), int64(0x2435), int64(0x3546), false, false, "", "", "", "")
+	f.Add(int64(0x1), int64(-0x2435), int64(0x3546), true, true,
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "rojo=00f067aa0ba902b7", "foo", "bar")
+
+	f.Fuzz(func(t *testing.T, traceIDHi, traceID, spanID int64, suppressed, sampled bool, traceparent, tracestate, key, value string) {
+		sc := SpanContext{
+			TraceIDHi:  traceIDHi,
+			TraceID:    traceID,
+			SpanID:     spanID,
+			Suppressed: suppressed,
+			Sampled:    sampled,
+		}
+
+		if traceparent != "" || tracestate != "" {
+			sc.W3CContext = w3ctrace.Context{RawParent: traceparent, RawState: tracestate}
+		}
+
+		if key != "" || value != "" {
+			sc.Baggage = map[string]string{key: value}
+		}
+
+		var buf bytes.Buffer
+		err := injectBinaryTraceContext(sc, &buf)
+		if err == errBinaryContextTooLarge {
+			// the values exceeding the size limit are not injected
+			return
+		}
+		require.NoError(t, err)
+
+		extracted, err := unmarshalBinaryTraceContext(&buf)
+		require.NoError(t, err)
+
+		assert.Equal(t, sc, extracted)
+		assert.Zero(t, buf.Len())
+	})
+}
+
+func FuzzBinaryTraceContext_Extract(f *testing.F) {
+	f.Add([]byte{})
+	f.Add(marshalBinaryTraceContext(SpanContext{TraceID: 0x1, SpanID: 0x2}))
+	f.Add(marshalBinaryTraceContext(SpanContext{
+		TraceIDHi:  0x1,
+		TraceID:    0x2,
+		SpanID:     0x3,
+		W3CContext: w3ctrace.Context{RawParent: "00-00000000000000010000000000000002-0000000000000003-01"},
+		Baggage:    map[string]string{"foo": "bar"},
+	}))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		sc, err := extractBinaryTraceContext(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+
+		// any successfully decoded context must survive the round trip
+		encoded := marshalBinaryTraceContext(sc)
+
+		extracted, err := extractBinaryTraceContext(bytes.NewReader(encoded))
+		require.NoError(t, err)
+
+		assert.Equal(t, sc, extracted)
+	})
+}
@@ -0,0 +1,177 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	instana "github.com/instana/go-sensor"
+	"github.com/instana/go-sensor/w3ctrace"
+	ot "github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracer_Inject_Extract_Binary(t *testing.T) {
+	tracer := instana.NewTracerWithEverything(&instana.Options{AgentClient: alwaysReadyClient{}}, instana.NewTestRecorder())
+	defer instana.ShutdownSensor()
+
+	examples := map[string]instana.SpanContext{
+		"instana trace": {
+			TraceID: 0x2435,
+			SpanID:  0x3546,
+		},
+		"128-bit trace ID": {
+			TraceIDHi: 0x1,
+			TraceID:   -0x2435,
+			SpanID:    0x3546,
+			Sampled:   true,
+		},
+		"suppressed": {
+			Suppressed: true,
+		},
+		"w3c trace context and baggage": {
+			TraceID: 0x2435,
+			SpanID:  0x3546,
+			W3CContext: w3ctrace.Context{
+				RawParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+				RawState:  "rojo=00f067aa0ba902b7",
+			},
+			Baggage: map[string]string{
+				"foo": "bar",
+				"":    "empty key",
+			},
+		},
+	}
+
+	for name, sc := range examples {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, tracer.Inject(sc, ot.Binary, &buf))
+
+			extracted, err := tracer.Extract(ot.Binary, &buf)
+			require.NoError(t, err)
+
+			assert.Equal(t, sc, extracted)
+			assert.Zero(t, buf.Len())
+		})
+	}
+}
+
+func TestTracer_Extract_Binary_KeepsPayload(t *testing.T) {
+	tracer := instana.NewTracerWithEverything(&instana.Options{AgentClient: alwaysReadyClient{}}, instana.NewTestRecorder())
+	defer instana.ShutdownSensor()
+
+	var buf bytes.Buffer
+	require.NoError(t, tracer.Inject(instana.SpanContext{TraceID: 0x1, SpanID: 0x2}, ot.Binary, &buf))
+	buf.WriteString("payload")
+
+	// a reader that does not implement io.ByteReader must not be read beyond the trace context
+	sc, err := tracer.Extract(ot.Binary, readerOnly{&buf})
+	require.NoError(t, err)
+
+	assert.Equal(t, instana.SpanContext{TraceID: 0x1, SpanID: 0x2}, sc)
+	assert.Equal(t, "payload", buf.String())
+}
+
+func TestTracer_Extract_Binary_NoContext(t *testing.T) {
+	tracer := instana.NewTracerWithEverything(&instana.Options{AgentClient: alwaysReadyClient{}}, instana.NewTestRecorder())
+	defer instana.ShutdownSensor()
+
+	_, err := tracer.Extract(ot.Binary, bytes.NewReader(nil))
+	assert.Equal(t, ot.ErrSpanContextNotFound, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, tracer.Inject(instana.SpanContext{}, ot.Binary, &buf))
+
+	_, err = tracer.Extract(ot.Binary, &buf)
+	assert.Equal(t, ot.ErrSpanContextNotFound, err)
+}
+
+func TestTracer_Extract_Binary_CorruptedContext(t *testing.T) {
+	tracer := instana.NewTracerWithEverything(&instana.Options{AgentClient: alwaysReadyClient{}}, instana.NewTestRecorder())
+	defer instana.ShutdownSensor()
+
+	var buf bytes.Buffer
+	require.NoError(t, tracer.Inject(instana.SpanContext{
+		TraceID: 0x1,
+		SpanID:  0x2,
+		Baggage: map[string]string{"foo": "bar"},
+	}, ot.Binary, &buf))
+
+	data := buf.Bytes()
+
+	examples := map[string][]byte{
+		"unknown version":  append([]byte{0xff}, data[1:]...),
+		"truncated header": data[:10],
+		"truncated body":   data[:len(data)-1],
+		"malformed length": append(append([]byte{}, data[:26]...), 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff),
+	}
+
+	for name, data := range examples {
+		t.Run(name, func(t *testing.T) {
+			_, err := tracer.Extract(ot.Binary, bytes.NewReader(data))
+			assert.Equal(t, ot.ErrSpanContextCorrupted, err)
+		})
+	}
+}
+
+func TestTracer_Inject_Binary_TooLarge(t *testing.T) {
+	tracer := instana.NewTracerWithEverything(&instana.Options{AgentClient: alwaysReadyClient{}}, instana.NewTestRecorder())
+	defer instana.ShutdownSensor()
+
+	examples := map[string]instana.SpanContext{
+		"baggage value": {
+			TraceID: 0x1,
+			SpanID:  0x2,
+			Baggage: map[string]string{"foo": strings.Repeat("x", 1<<16+1)},
+		},
+		"tracestate": {
+			TraceID:    0x1,
+			SpanID:     0x2,
+			W3CContext: w3ctrace.Context{RawParent: "00-00000000000000010000000000000002-0000000000000002-01", RawState: strings.Repeat("x", 1<<16+1)},
+		},
+	}
+
+	for name, sc := range examples {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			assert.Error(t, tracer.Inject(sc, ot.Binary, &buf))
+			assert.Zero(t, buf.Len())
+		})
+	}
+
+	// the values of maximum length are injected and extracted
+	sc := instana.SpanContext{
+		TraceID: 0x1,
+		SpanID:  0x2,
+		Baggage: map[string]string{"foo": strings.Repeat("x", 1<<16)},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, tracer.Inject(sc, ot.Binary, &buf))
+
+	extracted, err := tracer.Extract(ot.Binary, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, sc.Baggage, extracted.(instana.SpanContext).Baggage)
+}
+
+func TestTracer_Inject_Extract_Binary_InvalidCarrier(t *testing.T) {
+	tracer := instana.NewTracerWithEverything(&instana.Options{AgentClient: alwaysReadyClient{}}, instana.NewTestRecorder())
+	defer instana.ShutdownSensor()
+
+	assert.Equal(t, ot.ErrInvalidCarrier, tracer.Inject(instana.SpanContext{TraceID: 0x1, SpanID: 0x2}, ot.Binary, "carrier"))
+
+	_, err := tracer.Extract(ot.Binary, "carrier")
+	assert.Equal(t, ot.ErrInvalidCarrier, err)
+}
+
+// readerOnly hides all methods of the underlying reader except Read()
+type readerOnly struct {
+	r io.Reader
+}
+
+func (r readerOnly) Read(p []byte) (int, error) { return r.r.Read(p) }
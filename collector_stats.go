@@ -0,0 +1,212 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/instana/go-sensor/acceptor"
+)
+
+// SensorStats contains the internal metrics of the Instana collector. These metrics are reported
+// to the agent along with the Go runtime metrics and can be used to troubleshoot missing traces.
+type SensorStats struct {
+	// Recorder contains the counters of the span recorder used by the tracer, see instana.RecorderStats
+	Recorder RecorderStats
+	// DroppedDelayedSpans is the number of spans lost because the buffer used to keep spans finished
+	// before the agent is ready was full
+	DroppedDelayedSpans uint64
+	// RejectedPayloads is the number of payloads that were not sent to the agent because of exceeding the size limit
+	RejectedPayloads uint64
+	// RejectedPayloadBytes is the total size of payloads that were not sent to the agent because of exceeding
+	// the size limit
+	RejectedPayloadBytes uint64
+	// RejectedSpans is the number of spans contained in the rejected payloads
+	RejectedSpans uint64
+	// OversizedSpans is the number of spans dropped because each of them alone exceeds the payload size limit
+	OversizedSpans uint64
+	// AgentState is the current state of the host agent connection, e.g. "unannounced" or "ready"
+	AgentState string
+	// StateTransitions is the number of host agent connection state changes
+	StateTransitions uint64
+	// AnnounceRetries is the number of retried attempts to announce the process or to test the agent connection
+	AnnounceRetries uint64
+}
+
+// collectorMetrics is the registry of internal collector metrics of a sensor. All methods are safe to call
+// on a nil *collectorMetrics, in which case the values are not recorded
+type collectorMetrics struct {
+	// counters are placed first to ensure 64-bit alignment required by sync/atomic on 32-bit platforms
+	droppedDelayedSpans  uint64
+	rejectedPayloads     uint64
+	rejectedPayloadBytes uint64
+	rejectedSpans        uint64
+	oversizedSpans       uint64
+	stateTransitions     uint64
+	announceRetries      uint64
+
+	mu         sync.RWMutex
+	agentState string
+	recorders  []*Recorder
+}
+
+// CollectorStats returns the current values of the internal metrics of the global sensor. The returned
+// value is empty if the sensor is not initialized
+func CollectorStats() SensorStats {
+	return sensor.Metrics().Stats()
+}
+
+// Stats returns the current metric values. The recorder stats are summed across all registered recorders
+func (m *collectorMetrics) Stats() SensorStats {
+	if m == nil {
+		return SensorStats{}
+	}
+
+	m.mu.RLock()
+	state, recorders := m.agentState, m.recorders
+	m.mu.RUnlock()
+
+	stats := SensorStats{
+		DroppedDelayedSpans:  atomic.LoadUint64(&m.droppedDelayedSpans),
+		RejectedPayloads:     atomic.LoadUint64(&m.rejectedPayloads),
+		RejectedPayloadBytes: atomic.LoadUint64(&m.rejectedPayloadBytes),
+		RejectedSpans:        atomic.LoadUint64(&m.rejectedSpans),
+		OversizedSpans:       atomic.LoadUint64(&m.oversizedSpans),
+		AgentState:           state,
+		StateTransitions:     atomic.LoadUint64(&m.stateTransitions),
+		AnnounceRetries:      atomic.LoadUint64(&m.announceRetries),
+	}
+
+	for _, rec := range recorders {
+		rs := rec.Stats()
+
+		stats.Recorder.QueuedSpans += rs.QueuedSpans
+		stats.Recorder.SentSpans += rs.SentSpans
+		stats.Recorder.DroppedSpans += rs.DroppedSpans
+		stats.Recorder.SpooledSpans += rs.SpooledSpans
+		stats.Recorder.SendFailures += rs.SendFailures
+		stats.Recorder.Retries += rs.Retries
+	}
+
+	return stats
+}
+
+// AddRecorder registers a span recorder used by a tracer, so that its stats are reported. The recorder can
+// either be an *instana.Recorder, or a *instana.TailSamplingRecorder using one. Other recorders are ignored,
+// since their stats are not available.
+func (m *collectorMetrics) AddRecorder(recorder SpanRecorder) {
+	if m == nil {
+		return
+	}
+
+	if r, ok := recorder.(*TailSamplingRecorder); ok {
+		recorder = r.next
+	}
+
+	rec, ok := recorder.(*Recorder)
+	if !ok || rec == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, r := range m.recorders {
+		if r == rec {
+			return
+		}
+	}
+
+	m.recorders = append(m.recorders, rec)
+}
+
+// DelayedSpanDropped increments the number of spans lost due to the delayed spans buffer being full
+func (m *collectorMetrics) DelayedSpanDropped() {
+	if m == nil {
+		return
+	}
+
+	atomic.AddUint64(&m.droppedDelayedSpans, 1)
+}
+
+// PayloadRejected records the size of a payload rejected because of exceeding the size limit
+func (m *collectorMetrics) PayloadRejected(size int) {
+	if m == nil {
+		return
+	}
+
+	atomic.AddUint64(&m.rejectedPayloads, 1)
+	atomic.AddUint64(&m.rejectedPayloadBytes, uint64(size))
+}
+
+// SpansRejected increments the number of spans that were contained in rejected payloads
+func (m *collectorMetrics) SpansRejected(n int) {
+	if m == nil {
+		return
+	}
+
+	atomic.AddUint64(&m.rejectedSpans, uint64(n))
+}
+
+// SpansOversized increments the number of spans dropped for exceeding the payload size limit
+func (m *collectorMetrics) SpansOversized(n int) {
+	if m == nil {
+		return
+	}
+
+	atomic.AddUint64(&m.oversizedSpans, uint64(n))
+}
+
+// StateChanged records a host agent connection state transition
+func (m *collectorMetrics) StateChanged(state string) {
+	if m == nil {
+		return
+	}
+
+	atomic.AddUint64(&m.stateTransitions, 1)
+
+	m.mu.Lock()
+	m.agentState = state
+	m.mu.Unlock()
+}
+
+// AnnounceRetried increments the number of announce retries
+func (m *collectorMetrics) AnnounceRetried() {
+	if m == nil {
+		return
+	}
+
+	atomic.AddUint64(&m.announceRetries, 1)
+}
+
+// Recorders returns the registered span recorders
+func (m *collectorMetrics) Recorders() []*Recorder {
+	if m == nil {
+		return nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return append([]*Recorder(nil), m.recorders...)
+}
+
+func newCollectorMetricsPayload(stats SensorStats) *acceptor.CollectorMetrics {
+	return &acceptor.CollectorMetrics{
+		QueuedSpans:          stats.Recorder.QueuedSpans,
+		SentSpans:            stats.Recorder.SentSpans,
+		DroppedSpans:         stats.Recorder.DroppedSpans,
+		SpooledSpans:         stats.Recorder.SpooledSpans,
+		SendFailures:         stats.Recorder.SendFailures,
+		Retries:              stats.Recorder.Retries,
+		DroppedDelayedSpans:  stats.DroppedDelayedSpans,
+		RejectedPayloads:     stats.RejectedPayloads,
+		RejectedPayloadBytes: stats.RejectedPayloadBytes,
+		RejectedSpans:        stats.RejectedSpans,
+		OversizedSpans:       stats.OversizedSpans,
+		AgentState:           stats.AgentState,
+		StateTransitions:     stats.StateTransitions,
+		AnnounceRetries:      stats.AnnounceRetries,
+	}
+}
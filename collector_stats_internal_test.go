@@ -0,0 +1,116 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana
+
+import (
+	"testing"
+
+	"github.com/instana/go-sensor/acceptor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectorMetrics_Stats(t *testing.T) {
+	m := &collectorMetrics{}
+
+	m.DelayedSpanDropped()
+	m.PayloadRejected(100)
+	m.PayloadRejected(200)
+	m.SpansRejected(5)
+	m.StateChanged("init")
+	m.StateChanged("unannounced")
+	m.AnnounceRetried()
+
+	assert.Equal(t, SensorStats{
+		DroppedDelayedSpans:  1,
+		RejectedPayloads:     2,
+		RejectedPayloadBytes: 300,
+		RejectedSpans:        5,
+		AgentState:           "unannounced",
+		StateTransitions:     2,
+		AnnounceRetries:      1,
+	}, m.Stats())
+}
+
+func TestCollectorMetrics_Nil(t *testing.T) {
+	var m *collectorMetrics
+
+	m.DelayedSpanDropped()
+	m.StateChanged("ready")
+	m.AddRecorder(NewTestRecorder())
+
+	assert.Equal(t, SensorStats{}, m.Stats())
+	assert.Empty(t, m.Recorders())
+}
+
+func TestCollectorMetrics_AddRecorder(t *testing.T) {
+	rec1, rec2 := NewTestRecorder(), NewTestRecorder()
+
+	m := &collectorMetrics{}
+	m.AddRecorder(rec1)
+	m.AddRecorder(newTailSamplingRecorder(rec2, DefaultTailSamplingOptions(), nil))
+	m.AddRecorder(rec1)
+	m.AddRecorder(&capturingRecorder{})
+
+	assert.Equal(t, []*Recorder{rec1, rec2}, m.Recorders())
+}
+
+func TestCollectorMetrics_Stats_Recorders(t *testing.T) {
+	InitSensor(&Options{AgentClient: alwaysReadyClient{}})
+	defer ShutdownSensor()
+
+	rec1, rec2 := NewTestRecorder(), NewTestRecorder()
+	rec1.RecordSpan(&spanS{Service: "service-1", Operation: "span-1"})
+	rec2.RecordSpan(&spanS{Service: "service-2", Operation: "span-2"})
+
+	m := &collectorMetrics{}
+	m.AddRecorder(rec1)
+	m.AddRecorder(rec2)
+
+	assert.Equal(t, 2, m.Stats().Recorder.QueuedSpans)
+}
+
+func TestDelayedSpans_Append_Full(t *testing.T) {
+	defer func(s *sensorS) { sensor = s }(sensor)
+	sensor = &sensorS{metrics: &collectorMetrics{}}
+
+	ds := &delayedSpans{spans: make(chan *spanS, 1)}
+
+	assert.True(t, ds.append(&spanS{}))
+	assert.False(t, ds.append(&spanS{}))
+
+	assert.Equal(t, uint64(1), CollectorStats().DroppedDelayedSpans)
+}
+
+func TestNewCollectorMetricsPayload(t *testing.T) {
+	assert.Equal(t, &acceptor.CollectorMetrics{
+		QueuedSpans:          1,
+		SentSpans:            2,
+		DroppedSpans:         3,
+		SpooledSpans:         4,
+		SendFailures:         5,
+		Retries:              6,
+		DroppedDelayedSpans:  7,
+		RejectedPayloads:     8,
+		RejectedPayloadBytes: 9,
+		RejectedSpans:        10,
+		AgentState:           "ready",
+		StateTransitions:     11,
+		AnnounceRetries:      12,
+	}, newCollectorMetricsPayload(SensorStats{
+		Recorder: RecorderStats{
+			QueuedSpans:  1,
+			SentSpans:    2,
+			DroppedSpans: 3,
+			SpooledSpans: 4,
+			SendFailures: 5,
+			Retries:      6,
+		},
+		DroppedDelayedSpans:  7,
+		RejectedPayloads:     8,
+		RejectedPayloadBytes: 9,
+		RejectedSpans:        10,
+		AgentState:           "ready",
+		StateTransitions:     11,
+		AnnounceRetries:      12,
+	}))
+}
@@ -0,0 +1,28 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana_test
+
+import (
+	"testing"
+
+	instana "github.com/instana/go-sensor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectorStats_Recorder(t *testing.T) {
+	recorder := instana.NewTestRecorder()
+	tracer := instana.NewTracerWithEverything(&instana.Options{
+		AgentClient:      alwaysReadyClient{},
+		MaxBufferedSpans: 2,
+	}, recorder)
+	defer instana.ShutdownSensor()
+
+	tracer.StartSpan("span-1").Finish()
+	tracer.StartSpan("span-2").Finish()
+	tracer.StartSpan("span-3").Finish()
+
+	stats := instana.CollectorStats()
+	assert.Equal(t, recorder.Stats(), stats.Recorder)
+	assert.Equal(t, 2, stats.Recorder.QueuedSpans)
+	assert.Equal(t, uint64(1), stats.Recorder.DroppedSpans)
+}
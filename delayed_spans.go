@@ -22,6 +22,7 @@ func (ds *delayedSpans) append(span *spanS) bool {
 	case ds.spans <- span:
 		return true
 	default:
+		sensor.Metrics().DelayedSpanDropped()
 		return false
 	}
 }
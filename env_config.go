@@ -6,6 +6,7 @@ package instana
 import (
 	"errors"
 	"fmt"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -107,3 +108,61 @@ func parseInstanaTimeout(s string) (time.Duration, error) {
 
 	return time.Duration(ms) * time.Millisecond, nil
 }
+
+// parseOTLPHeaders parses the OTEL_EXPORTER_OTLP_HEADERS value, which is a comma-separated list of
+// URL-encoded key=value pairs
+func parseOTLPHeaders(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		ind := strings.Index(pair, "=")
+		if ind < 1 {
+			return nil, errors.New("malformed header: " + pair)
+		}
+
+		k, err := url.PathUnescape(strings.TrimSpace(pair[:ind]))
+		if err != nil {
+			return nil, fmt.Errorf("malformed header name %q: %s", pair[:ind], err)
+		}
+
+		v, err := url.PathUnescape(strings.TrimSpace(pair[ind+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("malformed header value for %q: %s", k, err)
+		}
+
+		headers[k] = v
+	}
+
+	return headers, nil
+}
+
+// parseInstanaPropagators parses the list of propagators passed via INSTANA_PROPAGATORS.
+// The propagator names are expected to come in a comma-separated list:
+//
+//	INSTANA_PROPAGATORS := propagator1[,propagator2,...]
+//
+// This function returns an error if the list contains an unknown propagator name.
+func parseInstanaPropagators(s string) ([]string, error) {
+	var propagators []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		switch name {
+		case "":
+			continue
+		case PropagatorInstana, PropagatorW3C, PropagatorB3, PropagatorB3Single, PropagatorJaeger:
+			propagators = append(propagators, name)
+		default:
+			return nil, fmt.Errorf("unknown propagator %q", name)
+		}
+	}
+
+	return propagators, nil
+}
@@ -146,3 +146,70 @@ func TestParseInstanaTimeout_Error(t *testing.T) {
 		})
 	}
 }
+
+func TestParseOTLPHeaders(t *testing.T) {
+	examples := map[string]struct {
+		Value    string
+		Expected map[string]string
+	}{
+		"empty":  {"", nil},
+		"single": {"api-key=secret", map[string]string{"api-key": "secret"}},
+		"multiple, url-encoded": {
+			" api-key = secret ,Authorization=Basic%20dXNlcjpwYXNz,",
+			map[string]string{
+				"api-key":       "secret",
+				"Authorization": "Basic dXNlcjpwYXNz",
+			},
+		},
+	}
+
+	for name, example := range examples {
+		t.Run(name, func(t *testing.T) {
+			headers, err := parseOTLPHeaders(example.Value)
+			require.NoError(t, err)
+			assert.Equal(t, example.Expected, headers)
+		})
+	}
+}
+
+func TestParseOTLPHeaders_Malformed(t *testing.T) {
+	examples := map[string]string{
+		"no value":        "api-key",
+		"empty key":       "=secret",
+		"malformed value": "api-key=%zz",
+	}
+
+	for name, value := range examples {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseOTLPHeaders(value)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseInstanaPropagators(t *testing.T) {
+	examples := map[string]struct {
+		Value    string
+		Expected []string
+	}{
+		"empty":  {"", nil},
+		"single": {"b3", []string{PropagatorB3}},
+		"multiple, mixed case": {
+			" instana, W3C ,b3single,Jaeger,",
+			[]string{PropagatorInstana, PropagatorW3C, PropagatorB3Single, PropagatorJaeger},
+		},
+	}
+
+	for name, example := range examples {
+		t.Run(name, func(t *testing.T) {
+			propagators, err := parseInstanaPropagators(example.Value)
+			require.NoError(t, err)
+			assert.Equal(t, example.Expected, propagators)
+		})
+	}
+}
+
+func TestParseInstanaPropagators_Unknown(t *testing.T) {
+	_, err := parseInstanaPropagators("instana,xray")
+	assert.Error(t, err)
+}
@@ -4,15 +4,9 @@
 package instana
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"fmt"
-	"io"
-	"io/ioutil"
 	"net/http"
 	"os"
-	"strconv"
 	"sync"
 	"time"
 
@@ -135,23 +129,19 @@ type metricsPayload struct {
 }
 
 type fargateAgent struct {
-	Endpoint string
-	Key      string
-	PID      int
-	Zone     string
-	Tags     map[string]interface{}
+	PID  int
+	Zone string
+	Tags map[string]interface{}
 
 	snapshot         fargateSnapshot
 	lastDockerStats  map[string]docker.ContainerStats
 	lastProcessStats processStats
 
-	mu        sync.Mutex
-	spanQueue []Span
+	transport *serverlessTransport
 
 	runtimeSnapshot *SnapshotCollector
 	dockerStats     *ecsDockerStatsCollector
 	processStats    *processStatsCollector
-	client          *http.Client
 	ecs             *aws.ECSMetadataProvider
 	logger          LeveledLogger
 }
@@ -167,18 +157,12 @@ func newFargateAgent(
 		logger = defaultLogger
 	}
 
-	if client == nil {
-		client = http.DefaultClient
-	}
-
 	logger.Debug("initializing aws fargate agent")
 
 	agent := &fargateAgent{
-		Endpoint: acceptorEndpoint,
-		Key:      agentKey,
-		PID:      os.Getpid(),
-		Zone:     os.Getenv("INSTANA_ZONE"),
-		Tags:     parseInstanaTags(os.Getenv("INSTANA_TAGS")),
+		PID:  os.Getpid(),
+		Zone: os.Getenv("INSTANA_ZONE"),
+		Tags: parseInstanaTags(os.Getenv("INSTANA_TAGS")),
 		runtimeSnapshot: &SnapshotCollector{
 			CollectionInterval: snapshotCollectionInterval,
 			ServiceName:        serviceName,
@@ -190,9 +174,9 @@ func newFargateAgent(
 		processStats: &processStatsCollector{
 			logger: logger,
 		},
-		client: client,
-		ecs:    mdProvider,
-		logger: logger,
+		ecs:       mdProvider,
+		transport: newServerlessTransport(acceptorEndpoint, agentKey, client, logger),
+		logger:    logger,
 	}
 
 	go func() {
@@ -263,41 +247,19 @@ func (a *fargateAgent) SendMetrics(data acceptor.Metrics) (err error) {
 		)
 	}
 
-	a.mu.Lock()
-	if len(a.spanQueue) > 0 {
-		payload.Spans = make([]Span, len(a.spanQueue))
-		copy(payload.Spans, a.spanQueue)
-		a.spanQueue = a.spanQueue[:0]
-	}
-	a.mu.Unlock()
-
-	buf := bytes.NewBuffer(nil)
-	if err := json.NewEncoder(buf).Encode(payload); err != nil {
-		return fmt.Errorf("failed to marshal metrics payload: %s", err)
-	}
-
-	req, err := http.NewRequest(http.MethodPost, a.Endpoint+"/bundle", buf)
-	if err != nil {
-		return fmt.Errorf("failed to prepare send metrics request: %s", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	return a.sendRequest(req)
+	return a.transport.SendBundle(
+		context.Background(),
+		a.snapshot.Service.EntityID,
+		newServerlessAgentFromS(a.snapshot.Service.EntityID, "aws"),
+		payload.Metrics.Plugins,
+	)
 }
 
 func (a *fargateAgent) SendEvent(event *EventData) error { return nil }
 
 func (a *fargateAgent) SendSpans(spans []Span) error {
-	from := newServerlessAgentFromS(a.snapshot.Service.EntityID, "aws")
-	for i := range spans {
-		spans[i].From = from
-	}
-
 	// enqueue the spans to send them in a bundle with metrics instead of sending immediately
-	a.mu.Lock()
-	a.spanQueue = append(a.spanQueue, spans...)
-	a.mu.Unlock()
+	a.transport.Enqueue(spans)
 
 	return nil
 }
@@ -305,7 +267,7 @@ func (a *fargateAgent) SendSpans(spans []Span) error {
 func (a *fargateAgent) SendProfiles(profiles []autoprofile.Profile) error { return nil }
 
 func (a *fargateAgent) Flush(ctx context.Context) error {
-	if len(a.spanQueue) == 0 {
+	if a.transport.QueueLen() == 0 {
 		return nil
 	}
 
@@ -313,51 +275,7 @@ func (a *fargateAgent) Flush(ctx context.Context) error {
 		return ErrAgentNotReady
 	}
 
-	a.mu.Lock()
-	defer a.mu.Unlock()
-
-	buf := bytes.NewBuffer(nil)
-	if err := json.NewEncoder(buf).Encode(a.spanQueue); err != nil {
-		return fmt.Errorf("failed to marshal traces payload: %s", err)
-	}
-	a.spanQueue = a.spanQueue[:0]
-
-	req, err := http.NewRequest(http.MethodPost, a.Endpoint+"/traces", buf)
-	if err != nil {
-		return fmt.Errorf("failed to prepare send traces request: %s", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	return a.sendRequest(req.WithContext(ctx))
-}
-
-func (a *fargateAgent) sendRequest(req *http.Request) error {
-	req.Header.Set("X-Instana-Host", a.snapshot.Service.EntityID)
-	req.Header.Set("X-Instana-Key", a.Key)
-	req.Header.Set("X-Instana-Time", strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10))
-
-	resp, err := a.client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send request to the serverless agent: %s", err)
-	}
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= http.StatusBadRequest {
-		respBody, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			a.logger.Debug("failed to read serverless agent response: ", err)
-			return nil
-		}
-
-		a.logger.Info("serverless agent has responded with ", resp.Status, ": ", string(respBody))
-		return nil
-	}
-
-	io.CopyN(ioutil.Discard, resp.Body, 1<<20)
-
-	return nil
+	return a.transport.SendSpans(ctx, a.snapshot.Service.EntityID, newServerlessAgentFromS(a.snapshot.Service.EntityID, "aws"))
 }
 
 func (a *fargateAgent) collectSnapshot(ctx context.Context) (fargateSnapshot, bool) {
@@ -5,14 +5,16 @@ package instana
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"math"
-	"net"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	f "github.com/looplab/fsm"
@@ -33,10 +35,40 @@ type fsmS struct {
 	agentComm                  *agentCommunicator
 	fsm                        *f.FSM
 	timer                      *time.Timer
+	maxRetries                 int
 	retriesLeft                int
 	expDelayFunc               func(retryNumber int) time.Duration
 	lookupAgentHostRetryPeriod time.Duration
-	logger                     LeveledLogger
+	// jitterFunc randomizes the agent host lookup retry period for each attempt
+	jitterFunc func(time.Duration) time.Duration
+	logger     LeveledLogger
+
+	// configPollPeriod is the interval between the agent configuration update requests. The polling is disabled
+	// if this value is not positive.
+	configPollPeriod time.Duration
+	// actionsPollPeriod is the interval between the requests for actions to be executed. The agent actions are
+	// disabled if this value is not positive.
+	actionsPollPeriod time.Duration
+	// sensor is the sensor instance the configuration received from the agent is applied to
+	sensor *sensorS
+	// pollMu guards stopPolling, which is closed to stop the agent pollers once the fsm leaves the ready state,
+	// and stopped, which prevents the pollers from being restarted once the sensor has been shut down
+	pollMu      sync.Mutex
+	stopPolling chan struct{}
+	stopped     bool
+	// pollers tracks the running agent pollers
+	pollers sync.WaitGroup
+	// configMu serializes the updates of the agent configuration received during the announcement and by the poller
+	configMu sync.Mutex
+	// logLevel is the log level from the last applied agent configuration
+	logLevel string
+	// samplingRate is the sampling rate from the last applied agent configuration, and sampler is the sampler built for it
+	samplingRate *float64
+	sampler      Sampler
+	// wasReady is set to 1 once the agent has become ready for the first time
+	wasReady int32
+	// conn tracks the connection status and notifies the subscribers about its changes
+	conn *agentConnectionTracker
 }
 
 func newHostAgentFromS(pid int, hostID string) *fromS {
@@ -46,16 +78,28 @@ func newHostAgentFromS(pid int, hostID string) *fromS {
 	}
 }
 
-func newFSM(ahd *agentCommunicator, logger LeveledLogger) *fsmS {
+func newFSM(s *sensorS, ahd *agentCommunicator, policy RetryPolicy, actionsPollPeriod time.Duration, logger LeveledLogger) *fsmS {
 	logger.Warn("Stan is on the scene. Starting Instana instrumentation.")
 	logger.Debug("initializing fsm")
 
+	maxRetries := policy.MaxRetries
+	if maxRetries < 0 {
+		// the number of retries left never reaches zero, so the retries are unlimited
+		maxRetries = -1
+	}
+
 	ret := &fsmS{
+		sensor:                     s,
 		agentComm:                  ahd,
-		retriesLeft:                maximumRetries,
-		expDelayFunc:               expDelay,
+		maxRetries:                 maxRetries,
+		retriesLeft:                maxRetries,
+		expDelayFunc:               policy.Delay,
 		logger:                     logger,
-		lookupAgentHostRetryPeriod: retryPeriod,
+		lookupAgentHostRetryPeriod: policy.LookupDelay,
+		jitterFunc:                 policy.jitter,
+		configPollPeriod:           agentConfigPollPeriod,
+		actionsPollPeriod:          actionsPollPeriod,
+		conn:                       newAgentConnectionTracker(),
 	}
 
 	ret.fsm = f.NewFSM(
@@ -70,6 +114,8 @@ func newFSM(ahd *agentCommunicator, logger LeveledLogger) *fsmS {
 			"enter_unannounced": ret.announceSensor,
 			"enter_announced":   ret.testAgent,
 			"ready":             ret.ready,
+			"leave_ready":       ret.leaveReady,
+			"enter_state":       ret.stateChanged,
 		})
 	ret.fsm.Event(context.Background(), eInit)
 
@@ -77,7 +123,12 @@ func newFSM(ahd *agentCommunicator, logger LeveledLogger) *fsmS {
 }
 
 func (r *fsmS) scheduleRetry(e *f.Event, cb func(_ context.Context, e *f.Event)) {
-	r.timer = time.NewTimer(r.lookupAgentHostRetryPeriod)
+	d := r.lookupAgentHostRetryPeriod
+	if r.jitterFunc != nil {
+		d = r.jitterFunc(d)
+	}
+
+	r.timer = time.NewTimer(d)
 	go func() {
 		<-r.timer.C
 		cb(context.Background(), e)
@@ -131,6 +182,8 @@ func (r *fsmS) checkHost(e *f.Event) {
 		r.agentComm.host = originalHost
 	}
 
+	r.failed(fmt.Errorf("host agent is not reachable at %s:%s", r.agentComm.host, r.agentComm.port))
+
 	// Look for a successful ping for the configured default gateway
 	routeFilename := "/proc/net/route"
 	r.logger.Debug("Lookup failed for expected host: ", r.agentComm.host, ". Will attempt to read host from ", routeFilename)
@@ -180,11 +233,13 @@ func (r *fsmS) lookupSuccess(host string) {
 	r.logger.Debug("agent lookup success ", host)
 
 	r.agentComm.host = host
-	r.retriesLeft = maximumRetries
+	r.retriesLeft = r.maxRetries
 	r.fsm.Event(context.Background(), eLookup)
 }
 
 func (r *fsmS) handleRetries(e *f.Event, cb func(_ context.Context, e *f.Event), retryFailMsg, retryMsg string) {
+	r.agentComm.metrics.AnnounceRetried()
+
 	r.retriesLeft--
 	if r.retriesLeft == 0 {
 		r.logger.Error(retryFailMsg)
@@ -193,24 +248,114 @@ func (r *fsmS) handleRetries(e *f.Event, cb func(_ context.Context, e *f.Event),
 	}
 
 	r.logger.Debug(retryMsg)
-	retryNumber := maximumRetries - r.retriesLeft + 1
+	retryNumber := r.maxRetries - r.retriesLeft + 1
 	r.scheduleRetryWithExponentialDelay(e, cb, retryNumber)
 }
 
 func (r *fsmS) applyHostAgentSettings(resp agentResponse) {
 	r.agentComm.from = newHostAgentFromS(int(resp.Pid), resp.HostID)
+	r.applyAgentConfig(resp)
+}
 
-	if resp.Secrets.Matcher != "" {
-		m, err := NamedMatcher(resp.Secrets.Matcher, resp.Secrets.List)
-		if err != nil {
-			r.logger.Warn("failed to apply secrets matcher configuration: ", err)
-		} else {
-			sensor.options.Tracer.Secrets = m
+// applyAgentConfig updates the tracer options and the log level with the configuration received from the agent
+func (r *fsmS) applyAgentConfig(resp agentResponse) {
+	r.configMu.Lock()
+	defer r.configMu.Unlock()
+
+	sampler := r.agentSampler(resp.Tracing.Sampling.Rate)
+	r.sensor.updateTracerOptions(func(configured TracerOptions) TracerOptions {
+		return agentTracerOptions(configured, resp, sampler, r.logger)
+	})
+
+	if resp.LogLevel != r.logLevel {
+		r.logLevel = resp.LogLevel
+		r.sensor.applyAgentLogLevel(resp.LogLevel)
+	}
+}
+
+// agentSampler returns the sampler for the sampling rate received from the agent, or nil if there is none.
+// The sampler is only rebuilt if the rate has changed. This method is not thread-safe and needs to be called
+// while holding the configMu lock.
+func (r *fsmS) agentSampler(rate *float64) Sampler {
+	if rate == nil {
+		r.samplingRate, r.sampler = nil, nil
+		return nil
+	}
+
+	if r.samplingRate == nil || *r.samplingRate != *rate {
+		r.samplingRate, r.sampler = rate, NewProbabilisticSampler(*rate)
+	}
+
+	return r.sampler
+}
+
+// pollAgentConfig periodically requests the tracing configuration from the agent and applies it
+// without restarting the announcement cycle. The polling is stopped once the done channel is closed.
+func (r *fsmS) pollAgentConfig(done <-chan struct{}) {
+	ticker := time.NewTicker(r.configPollPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+
+		if resp := r.agentComm.agentConfig(); resp != nil {
+			r.logger.Debug("applying configuration received from the agent")
+			r.applyAgentConfig(*resp)
+		}
+	}
+}
+
+// pollAgentActions periodically requests the actions to be executed from the agent, runs them and sends
+// their results back. The polling is stopped once the done channel is closed. The actions requested while
+// the maximum number of actions is already running are rejected.
+func (r *fsmS) pollAgentActions(done <-chan struct{}) {
+	ticker := time.NewTicker(r.actionsPollPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+
+		for _, req := range r.agentComm.agentActions() {
+			select {
+			case agentActionSlots <- struct{}{}:
+				go func(req agentActionRequest) {
+					defer func() { <-agentActionSlots }()
+					r.runAgentAction(req)
+				}(req)
+			default:
+				r.sendAgentActionResult(agentActionResult{
+					ID:     req.ID,
+					Action: req.Action,
+					Error:  errTooManyAgentActions.Error(),
+				})
+			}
 		}
 	}
+}
+
+// runAgentAction executes the action requested by the agent and sends its result back
+func (r *fsmS) runAgentAction(req agentActionRequest) {
+	r.logger.Debug("executing action ", req.Action, " requested by the agent")
+
+	r.sendAgentActionResult(runAgentAction(context.Background(), req))
+}
+
+// sendAgentActionResult reports the outcome of an action back to the agent
+func (r *fsmS) sendAgentActionResult(res agentActionResult) {
+	if res.Error != "" {
+		r.logger.Info("action ", res.Action, " requested by the agent failed: ", res.Error)
+	}
 
-	if len(sensor.options.Tracer.CollectableHTTPHeaders) == 0 {
-		sensor.options.Tracer.CollectableHTTPHeaders = resp.getExtraHTTPHeaders()
+	if err := r.agentComm.sendDataToAgent(agentResultsURL, res); err != nil {
+		r.logger.Warn("failed to send the result of action ", res.Action, " to the agent: ", err)
 	}
 }
 
@@ -232,6 +377,7 @@ func (r *fsmS) announceSensor(_ context.Context, e *f.Event) {
 		resp := r.agentComm.agentResponse(d)
 
 		if resp == nil {
+			r.failed(errors.New("failed to announce the sensor to the host agent"))
 			r.handleRetries(e, r.announceSensor, retryFailedMsg, retryMsg)
 			return
 		}
@@ -240,7 +386,7 @@ func (r *fsmS) announceSensor(_ context.Context, e *f.Event) {
 
 		r.applyHostAgentSettings(*resp)
 
-		r.retriesLeft = maximumRetries
+		r.retriesLeft = r.maxRetries
 		r.fsm.Event(context.Background(), eAnnounce)
 	}()
 }
@@ -268,11 +414,14 @@ func (r *fsmS) getDiscoveryS() *discoveryS {
 	}
 
 	if _, err := os.Stat("/proc"); err == nil {
-		if addr, err := net.ResolveTCPAddr("tcp", r.agentComm.host+":42699"); err == nil {
-			if tcpConn, err := net.DialTCP("tcp", nil, addr); err == nil {
-				defer tcpConn.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), clientTimeout)
+		defer cancel()
+
+		if conn, err := r.agentComm.dialAgent(ctx); err == nil {
+			defer conn.Close()
 
-				file, err := tcpConn.File()
+			if fc, ok := conn.(interface{ File() (*os.File, error) }); ok {
+				file, err := fc.File()
 
 				if err != nil {
 					r.logger.Error(err)
@@ -295,23 +444,89 @@ func (r *fsmS) testAgent(_ context.Context, e *f.Event) {
 	r.logger.Debug("testing communication with the agent")
 	go func() {
 		if !r.agentComm.pingAgent() {
+			r.failed(errors.New("host agent is not ready to accept data"))
 			r.handleRetries(e, r.testAgent, "testAgent: Couldn't announce the sensor after reaching the maximum amount of attempts.", "Agent is not yet ready. Scheduling retry.")
 			return
 		}
 
-		r.retriesLeft = maximumRetries
+		r.retriesLeft = r.maxRetries
 		r.fsm.Event(context.Background(), eTest)
 	}()
 }
 
 func (r *fsmS) reset() {
 	r.logger.Debug("State machine reset. Will restart agent connection cycle from the 'init' state")
-	r.retriesLeft = maximumRetries
+	r.retriesLeft = r.maxRetries
 	r.fsm.Event(context.Background(), eInit)
 }
 
+func (r *fsmS) stateChanged(_ context.Context, e *f.Event) {
+	r.agentComm.metrics.StateChanged(e.Dst)
+
+	if r.conn != nil {
+		r.conn.StateChanged(e.Dst)
+	}
+}
+
+// failed records the last error that occurred while connecting to the agent
+func (r *fsmS) failed(err error) {
+	if r.conn != nil {
+		r.conn.Failed(err)
+	}
+}
+
 func (r *fsmS) ready(_ context.Context, e *f.Event) {
+	atomic.StoreInt32(&r.wasReady, 1)
 	go delayed.flush()
+
+	r.pollMu.Lock()
+	if r.stopPolling == nil && !r.stopped {
+		r.stopPolling = make(chan struct{})
+
+		if r.configPollPeriod > 0 {
+			r.pollers.Add(1)
+			go func(done <-chan struct{}) {
+				defer r.pollers.Done()
+				r.pollAgentConfig(done)
+			}(r.stopPolling)
+		}
+
+		if r.actionsPollPeriod > 0 {
+			r.pollers.Add(1)
+			go func(done <-chan struct{}) {
+				defer r.pollers.Done()
+				r.pollAgentActions(done)
+			}(r.stopPolling)
+		}
+	}
+	r.pollMu.Unlock()
+}
+
+// leaveReady stops the agent pollers, since the agent connection is going to be re-established
+func (r *fsmS) leaveReady(_ context.Context, e *f.Event) {
+	r.pollMu.Lock()
+	defer r.pollMu.Unlock()
+
+	r.stopPollers()
+}
+
+// shutdown stops the agent pollers, prevents them from being started again and waits for them to return
+func (r *fsmS) shutdown() {
+	r.pollMu.Lock()
+	r.stopped = true
+	r.stopPollers()
+	r.pollMu.Unlock()
+
+	r.pollers.Wait()
+}
+
+// stopPollers signals the running agent pollers to stop. This method is not thread-safe and needs to be called
+// while holding the pollMu lock.
+func (r *fsmS) stopPollers() {
+	if r.stopPolling != nil {
+		close(r.stopPolling)
+		r.stopPolling = nil
+	}
 }
 
 func (r *fsmS) cpuSetFileContent(pid int) string {
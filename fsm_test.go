@@ -4,17 +4,23 @@ package instana
 
 import (
 	"context"
+	"encoding/json"
 	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	f "github.com/looplab/fsm"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func getTestServer(fn func(w http.ResponseWriter, r *http.Request)) *httptest.Server {
@@ -58,6 +64,7 @@ func Test_fsmS_testAgent(t *testing.T) {
 					res <- true
 				},
 			}),
+		maxRetries:  maximumRetries,
 		retriesLeft: maximumRetries,
 		expDelayFunc: func(retryNumber int) time.Duration {
 			return 0
@@ -98,6 +105,7 @@ func Test_fsmS_testAgent_Error(t *testing.T) {
 					res <- true
 				},
 			}),
+		maxRetries:  maximumRetries,
 		retriesLeft: maximumRetries,
 		expDelayFunc: func(retryNumber int) time.Duration {
 			return 0
@@ -111,6 +119,59 @@ func Test_fsmS_testAgent_Error(t *testing.T) {
 	assert.Equal(t, 0, r.retriesLeft)
 }
 
+func Test_fsmS_testAgent_UnlimitedRetries(t *testing.T) {
+	// Forces the mocked agent to fail more times than maximumRetries before becoming ready
+	var numCalls int32
+
+	server := getTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&numCalls, 1) <= 2*maximumRetries {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	res := make(chan bool, 1)
+
+	var retryNumbers []int
+
+	r := &fsmS{
+		agentComm: newAgentCommunicator(u.Hostname(), u.Port(), &fromS{}, defaultLogger),
+		fsm: f.NewFSM(
+			"announced",
+			f.Events{
+				{Name: eTest, Src: []string{"announced"}, Dst: "ready"},
+				{Name: eInit, Src: []string{"announced"}, Dst: "init"}},
+			f.Callbacks{
+				"ready": func(_ context.Context, event *f.Event) {
+					res <- true
+				},
+				"init": func(_ context.Context, event *f.Event) {
+					res <- false
+				},
+			}),
+		maxRetries:  -1,
+		retriesLeft: -1,
+		expDelayFunc: func(retryNumber int) time.Duration {
+			retryNumbers = append(retryNumbers, retryNumber)
+			return 0
+		},
+		logger: defaultLogger,
+		conn:   newAgentConnectionTracker(),
+	}
+
+	r.testAgent(context.Background(), &f.Event{})
+
+	assert.True(t, <-res)
+	assert.Equal(t, []int{2, 3, 4, 5, 6, 7}, retryNumbers)
+	assert.EqualError(t, r.conn.Status().LastError, "host agent is not ready to accept data")
+}
+
 func Test_fsmS_announceSensor(t *testing.T) {
 	// initializes the global sensor as it is needed when the announcement is successful
 	InitSensor(DefaultOptions())
@@ -148,6 +209,7 @@ func Test_fsmS_announceSensor(t *testing.T) {
 					res <- true
 				},
 			}),
+		maxRetries:  maximumRetries,
 		retriesLeft: maximumRetries,
 		expDelayFunc: func(retryNumber int) time.Duration {
 			return 0
@@ -161,6 +223,72 @@ func Test_fsmS_announceSensor(t *testing.T) {
 	assert.Equal(t, maximumRetries, r.retriesLeft)
 }
 
+func Test_fsmS_announceSensor_UnixSocket(t *testing.T) {
+	InitSensor(DefaultOptions())
+	defer ShutdownSensor()
+
+	dir, err := ioutil.TempDir("", "instana-agent")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	socketPath := filepath.Join(dir, "agent.sock")
+
+	ln, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+
+	discovery := make(chan discoveryS, 1)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var d discoveryS
+		if err := json.NewDecoder(r.Body).Decode(&d); err == nil {
+			discovery <- d
+		}
+
+		pid := strconv.FormatInt(int64(os.Getpid()), 10)
+		io.WriteString(w, `{"pid":`+pid+`}`)
+	}))
+	server.Listener.Close()
+	server.Listener = ln
+	server.Start()
+	defer server.Close()
+
+	res := make(chan bool, 1)
+
+	// the host and port are not reachable and are only used to build request URLs
+	agentComm := newAgentCommunicator("agent.invalid", "42699", &fromS{}, defaultLogger)
+	agentComm.configureTransport(unixSocketDialer(socketPath), AgentTLSOptions{})
+
+	r := &fsmS{
+		agentComm: agentComm,
+		fsm: f.NewFSM(
+			"unannounced",
+			f.Events{
+				{Name: eAnnounce, Src: []string{"unannounced"}, Dst: "announced"}},
+			f.Callbacks{
+				"announced": func(_ context.Context, event *f.Event) {
+					res <- true
+				},
+			}),
+		maxRetries:  maximumRetries,
+		retriesLeft: maximumRetries,
+		expDelayFunc: func(retryNumber int) time.Duration {
+			return 0
+		},
+		logger: defaultLogger,
+	}
+
+	r.announceSensor(context.Background(), &f.Event{})
+
+	assert.True(t, <-res)
+
+	d := <-discovery
+	assert.Equal(t, os.Getpid(), d.PID)
+
+	if _, err := os.Stat("/proc"); err == nil {
+		assert.NotEmpty(t, d.Fd)
+	}
+}
+
 func Test_fsmS_announceSensor_Error(t *testing.T) {
 	server := getTestServer(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
@@ -185,6 +313,7 @@ func Test_fsmS_announceSensor_Error(t *testing.T) {
 					res <- true
 				},
 			}),
+		maxRetries:  maximumRetries,
 		retriesLeft: maximumRetries,
 		expDelayFunc: func(retryNumber int) time.Duration {
 			return 0
@@ -232,6 +361,7 @@ func Test_fsmS_lookupAgentHost(t *testing.T) {
 					res <- true
 				},
 			}),
+		maxRetries:  maximumRetries,
 		retriesLeft: maximumRetries,
 		expDelayFunc: func(retryNumber int) time.Duration {
 			return 0
@@ -262,12 +392,9 @@ func Test_fsmS_agentConnectionReestablished(t *testing.T) {
 		}
 	}`
 
-	sensor = &sensorS{
+	s := &sensorS{
 		options: DefaultOptions(),
 	}
-	defer func() {
-		sensor = nil
-	}()
 
 	server := getTestServer(func(w http.ResponseWriter, r *http.Request) {
 		p := r.URL.Path
@@ -295,8 +422,10 @@ func Test_fsmS_agentConnectionReestablished(t *testing.T) {
 	res := make(chan bool)
 
 	r := &fsmS{
+		sensor:                     s,
 		agentComm:                  newAgentCommunicator(u.Hostname(), u.Port(), &fromS{EntityID: "12345"}, defaultLogger),
 		lookupAgentHostRetryPeriod: 0,
+		maxRetries:                 maximumRetries,
 		retriesLeft:                maximumRetries,
 		expDelayFunc: func(retryNumber int) time.Duration {
 			return 0
@@ -345,3 +474,258 @@ func Test_fsmS_agentConnectionReestablished(t *testing.T) {
 	assert.True(t, <-res)
 	assert.Equal(t, os.Getenv("INSTANA_AGENT_HOST"), r.agentComm.host, "Configured host to be updated with env var value")
 }
+
+func Test_fsmS_pollAgentConfig(t *testing.T) {
+	s := &sensorS{
+		options: DefaultOptions(),
+		logger:  defaultLogger,
+	}
+
+	requested := make(chan struct{}, 1)
+	server := getTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/com.instana.plugin.golang/config.12345" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		io.WriteString(w, `{"tracing": {"extra-http-headers": ["x-request-id"], "disable": [{"kafka": true}]}}`)
+
+		select {
+		case requested <- struct{}{}:
+		default:
+		}
+	})
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+
+	r := &fsmS{
+		sensor:    s,
+		agentComm: newAgentCommunicator(u.Hostname(), u.Port(), &fromS{EntityID: "12345"}, defaultLogger),
+		fsm: f.NewFSM(
+			"ready",
+			f.Events{},
+			f.Callbacks{}),
+		logger:           defaultLogger,
+		configPollPeriod: 10 * time.Millisecond,
+	}
+
+	r.ready(context.Background(), &f.Event{})
+
+	select {
+	case <-requested:
+	case <-time.After(5 * time.Second):
+		t.Fatal("agent configuration has not been requested")
+	}
+
+	assert.Eventually(t, func() bool {
+		opts := s.tracerOptions()
+
+		return len(opts.DisabledInstrumentations) == 1 && len(opts.CollectableHTTPHeaders) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	opts := s.tracerOptions()
+	assert.Equal(t, []string{"kafka"}, opts.DisabledInstrumentations)
+	assert.Equal(t, []string{"x-request-id"}, opts.CollectableHTTPHeaders)
+
+	// the polling is stopped once the fsm leaves the ready state
+	r.leaveReady(context.Background(), &f.Event{})
+	r.pollers.Wait()
+
+	select {
+	case <-requested:
+	default:
+	}
+
+	select {
+	case <-requested:
+		t.Fatal("agent configuration has been requested after leaving the ready state")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func Test_fsmS_shutdown(t *testing.T) {
+	// the configuration is applied to the sensor the fsm belongs to, not to the global one
+	sensor = &sensorS{
+		options: DefaultOptions(),
+		logger:  defaultLogger,
+	}
+	defer func() {
+		sensor = nil
+	}()
+
+	s := &sensorS{
+		options: DefaultOptions(),
+		logger:  defaultLogger,
+	}
+
+	var configRequested int32
+	server := getTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/com.instana.plugin.golang/config.12345" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		atomic.AddInt32(&configRequested, 1)
+		io.WriteString(w, `{"tracing": {"extra-http-headers": ["x-request-id"]}}`)
+	})
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	r := &fsmS{
+		sensor:    s,
+		agentComm: newAgentCommunicator(u.Hostname(), u.Port(), &fromS{EntityID: "12345"}, defaultLogger),
+		fsm: f.NewFSM(
+			"ready",
+			f.Events{},
+			f.Callbacks{}),
+		logger:           defaultLogger,
+		configPollPeriod: 10 * time.Millisecond,
+	}
+
+	r.ready(context.Background(), &f.Event{})
+
+	assert.Eventually(t, func() bool {
+		return len(s.tracerOptions().CollectableHTTPHeaders) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Empty(t, sensor.tracerOptions().CollectableHTTPHeaders)
+
+	// the pollers are stopped once shutdown returns and not started again
+	r.shutdown()
+	r.ready(context.Background(), &f.Event{})
+
+	requested := atomic.LoadInt32(&configRequested)
+	time.Sleep(100 * time.Millisecond)
+
+	assert.Equal(t, requested, atomic.LoadInt32(&configRequested))
+}
+
+func Test_fsmS_pollAgentActions(t *testing.T) {
+	RegisterAgentAction("test.echo", func(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+		return args["message"], nil
+	})
+	defer RegisterAgentAction("test.echo", nil)
+
+	var actionsRequested int32
+
+	results := make(chan agentActionResult, 2)
+	server := getTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/com.instana.plugin.golang/actions.12345":
+			if atomic.AddInt32(&actionsRequested, 1) > 1 {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			io.WriteString(w, `[{"id":"1","action":"test.echo","args":{"message":"hello"}},{"id":"2","action":"test.unknown"}]`)
+		case "/com.instana.plugin.golang/action-results.12345":
+			var res agentActionResult
+			if err := json.NewDecoder(r.Body).Decode(&res); err == nil {
+				results <- res
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	r := &fsmS{
+		agentComm: newAgentCommunicator(u.Hostname(), u.Port(), &fromS{EntityID: "12345"}, defaultLogger),
+		fsm: f.NewFSM(
+			"ready",
+			f.Events{},
+			f.Callbacks{}),
+		logger:            defaultLogger,
+		actionsPollPeriod: 10 * time.Millisecond,
+	}
+
+	r.ready(context.Background(), &f.Event{})
+
+	received := make(map[string]agentActionResult)
+	for i := 0; i < 2; i++ {
+		select {
+		case res := <-results:
+			received[res.ID] = res
+		case <-time.After(5 * time.Second):
+			t.Fatal("action results have not been received")
+		}
+	}
+
+	assert.Equal(t, agentActionResult{ID: "1", Action: "test.echo", Data: "hello"}, received["1"])
+	assert.Equal(t, agentActionResult{ID: "2", Action: "test.unknown", Error: `unknown action "test.unknown"`}, received["2"])
+
+	// the actions are not polled anymore once the sensor is shut down
+	r.shutdown()
+
+	requested := atomic.LoadInt32(&actionsRequested)
+	time.Sleep(100 * time.Millisecond)
+
+	assert.Equal(t, requested, atomic.LoadInt32(&actionsRequested))
+}
+
+func Test_fsmS_pollAgentActions_TooManyActions(t *testing.T) {
+	// occupy all action slots
+	for i := 0; i < maxConcurrentAgentActions; i++ {
+		agentActionSlots <- struct{}{}
+	}
+	defer func() {
+		for i := 0; i < maxConcurrentAgentActions; i++ {
+			<-agentActionSlots
+		}
+	}()
+
+	var actionsRequested int32
+
+	results := make(chan agentActionResult, 1)
+	server := getTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/com.instana.plugin.golang/actions.12345":
+			if atomic.AddInt32(&actionsRequested, 1) > 1 {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			io.WriteString(w, `[{"id":"1","action":"goroutines"}]`)
+		case "/com.instana.plugin.golang/action-results.12345":
+			var res agentActionResult
+			if err := json.NewDecoder(r.Body).Decode(&res); err == nil {
+				results <- res
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	r := &fsmS{
+		agentComm: newAgentCommunicator(u.Hostname(), u.Port(), &fromS{EntityID: "12345"}, defaultLogger),
+		fsm: f.NewFSM(
+			"ready",
+			f.Events{},
+			f.Callbacks{}),
+		logger:            defaultLogger,
+		actionsPollPeriod: 10 * time.Millisecond,
+	}
+
+	r.ready(context.Background(), &f.Event{})
+	defer func() {
+		r.leaveReady(context.Background(), &f.Event{})
+		r.pollers.Wait()
+	}()
+
+	select {
+	case res := <-results:
+		assert.Equal(t, agentActionResult{ID: "1", Action: "goroutines", Error: errTooManyAgentActions.Error()}, res)
+	case <-time.After(5 * time.Second):
+		t.Fatal("action result has not been received")
+	}
+}
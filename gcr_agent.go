@@ -4,17 +4,10 @@
 package instana
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"fmt"
-	"io"
-	"io/ioutil"
 	"net/http"
 	"os"
-	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/instana/go-sensor/acceptor"
@@ -76,22 +69,18 @@ func newGCRServiceRevisionInstancePluginPayload(snapshot gcrSnapshot) acceptor.P
 }
 
 type gcrAgent struct {
-	Endpoint string
-	Key      string
-	PID      int
-	Zone     string
-	Tags     map[string]interface{}
+	PID  int
+	Zone string
+	Tags map[string]interface{}
 
 	snapshot         gcrSnapshot
 	lastProcessStats processStats
 
-	mu        sync.Mutex
-	spanQueue []Span
+	transport *serverlessTransport
 
 	runtimeSnapshot *SnapshotCollector
 	processStats    *processStatsCollector
 	gcr             *gcloud.ComputeMetadataProvider
-	client          *http.Client
 	logger          LeveledLogger
 }
 
@@ -104,10 +93,6 @@ func newGCRAgent(
 		logger = defaultLogger
 	}
 
-	if client == nil {
-		client = http.DefaultClient
-	}
-
 	logger.Debug("initializing google cloud run agent")
 
 	// allow overriding the metadata URL endpoint for testing purposes
@@ -117,11 +102,9 @@ func newGCRAgent(
 	}
 
 	agent := &gcrAgent{
-		Endpoint: acceptorEndpoint,
-		Key:      agentKey,
-		PID:      os.Getpid(),
-		Zone:     os.Getenv("INSTANA_ZONE"),
-		Tags:     parseInstanaTags(os.Getenv("INSTANA_TAGS")),
+		PID:  os.Getpid(),
+		Zone: os.Getenv("INSTANA_ZONE"),
+		Tags: parseInstanaTags(os.Getenv("INSTANA_TAGS")),
 		runtimeSnapshot: &SnapshotCollector{
 			CollectionInterval: snapshotCollectionInterval,
 			ServiceName:        serviceName,
@@ -129,9 +112,9 @@ func newGCRAgent(
 		processStats: &processStatsCollector{
 			logger: logger,
 		},
-		gcr:    gcloud.NewComputeMetadataProvider(mdURL, client),
-		client: client,
-		logger: logger,
+		gcr:       gcloud.NewComputeMetadataProvider(mdURL, client),
+		transport: newServerlessTransport(acceptorEndpoint, agentKey, client, logger),
+		logger:    logger,
 	}
 
 	go func() {
@@ -183,41 +166,19 @@ func (a *gcrAgent) SendMetrics(data acceptor.Metrics) (err error) {
 		},
 	}
 
-	a.mu.Lock()
-	if len(a.spanQueue) > 0 {
-		payload.Spans = make([]Span, len(a.spanQueue))
-		copy(payload.Spans, a.spanQueue)
-		a.spanQueue = a.spanQueue[:0]
-	}
-	a.mu.Unlock()
-
-	buf := bytes.NewBuffer(nil)
-	if err := json.NewEncoder(buf).Encode(payload); err != nil {
-		return fmt.Errorf("failed to marshal metrics payload: %s", err)
-	}
-
-	req, err := http.NewRequest(http.MethodPost, a.Endpoint+"/bundle", buf)
-	if err != nil {
-		return fmt.Errorf("failed to prepare send metrics request: %s", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	return a.sendRequest(req)
+	return a.transport.SendBundle(
+		context.Background(),
+		a.snapshot.Service.Host,
+		newServerlessAgentFromS(a.snapshot.Service.EntityID, "gcp"),
+		payload.Metrics.Plugins,
+	)
 }
 
 func (a *gcrAgent) SendEvent(event *EventData) error { return nil }
 
 func (a *gcrAgent) SendSpans(spans []Span) error {
-	from := newServerlessAgentFromS(a.snapshot.Service.EntityID, "gcp")
-	for i := range spans {
-		spans[i].From = from
-	}
-
 	// enqueue the spans to send them in a bundle with metrics instead of sending immediately
-	a.mu.Lock()
-	a.spanQueue = append(a.spanQueue, spans...)
-	a.mu.Unlock()
+	a.transport.Enqueue(spans)
 
 	return nil
 }
@@ -225,7 +186,7 @@ func (a *gcrAgent) SendSpans(spans []Span) error {
 func (a *gcrAgent) SendProfiles(profiles []autoprofile.Profile) error { return nil }
 
 func (a *gcrAgent) Flush(ctx context.Context) error {
-	if len(a.spanQueue) == 0 {
+	if a.transport.QueueLen() == 0 {
 		return nil
 	}
 
@@ -233,51 +194,7 @@ func (a *gcrAgent) Flush(ctx context.Context) error {
 		return ErrAgentNotReady
 	}
 
-	a.mu.Lock()
-	defer a.mu.Unlock()
-
-	buf := bytes.NewBuffer(nil)
-	if err := json.NewEncoder(buf).Encode(a.spanQueue); err != nil {
-		return fmt.Errorf("failed to marshal traces payload: %s", err)
-	}
-	a.spanQueue = a.spanQueue[:0]
-
-	req, err := http.NewRequest(http.MethodPost, a.Endpoint+"/traces", buf)
-	if err != nil {
-		return fmt.Errorf("failed to prepare send traces request: %s", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	return a.sendRequest(req.WithContext(ctx))
-}
-
-func (a *gcrAgent) sendRequest(req *http.Request) error {
-	req.Header.Set("X-Instana-Host", a.snapshot.Service.Host)
-	req.Header.Set("X-Instana-Key", a.Key)
-	req.Header.Set("X-Instana-Time", strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10))
-
-	resp, err := a.client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send request to the serverless agent: %s", err)
-	}
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= http.StatusBadRequest {
-		respBody, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			a.logger.Debug("failed to read serverless agent response: ", err)
-			return nil
-		}
-
-		a.logger.Info("serverless agent has responded with ", resp.Status, ": ", string(respBody))
-		return nil
-	}
-
-	io.CopyN(ioutil.Discard, resp.Body, 1<<20)
-
-	return nil
+	return a.transport.SendSpans(ctx, a.snapshot.Service.Host, newServerlessAgentFromS(a.snapshot.Service.EntityID, "gcp"))
 }
 
 func (a *gcrAgent) collectSnapshot(ctx context.Context) (gcrSnapshot, bool) {
@@ -134,6 +134,47 @@ func (c AmqpChannel) consumeMessage(pipeCh chan amqp.Delivery, deliveryData amqp
 	pipeCh <- deliveryData
 }
 
+// TraceDeliveries creates and returns an entry span for a batch of deliveries from the queue processed at once.
+// The span continues the trace of the first traced delivery in the batch, while the rest of them are referenced
+// as span links. The context of this span is injected into the headers of each delivery.
+//
+// The caller is responsible for finishing the returned span once the batch is processed.
+func (c AmqpChannel) TraceDeliveries(deliveries []amqp.Delivery, queue string) ot.Span {
+	var exchange string
+	if len(deliveries) > 0 {
+		exchange = deliveries[0].Exchange
+	}
+
+	opts := []ot.StartSpanOption{
+		ext.SpanKindConsumer,
+		ot.Tags{
+			"rabbitmq.exchange": exchange,
+			"rabbitmq.key":      queue,
+			"rabbitmq.sort":     consume,
+			"rabbitmq.address":  c.url,
+		},
+		instana.BatchSize(len(deliveries)),
+	}
+
+	for _, d := range deliveries {
+		if sc, ok := SpanContextFromConsumerMessage(d, c.sensor); ok {
+			opts = append(opts, ot.FollowsFrom(sc))
+		}
+	}
+
+	logger := c.sensor.Logger()
+	tracer := c.sensor.Tracer()
+
+	sp := tracer.StartSpan(operation, opts...)
+	for _, d := range deliveries {
+		if err := tracer.Inject(sp.Context(), ot.TextMap, &messageCarrier{d.Headers, logger}); err != nil {
+			logger.Debug(err)
+		}
+	}
+
+	return sp
+}
+
 // WrapChannel returns the AmqpChannel, which is Instana's wrapper around amqp.Channel
 func WrapChannel(sensor instana.TracerLogger, ch PubCons, serverUrl string) *AmqpChannel {
 	sUrl := ""
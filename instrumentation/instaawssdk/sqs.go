@@ -81,31 +81,76 @@ func TraceSQSMessage(msg *sqs.Message, sensor instana.TracerLogger) opentracing.
 		},
 	}
 
-	if spCtx, ok := SpanContextFromSQSMessage(msg, sensor); ok {
+	if spCtx, ok := sqsMessageSpanContext(msg, sensor); ok {
 		opts = append(opts, opentracing.ChildOf(spCtx))
-	} else {
-		body := aws.StringValue(msg.Body)
-
-		// In case the delivery has been created via a subscription to an SNS topic,
-		// the message body will be a JSON document containing the SNS notification
-		// along with message attributes.
-		var payload struct {
-			MessageAttributes snsMessageAttributes `json:"MessageAttributes"`
-		}
+	}
+
+	sp := sensor.Tracer().StartSpan("sqs", opts...)
+	injectSQSMessageSpanContext(sp, msg)
+
+	return sp
+}
+
+// TraceSQSMessages creates and returns an entry span for a batch of SQS messages processed at once. The span
+// continues the trace of the first traced message in the batch, while the rest of them are referenced as span
+// links. The context of this span is injected into the attributes of each message and can be retrieved with
+// instaawssdk.SpanContextFromSQSMessage().
+func TraceSQSMessages(msgs []*sqs.Message, sensor instana.TracerLogger) opentracing.Span {
+	opts := []opentracing.StartSpanOption{
+		ext.SpanKindConsumer,
+		opentracing.Tags{
+			sqsSort: "entry",
+			sqsSize: len(msgs),
+		},
+	}
 
-		// try to unmarshal the message attributes and extract the trace context from there
-		if err := json.Unmarshal([]byte(body), &payload); err == nil {
-			if spCtx, err := sensor.Tracer().Extract(
-				opentracing.TextMap,
-				SNSMessageAttributesCarrier(map[string]*sns.MessageAttributeValue(payload.MessageAttributes)),
-			); err == nil {
-				opts = append(opts, opentracing.ChildOf(spCtx))
-			}
+	for _, msg := range msgs {
+		if spCtx, ok := sqsMessageSpanContext(msg, sensor); ok {
+			opts = append(opts, opentracing.FollowsFrom(spCtx))
 		}
 	}
 
 	sp := sensor.Tracer().StartSpan("sqs", opts...)
+	for _, msg := range msgs {
+		injectSQSMessageSpanContext(sp, msg)
+	}
+
+	return sp
+}
+
+// sqsMessageSpanContext extracts the trace context either from the SQS message attributes or, if the message
+// has been delivered via a subscription to an SNS topic, from the SNS notification attributes
+func sqsMessageSpanContext(msg *sqs.Message, sensor instana.TracerLogger) (opentracing.SpanContext, bool) {
+	if spCtx, ok := SpanContextFromSQSMessage(msg, sensor); ok {
+		return spCtx, true
+	}
+
+	body := aws.StringValue(msg.Body)
+
+	// In case the delivery has been created via a subscription to an SNS topic,
+	// the message body will be a JSON document containing the SNS notification
+	// along with message attributes.
+	var payload struct {
+		MessageAttributes snsMessageAttributes `json:"MessageAttributes"`
+	}
+
+	// try to unmarshal the message attributes and extract the trace context from there
+	if err := json.Unmarshal([]byte(body), &payload); err != nil {
+		return nil, false
+	}
 
+	spCtx, err := sensor.Tracer().Extract(
+		opentracing.TextMap,
+		SNSMessageAttributesCarrier(map[string]*sns.MessageAttributeValue(payload.MessageAttributes)),
+	)
+	if err != nil {
+		return nil, false
+	}
+
+	return spCtx, true
+}
+
+func injectSQSMessageSpanContext(sp opentracing.Span, msg *sqs.Message) {
 	if msg.MessageAttributes == nil {
 		msg.MessageAttributes = make(map[string]*sqs.MessageAttributeValue)
 	}
@@ -115,6 +160,4 @@ func TraceSQSMessage(msg *sqs.Message, sensor instana.TracerLogger) opentracing.
 		opentracing.TextMap,
 		SQSMessageAttributesCarrier(msg.MessageAttributes),
 	)
-
-	return sp
 }
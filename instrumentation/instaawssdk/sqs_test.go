@@ -592,3 +592,62 @@ func TestTraceSQSMessage_NoTraceContext(t *testing.T) {
 		Sort: "entry",
 	}, data.Tags)
 }
+
+func TestTraceSQSMessages(t *testing.T) {
+	recorder := instana.NewTestRecorder()
+	sensor := instana.NewSensorWithTracer(
+		instana.NewTracerWithEverything(&instana.Options{AgentClient: alwaysReadyClient{}}, recorder),
+	)
+	defer instana.ShutdownSensor()
+
+	msgs := []*sqs.Message{
+		{Body: aws.String("message without trace context")},
+		{
+			Body: aws.String("message body"),
+			MessageAttributes: map[string]*sqs.MessageAttributeValue{
+				"X_INSTANA_T": {DataType: aws.String("String"), StringValue: aws.String("00000000000000010000000000000002")},
+				"X_INSTANA_S": {DataType: aws.String("String"), StringValue: aws.String("0000000000000003")},
+				"X_INSTANA_L": {DataType: aws.String("String"), StringValue: aws.String("1")},
+			},
+		},
+		{
+			Body: aws.String("message body"),
+			MessageAttributes: map[string]*sqs.MessageAttributeValue{
+				"X_INSTANA_T": {DataType: aws.String("String"), StringValue: aws.String("0000000000000004")},
+				"X_INSTANA_S": {DataType: aws.String("String"), StringValue: aws.String("0000000000000005")},
+				"X_INSTANA_L": {DataType: aws.String("String"), StringValue: aws.String("1")},
+			},
+		},
+	}
+
+	sp := instaawssdk.TraceSQSMessages(msgs, sensor)
+	require.Equal(t, 0, recorder.QueuedSpansCount())
+
+	sp.Finish()
+
+	spans := recorder.GetQueuedSpans()
+	require.Len(t, spans, 1)
+
+	sqsSpan := spans[0]
+
+	assert.EqualValues(t, 0x1, sqsSpan.TraceIDHi)
+	assert.EqualValues(t, 0x2, sqsSpan.TraceID)
+	assert.EqualValues(t, 0x3, sqsSpan.ParentID)
+
+	assert.Equal(t, "sqs", sqsSpan.Name)
+	assert.Equal(t, int(instana.EntrySpanKind), sqsSpan.Kind)
+
+	data := sqsSpan.Data.(instana.AWSSQSSpanData)
+	assert.Equal(t, instana.AWSSQSSpanTags{
+		Sort: "entry",
+		Size: 3,
+	}, data.Tags)
+
+	// the batch span context is injected into each message
+	for _, msg := range msgs {
+		spCtx, ok := instaawssdk.SpanContextFromSQSMessage(msg, sensor)
+		require.True(t, ok)
+
+		assert.Equal(t, sqsSpan.SpanID, spCtx.(instana.SpanContext).SpanID)
+	}
+}
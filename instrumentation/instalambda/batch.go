@@ -0,0 +1,135 @@
+// (c) Copyright IBM Corp. 2023
+
+package instalambda
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/opentracing/opentracing-go"
+	otlog "github.com/opentracing/opentracing-go/log"
+)
+
+var errBatchItemFailed = errors.New("batch item failure")
+
+// batchItemFailuresResponse is the partial batch response returned by SQS and Kinesis handlers
+// to report the records that failed to be processed
+type batchItemFailuresResponse struct {
+	BatchItemFailures []struct {
+		ItemIdentifier string `json:"itemIdentifier"`
+	} `json:"batchItemFailures"`
+}
+
+type recordSpansContextKey struct{}
+
+// RecordSpanFromContext returns the span created for an SQS message or a Kinesis record with given ID, i.e. the
+// SQS message ID or the Kinesis record sequence number. The record spans are only available within a handler
+// created with instalambda.WithRecordSpans() option.
+func RecordSpanFromContext(ctx context.Context, recordID string) (opentracing.Span, bool) {
+	batch, ok := ctx.Value(recordSpansContextKey{}).(*recordBatch)
+	if !ok {
+		return nil, false
+	}
+
+	sp, ok := batch.spans[recordID]
+
+	return sp, ok
+}
+
+// recordBatch holds the spans created for each record of an SQS or a Kinesis batch
+type recordBatch struct {
+	ids   []string
+	spans map[string]opentracing.Span
+	once  sync.Once
+}
+
+// startRecordSpans starts a span for each record of an SQS or a Kinesis event. It returns nil for other event types.
+func (h *wrappedHandler) startRecordSpans(parent opentracing.Span, payload []byte) *recordBatch {
+	batch := &recordBatch{spans: make(map[string]opentracing.Span)}
+
+	switch detectTriggerEventType(payload) {
+	case sqsEventType:
+		var v events.SQSEvent
+		if err := json.Unmarshal(payload, &v); err != nil {
+			return nil
+		}
+
+		for _, rec := range v.Records {
+			// the record span continues the trace propagated with the message and references the entry span,
+			// otherwise it becomes a child of the entry span
+			opts := []opentracing.StartSpanOption{opentracing.ChildOf(parent.Context())}
+			if recCtx, ok := h.extractParentContext(sqsMessageTraceHeaders(rec)); ok {
+				opts = []opentracing.StartSpanOption{opentracing.ChildOf(recCtx), opentracing.FollowsFrom(parent.Context())}
+			}
+
+			batch.start(h, rec.MessageId, rec.EventSourceARN, opts)
+		}
+	case kinesisEventType:
+		var v events.KinesisEvent
+		if err := json.Unmarshal(payload, &v); err != nil {
+			return nil
+		}
+
+		// Kinesis records carry no metadata besides the partition key and an opaque data blob, so there is no trace
+		// context to be extracted and the record spans are always children of the entry span
+		for _, rec := range v.Records {
+			batch.start(h, rec.Kinesis.SequenceNumber, rec.EventSourceArn, []opentracing.StartSpanOption{
+				opentracing.ChildOf(parent.Context()),
+			})
+		}
+	default:
+		return nil
+	}
+
+	return batch
+}
+
+func (b *recordBatch) start(h *wrappedHandler, id, source string, opts []opentracing.StartSpanOption) {
+	opts = append(opts, opentracing.Tags{
+		lambdaRecordID:     id,
+		lambdaRecordSource: source,
+	})
+
+	b.ids = append(b.ids, id)
+	b.spans[id] = h.sensor.Tracer().StartSpan("aws.lambda.record", opts...)
+}
+
+// Finish marks the records reported in the partial batch response as failed, or all of them if the handler
+// has returned an error, finishes the record spans and tags the entry span with the batch summary
+func (b *recordBatch) Finish(entrySpan opentracing.Span, resp []byte, err error) {
+	b.once.Do(func() {
+		failed := make(map[string]bool)
+
+		if err == nil {
+			var v batchItemFailuresResponse
+			// the response is not necessarily a partial batch response, so the error is ignored
+			json.Unmarshal(resp, &v)
+
+			for _, item := range v.BatchItemFailures {
+				failed[item.ItemIdentifier] = true
+			}
+		}
+
+		var numFailed int
+		for _, id := range b.ids {
+			sp := b.spans[id]
+
+			switch {
+			case err != nil:
+				sp.LogFields(otlog.Error(err))
+				numFailed++
+			case failed[id]:
+				sp.LogFields(otlog.Error(errBatchItemFailed))
+				numFailed++
+			}
+
+			sp.Finish()
+		}
+
+		entrySpan.SetTag(lambdaBatchRecords, len(b.ids))
+		entrySpan.SetTag(lambdaBatchFailed, numFailed)
+	})
+}
@@ -36,19 +36,40 @@ type wrappedHandler struct {
 
 	sensor      instana.TracerLogger
 	onColdStart sync.Once
+	recordSpans bool
+}
+
+// Option configures an instrumented handler
+type Option func(*wrappedHandler)
+
+// WithRecordSpans makes the instrumented handler create a span for each record of an SQS or a Kinesis
+// batch as a child of the invocation entry span. The record spans are linked to the trace context propagated
+// via SQS message attributes, and can be retrieved within the handler using instalambda.RecordSpanFromContext().
+// The records reported as failed in the partial batch response, i.e. events.SQSEventResponse, are marked as
+// erroneous, while the entry span is tagged with the number of processed and failed records.
+func WithRecordSpans() Option {
+	return func(h *wrappedHandler) {
+		h.recordSpans = true
+	}
 }
 
 // NewHandler creates a new instrumented handler that can be used with `lambda.StartHandler()` from a handler function
-func NewHandler(handlerFunc interface{}, sensor instana.TracerLogger) *wrappedHandler {
-	return WrapHandler(lambda.NewHandler(handlerFunc), sensor)
+func NewHandler(handlerFunc interface{}, sensor instana.TracerLogger, opts ...Option) *wrappedHandler {
+	return WrapHandler(lambda.NewHandler(handlerFunc), sensor, opts...)
 }
 
 // WrapHandler instruments a lambda.Handler to trace the invokations with Instana
-func WrapHandler(h lambda.Handler, sensor instana.TracerLogger) *wrappedHandler {
-	return &wrappedHandler{
+func WrapHandler(h lambda.Handler, sensor instana.TracerLogger, opts ...Option) *wrappedHandler {
+	wh := &wrappedHandler{
 		Handler: h,
 		sensor:  sensor,
 	}
+
+	for _, opt := range opts {
+		opt(wh)
+	}
+
+	return wh
 }
 
 // Invoke is a handler function for a wrapped handler
@@ -69,6 +90,11 @@ func (h *wrappedHandler) Invoke(ctx context.Context, payload []byte) ([]byte, er
 		sp.SetTag(lambdaColdStart, true)
 	})
 
+	var batch *recordBatch
+	if h.recordSpans {
+		batch = h.startRecordSpans(sp, payload)
+	}
+
 	// Here we create a separate context.Context to finalize and send the span. This context
 	// supposed to be canceled once the wrapped handler is done.
 	traceCtx, cancelTraceCtx := context.WithCancel(ctx)
@@ -95,17 +121,30 @@ func (h *wrappedHandler) Invoke(ctx context.Context, payload []byte) ([]byte, er
 
 			sp.SetTag(lambdaMsLeft, int64(remainingTime)/1e6) // cast time.Duration to int64 for compatibility with older Go versions
 			sp.LogFields(otlog.Error(errHandlerTimedOut))
+
+			if batch != nil {
+				batch.Finish(sp, nil, errHandlerTimedOut)
+			}
 		}
 
 		sp.Finish()
 		h.flushAgent(awsLambdaFlushRetryPeriod, awsLambdaFlushMaxRetries)
 	}()
 
-	resp, err := h.Handler.Invoke(instana.ContextWithSpan(ctx, sp), payload)
+	handlerCtx := instana.ContextWithSpan(ctx, sp)
+	if batch != nil {
+		handlerCtx = context.WithValue(handlerCtx, recordSpansContextKey{}, batch)
+	}
+
+	resp, err := h.Handler.Invoke(handlerCtx, payload)
 	if err != nil {
 		sp.LogFields(otlog.Error(err))
 	}
 
+	if batch != nil {
+		batch.Finish(sp, resp, err)
+	}
+
 	cancelTraceCtx()
 
 	// ensure that span has been finished and sent to the agent before quit
@@ -163,6 +202,19 @@ func (h *wrappedHandler) triggerEventSpanOptions(payload []byte, lcc lambdaconte
 			opts = append(opts, opentracing.ChildOf(parentCtx))
 		}
 
+		return opts
+	case functionURLEventType:
+		var v events.APIGatewayV2HTTPRequest
+		if err := json.Unmarshal(payload, &v); err != nil {
+			h.sensor.Logger().Warn("failed to unmarshal Lambda Function URL event payload: ", err)
+			return []opentracing.StartSpanOption{opentracing.Tags{}}
+		}
+
+		opts := []opentracing.StartSpanOption{h.extractFunctionURLTriggerTags(v)}
+		if parentCtx, ok := h.extractParentContext(v.Headers); ok {
+			opts = append(opts, opentracing.ChildOf(parentCtx))
+		}
+
 		return opts
 	case albEventType:
 		var v events.ALBTargetGroupRequest
@@ -185,6 +237,14 @@ func (h *wrappedHandler) triggerEventSpanOptions(payload []byte, lcc lambdaconte
 		}
 
 		return []opentracing.StartSpanOption{h.extractCloudWatchTriggerTags(v)}
+	case eventBridgeEventType:
+		var v events.CloudWatchEvent
+		if err := json.Unmarshal(payload, &v); err != nil {
+			h.sensor.Logger().Warn("failed to unmarshal EventBridge event payload: ", err)
+			return []opentracing.StartSpanOption{opentracing.Tags{}}
+		}
+
+		return []opentracing.StartSpanOption{h.extractEventBridgeTriggerTags(v)}
 	case cloudWatchLogsEventType:
 		var v events.CloudwatchLogsEvent
 		if err := json.Unmarshal(payload, &v); err != nil {
@@ -208,7 +268,66 @@ func (h *wrappedHandler) triggerEventSpanOptions(payload []byte, lcc lambdaconte
 			return []opentracing.StartSpanOption{opentracing.Tags{}}
 		}
 
-		return []opentracing.StartSpanOption{h.extractSQSTriggerTags(v)}
+		opts := []opentracing.StartSpanOption{h.extractSQSTriggerTags(v)}
+		if len(v.Records) > 0 {
+			if parentCtx, ok := h.extractParentContext(sqsMessageTraceHeaders(v.Records[0])); ok {
+				opts = append(opts, opentracing.ChildOf(parentCtx))
+			}
+		}
+
+		return opts
+	case snsEventType:
+		var v events.SNSEvent
+		if err := json.Unmarshal(payload, &v); err != nil {
+			h.sensor.Logger().Warn("failed to unmarshal SNS event payload: ", err)
+			return []opentracing.StartSpanOption{opentracing.Tags{}}
+		}
+
+		opts := []opentracing.StartSpanOption{h.extractSNSTriggerTags(v)}
+		if len(v.Records) > 0 {
+			if parentCtx, ok := h.extractParentContext(snsMessageTraceHeaders(v.Records[0].SNS.MessageAttributes)); ok {
+				opts = append(opts, opentracing.ChildOf(parentCtx))
+			}
+		}
+
+		return opts
+	case dynamoDBEventType:
+		var v events.DynamoDBEvent
+		if err := json.Unmarshal(payload, &v); err != nil {
+			h.sensor.Logger().Warn("failed to unmarshal DynamoDB Streams event payload: ", err)
+			return []opentracing.StartSpanOption{opentracing.Tags{}}
+		}
+
+		return []opentracing.StartSpanOption{h.extractDynamoDBTriggerTags(v)}
+	case kinesisEventType:
+		var v events.KinesisEvent
+		if err := json.Unmarshal(payload, &v); err != nil {
+			h.sensor.Logger().Warn("failed to unmarshal Kinesis event payload: ", err)
+			return []opentracing.StartSpanOption{opentracing.Tags{}}
+		}
+
+		return []opentracing.StartSpanOption{h.extractKinesisTriggerTags(v)}
+	case kafkaEventType:
+		var v kafkaEvent
+		if err := json.Unmarshal(payload, &v); err != nil {
+			h.sensor.Logger().Warn("failed to unmarshal Kafka event payload: ", err)
+			return []opentracing.StartSpanOption{opentracing.Tags{}}
+		}
+
+		opts := []opentracing.StartSpanOption{h.extractKafkaTriggerTags(v)}
+		if parentCtx, ok := h.extractParentContext(v.traceHeaders()); ok {
+			opts = append(opts, opentracing.ChildOf(parentCtx))
+		}
+
+		return opts
+	case stepFunctionsEventType:
+		var v stepFunctionsContext
+		if err := json.Unmarshal(payload, &v); err != nil {
+			h.sensor.Logger().Warn("failed to unmarshal Step Functions event payload: ", err)
+			return []opentracing.StartSpanOption{opentracing.Tags{}}
+		}
+
+		return []opentracing.StartSpanOption{h.extractStepFunctionsTriggerTags(v)}
 	case invokeRequestType:
 
 		tags := opentracing.Tags{
@@ -285,6 +404,21 @@ func (h *wrappedHandler) extractAPIGatewayV2TriggerTags(evt events.APIGatewayV2H
 	return tags
 }
 
+func (h *wrappedHandler) extractFunctionURLTriggerTags(evt events.APIGatewayV2HTTPRequest) opentracing.Tags {
+	tags := opentracing.Tags{
+		lambdaTrigger: "aws:lambda.function.url",
+		httpMethod:    evt.RequestContext.HTTP.Method,
+		httpUrl:       evt.RequestContext.HTTP.Path,
+		httpParams:    h.sanitizeHTTPParams(evt.QueryStringParameters, nil).Encode(),
+	}
+
+	if headers := h.collectHTTPHeaders(evt.Headers, nil); len(headers) > 0 {
+		tags[httpHeader] = headers
+	}
+
+	return tags
+}
+
 func (h *wrappedHandler) extractALBTriggerTags(evt events.ALBTargetGroupRequest) opentracing.Tags {
 	tags := opentracing.Tags{
 		lambdaTrigger: "aws:application.load.balancer",
@@ -308,6 +442,16 @@ func (h *wrappedHandler) extractCloudWatchTriggerTags(evt events.CloudWatchEvent
 	}
 }
 
+func (h *wrappedHandler) extractEventBridgeTriggerTags(evt events.CloudWatchEvent) opentracing.Tags {
+	return opentracing.Tags{
+		lambdaTrigger:         "aws:eventbridge",
+		eventbridgeID:         evt.ID,
+		eventbridgeSource:     evt.Source,
+		eventbridgeDetailType: evt.DetailType,
+		eventbridgeResources:  evt.Resources,
+	}
+}
+
 func (h *wrappedHandler) extractCloudWatchLogsTriggerTags(evt events.CloudwatchLogsEvent) opentracing.Tags {
 	logs, err := evt.AWSLogs.Parse()
 	if err != nil {
@@ -360,6 +504,75 @@ func (h *wrappedHandler) extractSQSTriggerTags(evt events.SQSEvent) opentracing.
 	}
 }
 
+func (h *wrappedHandler) extractSNSTriggerTags(evt events.SNSEvent) opentracing.Tags {
+	tags := opentracing.Tags{
+		lambdaTrigger: "aws:sns",
+	}
+
+	if len(evt.Records) > 0 {
+		msg := evt.Records[0].SNS
+
+		tags[snsTopic] = msg.TopicArn
+		tags[snsMessageID] = msg.MessageID
+		tags[snsSubject] = msg.Subject
+	}
+
+	return tags
+}
+
+func (h *wrappedHandler) extractDynamoDBTriggerTags(evt events.DynamoDBEvent) opentracing.Tags {
+	tags := opentracing.Tags{
+		lambdaTrigger: "aws:dynamodb",
+	}
+
+	var e []string
+	for _, rec := range evt.Records {
+		e = append(e, rec.EventName)
+	}
+
+	if len(evt.Records) > 0 {
+		tags[dynamodbStream] = evt.Records[0].EventSourceArn
+		tags[dynamodbEvents] = e
+	}
+
+	return tags
+}
+
+func (h *wrappedHandler) extractKinesisTriggerTags(evt events.KinesisEvent) opentracing.Tags {
+	tags := opentracing.Tags{
+		lambdaTrigger: "aws:kinesis",
+	}
+
+	if len(evt.Records) > 0 {
+		tags[kinesisStream] = evt.Records[0].EventSourceArn
+		tags[kinesisRecords] = len(evt.Records)
+	}
+
+	return tags
+}
+
+func (h *wrappedHandler) extractKafkaTriggerTags(evt kafkaEvent) opentracing.Tags {
+	source := evt.EventSourceARN
+	if source == "" {
+		source = evt.BootstrapServers
+	}
+
+	return opentracing.Tags{
+		lambdaTrigger: "aws:kafka",
+		kafkaSource:   source,
+		kafkaTopics:   evt.topics(),
+	}
+}
+
+func (h *wrappedHandler) extractStepFunctionsTriggerTags(evt stepFunctionsContext) opentracing.Tags {
+	return opentracing.Tags{
+		lambdaTrigger:             "aws:stepfunctions",
+		stepfunctionsStateMachine: evt.StateMachine.ID,
+		stepfunctionsExecution:    evt.Execution.ID,
+		stepfunctionsState:        evt.State.Name,
+	}
+}
+
 func (h *wrappedHandler) sanitizeHTTPParams(
 	queryStringParams map[string]string,
 	multiValueQueryStringParams map[string][]string,
@@ -0,0 +1,126 @@
+// (c) Copyright IBM Corp. 2023
+
+package instalambda
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	instana "github.com/instana/go-sensor"
+	"github.com/instana/go-sensor/acceptor"
+	"github.com/instana/go-sensor/autoprofile"
+	"github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrappedHandler_triggerEventSpanOptions(t *testing.T) {
+	testCases := map[string]struct {
+		FileName string
+		Expected opentracing.Tags
+	}{
+		"Lambda Function URL": {
+			FileName: "testdata/function_url_event.json",
+			Expected: opentracing.Tags{
+				lambdaTrigger: "aws:lambda.function.url",
+				httpMethod:    "POST",
+				httpUrl:       "/my/path",
+				httpParams:    "q=term&secret=%3Credacted%3E",
+				httpHeader:    map[string]string{"X-Custom-Header-1": "value1"},
+			},
+		},
+		"EventBridge": {
+			FileName: "testdata/eventbridge_event.json",
+			Expected: opentracing.Tags{
+				lambdaTrigger:         "aws:eventbridge",
+				eventbridgeID:         "7bf73129-1428-4cd3-a780-95db273d1602",
+				eventbridgeSource:     "com.example.orders",
+				eventbridgeDetailType: "Order Created",
+				eventbridgeResources:  []string{"arn:aws:dynamodb:us-east-2:123456789012:table/orders"},
+			},
+		},
+		"SNS": {
+			FileName: "testdata/sns_event.json",
+			Expected: opentracing.Tags{
+				lambdaTrigger: "aws:sns",
+				snsTopic:      "arn:aws:sns:us-east-2:123456789012:sns-lambda",
+				snsMessageID:  "95df01b4-ee98-5cb9-9903-4c221d41eb5e",
+				snsSubject:    "TestInvoke",
+			},
+		},
+		"DynamoDB Streams": {
+			FileName: "testdata/dynamodb_event.json",
+			Expected: opentracing.Tags{
+				lambdaTrigger:  "aws:dynamodb",
+				dynamodbStream: "arn:aws:dynamodb:us-east-2:123456789012:table/my-table/stream/2015-06-27T00:48:05.899",
+				dynamodbEvents: []string{"INSERT", "MODIFY"},
+			},
+		},
+		"Kinesis Data Streams": {
+			FileName: "testdata/kinesis_event.json",
+			Expected: opentracing.Tags{
+				lambdaTrigger:  "aws:kinesis",
+				kinesisStream:  "arn:aws:kinesis:us-east-2:123456789012:stream/lambda-stream",
+				kinesisRecords: 2,
+			},
+		},
+		"Amazon MSK": {
+			FileName: "testdata/msk_event.json",
+			Expected: opentracing.Tags{
+				lambdaTrigger: "aws:kafka",
+				kafkaSource:   "arn:aws:kafka:us-east-2:123456789012:cluster/vpc-2priv-2pub/751d2973-a626-431c-9d4e-d7975eb44dd7-2",
+				kafkaTopics:   []string{"mytopic"},
+			},
+		},
+		"Self-managed Kafka": {
+			FileName: "testdata/self_managed_kafka_event.json",
+			Expected: opentracing.Tags{
+				lambdaTrigger: "aws:kafka",
+				kafkaSource:   "b-2.demo-cluster-1.a1bcde.c1.kafka.us-east-2.amazonaws.com:9092,b-1.demo-cluster-1.a1bcde.c1.kafka.us-east-2.amazonaws.com:9092",
+				kafkaTopics:   []string{"mytopic", "othertopic"},
+			},
+		},
+		"Step Functions context": {
+			FileName: "testdata/stepfunctions_event.json",
+			Expected: opentracing.Tags{
+				lambdaTrigger:             "aws:stepfunctions",
+				stepfunctionsStateMachine: "arn:aws:states:us-east-2:123456789012:stateMachine:my-state-machine",
+				stepfunctionsExecution:    "arn:aws:states:us-east-2:123456789012:execution:my-state-machine:exec-1",
+				stepfunctionsState:        "ProcessOrder",
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			sensor := instana.NewSensorWithTracer(instana.NewTracerWithEverything(&instana.Options{
+				Tracer: instana.TracerOptions{
+					CollectableHTTPHeaders: []string{"X-Custom-Header-1"},
+					Secrets:                instana.DefaultSecretsMatcher(),
+				},
+				AgentClient: alwaysReadyClient{},
+			}, instana.NewTestRecorder()))
+			defer instana.ShutdownSensor()
+
+			payload, err := ioutil.ReadFile(tc.FileName)
+			require.NoError(t, err)
+
+			h := WrapHandler(nil, sensor)
+
+			opts := h.triggerEventSpanOptions(payload, lambdacontext.ClientContext{})
+			require.NotEmpty(t, opts)
+			assert.Equal(t, tc.Expected, opts[0])
+		})
+	}
+}
+
+type alwaysReadyClient struct{}
+
+func (alwaysReadyClient) Ready() bool                                       { return true }
+func (alwaysReadyClient) SendMetrics(data acceptor.Metrics) error           { return nil }
+func (alwaysReadyClient) SendEvent(event *instana.EventData) error          { return nil }
+func (alwaysReadyClient) SendSpans(spans []instana.Span) error              { return nil }
+func (alwaysReadyClient) SendProfiles(profiles []autoprofile.Profile) error { return nil }
+func (alwaysReadyClient) Flush(context.Context) error                       { return nil }
@@ -18,6 +18,7 @@ import (
 	"github.com/aws/aws-lambda-go/lambdacontext"
 	instana "github.com/instana/go-sensor"
 	"github.com/instana/go-sensor/instrumentation/instalambda"
+	"github.com/opentracing/opentracing-go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -514,6 +515,158 @@ func TestNewHandler_SQSEvent(t *testing.T) {
 	}, span.Data)
 }
 
+func TestNewHandler_AdditionalTriggers(t *testing.T) {
+	testCases := map[string]struct {
+		FileName        string
+		ExpectedTrigger string
+		ContinuesTrace  bool
+	}{
+		"Lambda Function URL":    {"testdata/function_url_event.json", "aws:lambda.function.url", true},
+		"EventBridge":            {"testdata/eventbridge_event.json", "aws:eventbridge", false},
+		"SNS":                    {"testdata/sns_event.json", "aws:sns", true},
+		"SQS with attributes":    {"testdata/sqs_event_with_instana_headers.json", "aws:sqs", true},
+		"SQS with SNS message":   {"testdata/sqs_sns_event.json", "aws:sqs", true},
+		"DynamoDB Streams":       {"testdata/dynamodb_event.json", "aws:dynamodb", false},
+		"Kinesis Data Streams":   {"testdata/kinesis_event.json", "aws:kinesis", false},
+		"Amazon MSK":             {"testdata/msk_event.json", "aws:kafka", true},
+		"Self-managed Kafka":     {"testdata/self_managed_kafka_event.json", "aws:kafka", false},
+		"Step Functions context": {"testdata/stepfunctions_event.json", "aws:stepfunctions", false},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			recorder := instana.NewTestRecorder()
+			sensor := instana.NewSensorWithTracer(instana.NewTracerWithEverything(getOptions(), recorder))
+			defer instana.ShutdownSensor()
+
+			payload, err := ioutil.ReadFile(tc.FileName)
+			require.NoError(t, err)
+
+			h := instalambda.NewHandler(func(ctx context.Context, evt interface{}) error {
+				_, ok := instana.SpanFromContext(ctx)
+				assert.True(t, ok)
+
+				return nil
+			}, sensor)
+
+			_, err = h.Invoke(lambdacontext.NewContext(context.Background(), &lambdacontext.LambdaContext{}), payload)
+			require.NoError(t, err)
+
+			spans := recorder.GetQueuedSpans()
+			require.Len(t, spans, 1)
+
+			span := spans[0]
+			require.Equal(t, "aws.lambda.entry", span.Name)
+			require.IsType(t, instana.AWSLambdaSpanData{}, span.Data)
+			assert.Equal(t, tc.ExpectedTrigger, span.Data.(instana.AWSLambdaSpanData).Snapshot.Trigger)
+
+			if tc.ContinuesTrace {
+				assert.EqualValues(t, 0x1234, span.TraceID)
+				assert.EqualValues(t, 0x4567, span.ParentID)
+			} else {
+				assert.Zero(t, span.ParentID)
+			}
+		})
+	}
+}
+
+func TestNewHandler_SQSEvent_WithRecordSpans(t *testing.T) {
+	recorder := instana.NewTestRecorder()
+	sensor := instana.NewSensorWithTracer(instana.NewTracerWithEverything(getOptions(), recorder))
+	defer instana.ShutdownSensor()
+
+	payload, err := ioutil.ReadFile("testdata/sqs_event.json")
+	require.NoError(t, err)
+
+	h := instalambda.NewHandler(func(ctx context.Context, evt *events.SQSEvent) (interface{}, error) {
+		for _, rec := range evt.Records {
+			_, ok := instalambda.RecordSpanFromContext(ctx, rec.MessageId)
+			assert.True(t, ok)
+		}
+
+		return map[string]interface{}{
+			"batchItemFailures": []map[string]string{
+				{"itemIdentifier": "2e1424d4-f796-459a-8184-9c92662be6da"},
+			},
+		}, nil
+	}, sensor, instalambda.WithRecordSpans())
+
+	_, err = h.Invoke(lambdacontext.NewContext(context.Background(), &lambdacontext.LambdaContext{}), payload)
+	require.NoError(t, err)
+
+	entrySpan, recordSpans := splitRecordSpans(t, recorder.GetQueuedSpans())
+	require.Len(t, recordSpans, 2)
+
+	ec := make(map[string]int)
+	for _, span := range recordSpans {
+		assert.Equal(t, entrySpan.TraceID, span.TraceID)
+		assert.Equal(t, entrySpan.SpanID, span.ParentID)
+
+		require.IsType(t, instana.SDKSpanData{}, span.Data)
+		data := span.Data.(instana.SDKSpanData)
+
+		assert.Equal(t, "aws.lambda.record", data.Tags.Name)
+
+		tags := data.Tags.Custom["tags"].(opentracing.Tags)
+		assert.Equal(t, "arn:aws:sqs:us-east-2:123456789012:my-queue", tags["lambda.record.source"])
+
+		ec[tags["lambda.record.id"].(string)] = span.Ec
+	}
+
+	assert.Equal(t, map[string]int{
+		"059f36b4-87a3-44ab-83d2-661975830a7d": 0,
+		"2e1424d4-f796-459a-8184-9c92662be6da": 1,
+	}, ec)
+}
+
+func TestNewHandler_SQSEvent_WithRecordSpans_TraceContext(t *testing.T) {
+	recorder := instana.NewTestRecorder()
+	sensor := instana.NewSensorWithTracer(instana.NewTracerWithEverything(getOptions(), recorder))
+	defer instana.ShutdownSensor()
+
+	payload, err := ioutil.ReadFile("testdata/sqs_event_with_instana_headers.json")
+	require.NoError(t, err)
+
+	h := instalambda.NewHandler(func(ctx context.Context, evt *events.SQSEvent) error {
+		return nil
+	}, sensor, instalambda.WithRecordSpans())
+
+	_, err = h.Invoke(lambdacontext.NewContext(context.Background(), &lambdacontext.LambdaContext{}), payload)
+	require.NoError(t, err)
+
+	entrySpan, recordSpans := splitRecordSpans(t, recorder.GetQueuedSpans())
+	require.Len(t, recordSpans, 1)
+
+	// the record span continues the trace propagated with the message
+	assert.EqualValues(t, 0x1234, recordSpans[0].TraceID)
+	assert.EqualValues(t, 0x4567, recordSpans[0].ParentID)
+	assert.NotEqual(t, entrySpan.SpanID, recordSpans[0].ParentID)
+}
+
+func TestNewHandler_KinesisEvent_WithRecordSpans_HandlerError(t *testing.T) {
+	recorder := instana.NewTestRecorder()
+	sensor := instana.NewSensorWithTracer(instana.NewTracerWithEverything(getOptions(), recorder))
+	defer instana.ShutdownSensor()
+
+	payload, err := ioutil.ReadFile("testdata/kinesis_event.json")
+	require.NoError(t, err)
+
+	h := instalambda.NewHandler(func(ctx context.Context, evt *events.KinesisEvent) error {
+		return errors.New("something went wrong")
+	}, sensor, instalambda.WithRecordSpans())
+
+	_, err = h.Invoke(lambdacontext.NewContext(context.Background(), &lambdacontext.LambdaContext{}), payload)
+	require.Error(t, err)
+
+	entrySpan, recordSpans := splitRecordSpans(t, recorder.GetQueuedSpans())
+	require.Len(t, recordSpans, 2)
+
+	for _, span := range recordSpans {
+		assert.Equal(t, entrySpan.SpanID, span.ParentID)
+		assert.Equal(t, 1, span.Ec)
+	}
+}
+
 func TestNewHandler_PreferInstanaHeadersToW3ContextHeaders(t *testing.T) {
 	testCases := map[string]string{
 		"API_GW_Event":    "testdata/apigw_v2_event_with_instana_headers_and_w3context.json",
@@ -776,6 +929,27 @@ func TestNewHandler_InvokeLambda_WithIncompleteSetOfInstanaHeaders(t *testing.T)
 	}, span.Data)
 }
 
+// splitRecordSpans returns the Lambda entry span and the record spans, skipping the log spans
+func splitRecordSpans(t *testing.T, spans []instana.Span) (instana.Span, []instana.Span) {
+	var (
+		entrySpan   instana.Span
+		recordSpans []instana.Span
+	)
+
+	for _, span := range spans {
+		switch span.Name {
+		case "aws.lambda.entry":
+			entrySpan = span
+		case "sdk":
+			recordSpans = append(recordSpans, span)
+		}
+	}
+
+	require.Equal(t, "aws.lambda.entry", entrySpan.Name)
+
+	return entrySpan, recordSpans
+}
+
 type alwaysReadyClient struct{}
 
 func (alwaysReadyClient) Ready() bool                              { return true }
@@ -0,0 +1,156 @@
+// (c) Copyright IBM Corp. 2023
+
+package instalambda
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	instana "github.com/instana/go-sensor"
+)
+
+// kafkaEvent is an Amazon MSK or a self-managed Kafka event. The events.KafkaEvent
+// does not provide record headers, so a custom type is used instead.
+type kafkaEvent struct {
+	EventSource      string                   `json:"eventSource"`
+	EventSourceARN   string                   `json:"eventSourceArn"`
+	BootstrapServers string                   `json:"bootstrapServers"`
+	Records          map[string][]kafkaRecord `json:"records"`
+}
+
+type kafkaRecord struct {
+	Topic     string              `json:"topic"`
+	Partition int64               `json:"partition"`
+	Offset    int64               `json:"offset"`
+	Headers   []map[string][]byte `json:"headers"`
+}
+
+// topics returns the sorted list of topics the event records were consumed from
+func (evt kafkaEvent) topics() []string {
+	seen := make(map[string]struct{})
+
+	var topics []string
+	for _, records := range evt.Records {
+		for _, rec := range records {
+			if _, ok := seen[rec.Topic]; ok {
+				continue
+			}
+
+			seen[rec.Topic] = struct{}{}
+			topics = append(topics, rec.Topic)
+		}
+	}
+
+	sort.Strings(topics)
+
+	return topics
+}
+
+// traceHeaders returns the headers of the first record in the first topic partition
+func (evt kafkaEvent) traceHeaders() map[string]string {
+	partitions := make([]string, 0, len(evt.Records))
+	for k := range evt.Records {
+		partitions = append(partitions, k)
+	}
+
+	sort.Strings(partitions)
+
+	for _, k := range partitions {
+		if len(evt.Records[k]) == 0 {
+			continue
+		}
+
+		headers := make(map[string]string)
+		for _, hdrs := range evt.Records[k][0].Headers {
+			for name, value := range hdrs {
+				headers[name] = string(value)
+			}
+		}
+
+		return normalizeTraceHeaders(headers)
+	}
+
+	return nil
+}
+
+// stepFunctionsContext is the Step Functions context object passed as a task payload, i.e. with
+// "Payload.$": "$$"
+type stepFunctionsContext struct {
+	Execution struct {
+		ID string `json:"Id"`
+	} `json:"Execution"`
+	State struct {
+		Name string `json:"Name"`
+	} `json:"State"`
+	StateMachine struct {
+		ID string `json:"Id"`
+	} `json:"StateMachine"`
+}
+
+// snsNotification is an SNS message delivered to an SQS queue without raw message delivery enabled
+type snsNotification struct {
+	Type              string                 `json:"Type"`
+	TopicArn          string                 `json:"TopicArn"`
+	MessageAttributes map[string]interface{} `json:"MessageAttributes"`
+}
+
+// sqsMessageTraceHeaders returns the trace context propagated via SQS message attributes. If there are
+// no message attributes, and the message is an SNS notification, the SNS message attributes are used instead.
+func sqsMessageTraceHeaders(msg events.SQSMessage) map[string]string {
+	headers := make(map[string]string)
+	for k, attr := range msg.MessageAttributes {
+		if attr.StringValue != nil {
+			headers[k] = *attr.StringValue
+		}
+	}
+
+	if len(headers) > 0 {
+		return normalizeTraceHeaders(headers)
+	}
+
+	var notification snsNotification
+	if err := json.Unmarshal([]byte(msg.Body), &notification); err != nil || notification.Type != "Notification" {
+		return nil
+	}
+
+	return snsMessageTraceHeaders(notification.MessageAttributes)
+}
+
+// snsMessageTraceHeaders returns the trace context propagated via SNS message attributes
+func snsMessageTraceHeaders(attrs map[string]interface{}) map[string]string {
+	headers := make(map[string]string)
+	for k, attr := range attrs {
+		attr, ok := attr.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if v, ok := attr["Value"].(string); ok {
+			headers[k] = v
+		}
+	}
+
+	return normalizeTraceHeaders(headers)
+}
+
+// normalizeTraceHeaders converts the Instana trace context fields propagated via message attributes or record
+// headers, i.e. X_INSTANA_T, into the HTTP header names recognized by the tracer
+func normalizeTraceHeaders(fields map[string]string) map[string]string {
+	headers := make(map[string]string, len(fields))
+	for k, v := range fields {
+		switch strings.ToUpper(k) {
+		case "X_INSTANA_T":
+			k = instana.FieldT
+		case "X_INSTANA_S":
+			k = instana.FieldS
+		case "X_INSTANA_L", "X_INSTANA_L_S":
+			k = instana.FieldL
+		}
+
+		headers[k] = v
+	}
+
+	return headers
+}
@@ -8,6 +8,10 @@ const lambdaVersion = "lambda.version"
 const lambdaColdStart = "lambda.coldStart"
 const lambdaMsLeft = "lambda.msleft"
 const lambdaTrigger = "lambda.trigger"
+const lambdaBatchRecords = "lambda.batch.records"
+const lambdaBatchFailed = "lambda.batch.failed"
+const lambdaRecordID = "lambda.record.id"
+const lambdaRecordSource = "lambda.record.source"
 
 const httpMethod = "http.method"
 const httpUrl = "http.url"
@@ -24,3 +28,25 @@ const cloudwatchLogsEvents = "cloudwatch.logs.events"
 
 const s3Events = "s3.events"
 const sqsMessages = "sqs.messages"
+
+const dynamodbStream = "dynamodb.stream"
+const dynamodbEvents = "dynamodb.events"
+
+const kinesisStream = "kinesis.stream"
+const kinesisRecords = "kinesis.records"
+
+const snsTopic = "sns.topic"
+const snsMessageID = "sns.messageId"
+const snsSubject = "sns.subject"
+
+const eventbridgeID = "eventbridge.id"
+const eventbridgeSource = "eventbridge.source"
+const eventbridgeDetailType = "eventbridge.detailType"
+const eventbridgeResources = "eventbridge.resources"
+
+const stepfunctionsStateMachine = "stepfunctions.stateMachine"
+const stepfunctionsExecution = "stepfunctions.execution"
+const stepfunctionsState = "stepfunctions.state"
+
+const kafkaSource = "kafka.source"
+const kafkaTopics = "kafka.topics"
@@ -5,6 +5,7 @@ package instalambda
 
 import (
 	"encoding/json"
+	"strings"
 )
 
 type triggerEventType uint8
@@ -13,11 +14,18 @@ const (
 	unknownEventType triggerEventType = iota
 	apiGatewayEventType
 	apiGatewayV2EventType
+	functionURLEventType
 	albEventType
 	cloudWatchEventType
+	eventBridgeEventType
 	cloudWatchLogsEventType
 	s3EventType
 	sqsEventType
+	snsEventType
+	dynamoDBEventType
+	kinesisEventType
+	kafkaEventType
+	stepFunctionsEventType
 	invokeRequestType
 )
 
@@ -27,25 +35,35 @@ func detectTriggerEventType(payload []byte) triggerEventType {
 		Resource   string `json:"resource"`
 		Path       string `json:"path"`
 		HTTPMethod string `json:"httpMethod"`
-		// CloudWatch fields
-		Source     string `json:"source"`
-		DetailType string `json:"detail-type"`
+		// CloudWatch and EventBridge fields
+		Source     string          `json:"source"`
+		DetailType string          `json:"detail-type"`
+		Detail     json.RawMessage `json:"detail"`
 		// CloudWatch Logs fields
 		AWSLogs json.RawMessage `json:"awslogs"`
-		// S3 and SQS fields
-		Records []struct {
-			Source string `json:"eventSource"`
-		}
+		// S3, SQS, SNS, DynamoDB and Kinesis events contain a list of records, while Kafka
+		// events use the same field name for a map of records grouped by topic partition
+		Records json.RawMessage `json:"Records"`
+		// Kafka fields
+		EventSource string `json:"eventSource"`
+		// Step Functions context object fields
+		Execution struct {
+			ID string `json:"Id"`
+		} `json:"Execution"`
+		StateMachine struct {
+			ID string `json:"Id"`
+		} `json:"StateMachine"`
 		// Version is common for multiple event types
 		Version string `json:"version"`
 		// RequestContext is common for multiple event types
 		RequestContext struct {
 			// ALB fields
 			ELB json.RawMessage `json:"elb"`
-			// API Gateway v2.0 fields
-			ApiID string          `json:"apiId"`
-			Stage string          `json:"stage"`
-			HTTP  json.RawMessage `json:"http"`
+			// API Gateway v2.0 and Lambda Function URL fields
+			ApiID      string          `json:"apiId"`
+			Stage      string          `json:"stage"`
+			DomainName string          `json:"domainName"`
+			HTTP       json.RawMessage `json:"http"`
 		} `json:"requestContext"`
 	}
 
@@ -53,21 +71,41 @@ func detectTriggerEventType(payload []byte) triggerEventType {
 		return unknownEventType
 	}
 
+	var records []struct {
+		Source string `json:"eventSource"`
+	}
+	// the error is ignored, since the records might be not a list, i.e. for Kafka events
+	json.Unmarshal(v.Records, &records)
+
 	switch {
 	case v.Resource != "" && v.Path != "" && v.HTTPMethod != "" && v.RequestContext.ELB == nil:
 		return apiGatewayEventType
+	case v.Version == "2.0" && strings.Contains(v.RequestContext.DomainName, ".lambda-url.") && len(v.RequestContext.HTTP) > 0:
+		return functionURLEventType
 	case v.Version == "2.0" && v.RequestContext.ApiID != "" && v.RequestContext.Stage != "" && len(v.RequestContext.HTTP) > 0:
 		return apiGatewayV2EventType
 	case v.RequestContext.ELB != nil:
 		return albEventType
 	case v.Source == "aws.events" && v.DetailType == "Scheduled Event":
 		return cloudWatchEventType
+	case v.Source != "" && v.DetailType != "" && len(v.Detail) > 0:
+		return eventBridgeEventType
 	case len(v.AWSLogs) != 0:
 		return cloudWatchLogsEventType
-	case len(v.Records) > 0 && v.Records[0].Source == "aws:s3":
+	case len(records) > 0 && records[0].Source == "aws:s3":
 		return s3EventType
-	case len(v.Records) > 0 && v.Records[0].Source == "aws:sqs":
+	case len(records) > 0 && records[0].Source == "aws:sqs":
 		return sqsEventType
+	case len(records) > 0 && records[0].Source == "aws:sns":
+		return snsEventType
+	case len(records) > 0 && records[0].Source == "aws:dynamodb":
+		return dynamoDBEventType
+	case len(records) > 0 && records[0].Source == "aws:kinesis":
+		return kinesisEventType
+	case v.EventSource == "aws:kafka" || v.EventSource == "SelfManagedKafka":
+		return kafkaEventType
+	case v.Execution.ID != "" && v.StateMachine.ID != "":
+		return stepFunctionsEventType
 	default:
 		return invokeRequestType
 	}
@@ -0,0 +1,60 @@
+// (c) Copyright IBM Corp. 2023
+
+package instasarama
+
+import (
+	"strings"
+
+	"github.com/Shopify/sarama"
+	instana "github.com/instana/go-sensor"
+	ot "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+// StartConsumerBatchSpan starts an entry span for a batch of messages processed at once, e.g. by a consumer
+// group handler that accumulates messages before handling them. The span continues the trace of the first
+// traced message in the batch, while the rest of them are referenced as span links. The context of this span
+// is injected into each message, so that it becomes a parent for the calls made while processing them.
+//
+// The caller is responsible for finishing the returned span once the batch is processed.
+func StartConsumerBatchSpan(msgs []*sarama.ConsumerMessage, sensor instana.TracerLogger) ot.Span {
+	opts := []ot.StartSpanOption{
+		ext.SpanKindConsumer,
+		ot.Tags{
+			"kafka.service": consumerBatchTopics(msgs),
+			"kafka.access":  "consume",
+		},
+		instana.BatchSize(len(msgs)),
+	}
+
+	for _, msg := range msgs {
+		if spanContext, ok := SpanContextFromConsumerMessage(msg, sensor); ok {
+			opts = append(opts, ot.FollowsFrom(spanContext))
+		}
+	}
+
+	sp := sensor.Tracer().StartSpan("kafka", opts...)
+
+	for _, msg := range msgs {
+		sensor.Tracer().Inject(sp.Context(), ot.TextMap, ConsumerMessageCarrier{msg})
+	}
+
+	return sp
+}
+
+// consumerBatchTopics returns a comma-separated list of unique topics of the batch messages
+func consumerBatchTopics(msgs []*sarama.ConsumerMessage) string {
+	var topics []string
+
+	seen := make(map[string]struct{})
+	for _, msg := range msgs {
+		if _, ok := seen[msg.Topic]; ok {
+			continue
+		}
+
+		seen[msg.Topic] = struct{}{}
+		topics = append(topics, msg.Topic)
+	}
+
+	return strings.Join(topics, ",")
+}
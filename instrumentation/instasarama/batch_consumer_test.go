@@ -0,0 +1,62 @@
+// (c) Copyright IBM Corp. 2023
+
+package instasarama_test
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	instana "github.com/instana/go-sensor"
+	"github.com/instana/go-sensor/instrumentation/instasarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartConsumerBatchSpan(t *testing.T) {
+	recorder := instana.NewTestRecorder()
+	sensor := instana.NewSensorWithTracer(instana.NewTracerWithEverything(&instana.Options{AgentClient: alwaysReadyClient{}}, recorder))
+
+	msgs := []*sarama.ConsumerMessage{
+		{
+			Topic: "topic-a",
+			Headers: []*sarama.RecordHeader{
+				{Key: []byte("x_instana_t"), Value: []byte("0000000000000000000000000abcde12")},
+				{Key: []byte("x_instana_s"), Value: []byte("00000000deadbeef")},
+				{Key: []byte("x_instana_l_s"), Value: []byte("1")},
+			},
+		},
+		{
+			Topic: "topic-b",
+			Headers: []*sarama.RecordHeader{
+				{Key: []byte("x_instana_t"), Value: []byte("00000000000000000000000000012345")},
+				{Key: []byte("x_instana_s"), Value: []byte("0000000000067890")},
+				{Key: []byte("x_instana_l_s"), Value: []byte("1")},
+			},
+		},
+		{Topic: "topic-a"},
+	}
+
+	instasarama.StartConsumerBatchSpan(msgs, sensor).Finish()
+
+	spans := recorder.GetQueuedSpans()
+	require.Len(t, spans, 1)
+
+	span, err := extractAgentSpan(spans[0])
+	require.NoError(t, err)
+
+	assert.Equal(t, "000000000abcde12", span.TraceID)
+	assert.Equal(t, "00000000deadbeef", span.ParentID)
+	assert.Equal(t, 3, span.Batch.Size)
+	assert.Equal(t, agentKafkaSpanData{
+		Service: "topic-a,topic-b",
+		Access:  "consume",
+	}, span.Data.Kafka)
+
+	// the batch span context is injected into each message
+	for _, msg := range msgs {
+		sc, ok := instasarama.SpanContextFromConsumerMessage(msg, sensor)
+		require.True(t, ok)
+
+		assert.Equal(t, span.SpanID, instana.FormatID(sc.(instana.SpanContext).SpanID))
+	}
+}
@@ -62,6 +62,12 @@ type Span struct {
 	CorrelationType string
 	CorrelationID   string
 	ForeignTrace    bool
+	Links           []SpanReference
+
+	// longTraceID is whether the tracer has been configured to use 128-bit trace IDs when the span was finished
+	longTraceID bool
+	// raw contains the serialized span restored from the spool
+	raw json.RawMessage
 }
 
 func newSpan(span *spanS) Span {
@@ -80,6 +86,7 @@ func newSpan(span *spanS) Span {
 		ForeignTrace:    span.context.ForeignTrace,
 		Kind:            int(data.Kind()),
 		Data:            data,
+		longTraceID:     use128BitTraceIDs(),
 	}
 
 	if bs, ok := span.Tags[batchSizeTag].(int); ok {
@@ -94,6 +101,8 @@ func newSpan(span *spanS) Span {
 		delete(span.Tags, syntheticCallTag)
 	}
 
+	sp.Links = span.Links
+
 	if len(span.context.Links) > 0 {
 		ancestor := span.context.Links[0]
 		sp.Ancestor = &TraceReference{
@@ -113,13 +122,24 @@ type TraceReference struct {
 
 // MarshalJSON serializes span to JSON for sending it to Instana
 func (sp Span) MarshalJSON() ([]byte, error) {
+	if sp.raw != nil {
+		return sp.marshalRawJSON()
+	}
+
 	var parentID string
 	if sp.ParentID != 0 {
 		parentID = FormatID(sp.ParentID)
 	}
 
+	var links []spanLink
+	for _, link := range sp.Links {
+		links = append(links, spanLink{link.TraceID, link.SpanID})
+	}
+
+	// the full trace ID is reported for all spans of a 128-bit trace, otherwise it's only sent with entry spans
+	// continuing a trace with 128-bit trace ID, i.e. one started by a W3C-compliant tracer
 	var longTraceID string
-	if sp.TraceIDHi != 0 && sp.Kind == int(EntrySpanKind) {
+	if sp.TraceIDHi != 0 && (sp.longTraceID || sp.Kind == int(EntrySpanKind)) {
 		longTraceID = FormatLongID(sp.TraceIDHi, sp.TraceID)
 	}
 
@@ -141,6 +161,7 @@ func (sp Span) MarshalJSON() ([]byte, error) {
 		CorrelationID   string          `json:"crid,omitempty"`
 		ForeignTrace    bool            `json:"tp,omitempty"`
 		Ancestor        *TraceReference `json:"ia,omitempty"`
+		Links           []spanLink      `json:"lk,omitempty"`
 	}{
 		TraceReference{
 			FormatID(sp.TraceID),
@@ -161,9 +182,38 @@ func (sp Span) MarshalJSON() ([]byte, error) {
 		sp.CorrelationID,
 		sp.ForeignTrace,
 		sp.Ancestor,
+		links,
 	})
 }
 
+// marshalRawJSON updates the `f` section of a span restored from the spool
+// with the current agent info
+func (sp Span) marshalRawJSON() ([]byte, error) {
+	if sp.From == nil {
+		return sp.raw, nil
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(sp.raw, &doc); err != nil {
+		return nil, err
+	}
+
+	from, err := json.Marshal(sp.From)
+	if err != nil {
+		return nil, err
+	}
+	doc["f"] = from
+
+	return json.Marshal(doc)
+}
+
+// spanLink is the serialized form of a span reference used to link spans
+// other than the parent one
+type spanLink struct {
+	TraceID string `json:"t"`
+	SpanID  string `json:"s"`
+}
+
 type batchInfo struct {
 	Size int `json:"s"`
 }
@@ -4,6 +4,8 @@
 package instana_test
 
 import (
+	"encoding/json"
+	"net/http"
 	"os"
 	"testing"
 
@@ -118,6 +120,92 @@ func spanToJson(t *testing.T, span instana.Span) string {
 	return string(jsonBytes)
 }
 
+func TestSpan_MarshalJSON_LongTraceID(t *testing.T) {
+	recorder := instana.NewTestRecorder()
+	tracer := instana.NewTracerWithEverything(&instana.Options{
+		AgentClient: alwaysReadyClient{},
+		Tracer:      instana.TracerOptions{Use128BitTraceIDs: true},
+	}, recorder)
+	defer instana.ShutdownSensor()
+
+	entry := tracer.StartSpan("entry", ext.SpanKindRPCServer)
+	intermediate := tracer.StartSpan("intermediate", opentracing.ChildOf(entry.Context()))
+	tracer.StartSpan("exit", ext.SpanKindRPCClient, opentracing.ChildOf(intermediate.Context())).Finish()
+	intermediate.Finish()
+	entry.Finish()
+
+	spans := recorder.GetQueuedSpans()
+	require.Len(t, spans, 3)
+
+	kinds := make(map[int]bool)
+
+	for _, sp := range spans {
+		data, err := json.Marshal(sp)
+		require.NoError(t, err)
+
+		var doc struct {
+			TraceID     string `json:"t"`
+			LongTraceID string `json:"lt"`
+		}
+		require.NoError(t, json.Unmarshal(data, &doc))
+
+		assert.Equal(t, instana.FormatID(sp.TraceID), doc.TraceID)
+		assert.NotZero(t, sp.TraceIDHi)
+		assert.Equal(t, instana.FormatLongID(sp.TraceIDHi, sp.TraceID), doc.LongTraceID)
+
+		kinds[sp.Kind] = true
+	}
+
+	assert.Equal(t, map[int]bool{
+		int(instana.EntrySpanKind):        true,
+		int(instana.IntermediateSpanKind): true,
+		int(instana.ExitSpanKind):         true,
+	}, kinds)
+}
+
+func TestSpan_MarshalJSON_LongTraceID_64BitMode(t *testing.T) {
+	recorder := instana.NewTestRecorder()
+	tracer := instana.NewTracerWithEverything(&instana.Options{AgentClient: alwaysReadyClient{}}, recorder)
+	defer instana.ShutdownSensor()
+
+	// the trace is continued from a W3C-compliant upstream service using 128-bit trace IDs
+	upstream, err := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(http.Header{
+		"Traceparent": {"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+	}))
+	require.NoError(t, err)
+
+	entry := tracer.StartSpan("entry", ext.SpanKindRPCServer, opentracing.ChildOf(upstream))
+	intermediate := tracer.StartSpan("intermediate", opentracing.ChildOf(entry.Context()))
+	tracer.StartSpan("exit", ext.SpanKindRPCClient, opentracing.ChildOf(intermediate.Context())).Finish()
+	intermediate.Finish()
+	entry.Finish()
+
+	spans := recorder.GetQueuedSpans()
+	require.Len(t, spans, 3)
+
+	longTraceIDs := make(map[int]string)
+	for _, sp := range spans {
+		data, err := json.Marshal(sp)
+		require.NoError(t, err)
+
+		var doc struct {
+			TraceID     string `json:"t"`
+			LongTraceID string `json:"lt"`
+		}
+		require.NoError(t, json.Unmarshal(data, &doc))
+
+		assert.Equal(t, "8448eb211c80319c", doc.TraceID)
+		longTraceIDs[sp.Kind] = doc.LongTraceID
+	}
+
+	// only the entry span reports the full trace ID
+	assert.Equal(t, map[int]string{
+		int(instana.EntrySpanKind):        "0af7651916cd43dd8448eb211c80319c",
+		int(instana.IntermediateSpanKind): "",
+		int(instana.ExitSpanKind):         "",
+	}, longTraceIDs)
+}
+
 func TestNewSDKSpanData(t *testing.T) {
 	recorder := instana.NewTestRecorder()
 	tracer := instana.NewTracerWithEverything(&instana.Options{AgentClient: alwaysReadyClient{}}, recorder)
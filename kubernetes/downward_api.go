@@ -0,0 +1,159 @@
+// (c) Copyright IBM Corp. 2023
+
+// Package kubernetes provides the Kubernetes pod metadata exposed to containers via the downward API
+package kubernetes
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultPodInfoDir is the default mount path of the downward API volume
+const DefaultPodInfoDir = "/etc/podinfo"
+
+// ContainerLimits represents the resource limits of a container
+type ContainerLimits struct {
+	// CPU is the CPU limit in cores
+	CPU float64
+	// Memory is the memory limit in bytes
+	Memory int64
+}
+
+// PodMetadata represents the pod metadata provided by the Kubernetes downward API.
+//
+// See https://kubernetes.io/docs/concepts/workloads/pods/downward-api/ for further details.
+type PodMetadata struct {
+	Name          string
+	Namespace     string
+	UID           string
+	NodeName      string
+	ContainerName string
+	Labels        map[string]string
+	Limits        ContainerLimits
+}
+
+// DownwardAPIProvider reads the pod metadata from the env variables and the files of a downward API volume.
+//
+// The values are looked up in following env variables, which are expected to be populated with the downward API
+// fieldRef and resourceFieldRef selectors: POD_NAME (metadata.name), POD_NAMESPACE (metadata.namespace),
+// POD_UID (metadata.uid), NODE_NAME (spec.nodeName), CONTAINER_NAME, CPU_LIMIT (limits.cpu with divisor 1m) and
+// MEMORY_LIMIT (limits.memory). If a variable is not set, the value is read from a file with the same name in lower
+// case within the downward API volume, i.e. /etc/podinfo/pod_name. The pod labels are read from the labels file.
+type DownwardAPIProvider struct {
+	Dir string
+}
+
+// NewDownwardAPIProvider initializes a new DownwardAPIProvider that reads files from given directory.
+// If the directory is empty, the DefaultPodInfoDir is used.
+func NewDownwardAPIProvider(dir string) *DownwardAPIProvider {
+	if dir == "" {
+		dir = DefaultPodInfoDir
+	}
+
+	return &DownwardAPIProvider{
+		Dir: dir,
+	}
+}
+
+// PodMetadata returns the metadata of current pod. It returns an error if neither the pod name,
+// nor its namespace could be determined.
+func (p *DownwardAPIProvider) PodMetadata() (PodMetadata, error) {
+	md := PodMetadata{
+		Name:          p.lookup("POD_NAME"),
+		Namespace:     p.lookup("POD_NAMESPACE"),
+		UID:           p.lookup("POD_UID"),
+		NodeName:      p.lookup("NODE_NAME"),
+		ContainerName: p.lookup("CONTAINER_NAME"),
+	}
+
+	if md.Name == "" {
+		// the pod hostname defaults to its name
+		md.Name, _ = os.Hostname()
+	}
+
+	if md.Name == "" || md.Namespace == "" {
+		return md, fmt.Errorf("failed to determine the pod name and namespace, make sure that POD_NAME and POD_NAMESPACE are set")
+	}
+
+	if s := p.lookup("CPU_LIMIT"); s != "" {
+		millicores, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return md, fmt.Errorf("malformed CPU limit %q: %s", s, err)
+		}
+
+		md.Limits.CPU = float64(millicores) / 1000
+	}
+
+	if s := p.lookup("MEMORY_LIMIT"); s != "" {
+		mem, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return md, fmt.Errorf("malformed memory limit %q: %s", s, err)
+		}
+
+		md.Limits.Memory = mem
+	}
+
+	labels, err := p.labels()
+	if err != nil {
+		return md, err
+	}
+	md.Labels = labels
+
+	return md, nil
+}
+
+// lookup returns the value of an env variable, or the contents of the corresponding downward API file
+func (p *DownwardAPIProvider) lookup(name string) string {
+	if v, ok := os.LookupEnv(name); ok {
+		return strings.TrimSpace(v)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(p.Dir, strings.ToLower(name)))
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(data))
+}
+
+// labels parses the labels file, which contains one key="value" pair per line
+func (p *DownwardAPIProvider) labels() (map[string]string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(p.Dir, "labels"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to read pod labels: %s", err)
+	}
+
+	labels := make(map[string]string)
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+
+		ind := strings.IndexByte(line, '=')
+		if ind < 0 {
+			return nil, fmt.Errorf("malformed pod label %q", line)
+		}
+
+		value, err := strconv.Unquote(line[ind+1:])
+		if err != nil {
+			return nil, fmt.Errorf("malformed pod label %q: %s", line, err)
+		}
+
+		labels[line[:ind]] = value
+	}
+
+	return labels, sc.Err()
+}
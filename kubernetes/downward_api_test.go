@@ -0,0 +1,96 @@
+// (c) Copyright IBM Corp. 2023
+
+package kubernetes_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/instana/go-sensor/kubernetes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var downwardAPIEnvVars = []string{
+	"POD_NAME", "POD_NAMESPACE", "POD_UID", "NODE_NAME", "CONTAINER_NAME", "CPU_LIMIT", "MEMORY_LIMIT",
+}
+
+func TestDownwardAPIProvider_PodMetadata(t *testing.T) {
+	defer unsetEnvVars(downwardAPIEnvVars...)()
+
+	md, err := kubernetes.NewDownwardAPIProvider("testdata/podinfo").PodMetadata()
+	require.NoError(t, err)
+
+	assert.Equal(t, kubernetes.PodMetadata{
+		Name:      "test-pod",
+		Namespace: "test-namespace",
+		UID:       "6f8d1e0c-2b4f-4a53-9d7e-1c3a9b2f5e10",
+		NodeName:  "test-node",
+		Labels: map[string]string{
+			"app":               "test-app",
+			"pod-template-hash": "7d4b9c6f5",
+			"team":              `a "quoted" value`,
+		},
+		Limits: kubernetes.ContainerLimits{
+			CPU:    0.5,
+			Memory: 268435456,
+		},
+	}, md)
+}
+
+func TestDownwardAPIProvider_PodMetadata_EnvVars(t *testing.T) {
+	defer unsetEnvVars(downwardAPIEnvVars...)()
+
+	os.Setenv("POD_NAME", "env-pod")
+	os.Setenv("CONTAINER_NAME", "app")
+	os.Setenv("CPU_LIMIT", "2000")
+
+	md, err := kubernetes.NewDownwardAPIProvider("testdata/podinfo").PodMetadata()
+	require.NoError(t, err)
+
+	// env variables take precedence over the downward API files
+	assert.Equal(t, "env-pod", md.Name)
+	assert.Equal(t, "app", md.ContainerName)
+	assert.Equal(t, 2.0, md.Limits.CPU)
+
+	assert.Equal(t, "test-namespace", md.Namespace)
+	assert.Equal(t, int64(268435456), md.Limits.Memory)
+}
+
+func TestDownwardAPIProvider_PodMetadata_NoNamespace(t *testing.T) {
+	defer unsetEnvVars(downwardAPIEnvVars...)()
+
+	_, err := kubernetes.NewDownwardAPIProvider("testdata/missing").PodMetadata()
+	assert.Error(t, err)
+}
+
+func TestDownwardAPIProvider_PodMetadata_MalformedLimit(t *testing.T) {
+	defer unsetEnvVars(downwardAPIEnvVars...)()
+
+	os.Setenv("MEMORY_LIMIT", "256Mi")
+
+	_, err := kubernetes.NewDownwardAPIProvider("testdata/podinfo").PodMetadata()
+	assert.Error(t, err)
+}
+
+// unsetEnvVars unsets given env variables and returns a function that restores their values
+func unsetEnvVars(keys ...string) func() {
+	values := make(map[string]string)
+	for _, k := range keys {
+		if v, ok := os.LookupEnv(k); ok {
+			values[k] = v
+		}
+
+		os.Unsetenv(k)
+	}
+
+	return func() {
+		for _, k := range keys {
+			if v, ok := values[k]; ok {
+				os.Setenv(k, v)
+			} else {
+				os.Unsetenv(k)
+			}
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/instana/go-sensor/acceptor"
+	"github.com/instana/go-sensor/autoprofile"
+	"github.com/instana/go-sensor/kubernetes"
+)
+
+type kubernetesSnapshot struct {
+	Service serverlessSnapshot
+	Pod     kubernetes.PodMetadata
+}
+
+func newKubernetesSnapshot(pid int, md kubernetes.PodMetadata) kubernetesSnapshot {
+	entityID := md.UID
+	if entityID == "" {
+		entityID = md.Namespace + "/" + md.Name
+	}
+
+	return kubernetesSnapshot{
+		Service: serverlessSnapshot{
+			EntityID:  entityID,
+			Host:      "k8s:pod:" + md.Namespace + "/" + md.Name,
+			PID:       pid,
+			StartedAt: processStartedAt,
+			Container: containerSnapshot{
+				ID:   entityID,
+				Type: "kubernetesPod",
+			},
+		},
+		Pod: md,
+	}
+}
+
+func newKubernetesPodPluginPayload(snapshot kubernetesSnapshot) acceptor.PluginPayload {
+	return acceptor.NewKubernetesPodPluginPayload(snapshot.Service.EntityID, acceptor.KubernetesPodData{
+		Runtime:       "go",
+		Name:          snapshot.Pod.Name,
+		Namespace:     snapshot.Pod.Namespace,
+		UID:           snapshot.Pod.UID,
+		NodeName:      snapshot.Pod.NodeName,
+		ContainerName: snapshot.Pod.ContainerName,
+		Labels:        snapshot.Pod.Labels,
+		Limits: acceptor.KubernetesContainerLimits{
+			CPU:    snapshot.Pod.Limits.CPU,
+			Memory: snapshot.Pod.Limits.Memory,
+		},
+		InstanaZone: snapshot.Service.Zone,
+		Tags:        snapshot.Service.Tags,
+	})
+}
+
+// kubernetesAgent reports the pod metadata collected via the Kubernetes downward API along with the process and
+// runtime metrics to the serverless acceptor. It is used in Kubernetes clusters that have no host agent deployed.
+type kubernetesAgent struct {
+	PID  int
+	Zone string
+	Tags map[string]interface{}
+
+	// the snapshot is periodically re-collected in background, so it's guarded with snapshotMu
+	snapshotMu sync.RWMutex
+	snapshot   kubernetesSnapshot
+
+	// the process stats are reported as deltas to the last sent ones, so metrics are sent one at a time
+	sendMetricsMu    sync.Mutex
+	lastProcessStats processStats
+
+	transport *serverlessTransport
+
+	runtimeSnapshot *SnapshotCollector
+	processStats    *processStatsCollector
+	downwardAPI     *kubernetes.DownwardAPIProvider
+	logger          LeveledLogger
+}
+
+func newKubernetesAgent(
+	serviceName, acceptorEndpoint, agentKey string,
+	client *http.Client,
+	logger LeveledLogger,
+) *kubernetesAgent {
+	if logger == nil {
+		logger = defaultLogger
+	}
+
+	logger.Debug("initializing kubernetes agent")
+
+	agent := &kubernetesAgent{
+		PID:  os.Getpid(),
+		Zone: os.Getenv("INSTANA_ZONE"),
+		Tags: parseInstanaTags(os.Getenv("INSTANA_TAGS")),
+		runtimeSnapshot: &SnapshotCollector{
+			CollectionInterval: snapshotCollectionInterval,
+			ServiceName:        serviceName,
+		},
+		processStats: &processStatsCollector{
+			logger: logger,
+		},
+		// allow overriding the downward API volume path for testing purposes or if it's mounted elsewhere
+		downwardAPI: kubernetes.NewDownwardAPIProvider(os.Getenv("INSTANA_KUBERNETES_PODINFO_DIR")),
+		transport:   newServerlessTransport(acceptorEndpoint, agentKey, client, logger),
+		logger:      logger,
+	}
+
+	go func() {
+		for {
+			// the pod labels can be updated during the pod lifetime, so the snapshot is re-collected periodically
+			for i := 0; i < maximumRetries; i++ {
+				snapshot, ok := agent.collectSnapshot()
+				if ok {
+					agent.snapshotMu.Lock()
+					agent.snapshot = snapshot
+					agent.snapshotMu.Unlock()
+
+					break
+				}
+
+				time.Sleep(expDelay(i + 1))
+			}
+			time.Sleep(snapshotCollectionInterval)
+		}
+	}()
+	go agent.processStats.Run(context.Background(), time.Second)
+
+	return agent
+}
+
+func (a *kubernetesAgent) Ready() bool { return a.currentSnapshot().Service.EntityID != "" }
+
+func (a *kubernetesAgent) SendMetrics(data acceptor.Metrics) (err error) {
+	snapshot := a.currentSnapshot()
+
+	a.sendMetricsMu.Lock()
+	defer a.sendMetricsMu.Unlock()
+
+	processStats := a.processStats.Collect()
+	defer func() {
+		if err == nil {
+			// only update the last sent stats if they were transmitted successfully
+			// since they are updated on the backend incrementally using received
+			// deltas
+			a.lastProcessStats = processStats
+		}
+	}()
+
+	return a.transport.SendBundle(
+		context.Background(),
+		snapshot.Service.Host,
+		newServerlessAgentFromS(snapshot.Service.EntityID, ""),
+		[]acceptor.PluginPayload{
+			newKubernetesPodPluginPayload(snapshot),
+			newProcessPluginPayload(snapshot.Service, a.lastProcessStats, processStats),
+			acceptor.NewGoProcessPluginPayload(acceptor.GoProcessData{
+				PID:      a.PID,
+				Snapshot: a.runtimeSnapshot.Collect(),
+				Metrics:  data,
+			}),
+		},
+	)
+}
+
+func (a *kubernetesAgent) SendEvent(event *EventData) error { return nil }
+
+func (a *kubernetesAgent) SendSpans(spans []Span) error {
+	// enqueue the spans to send them in a bundle with metrics instead of sending immediately
+	a.transport.Enqueue(spans)
+
+	return nil
+}
+
+func (a *kubernetesAgent) SendProfiles(profiles []autoprofile.Profile) error { return nil }
+
+func (a *kubernetesAgent) Flush(ctx context.Context) error {
+	if a.transport.QueueLen() == 0 {
+		return nil
+	}
+
+	snapshot := a.currentSnapshot()
+	if snapshot.Service.EntityID == "" {
+		return ErrAgentNotReady
+	}
+
+	return a.transport.SendSpans(ctx, snapshot.Service.Host, newServerlessAgentFromS(snapshot.Service.EntityID, ""))
+}
+
+func (a *kubernetesAgent) currentSnapshot() kubernetesSnapshot {
+	a.snapshotMu.RLock()
+	defer a.snapshotMu.RUnlock()
+
+	return a.snapshot
+}
+
+func (a *kubernetesAgent) collectSnapshot() (kubernetesSnapshot, bool) {
+	md, err := a.downwardAPI.PodMetadata()
+	if err != nil {
+		a.logger.Warn("failed to get pod metadata: ", err)
+		return kubernetesSnapshot{}, false
+	}
+
+	snapshot := newKubernetesSnapshot(a.PID, md)
+	snapshot.Service.Zone = a.Zone
+	snapshot.Service.Tags = a.Tags
+
+	a.logger.Debug("collected snapshot")
+
+	return snapshot, true
+}
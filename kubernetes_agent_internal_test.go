@@ -0,0 +1,121 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/instana/go-sensor/acceptor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKubernetesAgent(t *testing.T) {
+	for _, k := range []string{"POD_NAME", "POD_NAMESPACE", "POD_UID", "NODE_NAME", "CONTAINER_NAME", "CPU_LIMIT", "MEMORY_LIMIT"} {
+		defer restoreEnvVarFunc(k)()
+		os.Unsetenv(k)
+	}
+
+	defer restoreEnvVarFunc("INSTANA_KUBERNETES_PODINFO_DIR")()
+	os.Setenv("INSTANA_KUBERNETES_PODINFO_DIR", "kubernetes/testdata/podinfo")
+
+	acc := newRecordingServerlessAcceptor()
+	defer acc.Close()
+
+	agent := newKubernetesAgent("test-service", acc.URL, "testkey", nil, defaultLogger)
+	require.Eventually(t, agent.Ready, 5*time.Second, 10*time.Millisecond)
+
+	t.Run("SendMetrics", func(t *testing.T) {
+		require.NoError(t, agent.SendMetrics(acceptor.Metrics{}))
+
+		reqs := acc.Requests()
+		require.NotEmpty(t, reqs)
+
+		req := reqs[len(reqs)-1]
+		assert.Equal(t, "/bundle", req.Path)
+		assert.Equal(t, "k8s:pod:test-namespace/test-pod", req.Header.Get("X-Instana-Host"))
+		assert.Equal(t, "testkey", req.Header.Get("X-Instana-Key"))
+
+		var payload struct {
+			Metrics struct {
+				Plugins []struct {
+					Name     string          `json:"name"`
+					EntityID string          `json:"entityId"`
+					Data     json.RawMessage `json:"data"`
+				} `json:"plugins"`
+			} `json:"metrics"`
+		}
+		require.NoError(t, json.Unmarshal(req.Body, &payload))
+
+		plugins := make(map[string]json.RawMessage)
+		for _, plugin := range payload.Metrics.Plugins {
+			plugins[plugin.Name] = plugin.Data
+		}
+
+		require.Contains(t, plugins, "com.instana.plugin.kubernetes.pod")
+		require.Contains(t, plugins, "com.instana.plugin.process")
+		require.Contains(t, plugins, "com.instana.plugin.golang")
+
+		assert.Equal(t, "6f8d1e0c-2b4f-4a53-9d7e-1c3a9b2f5e10", payload.Metrics.Plugins[0].EntityID)
+		assert.JSONEq(t, `{
+			"runtime": "go",
+			"name": "test-pod",
+			"namespace": "test-namespace",
+			"uid": "6f8d1e0c-2b4f-4a53-9d7e-1c3a9b2f5e10",
+			"nodeName": "test-node",
+			"labels": {"app": "test-app", "pod-template-hash": "7d4b9c6f5", "team": "a \"quoted\" value"},
+			"limits": {"cpu": 0.5, "memory": 268435456}
+		}`, string(plugins["com.instana.plugin.kubernetes.pod"]))
+	})
+
+	t.Run("SendMetrics concurrently", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, agent.SendMetrics(acceptor.Metrics{}))
+			}()
+		}
+		wg.Wait()
+	})
+
+	t.Run("Flush", func(t *testing.T) {
+		require.NoError(t, agent.SendSpans([]Span{{Name: "entry"}}))
+		require.NoError(t, agent.Flush(context.Background()))
+
+		reqs := acc.Requests()
+		require.NotEmpty(t, reqs)
+
+		req := reqs[len(reqs)-1]
+		assert.Equal(t, "/traces", req.Path)
+
+		var spans []struct {
+			From *fromS `json:"f"`
+		}
+		require.NoError(t, json.Unmarshal(req.Body, &spans))
+
+		require.Len(t, spans, 1)
+		assert.Equal(t, &fromS{EntityID: "6f8d1e0c-2b4f-4a53-9d7e-1c3a9b2f5e10", Hostless: true}, spans[0].From)
+	})
+}
+
+func TestNewServerlessAgent_Kubernetes(t *testing.T) {
+	for _, k := range []string{"AWS_EXECUTION_ENV", "K_SERVICE", "FUNCTIONS_WORKER_RUNTIME"} {
+		defer restoreEnvVarFunc(k)()
+		os.Unsetenv(k)
+	}
+
+	defer restoreEnvVarFunc("KUBERNETES_SERVICE_HOST")()
+	os.Setenv("KUBERNETES_SERVICE_HOST", "10.0.0.1")
+
+	defer restoreEnvVarFunc("INSTANA_KUBERNETES_PODINFO_DIR")()
+	os.Setenv("INSTANA_KUBERNETES_PODINFO_DIR", "kubernetes/testdata/podinfo")
+
+	assert.IsType(t, &kubernetesAgent{}, newServerlessAgent("test-service", "http://localhost", "testkey", nil, nil, false, defaultLogger))
+}
@@ -4,15 +4,10 @@
 package instana
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"net/http"
 	"os"
-	"strconv"
 	"sync"
 	"time"
 
@@ -22,18 +17,21 @@ import (
 
 const awsLambdaAgentFlushPeriod = 2 * time.Second
 
+// awsLambdaExtensionRegisterTimeout limits the time the sensor waits for the Lambda extension to be registered
+// before falling back to sending data at the end of each invocation
+const awsLambdaExtensionRegisterTimeout = 2 * time.Second
+
 type lambdaAgent struct {
-	Endpoint string
-	Key      string
-	PID      int
+	PID int
 
 	snapshot serverlessSnapshot
 
-	mu        sync.Mutex
-	spanQueue []Span
+	transport *serverlessTransport
+	logger    LeveledLogger
 
-	client *http.Client
-	logger LeveledLogger
+	extMu          sync.RWMutex
+	extension      *lambdaExtensionClient
+	invocationDone chan struct{}
 }
 
 func newLambdaAgent(
@@ -45,34 +43,48 @@ func newLambdaAgent(
 		logger = defaultLogger
 	}
 
-	if client == nil {
-		client = http.DefaultClient
-	}
-
 	logger.Debug("initializing aws lambda agent")
 
 	agent := &lambdaAgent{
-		Endpoint: acceptorEndpoint,
-		Key:      agentKey,
-		PID:      os.Getpid(),
-		client:   client,
-		logger:   logger,
+		PID:       os.Getpid(),
+		transport: newServerlessTransport(acceptorEndpoint, agentKey, client, logger),
+		logger:    logger,
 	}
 
-	go func() {
-		t := time.NewTicker(awsLambdaAgentFlushPeriod)
-		defer t.Stop()
+	if runtimeAPI := os.Getenv("AWS_LAMBDA_RUNTIME_API"); runtimeAPI != "" && lambdaExtensionEnabled() {
+		ctx, cancel := context.WithTimeout(context.Background(), awsLambdaExtensionRegisterTimeout)
+		defer cancel()
 
-		for range t.C {
-			if err := agent.Flush(context.Background()); err != nil {
-				agent.logger.Error("failed to post collected data: ", err)
-			}
+		ext := newLambdaExtensionClient(runtimeAPI)
+		if err := ext.Register(ctx); err != nil {
+			logger.Warn("failed to register aws lambda extension, falling back to sending data at the end of each invocation: ", err)
+		} else {
+			logger.Debug("registered aws lambda extension, the data will be sent after each invocation")
+
+			agent.extension = ext
+			agent.invocationDone = make(chan struct{}, 1)
+			go agent.runExtension()
+
+			return agent
 		}
-	}()
+	}
+
+	go agent.flushPeriodically()
 
 	return agent
 }
 
+func (a *lambdaAgent) flushPeriodically() {
+	t := time.NewTicker(awsLambdaAgentFlushPeriod)
+	defer t.Stop()
+
+	for range t.C {
+		if err := a.Flush(context.Background()); err != nil {
+			a.logger.Error("failed to post collected data: ", err)
+		}
+	}
+}
+
 func (a *lambdaAgent) Ready() bool { return true }
 
 func (a *lambdaAgent) SendMetrics(data acceptor.Metrics) error { return nil }
@@ -80,95 +92,46 @@ func (a *lambdaAgent) SendMetrics(data acceptor.Metrics) error { return nil }
 func (a *lambdaAgent) SendEvent(event *EventData) error { return nil }
 
 func (a *lambdaAgent) SendSpans(spans []Span) error {
-	a.enqueueSpans(spans)
+	a.transport.Enqueue(spans)
 	return nil
 }
 
 func (a *lambdaAgent) SendProfiles(profiles []autoprofile.Profile) error { return nil }
 
+// Flush sends the collected data to the serverless acceptor. If the sensor is running as a Lambda extension, Flush
+// notifies the extension that the invocation has been completed and returns immediately, leaving it up to the extension
+// to send the data before the execution environment is frozen.
 func (a *lambdaAgent) Flush(ctx context.Context) error {
-	snapshot := a.collectSnapshot(a.spanQueue)
-
-	if snapshot.EntityID == "" {
-		return ErrAgentNotReady
-	}
-
-	from := newServerlessAgentFromS(snapshot.EntityID, "aws")
-
-	payload := struct {
-		Metrics metricsPayload `json:"metrics,omitempty"`
-		Spans   []Span         `json:"spans,omitempty"`
-	}{
-		Metrics: metricsPayload{
-			Plugins: []acceptor.PluginPayload{
-				acceptor.NewAWSLambdaPluginPayload(snapshot.EntityID),
-			},
-		},
-	}
-
-	a.mu.Lock()
-	payload.Spans = make([]Span, len(a.spanQueue))
-	copy(payload.Spans, a.spanQueue)
-	a.spanQueue = a.spanQueue[:0]
-	a.mu.Unlock()
-
-	for i := range payload.Spans {
-		payload.Spans[i].From = from
-	}
-
-	buf := bytes.NewBuffer(nil)
-	if err := json.NewEncoder(buf).Encode(payload); err != nil {
-		return fmt.Errorf("failed to marshal traces payload: %s", err)
-	}
-
-	req, err := http.NewRequest(http.MethodPost, a.Endpoint+"/bundle", buf)
-	if err != nil {
-		a.enqueueSpans(payload.Spans)
-		return fmt.Errorf("failed to prepare send traces request: %s", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
+	if a.extensionEnabled() {
+		select {
+		case a.invocationDone <- struct{}{}:
+		default:
+		}
 
-	if err := a.sendRequest(req.WithContext(ctx)); err != nil {
-		a.enqueueSpans(payload.Spans)
-		return fmt.Errorf("failed to send traces, will retry later: %s", err)
+		return nil
 	}
 
-	return nil
-}
-
-func (a *lambdaAgent) enqueueSpans(spans []Span) {
-	a.mu.Lock()
-	defer a.mu.Unlock()
-
-	a.spanQueue = append(a.spanQueue, spans...)
+	return a.sendBundle(ctx)
 }
 
-func (a *lambdaAgent) sendRequest(req *http.Request) error {
-	req.Header.Set("X-Instana-Host", a.snapshot.Host)
-	req.Header.Set("X-Instana-Key", a.Key)
-	req.Header.Set("X-Instana-Time", strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10))
+func (a *lambdaAgent) sendBundle(ctx context.Context) error {
+	snapshot := a.collectSnapshot(a.transport.QueuedSpans())
 
-	resp, err := a.client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send request to the serverless agent: %s", err)
+	if snapshot.EntityID == "" {
+		return ErrAgentNotReady
 	}
 
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= http.StatusBadRequest {
-		respBody, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			a.logger.Debug("failed to read serverless agent response: ", err)
-			return nil
-		}
-
-		a.logger.Info("serverless agent has responded with ", resp.Status, ": ", string(respBody))
-		return nil
+	if err := a.transport.SendBundle(
+		ctx,
+		snapshot.Host,
+		newServerlessAgentFromS(snapshot.EntityID, "aws"),
+		[]acceptor.PluginPayload{
+			acceptor.NewAWSLambdaPluginPayload(snapshot.EntityID),
+		},
+	); err != nil {
+		return fmt.Errorf("failed to send traces, will retry later: %s", err)
 	}
 
-	io.CopyN(ioutil.Discard, resp.Body, 1<<20)
-
 	return nil
 }
 
@@ -0,0 +1,227 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	awsLambdaExtensionName    = "instana-go-sensor"
+	awsLambdaExtensionAPIPath = "/2020-01-01/extension"
+
+	awsLambdaExtensionNameHeader = "Lambda-Extension-Name"
+	awsLambdaExtensionIDHeader   = "Lambda-Extension-Identifier"
+
+	awsLambdaInvokeEvent   = "INVOKE"
+	awsLambdaShutdownEvent = "SHUTDOWN"
+
+	// awsLambdaExtensionSendTimeout is the time reserved to send the collected data before the invocation deadline
+	awsLambdaExtensionSendTimeout = 300 * time.Millisecond
+)
+
+// lambdaExtensionEnabled returns whether the sensor should register itself as an AWS Lambda extension. This mode
+// is enabled via INSTANA_AWS_LAMBDA_EXTENSION env variable.
+func lambdaExtensionEnabled() bool {
+	return os.Getenv("INSTANA_AWS_LAMBDA_EXTENSION") != ""
+}
+
+// lambdaExtensionEvent is an event received from the AWS Lambda Extensions API
+type lambdaExtensionEvent struct {
+	EventType  string `json:"eventType"`
+	DeadlineMs int64  `json:"deadlineMs"`
+	RequestID  string `json:"requestId"`
+}
+
+// Deadline returns the time the invocation or the shutdown phase times out, or zero time if no deadline was provided
+func (ev lambdaExtensionEvent) Deadline() time.Time {
+	if ev.DeadlineMs == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, ev.DeadlineMs*int64(time.Millisecond))
+}
+
+// lambdaExtensionClient is a client for the AWS Lambda Extensions API.
+// See https://docs.aws.amazon.com/lambda/latest/dg/runtimes-extensions-api.html for details
+type lambdaExtensionClient struct {
+	baseURL string
+	id      string
+	client  *http.Client
+}
+
+func newLambdaExtensionClient(runtimeAPI string) *lambdaExtensionClient {
+	return &lambdaExtensionClient{
+		baseURL: "http://" + runtimeAPI + awsLambdaExtensionAPIPath,
+		// the next event request blocks until there is an event, so there should be no timeout
+		client: &http.Client{},
+	}
+}
+
+// Register registers an internal extension that is notified about the function invocations. The internal
+// extensions are not allowed to subscribe to SHUTDOWN events, however they are handled if received.
+func (c *lambdaExtensionClient) Register(ctx context.Context) error {
+	body, err := json.Marshal(map[string][]string{
+		"events": {awsLambdaInvokeEvent},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal registration request: %s", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/register", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to prepare registration request: %s", err)
+	}
+
+	req.Header.Set(awsLambdaExtensionNameHeader, awsLambdaExtensionName)
+
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to register extension: %s", err)
+	}
+
+	defer func() {
+		io.CopyN(ioutil.Discard, resp.Body, 1<<20)
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to register extension: the Extensions API responded with %s", resp.Status)
+	}
+
+	c.id = resp.Header.Get(awsLambdaExtensionIDHeader)
+	if c.id == "" {
+		return fmt.Errorf("failed to register extension: no %s header in response", awsLambdaExtensionIDHeader)
+	}
+
+	return nil
+}
+
+// NextEvent signals the Extensions API that the extension is done processing the previous event and
+// blocks until the next one is received
+func (c *lambdaExtensionClient) NextEvent(ctx context.Context) (lambdaExtensionEvent, error) {
+	var ev lambdaExtensionEvent
+
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/event/next", nil)
+	if err != nil {
+		return ev, fmt.Errorf("failed to prepare next event request: %s", err)
+	}
+
+	req.Header.Set(awsLambdaExtensionIDHeader, c.id)
+
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return ev, fmt.Errorf("failed to request next event: %s", err)
+	}
+
+	defer func() {
+		io.CopyN(ioutil.Discard, resp.Body, 1<<20)
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return ev, fmt.Errorf("failed to request next event: the Extensions API responded with %s", resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&ev); err != nil {
+		return ev, fmt.Errorf("failed to decode next event: %s", err)
+	}
+
+	return ev, nil
+}
+
+func (a *lambdaAgent) extensionEnabled() bool {
+	a.extMu.RLock()
+	defer a.extMu.RUnlock()
+
+	return a.extension != nil
+}
+
+// runExtension processes the events received from the Lambda Extensions API. The collected data is sent once the
+// handler completes the invocation, so that the function response is not delayed by the sensor.
+func (a *lambdaAgent) runExtension() {
+	for {
+		// discard the completion signals left by the previous invocation, e.g. if the handler has flushed
+		// the sensor more than once. The next invocation is not started until the extension requests the
+		// next event, so there can't be any signals for it yet
+		a.drainInvocationDone()
+
+		ev, err := a.extension.NextEvent(context.Background())
+		if err != nil {
+			a.logger.Error("aws lambda extension failed, falling back to sending data at the end of each invocation: ", err)
+			a.disableExtension()
+
+			return
+		}
+
+		switch ev.EventType {
+		case awsLambdaInvokeEvent:
+			a.waitForInvocation(ev.Deadline())
+			a.sendExtensionBundle(ev.Deadline())
+		case awsLambdaShutdownEvent:
+			a.logger.Debug("aws lambda execution environment is shutting down, sending collected data")
+			a.sendExtensionBundle(ev.Deadline())
+
+			return
+		default:
+			a.logger.Debug("ignoring unknown aws lambda extension event ", ev.EventType)
+		}
+	}
+}
+
+// waitForInvocation blocks until the handler signals that the invocation is done, or until the
+// invocation is about to time out
+func (a *lambdaAgent) waitForInvocation(deadline time.Time) {
+	if deadline.IsZero() {
+		<-a.invocationDone
+		return
+	}
+
+	t := time.NewTimer(time.Until(deadline) - awsLambdaExtensionSendTimeout)
+	defer t.Stop()
+
+	select {
+	case <-a.invocationDone:
+	case <-t.C:
+		a.logger.Debug("aws lambda invocation is about to time out, sending collected data")
+	}
+}
+
+// drainInvocationDone removes a pending invocation completion signal, if there is one
+func (a *lambdaAgent) drainInvocationDone() {
+	select {
+	case <-a.invocationDone:
+	default:
+	}
+}
+
+func (a *lambdaAgent) sendExtensionBundle(deadline time.Time) {
+	ctx := context.Background()
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
+	if err := a.sendBundle(ctx); err != nil && err != ErrAgentNotReady {
+		a.logger.Error("failed to post collected data: ", err)
+	}
+}
+
+// disableExtension switches the agent back to sending data at the end of each invocation
+func (a *lambdaAgent) disableExtension() {
+	a.extMu.Lock()
+	a.extension = nil
+	a.extMu.Unlock()
+
+	go a.flushPeriodically()
+}
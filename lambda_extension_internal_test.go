@@ -0,0 +1,326 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLambdaAgent_Extension(t *testing.T) {
+	runtimeAPI := newFakeLambdaRuntimeAPI()
+	defer runtimeAPI.Close()
+
+	acceptor := newFakeServerlessAcceptor()
+	defer acceptor.Close()
+
+	defer restoreEnvVarFunc("AWS_LAMBDA_RUNTIME_API")()
+	os.Setenv("AWS_LAMBDA_RUNTIME_API", strings.TrimPrefix(runtimeAPI.URL, "http://"))
+
+	defer restoreEnvVarFunc("INSTANA_AWS_LAMBDA_EXTENSION")()
+	os.Setenv("INSTANA_AWS_LAMBDA_EXTENSION", "true")
+
+	agent := newLambdaAgent("test-service", acceptor.URL, "testkey", nil, defaultLogger)
+	require.True(t, agent.extensionEnabled())
+
+	assert.Equal(t, awsLambdaExtensionName, runtimeAPI.registeredName)
+	assert.Equal(t, []string{awsLambdaInvokeEvent}, runtimeAPI.registeredEvents)
+
+	runtimeAPI.events <- lambdaExtensionEvent{
+		EventType:  awsLambdaInvokeEvent,
+		DeadlineMs: time.Now().Add(time.Minute).UnixNano() / int64(time.Millisecond),
+		RequestID:  "req-1",
+	}
+
+	require.NoError(t, agent.SendSpans([]Span{newTestLambdaSpan("arn:aws:lambda:us-east-2:123456789012:function:test:1")}))
+
+	// the handler returns immediately, while the data is sent by the extension
+	require.NoError(t, agent.Flush(context.Background()))
+
+	select {
+	case bundle := <-acceptor.bundles:
+		require.Len(t, bundle.Spans, 1)
+		assert.Equal(t, "arn:aws:lambda:us-east-2:123456789012:function:test:1", bundle.Spans[0].From.EntityID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no bundle has been sent after the invocation")
+	}
+
+	// spans buffered after the invocation are sent on shutdown
+	require.NoError(t, agent.SendSpans([]Span{newTestLambdaSpan("arn:aws:lambda:us-east-2:123456789012:function:test:1")}))
+	runtimeAPI.events <- lambdaExtensionEvent{
+		EventType:  awsLambdaShutdownEvent,
+		DeadlineMs: time.Now().Add(time.Minute).UnixNano() / int64(time.Millisecond),
+	}
+
+	select {
+	case bundle := <-acceptor.bundles:
+		assert.Len(t, bundle.Spans, 1)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no bundle has been sent on shutdown")
+	}
+}
+
+func TestLambdaAgent_Extension_StaleInvocationDone(t *testing.T) {
+	runtimeAPI := newFakeLambdaRuntimeAPI()
+	defer runtimeAPI.Close()
+
+	acceptor := newFakeServerlessAcceptor()
+	defer acceptor.Close()
+
+	defer restoreEnvVarFunc("AWS_LAMBDA_RUNTIME_API")()
+	os.Setenv("AWS_LAMBDA_RUNTIME_API", strings.TrimPrefix(runtimeAPI.URL, "http://"))
+
+	defer restoreEnvVarFunc("INSTANA_AWS_LAMBDA_EXTENSION")()
+	os.Setenv("INSTANA_AWS_LAMBDA_EXTENSION", "true")
+
+	agent := newLambdaAgent("test-service", acceptor.URL, "testkey", nil, defaultLogger)
+	require.True(t, agent.extensionEnabled())
+
+	runtimeAPI.events <- lambdaExtensionEvent{
+		EventType:  awsLambdaInvokeEvent,
+		DeadlineMs: time.Now().Add(time.Minute).UnixNano() / int64(time.Millisecond),
+		RequestID:  "req-1",
+	}
+
+	require.NoError(t, agent.SendSpans([]Span{newTestLambdaSpan("arn:aws:lambda:us-east-2:123456789012:function:test:1")}))
+	require.NoError(t, agent.Flush(context.Background()))
+
+	// the handler flushes the sensor once again after the extension has received the completion signal
+	require.Eventually(t, func() bool { return len(agent.invocationDone) == 0 }, 5*time.Second, 100*time.Microsecond)
+	require.NoError(t, agent.Flush(context.Background()))
+
+	select {
+	case <-acceptor.bundles:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no bundle has been sent after the invocation")
+	}
+
+	runtimeAPI.events <- lambdaExtensionEvent{
+		EventType:  awsLambdaInvokeEvent,
+		DeadlineMs: time.Now().Add(time.Minute).UnixNano() / int64(time.Millisecond),
+		RequestID:  "req-2",
+	}
+
+	require.NoError(t, agent.SendSpans([]Span{newTestLambdaSpan("arn:aws:lambda:us-east-2:123456789012:function:test:1")}))
+
+	// the extra signal left by the previous invocation does not complete the current one
+	select {
+	case <-acceptor.bundles:
+		t.Fatal("the bundle has been sent before the invocation is complete")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	require.NoError(t, agent.Flush(context.Background()))
+
+	select {
+	case bundle := <-acceptor.bundles:
+		assert.Len(t, bundle.Spans, 1)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no bundle has been sent after the invocation")
+	}
+
+	runtimeAPI.events <- lambdaExtensionEvent{EventType: awsLambdaShutdownEvent}
+}
+
+func TestLambdaAgent_Extension_InvocationTimeout(t *testing.T) {
+	runtimeAPI := newFakeLambdaRuntimeAPI()
+	defer runtimeAPI.Close()
+
+	acceptor := newFakeServerlessAcceptor()
+	defer acceptor.Close()
+
+	defer restoreEnvVarFunc("AWS_LAMBDA_RUNTIME_API")()
+	os.Setenv("AWS_LAMBDA_RUNTIME_API", strings.TrimPrefix(runtimeAPI.URL, "http://"))
+
+	defer restoreEnvVarFunc("INSTANA_AWS_LAMBDA_EXTENSION")()
+	os.Setenv("INSTANA_AWS_LAMBDA_EXTENSION", "true")
+
+	agent := newLambdaAgent("test-service", acceptor.URL, "testkey", nil, defaultLogger)
+	require.True(t, agent.extensionEnabled())
+
+	require.NoError(t, agent.SendSpans([]Span{newTestLambdaSpan("arn:aws:lambda:us-east-2:123456789012:function:test:1")}))
+
+	// the handler never completes the invocation
+	runtimeAPI.events <- lambdaExtensionEvent{
+		EventType:  awsLambdaInvokeEvent,
+		DeadlineMs: time.Now().Add(awsLambdaExtensionSendTimeout+100*time.Millisecond).UnixNano() / int64(time.Millisecond),
+	}
+
+	select {
+	case bundle := <-acceptor.bundles:
+		assert.Len(t, bundle.Spans, 1)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no bundle has been sent before the invocation deadline")
+	}
+
+	runtimeAPI.events <- lambdaExtensionEvent{EventType: awsLambdaShutdownEvent}
+}
+
+func TestLambdaAgent_Extension_RegistrationFailed(t *testing.T) {
+	runtimeAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer runtimeAPI.Close()
+
+	acceptor := newFakeServerlessAcceptor()
+	defer acceptor.Close()
+
+	defer restoreEnvVarFunc("AWS_LAMBDA_RUNTIME_API")()
+	os.Setenv("AWS_LAMBDA_RUNTIME_API", strings.TrimPrefix(runtimeAPI.URL, "http://"))
+
+	defer restoreEnvVarFunc("INSTANA_AWS_LAMBDA_EXTENSION")()
+	os.Setenv("INSTANA_AWS_LAMBDA_EXTENSION", "true")
+
+	agent := newLambdaAgent("test-service", acceptor.URL, "testkey", nil, defaultLogger)
+	require.False(t, agent.extensionEnabled())
+
+	require.NoError(t, agent.SendSpans([]Span{newTestLambdaSpan("arn:aws:lambda:us-east-2:123456789012:function:test:1")}))
+
+	// the data is sent synchronously
+	require.NoError(t, agent.Flush(context.Background()))
+
+	select {
+	case bundle := <-acceptor.bundles:
+		assert.Len(t, bundle.Spans, 1)
+	default:
+		t.Fatal("no bundle has been sent during flush")
+	}
+}
+
+func TestLambdaAgent_Extension_NextEventFailed(t *testing.T) {
+	runtimeAPI := newFakeLambdaRuntimeAPI()
+	defer runtimeAPI.Close()
+
+	acceptor := newFakeServerlessAcceptor()
+	defer acceptor.Close()
+
+	defer restoreEnvVarFunc("AWS_LAMBDA_RUNTIME_API")()
+	os.Setenv("AWS_LAMBDA_RUNTIME_API", strings.TrimPrefix(runtimeAPI.URL, "http://"))
+
+	defer restoreEnvVarFunc("INSTANA_AWS_LAMBDA_EXTENSION")()
+	os.Setenv("INSTANA_AWS_LAMBDA_EXTENSION", "true")
+
+	agent := newLambdaAgent("test-service", acceptor.URL, "testkey", nil, defaultLogger)
+	require.True(t, agent.extensionEnabled())
+
+	close(runtimeAPI.events)
+
+	assert.Eventually(t, func() bool {
+		return !agent.extensionEnabled()
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// fakeLambdaRuntimeAPI is a fake of the AWS Lambda Extensions API that delivers events sent to its events channel.
+// Closing the events channel makes the next event request fail.
+type fakeLambdaRuntimeAPI struct {
+	*httptest.Server
+
+	events           chan lambdaExtensionEvent
+	registeredName   string
+	registeredEvents []string
+}
+
+func newFakeLambdaRuntimeAPI() *fakeLambdaRuntimeAPI {
+	api := &fakeLambdaRuntimeAPI{
+		events: make(chan lambdaExtensionEvent),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(awsLambdaExtensionAPIPath+"/register", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Events []string `json:"events"`
+		}
+
+		if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&body) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		api.registeredName = r.Header.Get(awsLambdaExtensionNameHeader)
+		api.registeredEvents = body.Events
+
+		w.Header().Set(awsLambdaExtensionIDHeader, "extension-id")
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc(awsLambdaExtensionAPIPath+"/event/next", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(awsLambdaExtensionIDHeader) != "extension-id" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		ev, ok := <-api.events
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(ev)
+	})
+
+	api.Server = httptest.NewServer(mux)
+
+	return api
+}
+
+type fakeServerlessBundle struct {
+	Spans []struct {
+		From *fromS `json:"f"`
+	} `json:"spans"`
+}
+
+// fakeServerlessAcceptor is a fake of the serverless acceptor that forwards received bundles to its bundles channel
+type fakeServerlessAcceptor struct {
+	*httptest.Server
+
+	bundles chan fakeServerlessBundle
+}
+
+func newFakeServerlessAcceptor() *fakeServerlessAcceptor {
+	acc := &fakeServerlessAcceptor{
+		bundles: make(chan fakeServerlessBundle, 10),
+	}
+
+	acc.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bundle" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		var bundle fakeServerlessBundle
+		if err := json.NewDecoder(r.Body).Decode(&bundle); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		acc.bundles <- bundle
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	return acc
+}
+
+func newTestLambdaSpan(arn string) Span {
+	return Span{
+		Name: "aws.lambda.entry",
+		Data: AWSLambdaSpanData{
+			Snapshot: AWSLambdaSpanTags{ARN: arn, Runtime: "go"},
+		},
+	}
+}
+
+func restoreEnvVarFunc(key string) func() {
+	if oldValue, ok := os.LookupEnv(key); ok {
+		return func() { os.Setenv(key, oldValue) }
+	}
+
+	return func() { os.Unsetenv(key) }
+}
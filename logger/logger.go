@@ -130,6 +130,14 @@ func (l *Logger) SetLevel(level Level) {
 	l.lvl = level
 }
 
+// Level returns the current log level of this logger instance
+func (l *Logger) Level() Level {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.lvl
+}
+
 // SetPrefix sets the label that will be used as a prefix for each log line
 func (l *Logger) SetPrefix(prefix string) {
 	l.mu.Lock()
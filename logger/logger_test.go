@@ -40,6 +40,13 @@ func TestLogger_SetPrefix_DefaultValue(t *testing.T) {
 	assert.Equal(t, []interface{}{"instana: ", "ERROR", ": ", "error level"}, p.Records[0])
 }
 
+func TestLogger_Level(t *testing.T) {
+	l := logger.New(&printer{})
+	l.SetLevel(logger.WarnLevel)
+
+	assert.Equal(t, logger.WarnLevel, l.Level())
+}
+
 func TestLogger_SetLevel(t *testing.T) {
 	examples := map[logger.Level][][]interface{}{
 		logger.DebugLevel: {
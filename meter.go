@@ -87,5 +87,6 @@ func (m *meterS) collectMetrics() acceptor.Metrics {
 		CgoCall:     runtime.NumCgoCall(),
 		Goroutine:   runtime.NumGoroutine(),
 		MemoryStats: m.collectMemoryMetrics(),
+		Collector:   newCollectorMetricsPayload(CollectorStats()),
 	}
 }
@@ -4,6 +4,8 @@
 package instana
 
 import (
+	"context"
+	"net"
 	"os"
 	"strconv"
 )
@@ -48,6 +50,41 @@ type Options struct {
 	// Recorder records and manages spans. When this option is not set, instana.NewRecorder() will be used.
 	Recorder SpanRecorder
 
+	// SpanProcessors is a chain of processors applied to each finished span before it is recorded. Processors
+	// are called in the order they are listed and can modify the span tags or drop the span.
+	SpanProcessors []SpanProcessor
+
+	// SpoolDir is the directory used to store the spans that could not be sent to the agent. Once the agent
+	// becomes available, the stored spans are sent before any new ones. The spooled spans survive the process
+	// restart, provided that the directory is preserved. The spooled spans are delivered at least once, so
+	// some of them can be sent again if the process crashes while replaying the spool. The spooling is disabled
+	// if this value is empty. This option can also be set via INSTANA_SPOOL_DIR env variable.
+	SpoolDir string
+	// SpoolMaxSize is the maximum size of the span spool in bytes. Once this limit is reached, the oldest
+	// spans are discarded. If not set, spool.DefaultMaxSize is used.
+	SpoolMaxSize int64
+	// AgentUnixSocket is the path to the unix domain socket exposed by the host agent. If set, the sensor connects
+	// to the agent via this socket instead of AgentHost:AgentPort. This option can also be set via
+	// INSTANA_AGENT_UNIX_SOCKET env variable.
+	AgentUnixSocket string
+	// AgentDialContext is a custom function used to establish connections to the host agent. If set, it takes
+	// precedence over AgentUnixSocket.
+	AgentDialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+	// AgentTLS configures HTTPS and mutual TLS for the host agent communication. The certificate files are
+	// reloaded once they change.
+	AgentTLS AgentTLSOptions
+	// AgentRetryPolicy configures the delays between the attempts to connect to the host agent and the number of
+	// retries. If not set, instana.DefaultRetryPolicy() is used. The fields left at their zero value are set
+	// to the defaults.
+	AgentRetryPolicy *RetryPolicy
+	// EnableAgentActions turns on the execution of actions requested by the host agent, such as profile
+	// capturing or goroutine dumps. This option can also be set via INSTANA_AGENT_ACTIONS env variable.
+	EnableAgentActions bool
+	// GzipAgentRequests enables the gzip compression of spans, metrics and events sent to the host agent,
+	// or to the serverless acceptor in serverless mode. This option can also be enabled via INSTANA_AGENT_GZIP
+	// env variable.
+	GzipAgentRequests bool
+
 	disableW3CTraceCorrelation bool
 }
 
@@ -107,5 +144,54 @@ func (opts *Options) setDefaults() {
 		opts.Tracer.CollectableHTTPHeaders = parseInstanaExtraHTTPHeaders(collectableHeaders)
 	}
 
+	if s, ok := os.LookupEnv("INSTANA_PROPAGATORS"); ok {
+		propagators, err := parseInstanaPropagators(s)
+		if err != nil {
+			defaultLogger.Warn("invalid INSTANA_PROPAGATORS= env variable value: ", err, ", ignoring")
+		} else {
+			opts.Tracer.Propagators = propagators
+		}
+	}
+
+	if os.Getenv("INSTANA_128BIT_TRACE_IDS") != "" {
+		opts.Tracer.Use128BitTraceIDs = true
+	}
+
+	if opts.AgentUnixSocket == "" {
+		opts.AgentUnixSocket = os.Getenv("INSTANA_AGENT_UNIX_SOCKET")
+	}
+
+	if os.Getenv("INSTANA_AGENT_TLS") != "" {
+		opts.AgentTLS.Enabled = true
+	}
+
+	if opts.AgentTLS.CAFile == "" {
+		opts.AgentTLS.CAFile = os.Getenv("INSTANA_AGENT_TLS_CA_FILE")
+	}
+
+	if opts.AgentTLS.CertFile == "" {
+		opts.AgentTLS.CertFile = os.Getenv("INSTANA_AGENT_TLS_CERT_FILE")
+	}
+
+	if opts.AgentTLS.KeyFile == "" {
+		opts.AgentTLS.KeyFile = os.Getenv("INSTANA_AGENT_TLS_KEY_FILE")
+	}
+
+	if opts.AgentTLS.ServerName == "" {
+		opts.AgentTLS.ServerName = os.Getenv("INSTANA_AGENT_TLS_SERVER_NAME")
+	}
+
+	if os.Getenv("INSTANA_AGENT_ACTIONS") != "" {
+		opts.EnableAgentActions = true
+	}
+
+	if os.Getenv("INSTANA_AGENT_GZIP") != "" {
+		opts.GzipAgentRequests = true
+	}
+
+	if opts.SpoolDir == "" {
+		opts.SpoolDir = os.Getenv("INSTANA_SPOOL_DIR")
+	}
+
 	opts.disableW3CTraceCorrelation = os.Getenv("INSTANA_DISABLE_W3C_TRACE_CORRELATION") != ""
 }
@@ -4,6 +4,7 @@
 package instana_test
 
 import (
+	"os"
 	"testing"
 
 	instana "github.com/instana/go-sensor"
@@ -19,3 +20,33 @@ func TestDefaultOptions(t *testing.T) {
 		Tracer:                      instana.DefaultTracerOptions(),
 	}, instana.DefaultOptions())
 }
+
+func TestDefaultOptions_AgentTLS(t *testing.T) {
+	for k, v := range map[string]string{
+		"INSTANA_AGENT_TLS":             "true",
+		"INSTANA_AGENT_TLS_CA_FILE":     "/etc/instana/ca.pem",
+		"INSTANA_AGENT_TLS_CERT_FILE":   "/etc/instana/client.pem",
+		"INSTANA_AGENT_TLS_KEY_FILE":    "/etc/instana/client-key.pem",
+		"INSTANA_AGENT_TLS_SERVER_NAME": "instana-agent",
+	} {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	assert.Equal(t, instana.AgentTLSOptions{
+		Enabled:    true,
+		CAFile:     "/etc/instana/ca.pem",
+		CertFile:   "/etc/instana/client.pem",
+		KeyFile:    "/etc/instana/client-key.pem",
+		ServerName: "instana-agent",
+	}, instana.DefaultOptions().AgentTLS)
+}
+
+func TestDefaultOptions_AgentActions(t *testing.T) {
+	assert.False(t, instana.DefaultOptions().EnableAgentActions)
+
+	os.Setenv("INSTANA_AGENT_ACTIONS", "true")
+	defer os.Unsetenv("INSTANA_AGENT_ACTIONS")
+
+	assert.True(t, instana.DefaultOptions().EnableAgentActions)
+}
@@ -0,0 +1,34 @@
+// (c) Copyright IBM Corp. 2023
+
+package otelbridge_test
+
+import (
+	"context"
+
+	instana "github.com/instana/go-sensor"
+	"github.com/instana/go-sensor/otelbridge"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// This example shows how to report the spans created with OpenTelemetry API to Instana
+func Example() {
+	sensor := instana.NewSensor("my-service")
+
+	// Register the Instana trace provider globally to be used by OpenTelemetry instrumentations
+	otel.SetTracerProvider(otelbridge.NewTracerProvider(sensor))
+
+	// The OpenTracing span stored in context becomes the parent of the OpenTelemetry span
+	entrySpan := sensor.Tracer().StartSpan("entry")
+	defer entrySpan.Finish()
+
+	ctx := instana.ContextWithSpan(context.Background(), entrySpan)
+
+	_, sp := otel.Tracer("my-library").Start(ctx, "process",
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attribute.String("job.id", "42")))
+	defer sp.End()
+
+	// ...
+}
@@ -0,0 +1,205 @@
+// (c) Copyright IBM Corp. 2023
+
+package otelbridge
+
+import (
+	"encoding/binary"
+	"sync"
+
+	instana "github.com/instana/go-sensor"
+	ot "github.com/opentracing/opentracing-go"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// span is an OpenTelemetry span that wraps an Instana one. The attributes are collected until the span
+// is ended and then converted into the span tags.
+type span struct {
+	span     ot.Span
+	provider *TracerProvider
+
+	mu         sync.Mutex
+	name       string
+	kind       trace.SpanKind
+	attributes map[attribute.Key]attribute.Value
+	events     []ot.LogRecord
+	status     codes.Code
+	statusDesc string
+	ended      bool
+}
+
+var _ trace.Span = (*span)(nil)
+
+// End completes the span and passes it to the Instana recorder. Any calls to span methods after
+// the span has been ended are ignored.
+func (s *span) End(options ...trace.SpanEndOption) {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+
+	s.ended = true
+	operation, tags := convertAttributes(s.name, s.kind, s.attributes)
+
+	if s.status == codes.Error && s.statusDesc != "" {
+		if key, ok := errorTags[instana.RegisteredSpanType(operation)]; ok {
+			tags[key] = s.statusDesc
+		}
+	}
+
+	events, status, statusDesc := s.events, s.status, s.statusDesc
+	s.mu.Unlock()
+
+	s.span.SetOperationName(operation)
+	for k, v := range tags {
+		s.span.SetTag(k, v)
+	}
+
+	if status == codes.Error {
+		if statusDesc == "" {
+			statusDesc = codes.Error.String()
+		}
+
+		s.span.LogFields(otlog.Object("error", statusDesc))
+	}
+
+	cfg := trace.NewSpanEndConfig(options...)
+	s.span.FinishWithOptions(ot.FinishOptions{
+		FinishTime: cfg.Timestamp(),
+		LogRecords: events,
+	})
+}
+
+// AddEvent adds an event to the span logs
+func (s *span) AddEvent(name string, options ...trace.EventOption) {
+	cfg := trace.NewEventConfig(options...)
+
+	fields := []otlog.Field{otlog.String("event", name)}
+	for _, attr := range cfg.Attributes() {
+		fields = append(fields, otlog.Object(string(attr.Key), attr.Value.AsInterface()))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ended {
+		return
+	}
+
+	s.events = append(s.events, ot.LogRecord{
+		Timestamp: cfg.Timestamp(),
+		Fields:    fields,
+	})
+}
+
+// IsRecording returns true until the span is ended
+func (s *span) IsRecording() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return !s.ended
+}
+
+// RecordError logs an error and marks the span as erroneous
+func (s *span) RecordError(err error, options ...trace.EventOption) {
+	if err == nil || !s.IsRecording() {
+		return
+	}
+
+	s.span.LogFields(otlog.Error(err))
+}
+
+// SpanContext returns the OpenTelemetry representation of the Instana span context
+func (s *span) SpanContext() trace.SpanContext {
+	sc, ok := s.span.Context().(instana.SpanContext)
+	if !ok {
+		return trace.SpanContext{}
+	}
+
+	return spanContext(sc)
+}
+
+// SetStatus sets the span status. A status description is only stored for the codes.Error status.
+// Once the status is set to codes.Ok, it can't be changed anymore.
+func (s *span) SetStatus(code codes.Code, description string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ended || s.status == codes.Ok || code == codes.Unset {
+		return
+	}
+
+	s.status, s.statusDesc = code, ""
+	if code == codes.Error {
+		s.statusDesc = description
+	}
+}
+
+// SetName sets the span name
+func (s *span) SetName(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ended {
+		return
+	}
+
+	s.name = name
+}
+
+// SetAttributes sets span attributes overriding any existing values for the same keys
+func (s *span) SetAttributes(kv ...attribute.KeyValue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ended {
+		return
+	}
+
+	if s.attributes == nil {
+		s.attributes = make(map[attribute.Key]attribute.Value, len(kv))
+	}
+
+	for _, attr := range kv {
+		if !attr.Valid() {
+			continue
+		}
+
+		s.attributes[attr.Key] = attr.Value
+	}
+}
+
+// TracerProvider returns the trace provider that created this span
+func (s *span) TracerProvider() trace.TracerProvider {
+	return s.provider
+}
+
+// spanContext converts an Instana span context into its OpenTelemetry representation
+func spanContext(sc instana.SpanContext) trace.SpanContext {
+	var tid trace.TraceID
+	binary.BigEndian.PutUint64(tid[:8], uint64(sc.TraceIDHi))
+	binary.BigEndian.PutUint64(tid[8:], uint64(sc.TraceID))
+
+	var sid trace.SpanID
+	binary.BigEndian.PutUint64(sid[:], uint64(sc.SpanID))
+
+	var flags trace.TraceFlags
+	if !sc.Suppressed {
+		flags = trace.FlagsSampled
+	}
+
+	cfg := trace.SpanContextConfig{
+		TraceID:    tid,
+		SpanID:     sid,
+		TraceFlags: flags,
+	}
+
+	if ts, err := trace.ParseTraceState(sc.W3CContext.RawState); err == nil {
+		cfg.TraceState = ts
+	}
+
+	return trace.NewSpanContext(cfg)
+}
@@ -0,0 +1,158 @@
+// (c) Copyright IBM Corp. 2023
+
+package otelbridge
+
+import (
+	"strings"
+
+	instana "github.com/instana/go-sensor"
+	ot "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// errorTags contains the names of tags used to store the error message for registered span types
+var errorTags = map[instana.RegisteredSpanType]string{
+	instana.HTTPServerSpanType: "http.error",
+	instana.HTTPClientSpanType: "http.error",
+	instana.RPCServerSpanType:  "rpc.error",
+	instana.RPCClientSpanType:  "rpc.error",
+}
+
+// spanKind converts the OpenTelemetry span kind into the value of span.kind tag
+func spanKind(kind trace.SpanKind) interface{} {
+	switch kind {
+	case trace.SpanKindServer:
+		return ext.SpanKindRPCServerEnum
+	case trace.SpanKindClient:
+		return ext.SpanKindRPCClientEnum
+	case trace.SpanKindProducer:
+		return ext.SpanKindProducerEnum
+	case trace.SpanKindConsumer:
+		return ext.SpanKindConsumerEnum
+	default:
+		return "intermediate"
+	}
+}
+
+// convertAttributes converts OpenTelemetry span attributes into Instana span tags. If the attributes follow the
+// OpenTelemetry semantic conventions for HTTP or RPC spans, the corresponding registered span type is returned
+// as an operation name along with the tags it expects. Otherwise the span is reported as an SDK span with all
+// attributes stored as custom tags.
+func convertAttributes(name string, kind trace.SpanKind, attrs map[attribute.Key]attribute.Value) (string, ot.Tags) {
+	switch {
+	case hasAttribute(attrs, "http.method", "http.request.method") && (kind == trace.SpanKindServer || kind == trace.SpanKindClient):
+		return httpSpanType(kind), httpTags(attrs)
+	case hasAttribute(attrs, "rpc.system") && (kind == trace.SpanKindServer || kind == trace.SpanKindClient):
+		return rpcSpanType(kind), rpcTags(attrs)
+	}
+
+	tags := make(ot.Tags, len(attrs))
+	for k, v := range attrs {
+		tags[string(k)] = v.AsInterface()
+	}
+
+	return name, tags
+}
+
+func httpSpanType(kind trace.SpanKind) string {
+	if kind == trace.SpanKindServer {
+		return string(instana.HTTPServerSpanType)
+	}
+
+	return string(instana.HTTPClientSpanType)
+}
+
+func httpTags(attrs map[attribute.Key]attribute.Value) ot.Tags {
+	tags := ot.Tags{}
+
+	if v, ok := lookupAttribute(attrs, "http.method", "http.request.method"); ok {
+		tags["http.method"] = v.Emit()
+	}
+
+	if v, ok := lookupAttribute(attrs, "http.status_code", "http.response.status_code"); ok {
+		tags["http.status"] = int(v.AsInt64())
+	}
+
+	if v, ok := lookupAttribute(attrs, "http.url", "url.full"); ok {
+		tags["http.url"] = v.Emit()
+	}
+
+	if v, ok := lookupAttribute(attrs, "url.path"); ok {
+		tags["http.path"] = v.Emit()
+	} else if v, ok := lookupAttribute(attrs, "http.target"); ok {
+		path := v.Emit()
+		if i := strings.IndexByte(path, '?'); i >= 0 {
+			path = path[:i]
+		}
+
+		tags["http.path"] = path
+	}
+
+	if v, ok := lookupAttribute(attrs, "http.route"); ok {
+		tags["http.path_tpl"] = v.Emit()
+	}
+
+	if v, ok := lookupAttribute(attrs, "http.host", "net.host.name", "server.address"); ok {
+		tags["http.host"] = v.Emit()
+	}
+
+	if v, ok := lookupAttribute(attrs, "http.scheme", "url.scheme"); ok {
+		tags["http.protocol"] = v.Emit()
+	}
+
+	return tags
+}
+
+func rpcSpanType(kind trace.SpanKind) string {
+	if kind == trace.SpanKindServer {
+		return string(instana.RPCServerSpanType)
+	}
+
+	return string(instana.RPCClientSpanType)
+}
+
+func rpcTags(attrs map[attribute.Key]attribute.Value) ot.Tags {
+	tags := ot.Tags{}
+
+	if v, ok := lookupAttribute(attrs, "rpc.system"); ok {
+		tags["rpc.flavor"] = v.Emit()
+	}
+
+	if method, ok := lookupAttribute(attrs, "rpc.method"); ok {
+		call := method.Emit()
+		if service, ok := lookupAttribute(attrs, "rpc.service"); ok {
+			call = service.Emit() + "/" + call
+		}
+
+		tags["rpc.call"] = call
+	}
+
+	if v, ok := lookupAttribute(attrs, "net.peer.name", "server.address"); ok {
+		tags["rpc.host"] = v.Emit()
+	}
+
+	if v, ok := lookupAttribute(attrs, "net.peer.port", "server.port"); ok {
+		tags["rpc.port"] = v.Emit()
+	}
+
+	return tags
+}
+
+func hasAttribute(attrs map[attribute.Key]attribute.Value, keys ...attribute.Key) bool {
+	_, ok := lookupAttribute(attrs, keys...)
+
+	return ok
+}
+
+// lookupAttribute returns the value of the first attribute found in the list of keys
+func lookupAttribute(attrs map[attribute.Key]attribute.Value, keys ...attribute.Key) (attribute.Value, bool) {
+	for _, k := range keys {
+		if v, ok := attrs[k]; ok {
+			return v, true
+		}
+	}
+
+	return attribute.Value{}, false
+}
@@ -0,0 +1,111 @@
+// (c) Copyright IBM Corp. 2023
+
+// Package otelbridge provides an OpenTelemetry trace provider that records spans using the Instana tracer,
+// so that the services instrumented with both OpenTracing and OpenTelemetry APIs report a single trace.
+package otelbridge
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	instana "github.com/instana/go-sensor"
+	"github.com/instana/go-sensor/w3ctrace"
+	ot "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerProvider is an OpenTelemetry trace.TracerProvider backed by the Instana tracer
+type TracerProvider struct {
+	sensor instana.TracerLogger
+}
+
+var _ trace.TracerProvider = (*TracerProvider)(nil)
+
+// NewTracerProvider returns a new OpenTelemetry trace provider that uses the Instana tracer to record spans
+func NewTracerProvider(sensor instana.TracerLogger) *TracerProvider {
+	return &TracerProvider{
+		sensor: sensor,
+	}
+}
+
+// Tracer returns an OpenTelemetry tracer. The instrumentation name and options are ignored, since all spans
+// are recorded by the same Instana tracer.
+func (tp *TracerProvider) Tracer(name string, opts ...trace.TracerOption) trace.Tracer {
+	return &tracer{
+		provider: tp,
+	}
+}
+
+type tracer struct {
+	provider *TracerProvider
+}
+
+// Start creates a new span. The parent span is looked up in the context using instana.SpanFromContext(), so
+// that a span started with OpenTracing API can be a parent of an OpenTelemetry one and vice versa. If there is
+// no active Instana span in the context, a remote span context extracted by an OpenTelemetry propagator is used.
+//
+// The returned context holds a reference to the new span for both OpenTelemetry and OpenTracing APIs.
+func (t *tracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	cfg := trace.NewSpanStartConfig(opts...)
+
+	startOpts := []ot.StartSpanOption{
+		ot.Tag{Key: string(ext.SpanKind), Value: spanKind(cfg.SpanKind())},
+	}
+
+	if !cfg.Timestamp().IsZero() {
+		startOpts = append(startOpts, ot.StartTime(cfg.Timestamp()))
+	}
+
+	if !cfg.NewRoot() {
+		if parent, ok := parentSpanContext(ctx); ok {
+			startOpts = append(startOpts, ot.ChildOf(parent))
+		}
+	}
+
+	for _, link := range cfg.Links() {
+		if !link.SpanContext.IsValid() {
+			continue
+		}
+
+		startOpts = append(startOpts, ot.FollowsFrom(remoteSpanContext(link.SpanContext)))
+	}
+
+	sp := &span{
+		span:     t.provider.sensor.Tracer().StartSpan(name, startOpts...),
+		provider: t.provider,
+		name:     name,
+		kind:     cfg.SpanKind(),
+	}
+	sp.SetAttributes(cfg.Attributes()...)
+
+	return trace.ContextWithSpan(instana.ContextWithSpan(ctx, sp.span), sp), sp
+}
+
+// parentSpanContext returns the context of the active span, which is either an Instana span, or a remote
+// span extracted using OpenTelemetry propagators
+func parentSpanContext(ctx context.Context) (ot.SpanContext, bool) {
+	if parent, ok := instana.SpanFromContext(ctx); ok {
+		return parent.Context(), true
+	}
+
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		return remoteSpanContext(sc), true
+	}
+
+	return nil, false
+}
+
+// remoteSpanContext converts an OpenTelemetry span context into an Instana one using its W3C trace context
+// representation, so that the trace is continued the same way as if it was received via the traceparent header
+func remoteSpanContext(sc trace.SpanContext) instana.SpanContext {
+	tid, sid := sc.TraceID(), sc.SpanID()
+
+	return instana.SpanContext{
+		W3CContext: w3ctrace.Context{
+			RawParent: fmt.Sprintf("00-%s-%s-%02x", hex.EncodeToString(tid[:]), hex.EncodeToString(sid[:]), byte(sc.TraceFlags())),
+			RawState:  sc.TraceState().String(),
+		},
+	}
+}
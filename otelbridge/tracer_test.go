@@ -0,0 +1,278 @@
+// (c) Copyright IBM Corp. 2023
+
+package otelbridge_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	instana "github.com/instana/go-sensor"
+	"github.com/instana/go-sensor/acceptor"
+	"github.com/instana/go-sensor/autoprofile"
+	"github.com/instana/go-sensor/otelbridge"
+	ot "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMain(m *testing.M) {
+	instana.InitSensor(&instana.Options{
+		Service:     "otelbridge-test",
+		AgentClient: alwaysReadyClient{},
+	})
+
+	os.Exit(m.Run())
+}
+
+func TestTracer_Start_SDKSpan(t *testing.T) {
+	recorder := instana.NewTestRecorder()
+	sensor := instana.NewSensorWithTracer(instana.NewTracerWithEverything(nil, recorder))
+
+	tracer := otelbridge.NewTracerProvider(sensor).Tracer("test")
+
+	startTime := time.Now().Add(-time.Second)
+
+	_, sp := tracer.Start(context.Background(), "process-job",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithTimestamp(startTime),
+		trace.WithAttributes(attribute.String("job.id", "42")))
+	sp.SetAttributes(attribute.Int("job.attempt", 3), attribute.Bool("job.retry", true))
+	sp.SetName("process-queued-job")
+	sp.AddEvent("job started")
+
+	assert.True(t, sp.IsRecording())
+	sp.End()
+	assert.False(t, sp.IsRecording())
+
+	spans := recorder.GetQueuedSpans()
+	require.Len(t, spans, 1)
+
+	span := spans[0]
+	assert.Equal(t, "sdk", span.Name)
+	assert.Equal(t, int(instana.EntrySpanKind), span.Kind)
+	assert.Equal(t, 0, span.Ec)
+	assert.Equal(t, uint64(startTime.UnixNano())/uint64(time.Millisecond), span.Timestamp)
+
+	require.IsType(t, instana.SDKSpanData{}, span.Data)
+	data := span.Data.(instana.SDKSpanData)
+
+	assert.Equal(t, "process-queued-job", data.Tags.Name)
+	assert.Equal(t, "entry", data.Tags.Type)
+	assert.Equal(t, ot.Tags{
+		"span.kind":   ext.SpanKindConsumerEnum,
+		"job.id":      "42",
+		"job.attempt": int64(3),
+		"job.retry":   true,
+	}, data.Tags.Custom["tags"])
+}
+
+func TestTracer_Start_HTTPServerSpan(t *testing.T) {
+	recorder := instana.NewTestRecorder()
+	sensor := instana.NewSensorWithTracer(instana.NewTracerWithEverything(nil, recorder))
+
+	tracer := otelbridge.NewTracerProvider(sensor).Tracer("test")
+
+	_, sp := tracer.Start(context.Background(), "GET /users/{id}",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.method", "GET"),
+			attribute.String("http.target", "/users/42?fields=name"),
+			attribute.String("http.route", "/users/{id}"),
+			attribute.String("net.host.name", "example.com"),
+		))
+	sp.SetAttributes(attribute.Int("http.status_code", 503))
+	sp.SetStatus(codes.Error, "service unavailable")
+	sp.End()
+
+	spans := recorder.GetQueuedSpans()
+	require.Len(t, spans, 2)
+
+	span, logSpan := spans[0], spans[1]
+
+	assert.Equal(t, "g.http", span.Name)
+	assert.Equal(t, int(instana.EntrySpanKind), span.Kind)
+	assert.Equal(t, 1, span.Ec)
+
+	require.IsType(t, instana.HTTPSpanData{}, span.Data)
+	assert.Equal(t, instana.HTTPSpanTags{
+		Method:       "GET",
+		Status:       503,
+		Path:         "/users/42",
+		PathTemplate: "/users/{id}",
+		Host:         "example.com",
+		Error:        "service unavailable",
+	}, span.Data.(instana.HTTPSpanData).Tags)
+
+	assert.Equal(t, "log.go", logSpan.Name)
+	assert.Equal(t, span.SpanID, logSpan.ParentID)
+}
+
+func TestTracer_Start_RPCClientSpan(t *testing.T) {
+	recorder := instana.NewTestRecorder()
+	sensor := instana.NewSensorWithTracer(instana.NewTracerWithEverything(nil, recorder))
+
+	tracer := otelbridge.NewTracerProvider(sensor).Tracer("test")
+
+	_, sp := tracer.Start(context.Background(), "users.Users/Get",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "grpc"),
+			attribute.String("rpc.service", "users.Users"),
+			attribute.String("rpc.method", "Get"),
+			attribute.String("net.peer.name", "users.local"),
+			attribute.Int("net.peer.port", 8443),
+		))
+	sp.End()
+
+	spans := recorder.GetQueuedSpans()
+	require.Len(t, spans, 1)
+
+	span := spans[0]
+	assert.Equal(t, "rpc-client", span.Name)
+	assert.Equal(t, int(instana.ExitSpanKind), span.Kind)
+
+	require.IsType(t, instana.RPCSpanData{}, span.Data)
+	assert.Equal(t, instana.RPCSpanTags{
+		Host:   "users.local",
+		Port:   "8443",
+		Call:   "users.Users/Get",
+		Flavor: "grpc",
+	}, span.Data.(instana.RPCSpanData).Tags)
+}
+
+func TestTracer_Start_MixedContext(t *testing.T) {
+	recorder := instana.NewTestRecorder()
+	sensor := instana.NewSensorWithTracer(instana.NewTracerWithEverything(nil, recorder))
+
+	tracer := otelbridge.NewTracerProvider(sensor).Tracer("test")
+
+	// OpenTracing entry span
+	entry := sensor.Tracer().StartSpan("entry")
+	ctx := instana.ContextWithSpan(context.Background(), entry)
+
+	// OpenTelemetry intermediate span
+	ctx, sp := tracer.Start(ctx, "intermediate")
+
+	// OpenTracing exit span started from the context
+	parent, ok := instana.SpanFromContext(ctx)
+	require.True(t, ok)
+
+	exit := sensor.Tracer().StartSpan("exit", ot.ChildOf(parent.Context()))
+
+	exit.Finish()
+	sp.End()
+	entry.Finish()
+
+	spans := recorder.GetQueuedSpans()
+	require.Len(t, spans, 3)
+
+	exitSpan, interSpan, entrySpan := spans[0], spans[1], spans[2]
+
+	assert.Equal(t, entrySpan.TraceID, interSpan.TraceID)
+	assert.Equal(t, entrySpan.SpanID, interSpan.ParentID)
+
+	assert.Equal(t, interSpan.TraceID, exitSpan.TraceID)
+	assert.Equal(t, interSpan.SpanID, exitSpan.ParentID)
+
+	// OpenTelemetry span context refers to the same span
+	sc := sp.SpanContext()
+	assert.True(t, sc.IsValid())
+	assert.True(t, sc.IsSampled())
+	assert.Equal(t, instana.FormatID(interSpan.SpanID), sc.SpanID().String())
+	assert.Equal(t, instana.FormatLongID(interSpan.TraceIDHi, interSpan.TraceID), sc.TraceID().String())
+
+	assert.Equal(t, sp, trace.SpanFromContext(ctx))
+}
+
+func TestTracer_Start_RemoteParent(t *testing.T) {
+	recorder := instana.NewTestRecorder()
+	sensor := instana.NewSensorWithTracer(instana.NewTracerWithEverything(nil, recorder))
+
+	tracer := otelbridge.NewTracerProvider(sensor).Tracer("test")
+
+	tid, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	require.NoError(t, err)
+
+	sid, err := trace.SpanIDFromHex("00f067aa0ba902b7")
+	require.NoError(t, err)
+
+	ctx := trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    tid,
+		SpanID:     sid,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	}))
+
+	_, sp := tracer.Start(ctx, "entry", trace.WithSpanKind(trace.SpanKindServer))
+	sp.End()
+
+	spans := recorder.GetQueuedSpans()
+	require.Len(t, spans, 1)
+
+	span := spans[0]
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", instana.FormatLongID(span.TraceIDHi, span.TraceID))
+	assert.Equal(t, "00f067aa0ba902b7", instana.FormatID(span.ParentID))
+	assert.Equal(t, tid, sp.SpanContext().TraceID())
+}
+
+func TestTracer_Start_NewRoot(t *testing.T) {
+	recorder := instana.NewTestRecorder()
+	sensor := instana.NewSensorWithTracer(instana.NewTracerWithEverything(nil, recorder))
+
+	tracer := otelbridge.NewTracerProvider(sensor).Tracer("test")
+
+	ctx, parent := tracer.Start(context.Background(), "parent")
+	_, sp := tracer.Start(ctx, "root", trace.WithNewRoot())
+
+	sp.End()
+	parent.End()
+
+	spans := recorder.GetQueuedSpans()
+	require.Len(t, spans, 2)
+
+	assert.NotEqual(t, spans[0].TraceID, spans[1].TraceID)
+	assert.Zero(t, spans[0].ParentID)
+}
+
+func TestSpan_RecordError(t *testing.T) {
+	recorder := instana.NewTestRecorder()
+	sensor := instana.NewSensorWithTracer(instana.NewTracerWithEverything(nil, recorder))
+
+	tracer := otelbridge.NewTracerProvider(sensor).Tracer("test")
+
+	_, sp := tracer.Start(context.Background(), "task")
+	sp.RecordError(errors.New("something went wrong"))
+	sp.End()
+
+	// calls after the span has ended are ignored
+	sp.RecordError(errors.New("something else went wrong"))
+	sp.SetAttributes(attribute.String("key", "value"))
+	sp.End()
+
+	spans := recorder.GetQueuedSpans()
+	require.Len(t, spans, 2)
+
+	span, logSpan := spans[0], spans[1]
+
+	assert.Equal(t, 1, span.Ec)
+	assert.Equal(t, span.SpanID, logSpan.ParentID)
+
+	require.IsType(t, instana.LogSpanData{}, logSpan.Data)
+	assert.Equal(t, `error.object: "something went wrong"`, logSpan.Data.(instana.LogSpanData).Tags.Message)
+}
+
+type alwaysReadyClient struct{}
+
+func (alwaysReadyClient) Ready() bool                                       { return true }
+func (alwaysReadyClient) SendMetrics(data acceptor.Metrics) error           { return nil }
+func (alwaysReadyClient) SendEvent(event *instana.EventData) error          { return nil }
+func (alwaysReadyClient) SendSpans(spans []instana.Span) error              { return nil }
+func (alwaysReadyClient) SendProfiles(profiles []autoprofile.Profile) error { return nil }
+func (alwaysReadyClient) Flush(context.Context) error                       { return nil }
@@ -0,0 +1,6 @@
+// (c) Copyright IBM Corp. 2023
+
+package otelbridge
+
+// Version is the instrumentation module semantic version
+const Version = "0.1.0"
@@ -0,0 +1,292 @@
+// (c) Copyright IBM Corp. 2023
+
+// Package otlp provides the data types of the OpenTelemetry protocol (OTLP) trace export request along with their
+// protobuf encoding as defined by https://github.com/open-telemetry/opentelemetry-proto. Only the subset of fields
+// required to export spans is supported.
+package otlp
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// SpanKind is the type of span, see https://github.com/open-telemetry/opentelemetry-proto/blob/v1.0.0/opentelemetry/proto/trace/v1/trace.proto
+type SpanKind int32
+
+// Valid span kinds
+const (
+	SpanKindUnspecified SpanKind = iota
+	SpanKindInternal
+	SpanKindServer
+	SpanKindClient
+	SpanKindProducer
+	SpanKindConsumer
+)
+
+// StatusCode is the span status code
+type StatusCode int32
+
+// Valid span status codes
+const (
+	StatusCodeUnset StatusCode = iota
+	StatusCodeOk
+	StatusCodeError
+)
+
+// TracesRequest represents the opentelemetry.proto.collector.trace.v1.ExportTraceServiceRequest message
+type TracesRequest struct {
+	ResourceSpans []ResourceSpans
+}
+
+// ResourceSpans represents the opentelemetry.proto.trace.v1.ResourceSpans message
+type ResourceSpans struct {
+	Resource   Resource
+	ScopeSpans []ScopeSpans
+}
+
+// Resource represents the opentelemetry.proto.resource.v1.Resource message
+type Resource struct {
+	Attributes []KeyValue
+}
+
+// ScopeSpans represents the opentelemetry.proto.trace.v1.ScopeSpans message
+type ScopeSpans struct {
+	Scope InstrumentationScope
+	Spans []Span
+}
+
+// InstrumentationScope represents the opentelemetry.proto.common.v1.InstrumentationScope message
+type InstrumentationScope struct {
+	Name    string
+	Version string
+}
+
+// Span represents the opentelemetry.proto.trace.v1.Span message
+type Span struct {
+	TraceID           [16]byte
+	SpanID            [8]byte
+	ParentSpanID      [8]byte
+	Name              string
+	Kind              SpanKind
+	StartTimeUnixNano uint64
+	EndTimeUnixNano   uint64
+	Attributes        []KeyValue
+	Links             []Link
+	Status            Status
+}
+
+// Link represents the opentelemetry.proto.trace.v1.Span.Link message
+type Link struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+}
+
+// Status represents the opentelemetry.proto.trace.v1.Status message
+type Status struct {
+	Message string
+	Code    StatusCode
+}
+
+// KeyValue represents the opentelemetry.proto.common.v1.KeyValue message
+type KeyValue struct {
+	Key   string
+	Value Value
+}
+
+// Value represents the opentelemetry.proto.common.v1.AnyValue message. The value is expected to be
+// either a string, bool, int64, float64 or a []Value, any other type is encoded as an empty value.
+type Value struct {
+	Value interface{}
+}
+
+// StringValue returns a new string value
+func StringValue(s string) Value { return Value{s} }
+
+// BoolValue returns a new bool value
+func BoolValue(b bool) Value { return Value{b} }
+
+// IntValue returns a new integer value
+func IntValue(n int64) Value { return Value{n} }
+
+// DoubleValue returns a new floating point value
+func DoubleValue(f float64) Value { return Value{f} }
+
+// ArrayValue returns a new array value
+func ArrayValue(vs ...Value) Value { return Value{vs} }
+
+// Marshal returns the protobuf encoding of the request
+func (req TracesRequest) Marshal() []byte {
+	var e encoder
+	for _, rs := range req.ResourceSpans {
+		e.message(1, rs.encode)
+	}
+
+	return e.buf
+}
+
+func (rs ResourceSpans) encode(e *encoder) {
+	e.message(1, rs.Resource.encode)
+	for _, ss := range rs.ScopeSpans {
+		e.message(2, ss.encode)
+	}
+}
+
+func (r Resource) encode(e *encoder) {
+	for _, kv := range r.Attributes {
+		e.message(1, kv.encode)
+	}
+}
+
+func (ss ScopeSpans) encode(e *encoder) {
+	e.message(1, ss.Scope.encode)
+	for _, sp := range ss.Spans {
+		e.message(2, sp.encode)
+	}
+}
+
+func (s InstrumentationScope) encode(e *encoder) {
+	e.string(1, s.Name)
+	e.string(2, s.Version)
+}
+
+func (sp Span) encode(e *encoder) {
+	e.bytes(1, sp.TraceID[:])
+	e.bytes(2, sp.SpanID[:])
+	if sp.ParentSpanID != [8]byte{} {
+		e.bytes(4, sp.ParentSpanID[:])
+	}
+	e.string(5, sp.Name)
+	e.varint(6, uint64(sp.Kind))
+	e.fixed64(7, sp.StartTimeUnixNano)
+	e.fixed64(8, sp.EndTimeUnixNano)
+	for _, kv := range sp.Attributes {
+		e.message(9, kv.encode)
+	}
+	for _, l := range sp.Links {
+		e.message(13, l.encode)
+	}
+	e.message(15, sp.Status.encode)
+}
+
+func (l Link) encode(e *encoder) {
+	e.bytes(1, l.TraceID[:])
+	e.bytes(2, l.SpanID[:])
+}
+
+func (st Status) encode(e *encoder) {
+	e.string(2, st.Message)
+	e.varint(3, uint64(st.Code))
+}
+
+func (kv KeyValue) encode(e *encoder) {
+	e.string(1, kv.Key)
+	e.message(2, kv.Value.encode)
+}
+
+func (v Value) encode(e *encoder) {
+	switch val := v.Value.(type) {
+	case string:
+		e.field(1, wireBytes)
+		e.uvarint(uint64(len(val)))
+		e.buf = append(e.buf, val...)
+	case bool:
+		var n uint64
+		if val {
+			n = 1
+		}
+
+		e.field(2, wireVarint)
+		e.uvarint(n)
+	case int64:
+		e.field(3, wireVarint)
+		e.uvarint(uint64(val))
+	case float64:
+		e.field(4, wireFixed64)
+		e.uint64(math.Float64bits(val))
+	case []Value:
+		e.message(5, func(e *encoder) {
+			for _, item := range val {
+				e.message(1, item.encode)
+			}
+		})
+	}
+}
+
+// protobuf wire types, see https://protobuf.dev/programming-guides/encoding/#structure
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+// encoder is a minimal protobuf encoder that omits the fields with default values
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) field(num int, wireType int) {
+	e.uvarint(uint64(num)<<3 | uint64(wireType))
+}
+
+func (e *encoder) uvarint(n uint64) {
+	for n >= 0x80 {
+		e.buf = append(e.buf, byte(n)|0x80)
+		n >>= 7
+	}
+
+	e.buf = append(e.buf, byte(n))
+}
+
+func (e *encoder) varint(num int, n uint64) {
+	if n == 0 {
+		return
+	}
+
+	e.field(num, wireVarint)
+	e.uvarint(n)
+}
+
+func (e *encoder) fixed64(num int, n uint64) {
+	if n == 0 {
+		return
+	}
+
+	e.field(num, wireFixed64)
+	e.uint64(n)
+}
+
+func (e *encoder) uint64(n uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], n)
+	e.buf = append(e.buf, b[:]...)
+}
+
+func (e *encoder) bytes(num int, b []byte) {
+	if len(b) == 0 {
+		return
+	}
+
+	e.field(num, wireBytes)
+	e.uvarint(uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) string(num int, s string) {
+	if s == "" {
+		return
+	}
+
+	e.field(num, wireBytes)
+	e.uvarint(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// message encodes a nested message written by fn as a length-delimited field
+func (e *encoder) message(num int, fn func(e *encoder)) {
+	var nested encoder
+	fn(&nested)
+
+	e.field(num, wireBytes)
+	e.uvarint(uint64(len(nested.buf)))
+	e.buf = append(e.buf, nested.buf...)
+}
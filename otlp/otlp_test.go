@@ -0,0 +1,236 @@
+// (c) Copyright IBM Corp. 2023
+
+package otlp_test
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/instana/go-sensor/otlp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracesRequest_Marshal(t *testing.T) {
+	req := otlp.TracesRequest{
+		ResourceSpans: []otlp.ResourceSpans{
+			{
+				Resource: otlp.Resource{
+					Attributes: []otlp.KeyValue{
+						{Key: "service.name", Value: otlp.StringValue("my-service")},
+					},
+				},
+				ScopeSpans: []otlp.ScopeSpans{
+					{
+						Scope: otlp.InstrumentationScope{Name: "instana", Version: "1.0.0"},
+						Spans: []otlp.Span{
+							{
+								TraceID:           [16]byte{0x01, 15: 0x02},
+								SpanID:            [8]byte{0x03, 7: 0x04},
+								ParentSpanID:      [8]byte{0x05},
+								Name:              "g.http",
+								Kind:              otlp.SpanKindServer,
+								StartTimeUnixNano: 1000,
+								EndTimeUnixNano:   2000,
+								Attributes: []otlp.KeyValue{
+									{Key: "str", Value: otlp.StringValue("value")},
+									{Key: "bool", Value: otlp.BoolValue(true)},
+									{Key: "int", Value: otlp.IntValue(-1)},
+									{Key: "double", Value: otlp.DoubleValue(1.5)},
+									{Key: "array", Value: otlp.ArrayValue(otlp.IntValue(1), otlp.StringValue("two"))},
+								},
+								Links: []otlp.Link{
+									{TraceID: [16]byte{0x06}, SpanID: [8]byte{0x07}},
+								},
+								Status: otlp.Status{Code: otlp.StatusCodeError, Message: "failed"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	resourceSpans := decode(t, req.Marshal())
+	require.Len(t, resourceSpans, 1)
+	require.Equal(t, 1, resourceSpans[0].Num)
+
+	rs := decode(t, resourceSpans[0].Bytes)
+	require.Len(t, rs, 2)
+
+	// resource
+	require.Equal(t, 1, rs[0].Num)
+	resource := decode(t, rs[0].Bytes)
+	require.Len(t, resource, 1)
+	assert.Equal(t, keyValue{"service.name", "my-service"}, decodeKeyValue(t, resource[0].Bytes))
+
+	// scope spans
+	require.Equal(t, 2, rs[1].Num)
+	ss := decode(t, rs[1].Bytes)
+	require.Len(t, ss, 2)
+
+	scope := decode(t, ss[0].Bytes)
+	require.Len(t, scope, 2)
+	assert.Equal(t, "instana", string(scope[0].Bytes))
+	assert.Equal(t, "1.0.0", string(scope[1].Bytes))
+
+	// span
+	require.Equal(t, 2, ss[1].Num)
+	span := decode(t, ss[1].Bytes)
+
+	var (
+		nums  []int
+		attrs []keyValue
+	)
+	for _, f := range span {
+		nums = append(nums, f.Num)
+
+		switch f.Num {
+		case 1:
+			assert.Equal(t, []byte{0x01, 14: 0x00, 15: 0x02}, f.Bytes)
+		case 2:
+			assert.Equal(t, []byte{0x03, 6: 0x00, 7: 0x04}, f.Bytes)
+		case 4:
+			assert.Equal(t, []byte{0x05, 7: 0x00}, f.Bytes)
+		case 5:
+			assert.Equal(t, "g.http", string(f.Bytes))
+		case 6:
+			assert.Equal(t, uint64(otlp.SpanKindServer), f.Varint)
+		case 7:
+			assert.Equal(t, uint64(1000), f.Varint)
+		case 8:
+			assert.Equal(t, uint64(2000), f.Varint)
+		case 9:
+			attrs = append(attrs, decodeKeyValue(t, f.Bytes))
+		case 13:
+			link := decode(t, f.Bytes)
+			require.Len(t, link, 2)
+			assert.Equal(t, []byte{0x06, 15: 0x00}, link[0].Bytes)
+			assert.Equal(t, []byte{0x07, 7: 0x00}, link[1].Bytes)
+		case 15:
+			status := decode(t, f.Bytes)
+			require.Len(t, status, 2)
+			assert.Equal(t, "failed", string(status[0].Bytes))
+			assert.Equal(t, uint64(otlp.StatusCodeError), status[1].Varint)
+		}
+	}
+
+	assert.Equal(t, []int{1, 2, 4, 5, 6, 7, 8, 9, 9, 9, 9, 9, 13, 15}, nums)
+	assert.Equal(t, []keyValue{
+		{"str", "value"},
+		{"bool", true},
+		{"int", int64(-1)},
+		{"double", 1.5},
+		{"array", []interface{}{int64(1), "two"}},
+	}, attrs)
+}
+
+func TestTracesRequest_Marshal_DefaultValues(t *testing.T) {
+	req := otlp.TracesRequest{
+		ResourceSpans: []otlp.ResourceSpans{
+			{ScopeSpans: []otlp.ScopeSpans{{Spans: []otlp.Span{{}}}}},
+		},
+	}
+
+	rs := decode(t, decode(t, req.Marshal())[0].Bytes)
+	require.Len(t, rs, 2)
+	assert.Empty(t, rs[0].Bytes)
+
+	ss := decode(t, rs[1].Bytes)
+	require.Len(t, ss, 2)
+	assert.Empty(t, ss[0].Bytes)
+
+	// trace and span IDs are always present, while the rest of fields with default values are omitted
+	var nums []int
+	for _, f := range decode(t, ss[1].Bytes) {
+		nums = append(nums, f.Num)
+	}
+
+	assert.Equal(t, []int{1, 2, 15}, nums)
+}
+
+type field struct {
+	Num    int
+	Varint uint64
+	Bytes  []byte
+}
+
+type keyValue struct {
+	Key   string
+	Value interface{}
+}
+
+// decode parses the protobuf wire format into a list of fields
+func decode(t *testing.T, b []byte) []field {
+	t.Helper()
+
+	var fields []field
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		require.Greater(t, n, 0, "malformed field tag")
+		b = b[n:]
+
+		f := field{Num: int(tag >> 3)}
+
+		switch tag & 0x7 {
+		case 0:
+			f.Varint, n = binary.Uvarint(b)
+			require.Greater(t, n, 0, "malformed varint")
+			b = b[n:]
+		case 1:
+			require.GreaterOrEqual(t, len(b), 8, "malformed fixed64")
+			f.Varint, b = binary.LittleEndian.Uint64(b), b[8:]
+		case 2:
+			l, n := binary.Uvarint(b)
+			require.Greater(t, n, 0, "malformed length")
+			b = b[n:]
+
+			require.GreaterOrEqual(t, len(b), int(l), "malformed bytes")
+			f.Bytes, b = b[:l], b[l:]
+		default:
+			t.Fatalf("unexpected wire type %d", tag&0x7)
+		}
+
+		fields = append(fields, f)
+	}
+
+	return fields
+}
+
+func decodeKeyValue(t *testing.T, b []byte) keyValue {
+	t.Helper()
+
+	fields := decode(t, b)
+	require.Len(t, fields, 2)
+
+	return keyValue{string(fields[0].Bytes), decodeValue(t, fields[1].Bytes)}
+}
+
+func decodeValue(t *testing.T, b []byte) interface{} {
+	t.Helper()
+
+	fields := decode(t, b)
+	require.Len(t, fields, 1)
+
+	switch f := fields[0]; f.Num {
+	case 1:
+		return string(f.Bytes)
+	case 2:
+		return f.Varint == 1
+	case 3:
+		return int64(f.Varint)
+	case 4:
+		return math.Float64frombits(f.Varint)
+	case 5:
+		var items []interface{}
+		for _, item := range decode(t, f.Bytes) {
+			items = append(items, decodeValue(t, item.Bytes))
+		}
+
+		return items
+	default:
+		t.Fatalf("unexpected value field %d", f.Num)
+		return nil
+	}
+}
@@ -0,0 +1,662 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/instana/go-sensor/acceptor"
+	"github.com/instana/go-sensor/autoprofile"
+	"github.com/instana/go-sensor/otlp"
+)
+
+const (
+	// DefaultOTLPEndpoint is the default URL of the OTLP/HTTP traces endpoint
+	DefaultOTLPEndpoint = "http://localhost:4318/v1/traces"
+	// DefaultOTLPTimeout is the default timeout for a single OTLP export request
+	DefaultOTLPTimeout = 10 * time.Second
+	// DefaultOTLPMaxRetries is the default number of attempts to resend a batch of spans after a retryable failure
+	DefaultOTLPMaxRetries = 3
+	// DefaultOTLPRetryDelay is the default delay before the first retry. Each subsequent retry doubles the delay
+	DefaultOTLPRetryDelay = 100 * time.Millisecond
+	// DefaultOTLPMaxRetryTime is the default limit for the total time spent on retrying a batch of spans. It is kept
+	// below the interval the recorder sends spans at, so that an unavailable collector does not delay the next export.
+	DefaultOTLPMaxRetryTime = 800 * time.Millisecond
+
+	otlpTracesPath = "/v1/traces"
+	otlpScopeName  = "github.com/instana/go-sensor"
+)
+
+// OTLPExporterOptions contains the configuration of the OTLP/HTTP span exporter
+type OTLPExporterOptions struct {
+	// Endpoint is the URL of the OTLP/HTTP traces endpoint, i.e. http://localhost:4318/v1/traces
+	Endpoint string
+	// Headers are sent with each export request, i.e. the authentication headers required by the collector
+	Headers map[string]string
+	// Timeout is the maximum time to wait for a single export request to be completed
+	Timeout time.Duration
+	// MaxRetries is the maximum number of attempts to resend a batch of spans if the collector is not available
+	// or has responded with a retryable status code. A negative value disables retries
+	MaxRetries int
+	// RetryDelay is the delay before the first retry, which is doubled for each subsequent one. The delay
+	// requested by the collector via the Retry-After header takes precedence
+	RetryDelay time.Duration
+	// MaxRetryTime limits the total time spent on retrying a batch of spans after the first attempt has failed,
+	// including the delays between the retries. Once exceeded, the error is returned and the recorder keeps the
+	// spans to send them with the next batch
+	MaxRetryTime time.Duration
+	// ServiceName is used as a value of the service.name resource attribute. If empty, the name of the service
+	// configured for the sensor is used
+	ServiceName string
+	// Client is the HTTP client used to send export requests
+	Client *http.Client
+	// Logger is used to log export failures
+	Logger LeveledLogger
+}
+
+func (opts *OTLPExporterOptions) setDefaults() {
+	if opts.Endpoint == "" {
+		opts.Endpoint = DefaultOTLPEndpoint
+	}
+
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultOTLPTimeout
+	}
+
+	switch {
+	case opts.MaxRetries == 0:
+		opts.MaxRetries = DefaultOTLPMaxRetries
+	case opts.MaxRetries < 0:
+		opts.MaxRetries = 0
+	}
+
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = DefaultOTLPRetryDelay
+	}
+
+	if opts.MaxRetryTime <= 0 {
+		opts.MaxRetryTime = DefaultOTLPMaxRetryTime
+	}
+
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+
+	if opts.Logger == nil {
+		opts.Logger = defaultLogger
+	}
+}
+
+// OTLPExporter is an AgentClient that converts the spans into OpenTelemetry protocol (OTLP) format and sends
+// them to an OpenTelemetry collector over HTTP instead of an Instana agent. The Instana span IDs, kind and
+// the `data` section are stored as span attributes prefixed with `instana.`. The metrics, events and profiles
+// are not supported and discarded.
+//
+// The exporter can be configured either by passing it as (instana.Options).AgentClient, or by setting
+// INSTANA_EXPORTER=otlp in the environment. In the latter case it is configured using standard
+// OTEL_EXPORTER_OTLP_* env variables.
+type OTLPExporter struct {
+	opts OTLPExporterOptions
+	host string
+
+	// ctx is cancelled once the exporter is closed to interrupt pending export requests
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+var _ AgentClient = (*OTLPExporter)(nil)
+
+// NewOTLPExporter initializes a new OTLP/HTTP span exporter
+func NewOTLPExporter(opts OTLPExporterOptions) *OTLPExporter {
+	opts.setDefaults()
+
+	host, _ := os.Hostname()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &OTLPExporter{
+		opts:   opts,
+		host:   host,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// newOTLPExporterFromEnv initializes an OTLP/HTTP span exporter configured with OTEL_EXPORTER_OTLP_* env variables
+func newOTLPExporterFromEnv(serviceName string, logger LeveledLogger) *OTLPExporter {
+	opts := OTLPExporterOptions{
+		ServiceName: serviceName,
+		Logger:      logger,
+	}
+
+	if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); endpoint != "" {
+		opts.Endpoint = endpoint
+	} else if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint != "" {
+		opts.Endpoint = strings.TrimRight(endpoint, "/") + otlpTracesPath
+	}
+
+	headers, err := parseOTLPHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+	if err != nil {
+		logger.Warn("malformed OTEL_EXPORTER_OTLP_HEADERS value, no additional headers will be sent: ", err)
+	}
+	opts.Headers = headers
+
+	if s := os.Getenv("OTEL_EXPORTER_OTLP_TIMEOUT"); s != "" {
+		timeout, err := parseInstanaTimeout(s)
+		if err != nil {
+			logger.Warn("malformed OTEL_EXPORTER_OTLP_TIMEOUT value, falling back to the default one: ", err)
+		}
+
+		opts.Timeout = timeout
+	}
+
+	return NewOTLPExporter(opts)
+}
+
+// Ready returns true, since the OTLP exporter does not need to be announced
+func (e *OTLPExporter) Ready() bool { return true }
+
+// SendMetrics is a noop for the OTLP exporter
+func (e *OTLPExporter) SendMetrics(data acceptor.Metrics) error { return nil }
+
+// SendEvent is a noop for the OTLP exporter
+func (e *OTLPExporter) SendEvent(event *EventData) error { return nil }
+
+// SendProfiles is a noop for the OTLP exporter
+func (e *OTLPExporter) SendProfiles(profiles []autoprofile.Profile) error { return nil }
+
+// Flush is a noop for the OTLP exporter, since spans are sent synchronously
+func (e *OTLPExporter) Flush(ctx context.Context) error { return nil }
+
+// Close interrupts any pending export requests and retries. The spans sent after the exporter
+// has been closed are discarded.
+func (e *OTLPExporter) Close() { e.cancel() }
+
+// SendSpans converts spans into OTLP format and sends them to the collector
+func (e *OTLPExporter) SendSpans(spans []Span) error {
+	otlpSpans := make([]otlp.Span, 0, len(spans))
+	for _, sp := range spans {
+		s, err := newOTLPSpan(sp)
+		if err != nil {
+			e.opts.Logger.Debug("failed to convert span to OTLP format, skipping: ", err)
+			continue
+		}
+
+		otlpSpans = append(otlpSpans, s)
+	}
+
+	if len(otlpSpans) == 0 {
+		return nil
+	}
+
+	req := otlp.TracesRequest{
+		ResourceSpans: []otlp.ResourceSpans{
+			{
+				Resource: e.resource(),
+				ScopeSpans: []otlp.ScopeSpans{
+					{
+						Scope: otlp.InstrumentationScope{Name: otlpScopeName, Version: Version},
+						Spans: otlpSpans,
+					},
+				},
+			},
+		},
+	}
+
+	buf := bytes.NewBuffer(nil)
+
+	zw := gzip.NewWriter(buf)
+	if _, err := zw.Write(req.Marshal()); err != nil {
+		return fmt.Errorf("failed to compress OTLP request: %s", err)
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to compress OTLP request: %s", err)
+	}
+
+	return e.export(e.ctx, buf.Bytes())
+}
+
+// export sends the payload to the collector retrying on network errors and retryable response codes
+// until the context is done or the retry time limit is exceeded
+func (e *OTLPExporter) export(ctx context.Context, payload []byte) error {
+	var deadline time.Time
+
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := e.send(ctx, payload)
+		if err == nil {
+			return nil
+		}
+
+		if retryAfter < 0 || attempt >= e.opts.MaxRetries {
+			return err
+		}
+
+		if retryAfter == 0 {
+			retryAfter = time.Duration(math.Pow(2, float64(attempt))) * e.opts.RetryDelay
+		}
+
+		if attempt == 0 {
+			// the retries, including the requests themselves, need to be completed before the deadline
+			deadline = time.Now().Add(e.opts.MaxRetryTime)
+
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithDeadline(ctx, deadline)
+			defer cancel()
+		}
+
+		if time.Now().Add(retryAfter).After(deadline) {
+			return fmt.Errorf("%s, retry time limit exceeded", err)
+		}
+
+		e.opts.Logger.Debug(err, ", retrying in ", retryAfter)
+
+		t := time.NewTimer(retryAfter)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return fmt.Errorf("%s, giving up: %s", err, ctx.Err())
+		}
+	}
+}
+
+// send makes a single export request. If the request fails, it returns the delay requested by the collector
+// before the next attempt, or a negative value if the request should not be retried.
+func (e *OTLPExporter) send(ctx context.Context, payload []byte) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.opts.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodPost, e.opts.Endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return -1, fmt.Errorf("failed to prepare OTLP request: %s", err)
+	}
+
+	req = req.WithContext(ctx)
+
+	for k, v := range e.opts.Headers {
+		req.Header.Set(k, v)
+	}
+
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "gzip")
+
+	resp, err := e.opts.Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send spans to the OTLP collector: %s", err)
+	}
+
+	defer func() {
+		io.CopyN(ioutil.Discard, resp.Body, 1<<20)
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return 0, nil
+	}
+
+	err = fmt.Errorf("OTLP collector has responded with %s", resp.Status)
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		if sec, e := strconv.Atoi(resp.Header.Get("Retry-After")); e == nil && sec > 0 {
+			return time.Duration(sec) * time.Second, err
+		}
+
+		return 0, err
+	default:
+		return -1, err
+	}
+}
+
+func (e *OTLPExporter) resource() otlp.Resource {
+	serviceName := e.opts.ServiceName
+	if serviceName == "" && sensor != nil {
+		serviceName = sensor.serviceOrBinaryName()
+	}
+
+	if serviceName == "" {
+		serviceName = binaryName
+	}
+
+	attrs := []otlp.KeyValue{
+		{Key: "service.name", Value: otlp.StringValue(serviceName)},
+		{Key: "telemetry.sdk.name", Value: otlp.StringValue("instana")},
+		{Key: "telemetry.sdk.language", Value: otlp.StringValue("go")},
+		{Key: "telemetry.sdk.version", Value: otlp.StringValue(Version)},
+		{Key: "process.pid", Value: otlp.IntValue(int64(os.Getpid()))},
+	}
+
+	if e.host != "" {
+		attrs = append(attrs, otlp.KeyValue{Key: "host.name", Value: otlp.StringValue(e.host)})
+	}
+
+	return otlp.Resource{Attributes: attrs}
+}
+
+// otlpSpanDocument is the subset of span fields sent to the agent used to convert a spooled span to OTLP format
+type otlpSpanDocument struct {
+	TraceID     string          `json:"t"`
+	ParentID    string          `json:"p"`
+	SpanID      string          `json:"s"`
+	LongTraceID string          `json:"lt"`
+	Timestamp   uint64          `json:"ts"`
+	Duration    uint64          `json:"d"`
+	Name        string          `json:"n"`
+	Kind        int             `json:"k"`
+	Ec          int             `json:"ec"`
+	Synthetic   bool            `json:"sy"`
+	Data        json.RawMessage `json:"data"`
+	Links       []spanLink      `json:"lk"`
+}
+
+// otlpSpanFields contains the span fields converted to OTLP format
+type otlpSpanFields struct {
+	TraceIDHi, TraceID int64
+	ParentID, SpanID   int64
+	Timestamp          uint64
+	Duration           uint64
+	Name               string
+	Kind               int
+	Ec                 int
+	Synthetic          bool
+	Data               map[string]interface{}
+	Links              []spanLink
+}
+
+// newOTLPSpan converts an Instana span into OTLP format. The spans restored from the spool only contain
+// their JSON representation, so they are decoded first.
+func newOTLPSpan(sp Span) (otlp.Span, error) {
+	if sp.raw != nil {
+		return newOTLPSpanFromJSON(sp.raw)
+	}
+
+	data, err := decodeOTLPSpanData(sp.Data)
+	if err != nil {
+		return otlp.Span{}, err
+	}
+
+	fields := otlpSpanFields{
+		TraceIDHi: sp.TraceIDHi,
+		TraceID:   sp.TraceID,
+		ParentID:  sp.ParentID,
+		SpanID:    sp.SpanID,
+		Timestamp: sp.Timestamp,
+		Duration:  sp.Duration,
+		Name:      sp.Name,
+		Kind:      sp.Kind,
+		Ec:        sp.Ec,
+		Synthetic: sp.Synthetic,
+		Data:      data,
+	}
+
+	for _, link := range sp.Links {
+		fields.Links = append(fields.Links, spanLink{link.TraceID, link.SpanID})
+	}
+
+	return fields.OTLPSpan(), nil
+}
+
+// newOTLPSpanFromJSON converts the JSON representation of a span into OTLP format
+func newOTLPSpanFromJSON(raw []byte) (otlp.Span, error) {
+	var doc otlpSpanDocument
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return otlp.Span{}, err
+	}
+
+	fields := otlpSpanFields{
+		Timestamp: doc.Timestamp,
+		Duration:  doc.Duration,
+		Name:      doc.Name,
+		Kind:      doc.Kind,
+		Ec:        doc.Ec,
+		Synthetic: doc.Synthetic,
+		Links:     doc.Links,
+	}
+
+	var err error
+	if doc.LongTraceID != "" {
+		fields.TraceIDHi, fields.TraceID, err = ParseLongID(doc.LongTraceID)
+	} else {
+		fields.TraceID, err = ParseID(doc.TraceID)
+	}
+
+	if err != nil {
+		return otlp.Span{}, fmt.Errorf("malformed trace id: %s", err)
+	}
+
+	if fields.SpanID, err = ParseID(doc.SpanID); err != nil {
+		return otlp.Span{}, fmt.Errorf("malformed span id: %s", err)
+	}
+
+	if doc.ParentID != "" {
+		if fields.ParentID, err = ParseID(doc.ParentID); err != nil {
+			return otlp.Span{}, fmt.Errorf("malformed parent span id: %s", err)
+		}
+	}
+
+	if fields.Data, err = decodeOTLPSpanData(doc.Data); err != nil {
+		return otlp.Span{}, err
+	}
+
+	return fields.OTLPSpan(), nil
+}
+
+// decodeOTLPSpanData converts the span data into a nested map to be flattened into OTLP attributes. The data
+// is either a typed span data struct or the JSON representation of a spooled span. The typed data is marshaled
+// first, so that it's converted exactly the same way as the data sent to the agent.
+func decodeOTLPSpanData(data interface{}) (map[string]interface{}, error) {
+	raw, ok := data.(json.RawMessage)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(data); err != nil {
+			return nil, fmt.Errorf("failed to marshal span data: %s", err)
+		}
+	}
+
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	var m map[string]interface{}
+	if err := decodeOTLPJSON(raw, &m); err != nil {
+		return nil, fmt.Errorf("malformed span data: %s", err)
+	}
+
+	return m, nil
+}
+
+// decodeOTLPJSON decodes JSON data keeping the numbers as json.Number
+func decodeOTLPJSON(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	return dec.Decode(v)
+}
+
+// OTLPSpan returns the OTLP span
+func (f otlpSpanFields) OTLPSpan() otlp.Span {
+	s := otlp.Span{
+		Name:              f.Name,
+		Kind:              otlpSpanKind(RegisteredSpanType(f.Name), SpanKind(f.Kind)),
+		StartTimeUnixNano: f.Timestamp * uint64(time.Millisecond),
+		EndTimeUnixNano:   (f.Timestamp + f.Duration) * uint64(time.Millisecond),
+	}
+
+	putOTLPID(s.TraceID[:8], f.TraceIDHi)
+	putOTLPID(s.TraceID[8:], f.TraceID)
+	putOTLPID(s.SpanID[:], f.SpanID)
+	putOTLPID(s.ParentSpanID[:], f.ParentID)
+
+	// SDK spans are reported using their custom names
+	if sdk, ok := f.Data["sdk"].(map[string]interface{}); ok && f.Name == string(SDKSpanType) {
+		if name, ok := sdk["name"].(string); ok && name != "" {
+			s.Name = name
+		}
+	}
+
+	s.Attributes = []otlp.KeyValue{
+		{Key: "instana.n", Value: otlp.StringValue(f.Name)},
+		{Key: "instana.k", Value: otlp.IntValue(int64(f.Kind))},
+		{Key: "instana.t", Value: otlp.StringValue(FormatLongID(f.TraceIDHi, f.TraceID))},
+		{Key: "instana.s", Value: otlp.StringValue(FormatID(f.SpanID))},
+	}
+
+	if f.ParentID != 0 {
+		s.Attributes = append(s.Attributes, otlp.KeyValue{Key: "instana.p", Value: otlp.StringValue(FormatID(f.ParentID))})
+	}
+
+	if f.Synthetic {
+		s.Attributes = append(s.Attributes, otlp.KeyValue{Key: "instana.sy", Value: otlp.BoolValue(true)})
+	}
+
+	if f.Ec > 0 {
+		s.Attributes = append(s.Attributes, otlp.KeyValue{Key: "instana.ec", Value: otlp.IntValue(int64(f.Ec))})
+		s.Status.Code = otlp.StatusCodeError
+	}
+
+	s.Attributes = appendOTLPAttributes(s.Attributes, "instana.data", f.Data)
+
+	for _, link := range f.Links {
+		if l, ok := newOTLPLink(link); ok {
+			s.Links = append(s.Links, l)
+		}
+	}
+
+	return s
+}
+
+// newOTLPLink converts a span link into OTLP format. It returns false if the link IDs are malformed
+func newOTLPLink(link spanLink) (otlp.Link, bool) {
+	var (
+		traceIDHi, traceID int64
+		err                error
+	)
+
+	if len(link.TraceID) > 16 {
+		traceIDHi, traceID, err = ParseLongID(link.TraceID)
+	} else {
+		traceID, err = ParseID(link.TraceID)
+	}
+
+	if err != nil {
+		return otlp.Link{}, false
+	}
+
+	spanID, err := ParseID(link.SpanID)
+	if err != nil {
+		return otlp.Link{}, false
+	}
+
+	var l otlp.Link
+	putOTLPID(l.TraceID[:8], traceIDHi)
+	putOTLPID(l.TraceID[8:], traceID)
+	putOTLPID(l.SpanID[:], spanID)
+
+	return l, true
+}
+
+// appendOTLPAttributes flattens the nested map into a list of attributes with keys
+// prefixed by the path to the value
+func appendOTLPAttributes(attrs []otlp.KeyValue, prefix string, m map[string]interface{}) []otlp.KeyValue {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		key := prefix + "." + k
+
+		if nested, ok := m[k].(map[string]interface{}); ok {
+			attrs = appendOTLPAttributes(attrs, key, nested)
+			continue
+		}
+
+		if v, ok := newOTLPValue(m[k]); ok {
+			attrs = append(attrs, otlp.KeyValue{Key: key, Value: v})
+		}
+	}
+
+	return attrs
+}
+
+func newOTLPValue(v interface{}) (otlp.Value, bool) {
+	switch v := v.(type) {
+	case string:
+		return otlp.StringValue(v), true
+	case bool:
+		return otlp.BoolValue(v), true
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return otlp.IntValue(n), true
+		}
+
+		f, err := v.Float64()
+
+		return otlp.DoubleValue(f), err == nil
+	case []interface{}:
+		items := make([]otlp.Value, 0, len(v))
+		for _, item := range v {
+			if val, ok := newOTLPValue(item); ok {
+				items = append(items, val)
+			}
+		}
+
+		return otlp.ArrayValue(items...), true
+	case map[string]interface{}:
+		// maps nested into arrays are stored as JSON
+		data, err := json.Marshal(v)
+
+		return otlp.StringValue(string(data)), err == nil
+	default:
+		return otlp.Value{}, false
+	}
+}
+
+// otlpSpanKind returns the OTLP span kind for an Instana span. The entry and exit spans of messaging systems
+// are reported as consumer and producer spans respectively.
+func otlpSpanKind(st RegisteredSpanType, k SpanKind) otlp.SpanKind {
+	messaging := otlpMessagingSpanType(st)
+
+	switch {
+	case k == EntrySpanKind && messaging:
+		return otlp.SpanKindConsumer
+	case k == EntrySpanKind:
+		return otlp.SpanKindServer
+	case k == ExitSpanKind && messaging:
+		return otlp.SpanKindProducer
+	case k == ExitSpanKind:
+		return otlp.SpanKindClient
+	default:
+		return otlp.SpanKindInternal
+	}
+}
+
+func otlpMessagingSpanType(st RegisteredSpanType) bool {
+	switch st {
+	case KafkaSpanType, AWSSQSSpanType, AWSSNSSpanType, GCPPubSubSpanType, RabbitMQSpanType:
+		return true
+	default:
+		return false
+	}
+}
+
+func putOTLPID(dst []byte, id int64) {
+	for i := len(dst) - 1; i >= 0; i-- {
+		dst[i] = byte(id)
+		id >>= 8
+	}
+}
@@ -0,0 +1,173 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/instana/go-sensor/otlp"
+	ot "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewOTLPSpan(t *testing.T) {
+	recorder := NewTestRecorder()
+	tracer := NewTracerWithEverything(&Options{AgentClient: alwaysReadyClient{}}, recorder)
+	defer ShutdownSensor()
+
+	startTime := time.Unix(1, 0)
+
+	sp := tracer.StartSpan("g.http", ext.SpanKindRPCServer, ot.StartTime(startTime))
+	sp.SetTag("http.method", "GET")
+	sp.SetTag("http.status", 500)
+	sp.SetTag("http.path", "/status")
+	sp.SetTag("error", true)
+
+	sp.FinishWithOptions(ot.FinishOptions{FinishTime: startTime.Add(2 * time.Millisecond)})
+
+	spans := recorder.GetQueuedSpans()
+	require.Len(t, spans, 1)
+
+	spans[0].TraceIDHi = 0x1
+	spans[0].TraceID = 0x2
+	spans[0].SpanID = 0x3
+	spans[0].ParentID = 0x4
+
+	s, err := newOTLPSpan(spans[0])
+	require.NoError(t, err)
+
+	assert.Equal(t, [16]byte{7: 0x01, 15: 0x02}, s.TraceID)
+	assert.Equal(t, [8]byte{7: 0x03}, s.SpanID)
+	assert.Equal(t, [8]byte{7: 0x04}, s.ParentSpanID)
+	assert.Equal(t, "g.http", s.Name)
+	assert.Equal(t, otlp.SpanKindServer, s.Kind)
+	assert.Equal(t, uint64(time.Second), s.StartTimeUnixNano)
+	assert.Equal(t, uint64(time.Second+2*time.Millisecond), s.EndTimeUnixNano)
+	assert.Equal(t, otlp.StatusCodeError, s.Status.Code)
+
+	assert.Equal(t, []otlp.KeyValue{
+		{Key: "instana.n", Value: otlp.StringValue("g.http")},
+		{Key: "instana.k", Value: otlp.IntValue(1)},
+		{Key: "instana.t", Value: otlp.StringValue("00000000000000010000000000000002")},
+		{Key: "instana.s", Value: otlp.StringValue("0000000000000003")},
+		{Key: "instana.p", Value: otlp.StringValue("0000000000000004")},
+		{Key: "instana.ec", Value: otlp.IntValue(1)},
+		{Key: "instana.data.http.method", Value: otlp.StringValue("GET")},
+		{Key: "instana.data.http.path", Value: otlp.StringValue("/status")},
+		{Key: "instana.data.http.status", Value: otlp.IntValue(500)},
+		{Key: "instana.data.sdk.custom.tags.error", Value: otlp.BoolValue(true)},
+	}, s.Attributes)
+}
+
+func TestNewOTLPSpan_SDKSpan(t *testing.T) {
+	recorder := NewTestRecorder()
+	tracer := NewTracerWithEverything(&Options{AgentClient: alwaysReadyClient{}}, recorder)
+	defer ShutdownSensor()
+
+	sp := tracer.StartSpan("process-job", ext.SpanKindConsumer)
+	sp.SetTag("job.ids", []int{1, 2})
+	sp.Finish()
+
+	spans := recorder.GetQueuedSpans()
+	require.Len(t, spans, 1)
+
+	s, err := newOTLPSpan(spans[0])
+	require.NoError(t, err)
+
+	assert.Equal(t, "process-job", s.Name)
+	assert.Equal(t, otlp.SpanKindServer, s.Kind)
+	assert.Equal(t, otlp.StatusCodeUnset, s.Status.Code)
+	assert.Contains(t, s.Attributes, otlp.KeyValue{Key: "instana.n", Value: otlp.StringValue("sdk")})
+	assert.Contains(t, s.Attributes, otlp.KeyValue{
+		Key:   "instana.data.sdk.custom.tags.job.ids",
+		Value: otlp.ArrayValue(otlp.IntValue(1), otlp.IntValue(2)),
+	})
+}
+
+func TestNewOTLPSpan_Spooled(t *testing.T) {
+	doc := json.RawMessage(`{"t":"0000000000000002","p":"0000000000000004","s":"0000000000000003",` +
+		`"lt":"00000000000000010000000000000002","ts":1000,"d":2,"n":"g.http","k":1,"data":{"http":{"method":"GET"}}}`)
+
+	s, err := newOTLPSpan(Span{raw: doc})
+	require.NoError(t, err)
+
+	assert.Equal(t, [16]byte{7: 0x01, 15: 0x02}, s.TraceID)
+	assert.Equal(t, [8]byte{7: 0x03}, s.SpanID)
+	assert.Equal(t, [8]byte{7: 0x04}, s.ParentSpanID)
+	assert.Equal(t, uint64(time.Second), s.StartTimeUnixNano)
+	assert.Contains(t, s.Attributes, otlp.KeyValue{Key: "instana.data.http.method", Value: otlp.StringValue("GET")})
+}
+
+func TestNewOTLPSpan_Links(t *testing.T) {
+	doc := json.RawMessage(`{"t":"0000000000000002","s":"0000000000000003","ts":1000,"d":2,"n":"sdk","k":3,` +
+		`"lk":[{"t":"0000000000000005","s":"0000000000000006"},{"t":"00000000000000070000000000000008","s":"0000000000000009"},` +
+		`{"t":"malformed","s":"000000000000000a"}]}`)
+
+	s, err := newOTLPSpan(Span{raw: doc})
+	require.NoError(t, err)
+
+	assert.Equal(t, []otlp.Link{
+		{TraceID: [16]byte{15: 0x05}, SpanID: [8]byte{7: 0x06}},
+		{TraceID: [16]byte{7: 0x07, 15: 0x08}, SpanID: [8]byte{7: 0x09}},
+	}, s.Links)
+}
+
+func TestOTLPSpanKind(t *testing.T) {
+	examples := map[string]struct {
+		Type     RegisteredSpanType
+		Kind     SpanKind
+		Expected otlp.SpanKind
+	}{
+		"http entry":       {HTTPServerSpanType, EntrySpanKind, otlp.SpanKindServer},
+		"http exit":        {HTTPClientSpanType, ExitSpanKind, otlp.SpanKindClient},
+		"kafka entry":      {KafkaSpanType, EntrySpanKind, otlp.SpanKindConsumer},
+		"kafka exit":       {KafkaSpanType, ExitSpanKind, otlp.SpanKindProducer},
+		"sqs entry":        {AWSSQSSpanType, EntrySpanKind, otlp.SpanKindConsumer},
+		"sqs exit":         {AWSSQSSpanType, ExitSpanKind, otlp.SpanKindProducer},
+		"sdk intermediate": {SDKSpanType, IntermediateSpanKind, otlp.SpanKindInternal},
+	}
+
+	for name, example := range examples {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, example.Expected, otlpSpanKind(example.Type, example.Kind))
+		})
+	}
+}
+
+func TestNewOTLPExporterFromEnv(t *testing.T) {
+	for k, v := range map[string]string{
+		"OTEL_EXPORTER_OTLP_ENDPOINT": "https://collector:4318/",
+		"OTEL_EXPORTER_OTLP_HEADERS":  "api-key=secret",
+		"OTEL_EXPORTER_OTLP_TIMEOUT":  "1500",
+	} {
+		require.NoError(t, os.Setenv(k, v))
+		defer os.Unsetenv(k)
+	}
+
+	e := newOTLPExporterFromEnv("my-service", defaultLogger)
+
+	assert.Equal(t, "https://collector:4318/v1/traces", e.opts.Endpoint)
+	assert.Equal(t, map[string]string{"api-key": "secret"}, e.opts.Headers)
+	assert.Equal(t, 1500*time.Millisecond, e.opts.Timeout)
+	assert.Equal(t, "my-service", e.opts.ServiceName)
+
+	// signal-specific endpoint takes precedence and is used as is
+	require.NoError(t, os.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "https://traces:4318/custom"))
+	defer os.Unsetenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+
+	assert.Equal(t, "https://traces:4318/custom", newOTLPExporterFromEnv("my-service", defaultLogger).opts.Endpoint)
+}
+
+func TestNewSensor_OTLPExporter(t *testing.T) {
+	require.NoError(t, os.Setenv("INSTANA_EXPORTER", "otlp"))
+	defer os.Unsetenv("INSTANA_EXPORTER")
+
+	s := newSensor(DefaultOptions())
+
+	assert.IsType(t, &OTLPExporter{}, s.Agent())
+}
@@ -0,0 +1,251 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana_test
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	instana "github.com/instana/go-sensor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOTLPExporter_SendSpans(t *testing.T) {
+	var payloads [][]byte
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Equal(t, "/v1/traces", req.URL.Path)
+		assert.Equal(t, "application/x-protobuf", req.Header.Get("Content-Type"))
+		assert.Equal(t, "gzip", req.Header.Get("Content-Encoding"))
+		assert.Equal(t, "secret", req.Header.Get("Api-Key"))
+
+		zr, err := gzip.NewReader(req.Body)
+		require.NoError(t, err)
+
+		data, err := ioutil.ReadAll(zr)
+		require.NoError(t, err)
+
+		payloads = append(payloads, data)
+	}))
+	defer srv.Close()
+
+	exporter := instana.NewOTLPExporter(instana.OTLPExporterOptions{
+		Endpoint:    srv.URL + "/v1/traces",
+		Headers:     map[string]string{"Api-Key": "secret"},
+		ServiceName: "my-service",
+	})
+
+	recorder := instana.NewTestRecorder()
+	tracer := instana.NewTracerWithEverything(&instana.Options{AgentClient: exporter}, recorder)
+	defer instana.ShutdownSensor()
+
+	tracer.StartSpan("my-span").Finish()
+
+	require.NoError(t, exporter.SendSpans(recorder.GetQueuedSpans()))
+	require.Len(t, payloads, 1)
+
+	assert.Contains(t, string(payloads[0]), "my-service")
+	assert.Contains(t, string(payloads[0]), "my-span")
+}
+
+func TestOTLPExporter_SendSpans_Retry(t *testing.T) {
+	var numReq int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&numReq, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+	}))
+	defer srv.Close()
+
+	exporter := instana.NewOTLPExporter(instana.OTLPExporterOptions{
+		Endpoint:   srv.URL,
+		RetryDelay: time.Millisecond,
+	})
+
+	require.NoError(t, exporter.SendSpans(newTestSpans(t)))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&numReq))
+}
+
+func TestOTLPExporter_SendSpans_MaxRetries(t *testing.T) {
+	var numReq int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&numReq, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	exporter := instana.NewOTLPExporter(instana.OTLPExporterOptions{
+		Endpoint:   srv.URL,
+		MaxRetries: 2,
+		RetryDelay: time.Millisecond,
+	})
+
+	assert.Error(t, exporter.SendSpans(newTestSpans(t)))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&numReq))
+}
+
+func TestOTLPExporter_Close(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	exporter := instana.NewOTLPExporter(instana.OTLPExporterOptions{
+		Endpoint:     srv.URL,
+		MaxRetryTime: 2 * time.Hour,
+	})
+
+	spans := newTestSpans(t)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- exporter.SendSpans(spans)
+	}()
+
+	// let the exporter receive the first response and wait before the retry
+	time.Sleep(100 * time.Millisecond)
+	exporter.Close()
+
+	select {
+	case err := <-errCh:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the pending retry has not been interrupted")
+	}
+}
+
+func TestShutdownSensor_ClosesOTLPExporter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	exporter := instana.NewOTLPExporter(instana.OTLPExporterOptions{
+		Endpoint:     srv.URL,
+		MaxRetryTime: 2 * time.Hour,
+	})
+
+	instana.InitSensor(&instana.Options{AgentClient: exporter})
+
+	spans := newTestSpans(t)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- exporter.SendSpans(spans)
+	}()
+
+	// let the exporter receive the first response and wait before the retry
+	time.Sleep(100 * time.Millisecond)
+	instana.ShutdownSensor()
+
+	select {
+	case err := <-errCh:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the pending retry has not been interrupted")
+	}
+}
+
+func TestOTLPExporter_SendSpans_MaxRetryTime(t *testing.T) {
+	var numReq int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&numReq, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	exporter := instana.NewOTLPExporter(instana.OTLPExporterOptions{
+		Endpoint:     srv.URL,
+		MaxRetries:   100,
+		RetryDelay:   10 * time.Millisecond,
+		MaxRetryTime: 50 * time.Millisecond,
+	})
+
+	start := time.Now()
+	assert.Error(t, exporter.SendSpans(newTestSpans(t)))
+
+	// the retries are given up once the next delay exceeds the time limit
+	assert.True(t, time.Since(start) < time.Second, "the retries took %s", time.Since(start))
+
+	n := atomic.LoadInt32(&numReq)
+	assert.True(t, n > 1 && n < 5, "unexpected number of requests: %d", n)
+}
+
+func TestOTLPExporter_SendSpans_RetryAfterExceedsMaxRetryTime(t *testing.T) {
+	var numReq int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&numReq, 1)
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	exporter := instana.NewOTLPExporter(instana.OTLPExporterOptions{
+		Endpoint: srv.URL,
+	})
+
+	assert.Error(t, exporter.SendSpans(newTestSpans(t)))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&numReq))
+}
+
+func TestOTLPExporter_SendSpans_NonRetryableError(t *testing.T) {
+	var numReq int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&numReq, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	exporter := instana.NewOTLPExporter(instana.OTLPExporterOptions{
+		Endpoint:   srv.URL,
+		RetryDelay: time.Millisecond,
+	})
+
+	assert.Error(t, exporter.SendSpans(newTestSpans(t)))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&numReq))
+}
+
+func TestOTLPExporter_SendSpans_Timeout(t *testing.T) {
+	done := make(chan struct{})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-done
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	exporter := instana.NewOTLPExporter(instana.OTLPExporterOptions{
+		Endpoint:   srv.URL,
+		Timeout:    10 * time.Millisecond,
+		MaxRetries: -1,
+	})
+
+	assert.Error(t, exporter.SendSpans(newTestSpans(t)))
+}
+
+func newTestSpans(t *testing.T) []instana.Span {
+	t.Helper()
+
+	recorder := instana.NewTestRecorder()
+	tracer := instana.NewTracerWithEverything(&instana.Options{AgentClient: alwaysReadyClient{}}, recorder)
+	defer instana.ShutdownSensor()
+
+	tracer.StartSpan("my-span").Finish()
+
+	return recorder.GetQueuedSpans()
+}
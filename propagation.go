@@ -6,6 +6,7 @@ package instana
 import (
 	"errors"
 	"net/http"
+	"sort"
 	"strings"
 
 	"github.com/instana/go-sensor/w3ctrace"
@@ -80,12 +81,20 @@ func injectTraceContext(sc SpanContext, opaqueCarrier interface{}) error {
 			}
 		}
 
-		addW3CTraceContext(h, sc)
+		if propagatorEnabled(PropagatorW3C) {
+			addW3CTraceContext(h, sc)
+		}
 		addEUMHeaders(h, sc)
 	}
 
+	injectForeignTraceContext(sc, carrier)
+
+	if !propagatorEnabled(PropagatorInstana) {
+		return nil
+	}
+
 	if !sc.Suppressed {
-		carrier.Set(exstfieldT, FormatID(sc.TraceID))
+		carrier.Set(exstfieldT, formatTraceID(sc))
 		carrier.Set(exstfieldS, FormatID(sc.SpanID))
 	} else {
 		// remove trace context keys from the carrier
@@ -127,16 +136,29 @@ func extractTraceContext(opaqueCarrier interface{}) (SpanContext, error) {
 		return spanContext, ot.ErrInvalidCarrier
 	}
 
-	if c, ok := opaqueCarrier.(ot.HTTPHeadersCarrier); ok {
+	if c, ok := opaqueCarrier.(ot.HTTPHeadersCarrier); ok && propagatorEnabled(PropagatorW3C) {
 		pickupW3CTraceContext(http.Header(c), &spanContext)
 	}
 
+	instanaEnabled := propagatorEnabled(PropagatorInstana)
+	foreignHeaders := make(map[string]string)
+
 	// Iterate over the headers, look for Instana headers and try to parse them.
 	// In case of error interrupt, iteration and return it.
 	err := carrier.ForeachKey(func(k, v string) error {
 		var err error
 
-		switch strings.ToLower(k) {
+		key := strings.ToLower(k)
+		if foreignTraceHeader(key) {
+			foreignHeaders[key] = v
+			return nil
+		}
+
+		if !instanaEnabled {
+			return nil
+		}
+
+		switch key {
 		case FieldT:
 			spanContext.TraceIDHi, spanContext.TraceID, err = ParseLongID(v)
 			if err != nil {
@@ -174,6 +196,14 @@ func extractTraceContext(opaqueCarrier interface{}) (SpanContext, error) {
 		return spanContext, err
 	}
 
+	// fall back to 3rd-party trace context if there is neither Instana nor W3C one
+	if spanContext.TraceIDHi == 0 && spanContext.TraceID == 0 && spanContext.SpanID == 0 && !spanContext.Suppressed && spanContext.W3CContext.IsZero() {
+		if c, ok := extractForeignTraceContext(foreignHeaders); ok {
+			spanContext.TraceIDHi, spanContext.TraceID, spanContext.SpanID = c.TraceIDHi, c.TraceID, c.SpanID
+			spanContext.Suppressed = c.Suppressed
+		}
+	}
+
 	// reset the trace IDs if a correlation ID has been provided
 	if spanContext.Correlation.ID != "" {
 		spanContext.TraceIDHi, spanContext.TraceID, spanContext.SpanID = 0, 0, 0
@@ -206,9 +236,21 @@ func extractTraceContext(opaqueCarrier interface{}) (SpanContext, error) {
 	return spanContext, nil
 }
 
+// formatTraceID returns the hex representation of a trace ID to be used as the X-Instana-T value. The trace ID
+// is propagated in full only if the tracer is configured to use 128-bit trace IDs, otherwise it's truncated to
+// the lower 64 bits
+func formatTraceID(sc SpanContext) string {
+	if sc.TraceIDHi != 0 && use128BitTraceIDs() {
+		return FormatLongID(sc.TraceIDHi, sc.TraceID)
+	}
+
+	return FormatID(sc.TraceID)
+}
+
 func addW3CTraceContext(h http.Header, sc SpanContext) {
 	traceID, spanID := FormatLongID(sc.TraceIDHi, sc.TraceID), FormatID(sc.SpanID)
 	trCtx := sc.W3CContext
+	baggage := w3cBaggage(sc)
 
 	// check for an existing w3c trace
 	if trCtx.IsZero() {
@@ -237,10 +279,53 @@ func addW3CTraceContext(h http.Header, sc SpanContext) {
 		trCtx.RawState = w3ctrace.FormStateWithInstanaTraceStateValue(trCtx.State(), FormatID(sc.TraceID)+";"+spanID).String()
 	}
 
+	trCtx.RawBaggage = baggage.String()
+
 	w3ctrace.Inject(trCtx, h)
 }
 
+// w3cBaggage returns the W3C baggage list for the span context baggage items. The order and properties
+// of list members received from upstream are preserved, the rest of items are appended sorted by key.
+func w3cBaggage(sc SpanContext) w3ctrace.Baggage {
+	var b w3ctrace.Baggage
+
+	seen := make(map[string]struct{}, len(sc.Baggage))
+	for _, m := range sc.W3CContext.Baggage() {
+		v, ok := sc.Baggage[m.Key]
+		if !ok {
+			continue
+		}
+
+		m.Value = v
+		b = append(b, m)
+		seen[m.Key] = struct{}{}
+	}
+
+	keys := make([]string, 0, len(sc.Baggage))
+	for k := range sc.Baggage {
+		if _, ok := seen[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		b = append(b, w3ctrace.BaggageMember{Key: k, Value: sc.Baggage[k]})
+	}
+
+	return b
+}
+
 func pickupW3CTraceContext(h http.Header, sc *SpanContext) {
+	// baggage is propagated regardless of whether there is a trace parent
+	if b, err := w3ctrace.ExtractBaggage(h); err == nil {
+		for _, m := range b {
+			sc.Baggage[m.Key] = m.Value
+		}
+
+		sc.W3CContext.RawBaggage = b.String()
+	}
+
 	trCtx, err := w3ctrace.Extract(h)
 	if err != nil {
 		return
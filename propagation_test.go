@@ -40,6 +40,7 @@ func TestTracer_Inject_HTTPHeaders(t *testing.T) {
 				"X-Instana-B-Foo": {"bar"},
 				"Traceparent":     {"00-00000000000000010000000000002435-0000000000003546-01"},
 				"Tracestate":      {"in=0000000000002435;0000000000003546"},
+				"Baggage":         {"foo=bar"},
 				"Server-Timing":   {"intid;desc=0000000000002435"},
 			},
 		},
@@ -67,6 +68,7 @@ func TestTracer_Inject_HTTPHeaders(t *testing.T) {
 				"X-Instana-B-Foo": {"bar"},
 				"Traceparent":     {"00-00000000000000010000000000002435-0000000000003546-01"},
 				"Tracestate":      {"in=0000000000002435;0000000000003546"},
+				"Baggage":         {"foo=bar"},
 				"Server-Timing":   {"intid;desc=0000000000002435"},
 			},
 		},
@@ -507,6 +509,58 @@ func TestTracer_Inject_TextMap_AddValues(t *testing.T) {
 	}, carrier)
 }
 
+func TestTracer_Inject_TextMap_128BitTraceIDs(t *testing.T) {
+	opts := &instana.Options{
+		AgentClient: alwaysReadyClient{},
+		Tracer:      instana.TracerOptions{Use128BitTraceIDs: true},
+	}
+	tracer := instana.NewTracerWithEverything(opts, instana.NewTestRecorder())
+	defer instana.ShutdownSensor()
+
+	sc := instana.SpanContext{
+		TraceIDHi: 0x1,
+		TraceID:   0x2435,
+		SpanID:    0x3546,
+	}
+
+	carrier := map[string]string{}
+	require.NoError(t, tracer.Inject(sc, ot.TextMap, ot.TextMapCarrier(carrier)))
+
+	assert.Equal(t, map[string]string{
+		"x-instana-t": "00000000000000010000000000002435",
+		"x-instana-s": "0000000000003546",
+		"x-instana-l": "1",
+	}, carrier)
+}
+
+func TestTracer_Inject_HTTPHeaders_128BitTraceIDs(t *testing.T) {
+	opts := &instana.Options{
+		AgentClient: alwaysReadyClient{},
+		Tracer:      instana.TracerOptions{Use128BitTraceIDs: true},
+	}
+	tracer := instana.NewTracerWithEverything(opts, instana.NewTestRecorder())
+	defer instana.ShutdownSensor()
+
+	sp := tracer.StartSpan("root")
+	defer sp.Finish()
+
+	sc := sp.Context().(instana.SpanContext)
+	require.NotEmpty(t, sc.TraceIDHi)
+
+	h := http.Header{}
+	require.NoError(t, tracer.Inject(sc, ot.HTTPHeaders, ot.HTTPHeadersCarrier(h)))
+
+	traceID := instana.FormatLongID(sc.TraceIDHi, sc.TraceID)
+	assert.Equal(t, traceID, h.Get(instana.FieldT))
+	assert.Equal(t, "00-"+traceID+"-"+instana.FormatID(sc.SpanID)+"-01", h.Get("traceparent"))
+
+	extracted, err := tracer.Extract(ot.HTTPHeaders, ot.HTTPHeadersCarrier(h))
+	require.NoError(t, err)
+
+	assert.Equal(t, sc.TraceIDHi, extracted.(instana.SpanContext).TraceIDHi)
+	assert.Equal(t, sc.TraceID, extracted.(instana.SpanContext).TraceID)
+}
+
 func TestTracer_Inject_TextMap_UpdateValues(t *testing.T) {
 	recorder := instana.NewTestRecorder()
 	tracer := instana.NewTracerWithEverything(&instana.Options{AgentClient: alwaysReadyClient{}}, recorder)
@@ -711,3 +765,47 @@ func TestTracer_Inject_CarrierWithRemoveAll_SuppressedTrace(t *testing.T) {
 		"X-Instana-L": "0",
 	}, carrier)
 }
+
+func TestTracer_Extract_HTTPHeaders_W3CBaggage(t *testing.T) {
+	recorder := instana.NewTestRecorder()
+	tracer := instana.NewTracerWithEverything(&instana.Options{AgentClient: alwaysReadyClient{}}, recorder)
+	defer instana.ShutdownSensor()
+
+	headers := http.Header{
+		"X-Instana-T":     {"0000000000002435"},
+		"X-Instana-S":     {"0000000000003546"},
+		"X-Instana-B-foo": {"hello"},
+		"Baggage":         {"tenant=acme%20corp;ttl=60,foo=bar"},
+	}
+
+	sc, err := tracer.Extract(ot.HTTPHeaders, ot.HTTPHeadersCarrier(headers))
+	require.NoError(t, err)
+
+	// Instana baggage headers take precedence
+	assert.Equal(t, map[string]string{
+		"tenant": "acme corp",
+		"foo":    "hello",
+	}, sc.(instana.SpanContext).Baggage)
+}
+
+func TestTracer_Inject_HTTPHeaders_W3CBaggage(t *testing.T) {
+	recorder := instana.NewTestRecorder()
+	tracer := instana.NewTracerWithEverything(&instana.Options{AgentClient: alwaysReadyClient{}}, recorder)
+	defer instana.ShutdownSensor()
+
+	sc, err := tracer.Extract(ot.HTTPHeaders, ot.HTTPHeadersCarrier(http.Header{
+		"Traceparent": {"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+		"Baggage":     {"tenant=acme;ttl=60,userId=alice"},
+	}))
+	require.NoError(t, err)
+
+	sp := tracer.StartSpan("test", ot.ChildOf(sc))
+	sp.SetBaggageItem("region", "eu de")
+
+	headers := http.Header{}
+	require.NoError(t, tracer.Inject(sp.Context(), ot.HTTPHeaders, ot.HTTPHeadersCarrier(headers)))
+
+	// upstream members keep their order and properties
+	assert.Equal(t, "tenant=acme;ttl=60,userId=alice,region=eu%20de", headers.Get(w3ctrace.BaggageHeader))
+	assert.Equal(t, "eu de", headers.Get("X-Instana-B-region"))
+}
@@ -0,0 +1,259 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana
+
+import (
+	"net/url"
+	"strings"
+
+	ot "github.com/opentracing/opentracing-go"
+)
+
+// Trace context propagators supported by the tracer
+const (
+	// PropagatorInstana propagates the trace context using X-Instana-* headers
+	PropagatorInstana = "instana"
+	// PropagatorW3C propagates the trace context using W3C Trace Context traceparent and tracestate headers.
+	// This propagator is only used with opentracing.HTTPHeaders format.
+	PropagatorW3C = "w3c"
+	// PropagatorB3 propagates the trace context using Zipkin B3 multiple X-B3-* headers
+	PropagatorB3 = "b3"
+	// PropagatorB3Single propagates the trace context using Zipkin B3 single b3 header
+	PropagatorB3Single = "b3single"
+	// PropagatorJaeger propagates the trace context using Jaeger uber-trace-id header
+	PropagatorJaeger = "jaeger"
+)
+
+// DefaultPropagators is the list of propagators used by the tracer unless configured otherwise
+var DefaultPropagators = []string{PropagatorInstana, PropagatorW3C}
+
+// B3 and Jaeger header names
+const (
+	b3TraceIDHeader = "x-b3-traceid"
+	b3SpanIDHeader  = "x-b3-spanid"
+	b3SampledHeader = "x-b3-sampled"
+	b3FlagsHeader   = "x-b3-flags"
+	b3SingleHeader  = "b3"
+	jaegerHeader    = "uber-trace-id"
+)
+
+// propagatorEnabled returns whether the tracer is configured to use given propagator
+func propagatorEnabled(name string) bool {
+	propagators := DefaultPropagators
+	if opts := sensor.tracerOptions(); opts.Propagators != nil {
+		propagators = opts.Propagators
+	}
+
+	for _, p := range propagators {
+		if p == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// foreignTraceContext holds the trace context extracted using one of 3rd-party propagators
+type foreignTraceContext struct {
+	TraceIDHi, TraceID, SpanID int64
+	Suppressed                 bool
+}
+
+// foreignTraceHeader returns true if the header is used by one of the 3rd-party propagators
+func foreignTraceHeader(key string) bool {
+	switch key {
+	case b3TraceIDHeader, b3SpanIDHeader, b3SampledHeader, b3FlagsHeader, b3SingleHeader, jaegerHeader:
+		return true
+	default:
+		return false
+	}
+}
+
+// extractForeignTraceContext looks up the trace context in the headers collected from a carrier using enabled
+// 3rd-party propagators. The header names are expected to be in lower case.
+func extractForeignTraceContext(headers map[string]string) (foreignTraceContext, bool) {
+	extractors := []struct {
+		Name    string
+		Extract func(map[string]string) (foreignTraceContext, bool)
+	}{
+		{PropagatorB3, extractB3TraceContext},
+		{PropagatorB3Single, extractB3SingleTraceContext},
+		{PropagatorJaeger, extractJaegerTraceContext},
+	}
+
+	for _, e := range extractors {
+		if !propagatorEnabled(e.Name) {
+			continue
+		}
+
+		if c, ok := e.Extract(headers); ok {
+			return c, true
+		}
+	}
+
+	return foreignTraceContext{}, false
+}
+
+// injectForeignTraceContext sets the trace context headers for all enabled 3rd-party propagators
+func injectForeignTraceContext(sc SpanContext, carrier ot.TextMapWriter) {
+	if sc.TraceID == 0 || sc.SpanID == 0 {
+		// propagate the sampling decision for a suppressed trace
+		if sc.Suppressed && propagatorEnabled(PropagatorB3) {
+			carrier.Set(b3SampledHeader, "0")
+		}
+
+		if sc.Suppressed && propagatorEnabled(PropagatorB3Single) {
+			carrier.Set(b3SingleHeader, "0")
+		}
+
+		return
+	}
+
+	traceID, spanID := FormatID(sc.TraceID), FormatID(sc.SpanID)
+	if sc.TraceIDHi != 0 {
+		traceID = FormatLongID(sc.TraceIDHi, sc.TraceID)
+	}
+
+	sampled, jaegerFlags := "1", "1"
+	if sc.Suppressed {
+		sampled, jaegerFlags = "0", "0"
+	}
+
+	if propagatorEnabled(PropagatorB3) {
+		carrier.Set(b3TraceIDHeader, traceID)
+		carrier.Set(b3SpanIDHeader, spanID)
+		carrier.Set(b3SampledHeader, sampled)
+	}
+
+	if propagatorEnabled(PropagatorB3Single) {
+		if sc.Suppressed {
+			carrier.Set(b3SingleHeader, "0")
+		} else {
+			carrier.Set(b3SingleHeader, traceID+"-"+spanID+"-"+sampled)
+		}
+	}
+
+	if propagatorEnabled(PropagatorJaeger) {
+		carrier.Set(jaegerHeader, traceID+":"+spanID+":0:"+jaegerFlags)
+	}
+}
+
+// extractB3TraceContext extracts the trace context from B3 multiple headers, see
+// https://github.com/openzipkin/b3-propagation#multiple-headers
+func extractB3TraceContext(headers map[string]string) (foreignTraceContext, bool) {
+	c := foreignTraceContext{
+		Suppressed: headers[b3SampledHeader] == "0" || headers[b3SampledHeader] == "false",
+	}
+
+	// the debug flag implies an accept sampling decision
+	if headers[b3FlagsHeader] == "1" {
+		c.Suppressed = false
+	}
+
+	traceID, spanID := headers[b3TraceIDHeader], headers[b3SpanIDHeader]
+	if traceID == "" || spanID == "" {
+		return c, c.Suppressed
+	}
+
+	var err error
+	if c.TraceIDHi, c.TraceID, err = parseForeignTraceID(traceID); err != nil {
+		return foreignTraceContext{}, false
+	}
+
+	if c.SpanID, err = ParseID(spanID); err != nil {
+		return foreignTraceContext{}, false
+	}
+
+	return c, true
+}
+
+// extractB3SingleTraceContext extracts the trace context from B3 single header, see
+// https://github.com/openzipkin/b3-propagation#single-header
+func extractB3SingleTraceContext(headers map[string]string) (foreignTraceContext, bool) {
+	v, ok := headers[b3SingleHeader]
+	if !ok {
+		return foreignTraceContext{}, false
+	}
+
+	// b3: {SamplingState}
+	if v == "0" {
+		return foreignTraceContext{Suppressed: true}, true
+	}
+
+	// b3: {TraceId}-{SpanId}-{SamplingState}-{ParentSpanId}
+	parts := strings.Split(v, "-")
+	if len(parts) < 2 || len(parts) > 4 {
+		return foreignTraceContext{}, false
+	}
+
+	var (
+		c   foreignTraceContext
+		err error
+	)
+
+	if c.TraceIDHi, c.TraceID, err = parseForeignTraceID(parts[0]); err != nil {
+		return foreignTraceContext{}, false
+	}
+
+	if c.SpanID, err = ParseID(parts[1]); err != nil {
+		return foreignTraceContext{}, false
+	}
+
+	if len(parts) > 2 {
+		c.Suppressed = parts[2] == "0"
+	}
+
+	return c, true
+}
+
+// extractJaegerTraceContext extracts the trace context from Jaeger uber-trace-id header, see
+// https://www.jaegertracing.io/docs/1.21/client-libraries/#propagation-format
+func extractJaegerTraceContext(headers map[string]string) (foreignTraceContext, bool) {
+	v, ok := headers[jaegerHeader]
+	if !ok {
+		return foreignTraceContext{}, false
+	}
+
+	// the header value might be URL-encoded
+	if unescaped, err := url.QueryUnescape(v); err == nil {
+		v = unescaped
+	}
+
+	// uber-trace-id: {trace-id}:{span-id}:{parent-span-id}:{flags}
+	parts := strings.Split(v, ":")
+	if len(parts) != 4 {
+		return foreignTraceContext{}, false
+	}
+
+	var (
+		c   foreignTraceContext
+		err error
+	)
+
+	if c.TraceIDHi, c.TraceID, err = parseForeignTraceID(parts[0]); err != nil {
+		return foreignTraceContext{}, false
+	}
+
+	if c.SpanID, err = ParseID(parts[1]); err != nil {
+		return foreignTraceContext{}, false
+	}
+
+	flags, err := ParseID(parts[3])
+	if err != nil {
+		return foreignTraceContext{}, false
+	}
+	c.Suppressed = flags&0x1 == 0
+
+	return c, true
+}
+
+// parseForeignTraceID parses either 64-bit or 128-bit trace ID
+func parseForeignTraceID(s string) (int64, int64, error) {
+	if len(s) > 16 {
+		return ParseLongID(s)
+	}
+
+	id, err := ParseID(s)
+
+	return 0, id, err
+}
@@ -0,0 +1,228 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana_test
+
+import (
+	"net/http"
+	"testing"
+
+	instana "github.com/instana/go-sensor"
+	ot "github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracer_Inject_HTTPHeaders_Propagators(t *testing.T) {
+	examples := map[string]struct {
+		Propagators []string
+		Expected    http.Header
+	}{
+		"b3": {
+			Propagators: []string{instana.PropagatorB3},
+			Expected: http.Header{
+				"X-B3-Traceid": {"00000000000000010000000000002435"},
+				"X-B3-Spanid":  {"0000000000003546"},
+				"X-B3-Sampled": {"1"},
+			},
+		},
+		"b3 single header": {
+			Propagators: []string{instana.PropagatorB3Single},
+			Expected: http.Header{
+				"B3": {"00000000000000010000000000002435-0000000000003546-1"},
+			},
+		},
+		"jaeger": {
+			Propagators: []string{instana.PropagatorJaeger},
+			Expected: http.Header{
+				"Uber-Trace-Id": {"00000000000000010000000000002435:0000000000003546:0:1"},
+			},
+		},
+		"instana and b3": {
+			Propagators: []string{instana.PropagatorInstana, instana.PropagatorB3},
+			Expected: http.Header{
+				"X-Instana-T":   {"0000000000002435"},
+				"X-Instana-S":   {"0000000000003546"},
+				"X-Instana-L":   {"1"},
+				"X-B3-Traceid":  {"00000000000000010000000000002435"},
+				"X-B3-Spanid":   {"0000000000003546"},
+				"X-B3-Sampled":  {"1"},
+				"Server-Timing": {"intid;desc=0000000000002435"},
+			},
+		},
+	}
+
+	for name, example := range examples {
+		t.Run(name, func(t *testing.T) {
+			opts := &instana.Options{
+				AgentClient: alwaysReadyClient{},
+				Tracer:      instana.TracerOptions{Propagators: example.Propagators},
+			}
+			tracer := instana.NewTracerWithEverything(opts, instana.NewTestRecorder())
+			defer instana.ShutdownSensor()
+
+			sc := instana.SpanContext{
+				TraceIDHi: 0x1,
+				TraceID:   0x2435,
+				SpanID:    0x3546,
+			}
+
+			h := http.Header{}
+			require.NoError(t, tracer.Inject(sc, ot.HTTPHeaders, ot.HTTPHeadersCarrier(h)))
+
+			// Server-Timing is set regardless of enabled propagators
+			if _, ok := example.Expected["Server-Timing"]; !ok {
+				h.Del("Server-Timing")
+			}
+
+			assert.Equal(t, example.Expected, h)
+		})
+	}
+}
+
+func TestTracer_Inject_TextMap_Propagators_SuppressedTracing(t *testing.T) {
+	opts := &instana.Options{
+		AgentClient: alwaysReadyClient{},
+		Tracer: instana.TracerOptions{
+			Propagators: []string{instana.PropagatorB3, instana.PropagatorB3Single, instana.PropagatorJaeger},
+		},
+	}
+	tracer := instana.NewTracerWithEverything(opts, instana.NewTestRecorder())
+	defer instana.ShutdownSensor()
+
+	sc := instana.SpanContext{
+		TraceID:    0x2435,
+		SpanID:     0x3546,
+		Suppressed: true,
+	}
+
+	carrier := map[string]string{}
+	require.NoError(t, tracer.Inject(sc, ot.TextMap, ot.TextMapCarrier(carrier)))
+
+	assert.Equal(t, map[string]string{
+		"x-b3-traceid":  "0000000000002435",
+		"x-b3-spanid":   "0000000000003546",
+		"x-b3-sampled":  "0",
+		"b3":            "0",
+		"uber-trace-id": "0000000000002435:0000000000003546:0:0",
+	}, carrier)
+}
+
+func TestTracer_Extract_HTTPHeaders_Propagators(t *testing.T) {
+	examples := map[string]struct {
+		Headers  http.Header
+		Expected instana.SpanContext
+	}{
+		"b3": {
+			Headers: http.Header{
+				"X-B3-TraceId":      {"463ac35c9f6413ad48485a3953bb6124"},
+				"X-B3-SpanId":       {"a2fb4a1d1a96d312"},
+				"X-B3-ParentSpanId": {"0020000000000001"},
+				"X-B3-Sampled":      {"1"},
+			},
+			Expected: instana.SpanContext{
+				TraceIDHi: 0x463ac35c9f6413ad,
+				TraceID:   0x48485a3953bb6124,
+				SpanID:    -0x5d04b5e2e5692cee,
+			},
+		},
+		"b3 not sampled": {
+			Headers: http.Header{
+				"X-B3-Sampled": {"0"},
+			},
+			Expected: instana.SpanContext{
+				Suppressed: true,
+			},
+		},
+		"b3 single header": {
+			Headers: http.Header{
+				"B3": {"80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1-05e3ac9a4f6e3b90"},
+			},
+			Expected: instana.SpanContext{
+				TraceIDHi: -0x7f0e6711a9cbc458,
+				TraceID:   0x64fe8b2a57d3eff7,
+				SpanID:    -0x1ba84a5d1b27942f,
+			},
+		},
+		"b3 single header, 64-bit trace id": {
+			Headers: http.Header{
+				"B3": {"64fe8b2a57d3eff7-00f067aa0ba902b7"},
+			},
+			Expected: instana.SpanContext{
+				TraceID: 0x64fe8b2a57d3eff7,
+				SpanID:  0xf067aa0ba902b7,
+			},
+		},
+		"b3 single header, deny": {
+			Headers: http.Header{
+				"B3": {"0"},
+			},
+			Expected: instana.SpanContext{
+				Suppressed: true,
+			},
+		},
+		"jaeger": {
+			Headers: http.Header{
+				"Uber-Trace-Id": {"64fe8b2a57d3eff7:f067aa0ba902b7:0:1"},
+			},
+			Expected: instana.SpanContext{
+				TraceID: 0x64fe8b2a57d3eff7,
+				SpanID:  0xf067aa0ba902b7,
+			},
+		},
+		"jaeger, url-encoded and not sampled": {
+			Headers: http.Header{
+				"Uber-Trace-Id": {"64fe8b2a57d3eff7%3Af067aa0ba902b7%3A0%3A0"},
+			},
+			Expected: instana.SpanContext{
+				TraceID:    0x64fe8b2a57d3eff7,
+				SpanID:     0xf067aa0ba902b7,
+				Suppressed: true,
+			},
+		},
+		"instana headers take precedence": {
+			Headers: http.Header{
+				"X-Instana-T":   {"0000000000002435"},
+				"X-Instana-S":   {"0000000000003546"},
+				"Uber-Trace-Id": {"64fe8b2a57d3eff7:f067aa0ba902b7:0:1"},
+			},
+			Expected: instana.SpanContext{
+				TraceID: 0x2435,
+				SpanID:  0x3546,
+			},
+		},
+	}
+
+	for name, example := range examples {
+		t.Run(name, func(t *testing.T) {
+			opts := &instana.Options{
+				AgentClient: alwaysReadyClient{},
+				Tracer: instana.TracerOptions{
+					Propagators: []string{
+						instana.PropagatorInstana,
+						instana.PropagatorB3,
+						instana.PropagatorB3Single,
+						instana.PropagatorJaeger,
+					},
+				},
+			}
+			tracer := instana.NewTracerWithEverything(opts, instana.NewTestRecorder())
+			defer instana.ShutdownSensor()
+
+			sc, err := tracer.Extract(ot.HTTPHeaders, ot.HTTPHeadersCarrier(example.Headers))
+			require.NoError(t, err)
+
+			example.Expected.Baggage = map[string]string{}
+			assert.Equal(t, example.Expected, sc)
+		})
+	}
+}
+
+func TestTracer_Extract_HTTPHeaders_PropagatorDisabled(t *testing.T) {
+	tracer := instana.NewTracerWithEverything(&instana.Options{AgentClient: alwaysReadyClient{}}, instana.NewTestRecorder())
+	defer instana.ShutdownSensor()
+
+	_, err := tracer.Extract(ot.HTTPHeaders, ot.HTTPHeadersCarrier(http.Header{
+		"Uber-Trace-Id": {"64fe8b2a57d3eff7:f067aa0ba902b7:0:1"},
+	}))
+	assert.Equal(t, ot.ErrSpanContextNotFound, err)
+}
@@ -6,10 +6,21 @@ package instana
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	// the number of independent span queues used by the recorder to reduce lock contention
+	recorderShards = 8
+	// the maximum number of span batches being sent to the agent simultaneously
+	maxInFlightBatches = 2
+	// the interval between regular flushes of the queued spans
+	recorderFlushInterval = 1 * time.Second
+)
+
 // A SpanRecorder handles all of the `RawSpan` data generated via an
 // associated `Tracer` (see `NewStandardTracer`) instance. It also names
 // the containing process and provides access to a straightforward tag map.
@@ -20,25 +31,68 @@ type SpanRecorder interface {
 	Flush(context.Context) error
 }
 
+// RecorderStats contains the span recorder counters
+type RecorderStats struct {
+	// QueuedSpans is the number of spans currently awaiting to be sent to the agent
+	QueuedSpans int
+	// SentSpans is the number of spans that have been successfully sent to the agent
+	SentSpans uint64
+	// DroppedSpans is the number of spans evicted from the queue because of exceeding the
+	// (instana.Options).MaxBufferedSpans limit
+	DroppedSpans uint64
+	// SpooledSpans is the number of spans moved to the span spool, see (instana.Options).SpoolDir
+	SpooledSpans uint64
+	// SendFailures is the number of failed attempts to send a batch of spans to the agent
+	SendFailures uint64
+	// Retries is the number of span batches returned back to the queue to be resent after a failure
+	Retries uint64
+}
+
+type queuedSpan struct {
+	Seq  uint64
+	Span Span
+}
+
+type recorderShard struct {
+	mu    sync.Mutex
+	spans []queuedSpan
+}
+
 // Recorder accepts spans, processes and queues them
 // for delivery to the backend.
+//
+// The finished spans are distributed between several independent queues, so that recording
+// spans from multiple goroutines does not require a global lock. The queued spans are sent to
+// the agent by a single exporter goroutine, either periodically or once the number of queued
+// spans reaches the (instana.Options).ForceTransmissionStartingAt value.
 type Recorder struct {
-	sync.RWMutex
-	spans    []Span
-	testMode bool
+	// counters are placed first to ensure 64-bit alignment required by sync/atomic on 32-bit platforms
+	seq          uint64
+	queued       int64
+	sentSpans    uint64
+	droppedSpans uint64
+	spooledSpans uint64
+	sendFailures uint64
+	retries      uint64
+
+	shards      [recorderShards]recorderShard
+	flushSignal chan struct{}
+	inFlight    chan struct{}
+	testMode    bool
+
+	done      chan struct{}
+	closeOnce sync.Once
+	exporter  sync.WaitGroup
 }
 
 // NewRecorder initializes a new span recorder
 func NewRecorder() *Recorder {
-	r := &Recorder{}
+	r := newRecorder(false)
 
-	ticker := time.NewTicker(1 * time.Second)
+	r.exporter.Add(1)
 	go func() {
-		for range ticker.C {
-			if sensor.Agent().Ready() {
-				go r.Flush(context.Background())
-			}
-		}
+		defer r.exporter.Done()
+		r.export(recorderFlushInterval)
 	}()
 
 	return r
@@ -47,8 +101,15 @@ func NewRecorder() *Recorder {
 // NewTestRecorder initializes a new span recorder that keeps all collected
 // until they are requested. This recorder does not send spans to the agent (used for testing)
 func NewTestRecorder() *Recorder {
+	return newRecorder(true)
+}
+
+func newRecorder(testMode bool) *Recorder {
 	return &Recorder{
-		testMode: true,
+		flushSignal: make(chan struct{}, 1),
+		inFlight:    make(chan struct{}, maxInFlightBatches),
+		testMode:    testMode,
+		done:        make(chan struct{}),
 	}
 }
 
@@ -56,80 +117,320 @@ func NewTestRecorder() *Recorder {
 // for eventual reporting to the host agent.
 func (r *Recorder) RecordSpan(span *spanS) {
 	// If we're not announced and not in test mode then just
-	// return
-	if !r.testMode && !sensor.Agent().Ready() {
+	// return. The spans are still buffered while the connection
+	// to the agent is being re-established.
+	if !r.testMode && !sensor.Agent().Ready() && !agentReconnecting() {
 		return
 	}
 
-	r.Lock()
-	defer r.Unlock()
-
-	if len(r.spans) == sensor.options.MaxBufferedSpans {
-		r.spans = r.spans[1:]
+	if atomic.LoadInt64(&r.queued) >= int64(sensor.options.MaxBufferedSpans) {
+		r.handleOverflow()
 	}
 
-	r.spans = append(r.spans, newSpan(span))
+	seq := atomic.AddUint64(&r.seq, 1)
+
+	shard := &r.shards[seq%recorderShards]
+	shard.mu.Lock()
+	shard.spans = append(shard.spans, queuedSpan{
+		Seq:  seq,
+		Span: newSpan(span),
+	})
+	shard.mu.Unlock()
+
+	queued := atomic.AddInt64(&r.queued, 1)
 
 	if r.testMode || !sensor.Agent().Ready() {
 		return
 	}
 
-	if len(r.spans) >= sensor.options.ForceTransmissionStartingAt {
-		sensor.logger.Debug("forcing ", len(r.spans), "span(s) to the agent")
-		go r.Flush(context.Background())
+	if queued >= int64(sensor.options.ForceTransmissionStartingAt) {
+		// notify the exporter without blocking if there is a pending notification already
+		select {
+		case r.flushSignal <- struct{}{}:
+			sensor.logger.Debug("forcing ", queued, " span(s) to the agent")
+		default:
+		}
 	}
 }
 
+// agentReconnecting returns whether the sensor is re-establishing the connection to the host agent
+func agentReconnecting() bool {
+	agent, ok := sensor.Agent().(*agentS)
+
+	return ok && agent.reconnecting()
+}
+
 // QueuedSpansCount returns the number of queued spans
 //
 //	Used only in tests currently.
 func (r *Recorder) QueuedSpansCount() int {
-	r.RLock()
-	defer r.RUnlock()
-	return len(r.spans)
+	return int(atomic.LoadInt64(&r.queued))
 }
 
 // GetQueuedSpans returns a copy of the queued spans and clears the queue.
 func (r *Recorder) GetQueuedSpans() []Span {
-	r.Lock()
-	defer r.Unlock()
-
-	// Copy queued spans
-	queuedSpans := make([]Span, len(r.spans))
-	copy(queuedSpans, r.spans)
+	return spansOf(r.drain())
+}
 
-	// and clear out the source
-	r.clearQueuedSpans()
-	return queuedSpans
+// Stats returns the recorder counters
+func (r *Recorder) Stats() RecorderStats {
+	return RecorderStats{
+		QueuedSpans:  r.QueuedSpansCount(),
+		SentSpans:    atomic.LoadUint64(&r.sentSpans),
+		DroppedSpans: atomic.LoadUint64(&r.droppedSpans),
+		SpooledSpans: atomic.LoadUint64(&r.spooledSpans),
+		SendFailures: atomic.LoadUint64(&r.sendFailures),
+		Retries:      atomic.LoadUint64(&r.retries),
+	}
 }
 
-// Flush sends queued spans to the agent
+// Flush sends queued spans to the agent. If the span spool is enabled, the oldest spooled batch is sent
+// first, and the spans that failed to be sent are moved to the spool. The spool is replayed one batch per
+// flush to avoid delaying the queued spans when there is a large backlog of spooled ones.
 func (r *Recorder) Flush(ctx context.Context) error {
-	spansToSend := r.GetQueuedSpans()
-	if len(spansToSend) == 0 {
+	// limit the number of batches being sent simultaneously
+	select {
+	case r.inFlight <- struct{}{}:
+		defer func() { <-r.inFlight }()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	// a failed replay does not prevent the queued spans from being sent or spooled
+	replayErr := sensor.Spool().ReplayNext(sensor.Agent().SendSpans)
+	if replayErr != nil {
+		atomic.AddUint64(&r.sendFailures, 1)
+		sensor.logger.Debug("failed to replay spooled spans: ", replayErr)
+	}
+
+	batch := r.drain()
+	if len(batch) == 0 {
+		return replayErr
+	}
+
+	if replayErr != nil && !sensor.Agent().Ready() {
+		// the agent has become unreachable while the spool was being replayed, so there is no point in sending
+		// the queued spans as well
+		return r.keep(batch, spansOf(batch), replayErr)
+	}
+
+	spansToSend := spansOf(batch)
+	sent, err := sendSpans(sensor.Agent(), spansToSend)
+	if err != nil {
+		atomic.AddUint64(&r.sendFailures, 1)
+
+		// only keep the spans that have not been delivered to the agent
+		if e, ok := err.(*unsentSpansError); ok {
+			atomic.AddUint64(&r.sentSpans, uint64(e.Sent))
+
+			batch = unsentQueuedSpans(batch, e.UnsentIndices, e.Unsent)
+			if len(batch) == 0 {
+				return fmt.Errorf("failed to send collected spans to the agent: %s", err)
+			}
+
+			spansToSend = e.Unsent
+		}
+
+		return r.keep(batch, spansToSend, err)
+	}
+
+	atomic.AddUint64(&r.sentSpans, uint64(sent))
+
+	return replayErr
+}
+
+// sendSpans sends spans using provided agent client and returns the number of delivered spans. The host agent
+// client drops the spans exceeding the payload size limit, so these are not counted as sent.
+func sendSpans(agent AgentClient, spans []Span) (int, error) {
+	if a, ok := agent.(*agentS); ok {
+		return a.sendSpans(spans)
+	}
+
+	if err := agent.SendSpans(spans); err != nil {
+		return 0, err
+	}
+
+	return len(spans), nil
+}
+
+// keep stores the spans that failed to be sent in the spool if it's enabled, otherwise they are put back
+// in the queue to be sent again
+func (r *Recorder) keep(batch []queuedSpan, spans []Span, err error) error {
+	if sensor.Spool().Store(spans) {
+		atomic.AddUint64(&r.spooledSpans, uint64(len(spans)))
+		return fmt.Errorf("failed to send collected spans to the agent, spans have been spooled: %s", err)
+	}
+
+	// put failed spans in front of the queue to make sure they are evicted first
+	// whenever the queue length exceeds options.MaxBufferedSpans
+	r.requeue(batch)
+	atomic.AddUint64(&r.retries, 1)
+
+	return fmt.Errorf("failed to send collected spans to the agent: %s", err)
+}
+
+// Close stops the periodic export of the queued spans and flushes them if the agent is ready. The spans
+// recorded after the recorder has been closed are retained until Flush() is called.
+func (r *Recorder) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.done)
+	})
+	r.exporter.Wait()
+
+	if r.testMode || !sensor.Agent().Ready() {
 		return nil
 	}
 
-	if err := sensor.Agent().SendSpans(spansToSend); err != nil {
-		r.Lock()
-		defer r.Unlock()
+	return r.Flush(context.Background())
+}
+
+// export periodically flushes the queued spans to the agent until the recorder is closed
+func (r *Recorder) export(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-r.flushSignal:
+		case <-r.done:
+			return
+		}
+
+		if !sensor.Agent().Ready() {
+			continue
+		}
+
+		if err := r.Flush(context.Background()); err != nil {
+			sensor.logger.Debug(err)
+		}
+	}
+}
+
+// handleOverflow makes room for a new span in the queue either by moving all queued spans
+// to the span spool or by evicting the oldest span
+func (r *Recorder) handleOverflow() {
+	if sp := sensor.Spool(); sp != nil {
+		batch := r.drain()
 
-		// put failed spans in front of the queue to make sure they are evicted first
-		// whenever the queue length exceeds options.MaxBufferedSpans
-		r.spans = append(spansToSend, r.spans...)
+		if sp.Store(spansOf(batch)) {
+			atomic.AddUint64(&r.spooledSpans, uint64(len(batch)))
+			return
+		}
 
-		return fmt.Errorf("failed to send collected spans to the agent: %s", err)
+		r.requeue(batch)
 	}
 
-	return nil
+	r.evictOldest()
 }
 
-// clearQueuedSpans brings the span queue to empty/0/nada
-//
-//	This function doesn't take the Lock so make sure to have
-//	the write lock before calling.
-//	This is meant to be called from GetQueuedSpans which handles
-//	locking.
-func (r *Recorder) clearQueuedSpans() {
-	r.spans = r.spans[:0]
+// drain removes all spans from the queue and returns them ordered by the time they were recorded
+func (r *Recorder) drain() []queuedSpan {
+	var batch []queuedSpan
+	for i := range r.shards {
+		shard := &r.shards[i]
+
+		shard.mu.Lock()
+		batch = append(batch, shard.spans...)
+		shard.spans = nil
+		shard.mu.Unlock()
+	}
+
+	atomic.AddInt64(&r.queued, -int64(len(batch)))
+
+	sort.Slice(batch, func(i, j int) bool {
+		return batch[i].Seq < batch[j].Seq
+	})
+
+	return batch
+}
+
+// requeue puts an ordered batch of spans back in front of the queue and evicts the oldest spans
+// if the queue length exceeds options.MaxBufferedSpans
+func (r *Recorder) requeue(batch []queuedSpan) {
+	var groups [recorderShards][]queuedSpan
+	for _, qs := range batch {
+		ind := qs.Seq % recorderShards
+		groups[ind] = append(groups[ind], qs)
+	}
+
+	for i := range r.shards {
+		if len(groups[i]) == 0 {
+			continue
+		}
+
+		shard := &r.shards[i]
+
+		shard.mu.Lock()
+		shard.spans = append(groups[i], shard.spans...)
+		shard.mu.Unlock()
+	}
+
+	atomic.AddInt64(&r.queued, int64(len(batch)))
+
+	for atomic.LoadInt64(&r.queued) > int64(sensor.options.MaxBufferedSpans) {
+		if !r.evictOldest() {
+			break
+		}
+	}
+}
+
+// evictOldest removes the oldest span from the queue. It returns false if the queue is empty.
+func (r *Recorder) evictOldest() bool {
+	oldest := -1
+	var oldestSeq uint64
+
+	for i := range r.shards {
+		shard := &r.shards[i]
+
+		shard.mu.Lock()
+		if len(shard.spans) > 0 && (oldest < 0 || shard.spans[0].Seq < oldestSeq) {
+			oldest, oldestSeq = i, shard.spans[0].Seq
+		}
+		shard.mu.Unlock()
+	}
+
+	if oldest < 0 {
+		return false
+	}
+
+	shard := &r.shards[oldest]
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if len(shard.spans) == 0 {
+		// the shard has been drained in the meantime
+		return false
+	}
+
+	shard.spans = shard.spans[1:]
+	atomic.AddInt64(&r.queued, -1)
+	atomic.AddUint64(&r.droppedSpans, 1)
+
+	return true
+}
+
+// unsentQueuedSpans pairs the spans that have not been delivered with the sequence numbers of the queued
+// spans they have been created from, so that they keep their order when put back in the queue
+func unsentQueuedSpans(batch []queuedSpan, indices []int, unsent []Span) []queuedSpan {
+	result := make([]queuedSpan, 0, len(unsent))
+	for i, idx := range indices {
+		if i >= len(unsent) || idx < 0 || idx >= len(batch) {
+			break
+		}
+
+		result = append(result, queuedSpan{Seq: batch[idx].Seq, Span: unsent[i]})
+	}
+
+	return result
+}
+
+func spansOf(batch []queuedSpan) []Span {
+	spans := make([]Span, len(batch))
+	for i, qs := range batch {
+		spans[i] = qs.Span
+	}
+
+	return spans
 }
@@ -0,0 +1,68 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_unsentQueuedSpans(t *testing.T) {
+	batch := []queuedSpan{
+		{Seq: 1, Span: Span{Name: "span-1"}},
+		{Seq: 2, Span: Span{Name: "span-2"}},
+		{Seq: 3, Span: Span{Name: "span-3"}},
+		{Seq: 4, Span: Span{Name: "span-4"}},
+	}
+
+	// span-3 has been dropped as oversized, so the unsent spans are not the last ones in the batch
+	unsent := []Span{{Name: "unsent-2"}, {Name: "unsent-4"}}
+
+	assert.Equal(t, []queuedSpan{
+		{Seq: 2, Span: Span{Name: "unsent-2"}},
+		{Seq: 4, Span: Span{Name: "unsent-4"}},
+	}, unsentQueuedSpans(batch, []int{1, 3}, unsent))
+}
+
+func TestShutdownSensor_ClosesRecorders(t *testing.T) {
+	optsRecorder, tracerRecorder := NewRecorder(), NewRecorder()
+
+	InitSensor(&Options{
+		Recorder:    NewTailSamplingRecorder(optsRecorder, DefaultTailSamplingOptions()),
+		AgentClient: alwaysReadyClient{},
+	})
+	sensor.Metrics().AddRecorder(tracerRecorder)
+
+	ShutdownSensor()
+
+	for _, r := range []*Recorder{optsRecorder, tracerRecorder} {
+		select {
+		case <-r.done:
+		default:
+			t.Error("recorder has not been closed")
+		}
+	}
+
+	// closing the recorder twice is safe
+	assert.NoError(t, optsRecorder.Close())
+}
+
+func TestRecorder_Flush_OversizedSpans(t *testing.T) {
+	agent, _ := newTestReadyAgent(&recordingHTTPClient{})
+
+	recorder := NewTestRecorder()
+	tracer := NewTracerWithEverything(&Options{AgentClient: agent}, recorder)
+	defer ShutdownSensor()
+
+	tracer.StartSpan("small").Finish()
+	tracer.StartSpan("oversized").SetTag("value", strings.Repeat("x", maxContentLength)).Finish()
+
+	require.NoError(t, recorder.Flush(context.Background()))
+
+	// the oversized span is dropped by the agent client and not counted as sent
+	assert.Equal(t, RecorderStats{SentSpans: 1}, recorder.Stats())
+}
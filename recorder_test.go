@@ -4,6 +4,13 @@
 package instana_test
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
 	"testing"
 
 	instana "github.com/instana/go-sensor"
@@ -56,3 +63,218 @@ func TestRecorder_BatchSpan_Single(t *testing.T) {
 
 	assert.Nil(t, spans[0].Batch)
 }
+
+func TestRecorder_Flush_Spool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "instana-spool")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	agent := &flakyAgentClient{Failing: true}
+
+	recorder := instana.NewTestRecorder()
+	tracer := instana.NewTracerWithEverything(&instana.Options{
+		AgentClient:      agent,
+		SpoolDir:         dir,
+		MaxBufferedSpans: 3,
+	}, recorder)
+	defer instana.ShutdownSensor()
+
+	tracer.StartSpan("span-1").Finish()
+	tracer.StartSpan("span-2").Finish()
+
+	// the agent is not available, spans are moved to the spool
+	assert.Error(t, recorder.Flush(context.Background()))
+	assert.Equal(t, 0, recorder.QueuedSpansCount())
+
+	// the buffer is full, spans are moved to the spool
+	tracer.StartSpan("span-3").Finish()
+	tracer.StartSpan("span-4").Finish()
+	tracer.StartSpan("span-5").Finish()
+	tracer.StartSpan("span-6").Finish()
+	assert.Equal(t, 1, recorder.QueuedSpansCount())
+
+	// the oldest spooled batch is sent before the queued spans
+	agent.Failing = false
+	require.NoError(t, recorder.Flush(context.Background()))
+	assert.Equal(t, []string{"span-1", "span-2", "span-6"}, sentSDKSpanNames(t, agent.Sent))
+
+	// the spool is replayed one batch per flush
+	require.NoError(t, recorder.Flush(context.Background()))
+	assert.Equal(t, []string{"span-1", "span-2", "span-6", "span-3", "span-4", "span-5"}, sentSDKSpanNames(t, agent.Sent))
+
+	// the spool is empty now
+	require.NoError(t, recorder.Flush(context.Background()))
+	assert.Len(t, agent.Sent, 3)
+}
+
+func TestRecorder_Flush_SpoolReplayFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "instana-spool")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	agent := &flakyAgentClient{Failing: true}
+
+	recorder := instana.NewTestRecorder()
+	tracer := instana.NewTracerWithEverything(&instana.Options{
+		AgentClient: agent,
+		SpoolDir:    dir,
+	}, recorder)
+	defer instana.ShutdownSensor()
+
+	tracer.StartSpan("span-1").Finish()
+	assert.Error(t, recorder.Flush(context.Background()))
+
+	// the failed replay does not prevent the queued spans from being sent and spooled
+	tracer.StartSpan("span-2").Finish()
+	assert.Error(t, recorder.Flush(context.Background()))
+	assert.Equal(t, 3, agent.Attempts)
+	assert.Equal(t, 0, recorder.QueuedSpansCount())
+
+	// the queued spans are spooled without being sent once the agent becomes unreachable
+	tracer.StartSpan("span-3").Finish()
+	agent.Unreachable = true
+
+	assert.Error(t, recorder.Flush(context.Background()))
+	assert.Equal(t, 4, agent.Attempts)
+	assert.Equal(t, 0, recorder.QueuedSpansCount())
+	assert.EqualValues(t, 3, recorder.Stats().SpooledSpans)
+
+	agent.Failing, agent.Unreachable = false, false
+	for i := 0; i < 3; i++ {
+		require.NoError(t, recorder.Flush(context.Background()))
+	}
+
+	assert.Equal(t, []string{"span-1", "span-2", "span-3"}, sentSDKSpanNames(t, agent.Sent))
+}
+
+func sentSDKSpanNames(t *testing.T, payloads [][]byte) []string {
+	var names []string
+	for _, payload := range payloads {
+		var spans []struct {
+			Data struct {
+				SDK struct {
+					Name string `json:"name"`
+				} `json:"sdk"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(payload, &spans))
+
+		for _, sp := range spans {
+			names = append(names, sp.Data.SDK.Name)
+		}
+	}
+
+	return names
+}
+
+type flakyAgentClient struct {
+	alwaysReadyClient
+
+	Failing     bool
+	Unreachable bool
+	Attempts    int
+	Sent        [][]byte
+}
+
+func (c *flakyAgentClient) Ready() bool { return !c.Unreachable }
+
+func (c *flakyAgentClient) SendSpans(spans []instana.Span) error {
+	c.Attempts++
+
+	if c.Failing {
+		return errors.New("agent is not available")
+	}
+
+	data, err := json.Marshal(spans)
+	if err != nil {
+		return err
+	}
+
+	c.Sent = append(c.Sent, data)
+
+	return nil
+}
+
+func TestRecorder_Stats(t *testing.T) {
+	agent := &flakyAgentClient{Failing: true}
+
+	recorder := instana.NewTestRecorder()
+	tracer := instana.NewTracerWithEverything(&instana.Options{
+		AgentClient:      agent,
+		MaxBufferedSpans: 3,
+	}, recorder)
+	defer instana.ShutdownSensor()
+
+	for i := 0; i < 5; i++ {
+		tracer.StartSpan(fmt.Sprintf("span-%d", i)).Finish()
+	}
+
+	assert.Equal(t, instana.RecorderStats{
+		QueuedSpans:  3,
+		DroppedSpans: 2,
+	}, recorder.Stats())
+
+	// failed spans are returned to the queue
+	assert.Error(t, recorder.Flush(context.Background()))
+	assert.Equal(t, instana.RecorderStats{
+		QueuedSpans:  3,
+		DroppedSpans: 2,
+		SendFailures: 1,
+		Retries:      1,
+	}, recorder.Stats())
+
+	// failed spans are evicted first
+	tracer.StartSpan("span-5").Finish()
+
+	agent.Failing = false
+	require.NoError(t, recorder.Flush(context.Background()))
+
+	assert.Equal(t, instana.RecorderStats{
+		SentSpans:    3,
+		DroppedSpans: 3,
+		SendFailures: 1,
+		Retries:      1,
+	}, recorder.Stats())
+
+	require.Len(t, agent.Sent, 1)
+
+	var spans []struct {
+		Data struct {
+			SDK struct {
+				Name string `json:"name"`
+			} `json:"sdk"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(agent.Sent[0], &spans))
+	require.Len(t, spans, 3)
+
+	assert.Equal(t, "span-3", spans[0].Data.SDK.Name)
+	assert.Equal(t, "span-4", spans[1].Data.SDK.Name)
+	assert.Equal(t, "span-5", spans[2].Data.SDK.Name)
+}
+
+func TestRecorder_RecordSpan_Concurrent(t *testing.T) {
+	recorder := instana.NewTestRecorder()
+	tracer := instana.NewTracerWithEverything(&instana.Options{
+		AgentClient:      alwaysReadyClient{},
+		MaxBufferedSpans: 10000,
+	}, recorder)
+	defer instana.ShutdownSensor()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				tracer.StartSpan("span").Finish()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1000, recorder.QueuedSpansCount())
+	assert.Len(t, recorder.GetQueuedSpans(), 1000)
+	assert.Equal(t, 0, recorder.QueuedSpansCount())
+}
@@ -914,6 +914,259 @@ func (tags AWSLambdaSQSSpanTags) IsZero() bool {
 	return len(tags.Messages) == 0
 }
 
+// truncateLambdaTriggerTags truncates the list to the first 3 items and limits each item to the first 200 characters
+// to reduce the payload. It returns true if the list was truncated.
+func truncateLambdaTriggerTags(items []string) ([]string, bool) {
+	var more bool
+	if len(items) > 3 {
+		items, more = items[:3], true
+	}
+
+	for i := range items {
+		if len(items[i]) > 200 {
+			items[i] = items[i][:200]
+		}
+	}
+
+	return items, more
+}
+
+// AWSLambdaDynamoDBSpanTags contains fields within the `data.lambda.dynamodb` section of an OT span document
+type AWSLambdaDynamoDBSpanTags struct {
+	// Stream is the ARN of the DynamoDB stream
+	Stream string `json:"stream"`
+	// Events contains the names of data modification events, i.e. INSERT, MODIFY or REMOVE
+	Events []string `json:"events,omitempty"`
+	// More is set to true if the events list was truncated
+	More bool `json:"more,omitempty"`
+}
+
+// newAWSLambdaDynamoDBSpanTags extracts DynamoDB Streams event tags for an AWS Lambda entry span. It truncates
+// the events list to the first 3 items to reduce the payload.
+func newAWSLambdaDynamoDBSpanTags(span *spanS) AWSLambdaDynamoDBSpanTags {
+	var tags AWSLambdaDynamoDBSpanTags
+
+	if v, ok := span.Tags["dynamodb.stream"]; ok {
+		readStringTag(&tags.Stream, v)
+	}
+
+	if v, ok := span.Tags["dynamodb.events"]; ok {
+		readArrayStringTag(&tags.Events, v)
+	}
+
+	tags.Events, tags.More = truncateLambdaTriggerTags(tags.Events)
+
+	return tags
+}
+
+// IsZero returns true if an AWSLambdaDynamoDBSpanTags struct was populated with stream data
+func (tags AWSLambdaDynamoDBSpanTags) IsZero() bool {
+	return tags.Stream == ""
+}
+
+// AWSLambdaKinesisSpanTags contains fields within the `data.lambda.kinesis` section of an OT span document
+type AWSLambdaKinesisSpanTags struct {
+	// Stream is the ARN of the Kinesis data stream
+	Stream string `json:"stream"`
+	// Records is the number of records delivered with the event
+	Records int `json:"records,omitempty"`
+}
+
+// newAWSLambdaKinesisSpanTags extracts Kinesis Data Streams event tags for an AWS Lambda entry span
+func newAWSLambdaKinesisSpanTags(span *spanS) AWSLambdaKinesisSpanTags {
+	var tags AWSLambdaKinesisSpanTags
+
+	if v, ok := span.Tags["kinesis.stream"]; ok {
+		readStringTag(&tags.Stream, v)
+	}
+
+	if v, ok := span.Tags["kinesis.records"]; ok {
+		readIntTag(&tags.Records, v)
+	}
+
+	return tags
+}
+
+// IsZero returns true if an AWSLambdaKinesisSpanTags struct was populated with stream data
+func (tags AWSLambdaKinesisSpanTags) IsZero() bool {
+	return tags.Stream == ""
+}
+
+// AWSLambdaSNSSpanTags contains fields within the `data.lambda.sns` section of an OT span document
+type AWSLambdaSNSSpanTags struct {
+	// Topic is the ARN of the SNS topic
+	Topic string `json:"topic"`
+	// MessageID is the ID of the SNS message
+	MessageID string `json:"messageId,omitempty"`
+	// Subject is the subject of the SNS message
+	Subject string `json:"subject,omitempty"`
+}
+
+// newAWSLambdaSNSSpanTags extracts SNS notification tags for an AWS Lambda entry span
+func newAWSLambdaSNSSpanTags(span *spanS) AWSLambdaSNSSpanTags {
+	var tags AWSLambdaSNSSpanTags
+
+	if v, ok := span.Tags["sns.topic"]; ok {
+		readStringTag(&tags.Topic, v)
+	}
+
+	if v, ok := span.Tags["sns.messageId"]; ok {
+		readStringTag(&tags.MessageID, v)
+	}
+
+	if v, ok := span.Tags["sns.subject"]; ok {
+		readStringTag(&tags.Subject, v)
+	}
+
+	return tags
+}
+
+// IsZero returns true if an AWSLambdaSNSSpanTags struct was populated with notification data
+func (tags AWSLambdaSNSSpanTags) IsZero() bool {
+	return tags.Topic == ""
+}
+
+// AWSLambdaEventBridgeSpanTags contains fields within the `data.lambda.eventbridge` section of an OT span document
+type AWSLambdaEventBridgeSpanTags struct {
+	// ID is the ID of the event
+	ID string `json:"id"`
+	// Source is the event source
+	Source string `json:"source,omitempty"`
+	// DetailType is the event detail type
+	DetailType string `json:"detailType,omitempty"`
+	// Resources contains the event resources
+	Resources []string `json:"resources,omitempty"`
+	// More is set to true if the event resources list was truncated
+	More bool `json:"more,omitempty"`
+}
+
+// newAWSLambdaEventBridgeSpanTags extracts EventBridge event tags for an AWS Lambda entry span. It truncates
+// the resources list to the first 3 items and limits each resource string to the first 200 characters to reduce
+// the payload.
+func newAWSLambdaEventBridgeSpanTags(span *spanS) AWSLambdaEventBridgeSpanTags {
+	var tags AWSLambdaEventBridgeSpanTags
+
+	if v, ok := span.Tags["eventbridge.id"]; ok {
+		readStringTag(&tags.ID, v)
+	}
+
+	if v, ok := span.Tags["eventbridge.source"]; ok {
+		readStringTag(&tags.Source, v)
+	}
+
+	if v, ok := span.Tags["eventbridge.detailType"]; ok {
+		readStringTag(&tags.DetailType, v)
+	}
+
+	if v, ok := span.Tags["eventbridge.resources"]; ok {
+		readArrayStringTag(&tags.Resources, v)
+	}
+
+	tags.Resources, tags.More = truncateLambdaTriggerTags(tags.Resources)
+
+	return tags
+}
+
+// IsZero returns true if an AWSLambdaEventBridgeSpanTags struct was populated with event data
+func (tags AWSLambdaEventBridgeSpanTags) IsZero() bool {
+	return tags.ID == ""
+}
+
+// AWSLambdaStepFunctionsSpanTags contains fields within the `data.lambda.stepfunctions` section of an OT span document
+type AWSLambdaStepFunctionsSpanTags struct {
+	// StateMachine is the ARN of the state machine
+	StateMachine string `json:"stateMachine"`
+	// Execution is the ARN of the state machine execution
+	Execution string `json:"execution,omitempty"`
+	// State is the name of the state that invoked the function
+	State string `json:"state,omitempty"`
+}
+
+// newAWSLambdaStepFunctionsSpanTags extracts Step Functions task tags for an AWS Lambda entry span
+func newAWSLambdaStepFunctionsSpanTags(span *spanS) AWSLambdaStepFunctionsSpanTags {
+	var tags AWSLambdaStepFunctionsSpanTags
+
+	if v, ok := span.Tags["stepfunctions.stateMachine"]; ok {
+		readStringTag(&tags.StateMachine, v)
+	}
+
+	if v, ok := span.Tags["stepfunctions.execution"]; ok {
+		readStringTag(&tags.Execution, v)
+	}
+
+	if v, ok := span.Tags["stepfunctions.state"]; ok {
+		readStringTag(&tags.State, v)
+	}
+
+	return tags
+}
+
+// IsZero returns true if an AWSLambdaStepFunctionsSpanTags struct was populated with state machine data
+func (tags AWSLambdaStepFunctionsSpanTags) IsZero() bool {
+	return tags.StateMachine == ""
+}
+
+// AWSLambdaKafkaSpanTags contains fields within the `data.lambda.kafka` section of an OT span document
+type AWSLambdaKafkaSpanTags struct {
+	// Source is either the ARN of an Amazon MSK cluster or the list of bootstrap servers of a self-managed cluster
+	Source string `json:"source"`
+	// Topics contains the names of the topics the records were consumed from
+	Topics []string `json:"topics,omitempty"`
+	// More is set to true if the topics list was truncated
+	More bool `json:"more,omitempty"`
+}
+
+// newAWSLambdaKafkaSpanTags extracts Kafka event tags for an AWS Lambda entry span. It truncates the topics list
+// to the first 3 items to reduce the payload.
+func newAWSLambdaKafkaSpanTags(span *spanS) AWSLambdaKafkaSpanTags {
+	var tags AWSLambdaKafkaSpanTags
+
+	if v, ok := span.Tags["kafka.source"]; ok {
+		readStringTag(&tags.Source, v)
+	}
+
+	if v, ok := span.Tags["kafka.topics"]; ok {
+		readArrayStringTag(&tags.Topics, v)
+	}
+
+	tags.Topics, tags.More = truncateLambdaTriggerTags(tags.Topics)
+
+	return tags
+}
+
+// IsZero returns true if an AWSLambdaKafkaSpanTags struct was populated with event data
+func (tags AWSLambdaKafkaSpanTags) IsZero() bool {
+	return tags.Source == ""
+}
+
+// AWSLambdaBatchSpanTags contains fields within the `data.lambda.batch` section of an OT span document
+type AWSLambdaBatchSpanTags struct {
+	// Records is the number of records in the batch
+	Records int `json:"records"`
+	// Failed is the number of records that failed to be processed
+	Failed int `json:"failed"`
+}
+
+// newAWSLambdaBatchSpanTags extracts the batch processing summary for an AWS Lambda entry span
+func newAWSLambdaBatchSpanTags(span *spanS) AWSLambdaBatchSpanTags {
+	var tags AWSLambdaBatchSpanTags
+
+	if v, ok := span.Tags["lambda.batch.records"]; ok {
+		readIntTag(&tags.Records, v)
+	}
+
+	if v, ok := span.Tags["lambda.batch.failed"]; ok {
+		readIntTag(&tags.Failed, v)
+	}
+
+	return tags
+}
+
+// IsZero returns true if an AWSLambdaBatchSpanTags struct was populated with batch data
+func (tags AWSLambdaBatchSpanTags) IsZero() bool {
+	return tags.Records == 0
+}
+
 // AWSLambdaSpanTags contains fields within the `data.lambda` section of an OT span document
 type AWSLambdaSpanTags struct {
 	// ARN is the ARN of invoked AWS Lambda function with the version attached
@@ -938,6 +1191,20 @@ type AWSLambdaSpanTags struct {
 	S3 *AWSLambdaS3SpanTags
 	// SQS holds the details of a SQS events associated with this lambda
 	SQS *AWSLambdaSQSSpanTags
+	// DynamoDB holds the details of a DynamoDB Streams event associated with this lambda
+	DynamoDB *AWSLambdaDynamoDBSpanTags `json:"dynamodb,omitempty"`
+	// Kinesis holds the details of a Kinesis Data Streams event associated with this lambda
+	Kinesis *AWSLambdaKinesisSpanTags `json:"kinesis,omitempty"`
+	// SNS holds the details of an SNS notification associated with this lambda
+	SNS *AWSLambdaSNSSpanTags `json:"sns,omitempty"`
+	// EventBridge holds the details of an EventBridge event associated with this lambda
+	EventBridge *AWSLambdaEventBridgeSpanTags `json:"eventbridge,omitempty"`
+	// StepFunctions holds the details of a Step Functions state machine execution that invoked this lambda
+	StepFunctions *AWSLambdaStepFunctionsSpanTags `json:"stepfunctions,omitempty"`
+	// Kafka holds the details of an Amazon MSK or a self-managed Kafka event associated with this lambda
+	Kafka *AWSLambdaKafkaSpanTags `json:"kafka,omitempty"`
+	// Batch holds the summary of an SQS or a Kinesis batch processed by this lambda
+	Batch *AWSLambdaBatchSpanTags `json:"batch,omitempty"`
 }
 
 // newAWSLambdaSpanTags extracts AWS Lambda entry span tags from a tracer span
@@ -984,6 +1251,34 @@ func newAWSLambdaSpanTags(span *spanS) AWSLambdaSpanTags {
 		tags.SQS = &sqs
 	}
 
+	if ddb := newAWSLambdaDynamoDBSpanTags(span); !ddb.IsZero() {
+		tags.DynamoDB = &ddb
+	}
+
+	if kinesis := newAWSLambdaKinesisSpanTags(span); !kinesis.IsZero() {
+		tags.Kinesis = &kinesis
+	}
+
+	if sns := newAWSLambdaSNSSpanTags(span); !sns.IsZero() {
+		tags.SNS = &sns
+	}
+
+	if eb := newAWSLambdaEventBridgeSpanTags(span); !eb.IsZero() {
+		tags.EventBridge = &eb
+	}
+
+	if sfn := newAWSLambdaStepFunctionsSpanTags(span); !sfn.IsZero() {
+		tags.StepFunctions = &sfn
+	}
+
+	if kafka := newAWSLambdaKafkaSpanTags(span); !kafka.IsZero() {
+		tags.Kafka = &kafka
+	}
+
+	if batch := newAWSLambdaBatchSpanTags(span); !batch.IsZero() {
+		tags.Batch = &batch
+	}
+
 	return tags
 }
 
@@ -1000,7 +1295,7 @@ func newAWSLambdaSpanData(span *spanS) AWSLambdaSpanData {
 	}
 
 	switch span.Tags["lambda.trigger"] {
-	case "aws:api.gateway", "aws:application.load.balancer":
+	case "aws:api.gateway", "aws:application.load.balancer", "aws:lambda.function.url":
 		tags := newHTTPSpanTags(span)
 		d.HTTP = &tags
 	}
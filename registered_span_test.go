@@ -290,7 +290,9 @@ func TestNewAWSLambdaSpanData(t *testing.T) {
 		},
 		"aws:sqs": {
 			Tags: opentracing.Tags{
-				"sqs.messages": []instana.AWSSQSMessageTags{{Queue: "q1"}, {Queue: "q2"}, {Queue: "q3"}, {Queue: "q4"}},
+				"sqs.messages":         []instana.AWSSQSMessageTags{{Queue: "q1"}, {Queue: "q2"}, {Queue: "q3"}, {Queue: "q4"}},
+				"lambda.batch.records": 4,
+				"lambda.batch.failed":  1,
 			},
 			Expected: instana.AWSLambdaSpanData{
 				Snapshot: instana.AWSLambdaSpanTags{
@@ -305,6 +307,152 @@ func TestNewAWSLambdaSpanData(t *testing.T) {
 					SQS: &instana.AWSLambdaSQSSpanTags{
 						Messages: []instana.AWSSQSMessageTags{{Queue: "q1"}, {Queue: "q2"}, {Queue: "q3"}},
 					},
+					Batch: &instana.AWSLambdaBatchSpanTags{
+						Records: 4,
+						Failed:  1,
+					},
+				},
+			},
+		},
+		"aws:dynamodb": {
+			Tags: opentracing.Tags{
+				"dynamodb.stream": "stream-arn-1",
+				"dynamodb.events": []string{"INSERT", "MODIFY", "REMOVE", "INSERT"},
+			},
+			Expected: instana.AWSLambdaSpanData{
+				Snapshot: instana.AWSLambdaSpanTags{
+					ARN:              "lambda-arn-1",
+					Runtime:          "go",
+					Name:             "test-lambda",
+					Version:          "42",
+					Trigger:          "aws:dynamodb",
+					ColdStart:        true,
+					MillisecondsLeft: 5,
+					Error:            "Not Found",
+					DynamoDB: &instana.AWSLambdaDynamoDBSpanTags{
+						Stream: "stream-arn-1",
+						Events: []string{"INSERT", "MODIFY", "REMOVE"},
+						More:   true,
+					},
+				},
+			},
+		},
+		"aws:kinesis": {
+			Tags: opentracing.Tags{
+				"kinesis.stream":  "stream-arn-1",
+				"kinesis.records": 2,
+			},
+			Expected: instana.AWSLambdaSpanData{
+				Snapshot: instana.AWSLambdaSpanTags{
+					ARN:              "lambda-arn-1",
+					Runtime:          "go",
+					Name:             "test-lambda",
+					Version:          "42",
+					Trigger:          "aws:kinesis",
+					ColdStart:        true,
+					MillisecondsLeft: 5,
+					Error:            "Not Found",
+					Kinesis: &instana.AWSLambdaKinesisSpanTags{
+						Stream:  "stream-arn-1",
+						Records: 2,
+					},
+				},
+			},
+		},
+		"aws:sns": {
+			Tags: opentracing.Tags{
+				"sns.topic":     "topic-arn-1",
+				"sns.messageId": "message-1",
+				"sns.subject":   "test",
+			},
+			Expected: instana.AWSLambdaSpanData{
+				Snapshot: instana.AWSLambdaSpanTags{
+					ARN:              "lambda-arn-1",
+					Runtime:          "go",
+					Name:             "test-lambda",
+					Version:          "42",
+					Trigger:          "aws:sns",
+					ColdStart:        true,
+					MillisecondsLeft: 5,
+					Error:            "Not Found",
+					SNS: &instana.AWSLambdaSNSSpanTags{
+						Topic:     "topic-arn-1",
+						MessageID: "message-1",
+						Subject:   "test",
+					},
+				},
+			},
+		},
+		"aws:eventbridge": {
+			Tags: opentracing.Tags{
+				"eventbridge.id":         "event-1",
+				"eventbridge.source":     "com.example",
+				"eventbridge.detailType": "order created",
+				"eventbridge.resources":  []string{"res1", strings.Repeat("long ", 40) + "res2", "res3", "res4"},
+			},
+			Expected: instana.AWSLambdaSpanData{
+				Snapshot: instana.AWSLambdaSpanTags{
+					ARN:              "lambda-arn-1",
+					Runtime:          "go",
+					Name:             "test-lambda",
+					Version:          "42",
+					Trigger:          "aws:eventbridge",
+					ColdStart:        true,
+					MillisecondsLeft: 5,
+					Error:            "Not Found",
+					EventBridge: &instana.AWSLambdaEventBridgeSpanTags{
+						ID:         "event-1",
+						Source:     "com.example",
+						DetailType: "order created",
+						Resources:  []string{"res1", strings.Repeat("long ", 40), "res3"},
+						More:       true,
+					},
+				},
+			},
+		},
+		"aws:stepfunctions": {
+			Tags: opentracing.Tags{
+				"stepfunctions.stateMachine": "state-machine-arn-1",
+				"stepfunctions.execution":    "execution-arn-1",
+				"stepfunctions.state":        "Process",
+			},
+			Expected: instana.AWSLambdaSpanData{
+				Snapshot: instana.AWSLambdaSpanTags{
+					ARN:              "lambda-arn-1",
+					Runtime:          "go",
+					Name:             "test-lambda",
+					Version:          "42",
+					Trigger:          "aws:stepfunctions",
+					ColdStart:        true,
+					MillisecondsLeft: 5,
+					Error:            "Not Found",
+					StepFunctions: &instana.AWSLambdaStepFunctionsSpanTags{
+						StateMachine: "state-machine-arn-1",
+						Execution:    "execution-arn-1",
+						State:        "Process",
+					},
+				},
+			},
+		},
+		"aws:kafka": {
+			Tags: opentracing.Tags{
+				"kafka.source": "cluster-arn-1",
+				"kafka.topics": []string{"topic1", "topic2"},
+			},
+			Expected: instana.AWSLambdaSpanData{
+				Snapshot: instana.AWSLambdaSpanTags{
+					ARN:              "lambda-arn-1",
+					Runtime:          "go",
+					Name:             "test-lambda",
+					Version:          "42",
+					Trigger:          "aws:kafka",
+					ColdStart:        true,
+					MillisecondsLeft: 5,
+					Error:            "Not Found",
+					Kafka: &instana.AWSLambdaKafkaSpanTags{
+						Source: "cluster-arn-1",
+						Topics: []string{"topic1", "topic2"},
+					},
 				},
 			},
 		},
@@ -0,0 +1,164 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	ot "github.com/opentracing/opentracing-go"
+)
+
+// SamplingParameters contains the information about a new trace that is available to a Sampler
+// when making the sampling decision
+type SamplingParameters struct {
+	// TraceIDHi is the higher 8 bytes of the trace ID
+	TraceIDHi int64
+	// TraceID is the lower 8 bytes of the trace ID
+	TraceID int64
+	// OperationName is the name of the root span operation, e.g. "g.http" or a custom SDK span name
+	OperationName string
+	// Tags are the tags provided to the tracer when starting the root span
+	Tags ot.Tags
+}
+
+// Sampler makes a head-based sampling decision for a new trace. The decision is made once for the root span
+// and then propagated to all child spans, as well as to the downstream services via the X-INSTANA-L header
+// and the W3C traceparent sampled flag. A trace that has not been sampled is marked as suppressed.
+type Sampler interface {
+	// ShouldSample returns true if the trace needs to be recorded
+	ShouldSample(p SamplingParameters) bool
+}
+
+// SamplerFunc is an adapter that allows to use an ordinary function as a Sampler
+type SamplerFunc func(p SamplingParameters) bool
+
+// ShouldSample calls f(p)
+func (f SamplerFunc) ShouldSample(p SamplingParameters) bool {
+	return f(p)
+}
+
+// AlwaysSample returns a sampler that records every trace
+func AlwaysSample() Sampler {
+	return SamplerFunc(func(SamplingParameters) bool { return true })
+}
+
+// NeverSample returns a sampler that drops every trace
+func NeverSample() Sampler {
+	return SamplerFunc(func(SamplingParameters) bool { return false })
+}
+
+type probabilisticSampler struct {
+	threshold uint64
+}
+
+// NewProbabilisticSampler returns a sampler that records a fraction of traces defined by rate, which is expected
+// to be a number between 0 and 1. The decision is made based on the trace ID, so that all services using the same
+// rate make the same decision for the same trace.
+func NewProbabilisticSampler(rate float64) Sampler {
+	switch {
+	case rate >= 1:
+		return AlwaysSample()
+	case rate <= 0 || math.IsNaN(rate):
+		return NeverSample()
+	}
+
+	return probabilisticSampler{
+		threshold: uint64(rate * (1 << 63)),
+	}
+}
+
+// ShouldSample implements instana.Sampler for probabilisticSampler
+func (s probabilisticSampler) ShouldSample(p SamplingParameters) bool {
+	// the lower 63 bits of an Instana trace ID are random
+	return uint64(p.TraceID)&math.MaxInt64 < s.threshold
+}
+
+type rateLimitingSampler struct {
+	mu         sync.Mutex
+	maxBalance float64
+	perSecond  float64
+	balance    float64
+	lastTick   time.Time
+	now        func() time.Time
+}
+
+// NewRateLimitingSampler returns a sampler that records at most tracesPerSecond new traces per second
+// using the token bucket algorithm. The traces exceeding this limit are dropped.
+func NewRateLimitingSampler(tracesPerSecond float64) Sampler {
+	if tracesPerSecond <= 0 || math.IsNaN(tracesPerSecond) {
+		return NeverSample()
+	}
+
+	return newRateLimitingSampler(tracesPerSecond, time.Now)
+}
+
+func newRateLimitingSampler(tracesPerSecond float64, now func() time.Time) *rateLimitingSampler {
+	maxBalance := math.Max(tracesPerSecond, 1)
+
+	return &rateLimitingSampler{
+		maxBalance: maxBalance,
+		perSecond:  tracesPerSecond,
+		balance:    maxBalance,
+		lastTick:   now(),
+		now:        now,
+	}
+}
+
+// ShouldSample implements instana.Sampler for rateLimitingSampler
+func (s *rateLimitingSampler) ShouldSample(SamplingParameters) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+
+	s.balance = math.Min(s.balance+now.Sub(s.lastTick).Seconds()*s.perSecond, s.maxBalance)
+	s.lastTick = now
+
+	if s.balance < 1 {
+		return false
+	}
+
+	s.balance--
+
+	return true
+}
+
+// SamplingRule associates a sampler with an operation name
+type SamplingRule struct {
+	// OperationName is the name of the root span operation this rule applies to
+	OperationName string
+	// Sampler is used to make the sampling decision for traces matching this rule
+	Sampler Sampler
+}
+
+type ruleBasedSampler struct {
+	rules    []SamplingRule
+	fallback Sampler
+}
+
+// NewRuleBasedSampler returns a sampler that delegates the sampling decision to the sampler of the first
+// rule matching the root span operation name. If none of the rules match, the fallback sampler is used.
+// The nil fallback sampler records all traces that do not match any rule.
+func NewRuleBasedSampler(rules []SamplingRule, fallback Sampler) Sampler {
+	if fallback == nil {
+		fallback = AlwaysSample()
+	}
+
+	return ruleBasedSampler{
+		rules:    rules,
+		fallback: fallback,
+	}
+}
+
+// ShouldSample implements instana.Sampler for ruleBasedSampler
+func (s ruleBasedSampler) ShouldSample(p SamplingParameters) bool {
+	for _, rule := range s.rules {
+		if rule.OperationName == p.OperationName && rule.Sampler != nil {
+			return rule.Sampler.ShouldSample(p)
+		}
+	}
+
+	return s.fallback.ShouldSample(p)
+}
@@ -0,0 +1,29 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitingSampler_ShouldSample(t *testing.T) {
+	now := time.Now()
+	s := newRateLimitingSampler(2, func() time.Time { return now })
+
+	assert.True(t, s.ShouldSample(SamplingParameters{}))
+	assert.True(t, s.ShouldSample(SamplingParameters{}))
+	assert.False(t, s.ShouldSample(SamplingParameters{}))
+
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, s.ShouldSample(SamplingParameters{}))
+	assert.False(t, s.ShouldSample(SamplingParameters{}))
+
+	// the balance is capped by the number of traces per second
+	now = now.Add(10 * time.Second)
+	assert.True(t, s.ShouldSample(SamplingParameters{}))
+	assert.True(t, s.ShouldSample(SamplingParameters{}))
+	assert.False(t, s.ShouldSample(SamplingParameters{}))
+}
@@ -0,0 +1,176 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana_test
+
+import (
+	"net/http"
+	"testing"
+
+	instana "github.com/instana/go-sensor"
+	ot "github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProbabilisticSampler(t *testing.T) {
+	examples := map[string]struct {
+		Rate     float64
+		TraceID  int64
+		Expected bool
+	}{
+		"rate 0": {
+			Rate:     0,
+			TraceID:  0,
+			Expected: false,
+		},
+		"rate 1": {
+			Rate:     1,
+			TraceID:  0x7fffffffffffffff,
+			Expected: true,
+		},
+		"below threshold": {
+			Rate:     0.5,
+			TraceID:  0x3fffffffffffffff,
+			Expected: true,
+		},
+		"above threshold": {
+			Rate:     0.5,
+			TraceID:  0x4000000000000001,
+			Expected: false,
+		},
+	}
+
+	for name, example := range examples {
+		t.Run(name, func(t *testing.T) {
+			s := instana.NewProbabilisticSampler(example.Rate)
+			assert.Equal(t, example.Expected, s.ShouldSample(instana.SamplingParameters{TraceID: example.TraceID}))
+		})
+	}
+}
+
+func TestNewProbabilisticSampler_Distribution(t *testing.T) {
+	s := instana.NewProbabilisticSampler(0.25)
+
+	var sampled int
+	for i := 0; i < 10000; i++ {
+		if s.ShouldSample(instana.SamplingParameters{TraceID: instana.NewRootSpanContext().TraceID}) {
+			sampled++
+		}
+	}
+
+	assert.InDelta(t, 2500, sampled, 300)
+}
+
+func TestNewRateLimitingSampler(t *testing.T) {
+	s := instana.NewRateLimitingSampler(2)
+
+	assert.True(t, s.ShouldSample(instana.SamplingParameters{}))
+	assert.True(t, s.ShouldSample(instana.SamplingParameters{}))
+	assert.False(t, s.ShouldSample(instana.SamplingParameters{}))
+}
+
+func TestNewRuleBasedSampler(t *testing.T) {
+	s := instana.NewRuleBasedSampler([]instana.SamplingRule{
+		{OperationName: "healthcheck", Sampler: instana.NeverSample()},
+		{OperationName: "g.http", Sampler: instana.AlwaysSample()},
+	}, instana.NeverSample())
+
+	assert.False(t, s.ShouldSample(instana.SamplingParameters{OperationName: "healthcheck"}))
+	assert.True(t, s.ShouldSample(instana.SamplingParameters{OperationName: "g.http"}))
+	assert.False(t, s.ShouldSample(instana.SamplingParameters{OperationName: "sdk"}))
+}
+
+func TestNewRuleBasedSampler_NilFallback(t *testing.T) {
+	s := instana.NewRuleBasedSampler(nil, nil)
+
+	assert.True(t, s.ShouldSample(instana.SamplingParameters{OperationName: "sdk"}))
+}
+
+func TestTracer_StartSpan_Sampler(t *testing.T) {
+	recorder := instana.NewTestRecorder()
+	tracer := instana.NewTracerWithEverything(&instana.Options{
+		AgentClient: alwaysReadyClient{},
+		Tracer: instana.TracerOptions{
+			Sampler: instana.NewRuleBasedSampler([]instana.SamplingRule{
+				{OperationName: "dropped", Sampler: instana.NeverSample()},
+			}, nil),
+		},
+	}, recorder)
+	defer instana.ShutdownSensor()
+
+	t.Run("sampled", func(t *testing.T) {
+		sp := tracer.StartSpan("recorded")
+		child := tracer.StartSpan("child", ot.ChildOf(sp.Context()))
+
+		sc := child.Context().(instana.SpanContext)
+		assert.True(t, sc.Sampled)
+		assert.False(t, sc.Suppressed)
+
+		child.Finish()
+		sp.Finish()
+
+		assert.Len(t, recorder.GetQueuedSpans(), 2)
+	})
+
+	t.Run("not sampled", func(t *testing.T) {
+		sp := tracer.StartSpan("dropped")
+		child := tracer.StartSpan("child", ot.ChildOf(sp.Context()))
+
+		sc := child.Context().(instana.SpanContext)
+		assert.False(t, sc.Sampled)
+		assert.True(t, sc.Suppressed)
+
+		h := http.Header{}
+		require.NoError(t, tracer.Inject(child.Context(), ot.HTTPHeaders, ot.HTTPHeadersCarrier(h)))
+
+		assert.Equal(t, "0", h.Get(instana.FieldL))
+		assert.Empty(t, h.Get(instana.FieldT))
+		assert.Regexp(t, "-00$", h.Get("Traceparent"))
+
+		child.Finish()
+		sp.Finish()
+
+		assert.Empty(t, recorder.GetQueuedSpans())
+	})
+
+	t.Run("upstream decision", func(t *testing.T) {
+		sp := tracer.StartSpan("dropped", ot.ChildOf(instana.SpanContext{
+			TraceID: 0x1,
+			SpanID:  0x2,
+		}))
+
+		sc := sp.Context().(instana.SpanContext)
+		assert.False(t, sc.Suppressed)
+
+		sp.Finish()
+
+		assert.Len(t, recorder.GetQueuedSpans(), 1)
+	})
+}
+
+func TestTracer_StartSpan_NoSampler(t *testing.T) {
+	recorder := instana.NewTestRecorder()
+	tracer := instana.NewTracerWithEverything(&instana.Options{
+		AgentClient: alwaysReadyClient{},
+	}, recorder)
+	defer instana.ShutdownSensor()
+
+	sp := tracer.StartSpan("recorded")
+	child := tracer.StartSpan("child", ot.ChildOf(sp.Context()))
+
+	// all new traces are sampled if there is no sampler configured
+	for _, span := range []ot.Span{sp, child} {
+		sc := span.Context().(instana.SpanContext)
+		assert.True(t, sc.Sampled)
+		assert.False(t, sc.Suppressed)
+	}
+
+	child.Finish()
+	sp.Finish()
+
+	assert.Len(t, recorder.GetQueuedSpans(), 2)
+
+	// the suppressed traces are not sampled
+	suppressed := tracer.StartSpan("suppressed", instana.SuppressTracing())
+	assert.False(t, suppressed.Context().(instana.SpanContext).Sampled)
+}
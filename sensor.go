@@ -53,9 +53,17 @@ type sensorS struct {
 	options     *Options
 	serviceName string
 	binaryName  string
+	spool       *spanSpool
+	metrics     *collectorMetrics
 
 	mu    sync.RWMutex
 	agent AgentClient
+
+	// muTracerOptions guards options.Tracer that is updated with the configuration received from the agent
+	muTracerOptions sync.RWMutex
+	// configuredTracerOptions is a copy of tracer options provided in code and via env variables before
+	// any agent configuration has been applied
+	configuredTracerOptions *TracerOptions
 }
 
 var (
@@ -77,6 +85,7 @@ func newSensor(options *Options) *sensorS {
 		options:     options,
 		serviceName: options.Service,
 		binaryName:  binaryName,
+		metrics:     &collectorMetrics{},
 	}
 
 	s.setLogger(defaultLogger)
@@ -96,12 +105,30 @@ func newSensor(options *Options) *sensorS {
 		}
 	}
 
+	if options.SpoolDir != "" {
+		sp, err := newSpanSpool(options.SpoolDir, options.SpoolMaxSize, s.logger)
+		if err != nil {
+			s.logger.Warn("failed to initialize span spool in ", options.SpoolDir, ", spooling is disabled: ", err)
+		}
+
+		s.spool = sp
+	}
+
 	var agent AgentClient
 
 	if options.AgentClient != nil {
 		agent = options.AgentClient
 	}
 
+	if exporter := os.Getenv("INSTANA_EXPORTER"); exporter != "" && agent == nil {
+		if strings.ToLower(exporter) == "otlp" {
+			s.logger.Debug("INSTANA_EXPORTER=otlp is set, sending spans to the OpenTelemetry collector")
+			agent = newOTLPExporterFromEnv(s.serviceOrBinaryName(), s.logger)
+		} else {
+			s.logger.Warn("unsupported INSTANA_EXPORTER value ", exporter, ", falling back to the default agent client")
+		}
+	}
+
 	if agentEndpoint := os.Getenv("INSTANA_ENDPOINT_URL"); agentEndpoint != "" && agent == nil {
 		s.logger.Debug("INSTANA_ENDPOINT_URL= is set, switching to the serverless mode")
 
@@ -121,11 +148,11 @@ func newSensor(options *Options) *sensorS {
 			}
 		}
 
-		agent = newServerlessAgent(s.serviceOrBinaryName(), agentEndpoint, os.Getenv("INSTANA_AGENT_KEY"), client, s.logger)
+		agent = newServerlessAgent(s.serviceOrBinaryName(), agentEndpoint, os.Getenv("INSTANA_AGENT_KEY"), client, s.spool, s.options.GzipAgentRequests, s.logger)
 	}
 
 	if agent == nil {
-		agent = newAgent(s.serviceOrBinaryName(), s.options.AgentHost, s.options.AgentPort, s.logger)
+		agent = newAgent(s)
 	}
 
 	s.setAgent(agent)
@@ -166,6 +193,56 @@ func (r *sensorS) Agent() AgentClient {
 	return r.agent
 }
 
+// tracerOptions returns the tracer options currently used by the sensor
+func (r *sensorS) tracerOptions() TracerOptions {
+	if r == nil || r.options == nil {
+		return DefaultTracerOptions()
+	}
+
+	r.muTracerOptions.RLock()
+	defer r.muTracerOptions.RUnlock()
+
+	return r.options.Tracer
+}
+
+// updateTracerOptions atomically replaces the tracer options used by the sensor with the value returned by update.
+// The update function is called with the tracer options originally provided in code and via env variables.
+func (r *sensorS) updateTracerOptions(update func(configured TracerOptions) TracerOptions) {
+	if r == nil || r.options == nil {
+		return
+	}
+
+	r.muTracerOptions.Lock()
+	defer r.muTracerOptions.Unlock()
+
+	if r.configuredTracerOptions == nil {
+		configured := r.options.Tracer
+		r.configuredTracerOptions = &configured
+	}
+
+	r.options.Tracer = update(*r.configuredTracerOptions)
+}
+
+// Spool returns the span spool used by the global sensor. It returns nil if the spooling is disabled
+// or the global sensor is not initialized
+func (r *sensorS) Spool() *spanSpool {
+	if r == nil {
+		return nil
+	}
+
+	return r.spool
+}
+
+// Metrics returns the internal collector metrics of the global sensor. It returns nil if the global sensor
+// is not initialized
+func (r *sensorS) Metrics() *collectorMetrics {
+	if r == nil {
+		return nil
+	}
+
+	return r.metrics
+}
+
 func (r *sensorS) serviceOrBinaryName() string {
 	if r == nil {
 		return ""
@@ -253,19 +330,76 @@ func Flush(ctx context.Context) error {
 
 // ShutdownSensor cleans up the internal global sensor reference. The next time that instana.InitSensor is called,
 // directly or indirectly, the internal sensor will be reinitialized.
+//
+// If the tail-based sampling recorder has been provided via (instana.Options).Recorder, it is closed, making the sampling
+// decision for all pending traces before the sensor is shut down. The instana.Recorder instances used by the tracers created
+// with this sensor are closed, which stops their periodic export and flushes the queued spans. The host agent configuration
+// and actions are not polled anymore once the sensor is shut down, and the pending OTLP export retries are interrupted.
 func ShutdownSensor() {
 	muSensor.Lock()
-	if sensor != nil {
-		sensor = nil
+	s := sensor
+	muSensor.Unlock()
+
+	if s == nil {
+		return
+	}
+
+	// the recorder sends spans via the global sensor, so it's closed before the sensor is reset
+	// without holding the lock
+	if r, ok := s.options.Recorder.(*TailSamplingRecorder); ok {
+		if err := r.Close(); err != nil {
+			s.logger.Warn("failed to flush pending traces: ", err)
+		}
+	}
+
+	for _, r := range sensorRecorders(s) {
+		if err := r.Close(); err != nil {
+			s.logger.Warn("failed to flush queued spans: ", err)
+		}
+	}
+
+	switch agent := s.Agent().(type) {
+	case *agentS:
+		// stop polling the agent, so that its configuration is not applied to the sensor anymore
+		agent.close()
+	case *OTLPExporter:
+		// interrupt the pending export retries
+		agent.Close()
 	}
+
+	muSensor.Lock()
+	sensor = nil
 	muSensor.Unlock()
 }
 
-func newServerlessAgent(serviceName, agentEndpoint, agentKey string, client *http.Client, logger LeveledLogger) AgentClient {
+// sensorRecorders returns the span recorders used by the tracers created with the sensor, including the one
+// provided via (instana.Options).Recorder
+func sensorRecorders(s *sensorS) []*Recorder {
+	recorders := s.Metrics().Recorders()
+
+	rec := s.options.Recorder
+	if r, ok := rec.(*TailSamplingRecorder); ok {
+		rec = r.next
+	}
+
+	if r, ok := rec.(*Recorder); ok && r != nil {
+		for _, known := range recorders {
+			if known == r {
+				return recorders
+			}
+		}
+
+		recorders = append(recorders, r)
+	}
+
+	return recorders
+}
+
+func newServerlessAgent(serviceName, agentEndpoint, agentKey string, client *http.Client, sp *spanSpool, gzip bool, logger LeveledLogger) AgentClient {
 	switch {
 	case os.Getenv("AWS_EXECUTION_ENV") == "AWS_ECS_FARGATE" && os.Getenv("ECS_CONTAINER_METADATA_URI") != "":
 		// AWS Fargate
-		return newFargateAgent(
+		agent := newFargateAgent(
 			serviceName,
 			agentEndpoint,
 			agentKey,
@@ -273,14 +407,32 @@ func newServerlessAgent(serviceName, agentEndpoint, agentKey string, client *htt
 			aws.NewECSMetadataProvider(os.Getenv("ECS_CONTAINER_METADATA_URI"), client),
 			logger,
 		)
+		agent.transport.spool, agent.transport.gzip = sp, gzip
+
+		return agent
 	case strings.HasPrefix(os.Getenv("AWS_EXECUTION_ENV"), "AWS_Lambda_"):
 		// AWS Lambda
-		return newLambdaAgent(serviceName, agentEndpoint, agentKey, client, logger)
+		agent := newLambdaAgent(serviceName, agentEndpoint, agentKey, client, logger)
+		agent.transport.spool, agent.transport.gzip = sp, gzip
+
+		return agent
 	case os.Getenv("K_SERVICE") != "" && os.Getenv("K_CONFIGURATION") != "" && os.Getenv("K_REVISION") != "":
 		// Knative, e.g. Google Cloud Run
-		return newGCRAgent(serviceName, agentEndpoint, agentKey, client, logger)
+		agent := newGCRAgent(serviceName, agentEndpoint, agentKey, client, logger)
+		agent.transport.spool, agent.transport.gzip = sp, gzip
+
+		return agent
 	case os.Getenv("FUNCTIONS_WORKER_RUNTIME") == azureCustomRuntime:
-		return newAzureAgent(agentEndpoint, agentKey, client, logger)
+		agent := newAzureAgent(agentEndpoint, agentKey, client, logger)
+		agent.transport.spool, agent.transport.gzip = sp, gzip
+
+		return agent
+	case os.Getenv("KUBERNETES_SERVICE_HOST") != "":
+		// Kubernetes pod without a host agent
+		agent := newKubernetesAgent(serviceName, agentEndpoint, agentKey, client, logger)
+		agent.transport.spool, agent.transport.gzip = sp, gzip
+
+		return agent
 	default:
 		return nil
 	}
@@ -52,7 +52,7 @@ func newProcessPluginPayload(snapshot serverlessSnapshot, prevStats, currentStat
 			continue
 		}
 
-		if sensor.options.Tracer.Secrets.Match(k) {
+		if sensor.tracerOptions().Secrets.Match(k) {
 			env[k] = "<redacted>"
 		}
 	}
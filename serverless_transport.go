@@ -0,0 +1,408 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/instana/go-sensor/acceptor"
+)
+
+const (
+	// serverlessMaxBufferedSpans is the maximum number of spans buffered by the serverless transport between
+	// two consecutive sends. Once this limit is reached, the oldest spans are dropped.
+	serverlessMaxBufferedSpans = 5 * DefaultMaxBufferedSpans
+	// serverlessMaxRetries is the number of attempts to resend a request after a timeout or a 5xx response
+	serverlessMaxRetries = 2
+	// serverlessRetryDelay is the delay before the first retry, which is doubled for each subsequent one
+	serverlessRetryDelay = 100 * time.Millisecond
+)
+
+// serverlessTransport delivers spans and metrics to the Instana serverless acceptor. It is shared by all serverless
+// agents, which only provide the snapshot data, i.e. the X-Instana-Host value, the span source and the plugin payloads.
+//
+// The spans are buffered until the next bundle or traces request. Requests that time out or are responded with 5xx
+// are retried with an exponential backoff. Spans that could not be delivered, unless rejected by the acceptor with
+// 4xx, are stored in the spool, if enabled, or put back into the buffer to be sent with the next request.
+type serverlessTransport struct {
+	Endpoint string
+	Key      string
+
+	client *http.Client
+	// timeout limits the duration of each request attempt, including the response body transfer
+	timeout time.Duration
+	// gzip enables the gzip compression of request bodies
+	gzip bool
+
+	maxRetries       int
+	retryDelay       time.Duration
+	maxPayloadSize   int
+	maxBufferedSpans int
+
+	mu        sync.Mutex
+	spanQueue []Span
+	spool     *spanSpool
+
+	logger LeveledLogger
+}
+
+func newServerlessTransport(acceptorEndpoint, agentKey string, client *http.Client, logger LeveledLogger) *serverlessTransport {
+	if logger == nil {
+		logger = defaultLogger
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	timeout, err := parseInstanaTimeout(os.Getenv("INSTANA_TIMEOUT"))
+	if err != nil {
+		logger.Warn("malformed INSTANA_TIMEOUT value, falling back to the default one: ", err)
+		timeout = defaultServerlessTimeout
+	}
+
+	return &serverlessTransport{
+		Endpoint:         acceptorEndpoint,
+		Key:              agentKey,
+		client:           client,
+		timeout:          timeout,
+		maxRetries:       serverlessMaxRetries,
+		retryDelay:       serverlessRetryDelay,
+		maxPayloadSize:   maxContentLength,
+		maxBufferedSpans: serverlessMaxBufferedSpans,
+		logger:           logger,
+	}
+}
+
+// Enqueue buffers spans to be sent with the next request. If the buffer is full, the oldest spans are dropped.
+func (t *serverlessTransport) Enqueue(spans []Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.spanQueue = append(t.spanQueue, spans...)
+
+	if n := len(t.spanQueue) - t.maxBufferedSpans; n > 0 {
+		t.logger.Warn("serverless span buffer is full, dropping ", n, " oldest span(s)")
+		t.spanQueue = append(t.spanQueue[:0], t.spanQueue[n:]...)
+	}
+}
+
+// QueueLen returns the number of buffered spans
+func (t *serverlessTransport) QueueLen() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.spanQueue)
+}
+
+// QueuedSpans returns a copy of the buffered spans
+func (t *serverlessTransport) QueuedSpans() []Span {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	spans := make([]Span, len(t.spanQueue))
+	copy(spans, t.spanQueue)
+
+	return spans
+}
+
+func (t *serverlessTransport) dequeue() []Span {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.spanQueue) == 0 {
+		return nil
+	}
+
+	spans := make([]Span, len(t.spanQueue))
+	copy(spans, t.spanQueue)
+	t.spanQueue = t.spanQueue[:0]
+
+	return spans
+}
+
+// SendBundle sends the plugin payloads along with the buffered spans to the /bundle endpoint. The spans that
+// do not fit into the bundle payload are sent to the /traces endpoint. The span source is set to from, unless
+// it's nil. Once the bundle has been delivered, the oldest batch of spooled spans is buffered to be sent next.
+func (t *serverlessTransport) SendBundle(ctx context.Context, host string, from *fromS, plugins []acceptor.PluginPayload) error {
+	spans := t.dequeue()
+	setSpansFrom(spans, from)
+
+	metrics, err := json.Marshal(metricsPayload{Plugins: plugins})
+	if err != nil {
+		t.keep(spans)
+		return fmt.Errorf("failed to marshal metrics payload: %s", err)
+	}
+
+	batches := t.splitBatches(spans)
+
+	// the first batch of spans is sent within the bundle if it fits along with the metrics
+	var bundleSpans []json.RawMessage
+	if len(batches) > 0 && len(metrics)+batchSize(batches[0])+len(`{"metrics":,"spans":}`) <= t.maxPayloadSize {
+		bundleSpans, batches = batches[0], batches[1:]
+	}
+
+	payload, err := json.Marshal(struct {
+		Metrics json.RawMessage   `json:"metrics"`
+		Spans   []json.RawMessage `json:"spans,omitempty"`
+	}{metrics, bundleSpans})
+	if err != nil {
+		t.keep(spans)
+		return fmt.Errorf("failed to marshal bundle payload: %s", err)
+	}
+
+	if err := t.post(ctx, "/bundle", host, payload); err != nil {
+		if !isRejectedServerlessPayload(err) {
+			t.keep(spans)
+		}
+
+		return err
+	}
+
+	if err := t.sendBatches(ctx, host, batches); err != nil {
+		return err
+	}
+
+	// enqueue the oldest batch of spooled spans to be sent along with the next bundle
+	if err := t.spool.ReplayNext(func(spans []Span) error {
+		t.Enqueue(spans)
+		return nil
+	}); err != nil {
+		t.logger.Warn("failed to replay spooled spans: ", err)
+	}
+
+	return nil
+}
+
+// SendSpans sends the buffered spans to the /traces endpoint, splitting them into batches if the payload
+// exceeds the size limit. The span source is set to from, unless it's nil.
+func (t *serverlessTransport) SendSpans(ctx context.Context, host string, from *fromS) error {
+	spans := t.dequeue()
+	if len(spans) == 0 {
+		return nil
+	}
+
+	setSpansFrom(spans, from)
+
+	return t.sendBatches(ctx, host, t.splitBatches(spans))
+}
+
+// sendBatches sends span batches to the /traces endpoint. If a batch could not be delivered, it's kept along
+// with the remaining ones to be sent later, unless it has been rejected by the acceptor.
+func (t *serverlessTransport) sendBatches(ctx context.Context, host string, batches [][]json.RawMessage) error {
+	for i, batch := range batches {
+		payload, err := json.Marshal(batch)
+		if err != nil {
+			return fmt.Errorf("failed to marshal traces payload: %s", err)
+		}
+
+		if err := t.post(ctx, "/traces", host, payload); err != nil {
+			if !isRejectedServerlessPayload(err) {
+				var undelivered []json.RawMessage
+				for _, batch := range batches[i:] {
+					undelivered = append(undelivered, batch...)
+				}
+
+				t.keep(rawSpans(undelivered))
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// splitBatches groups spans into batches that fit into the payload size limit dropping the oversized ones
+func (t *serverlessTransport) splitBatches(spans []Span) [][]json.RawMessage {
+	batches, _, oversized := splitSpanBatches(spans, t.maxPayloadSize)
+	if len(oversized) > 0 {
+		sensor.Metrics().SpansOversized(len(oversized))
+		t.logger.Warn("dropping ", len(oversized), " span(s) exceeding the serverless acceptor payload size limit of ", t.maxPayloadSize, " bytes")
+	}
+
+	return batches
+}
+
+// keep stores undelivered spans in the spool if it's enabled, otherwise they are buffered again to be sent
+// with the next request
+func (t *serverlessTransport) keep(spans []Span) {
+	if len(spans) == 0 || t.spool.Store(spans) {
+		return
+	}
+
+	t.mu.Lock()
+	queued := t.spanQueue
+	t.spanQueue = append(make([]Span, 0, len(spans)+len(queued)), spans...)
+	t.mu.Unlock()
+
+	t.Enqueue(queued)
+}
+
+// post sends the payload to the serverless acceptor retrying on timeouts and 5xx responses
+func (t *serverlessTransport) post(ctx context.Context, path, host string, payload []byte) error {
+	contentEncoding := ""
+	if t.gzip {
+		compressed, err := gzipPayload(payload)
+		if err != nil {
+			return err
+		}
+
+		payload, contentEncoding = compressed, "gzip"
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := t.send(ctx, path, host, contentEncoding, payload)
+		if err == nil || !isRetryableServerlessError(err) || attempt >= t.maxRetries {
+			return err
+		}
+
+		delay := time.Duration(math.Pow(2, float64(attempt))) * t.retryDelay
+		t.logger.Debug(err, ", retrying in ", delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// send makes a single request to the serverless acceptor
+func (t *serverlessTransport) send(ctx context.Context, path, host, contentEncoding string, payload []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodPost, t.Endpoint+path, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to prepare %s request: %s", path, err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if contentEncoding != "" {
+		req.Header.Set("Content-Encoding", contentEncoding)
+	}
+
+	req.Header.Set("X-Instana-Host", host)
+	req.Header.Set("X-Instana-Key", t.Key)
+	req.Header.Set("X-Instana-Time", strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10))
+
+	resp, err := t.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return serverlessSendError{
+			err:     fmt.Errorf("failed to send request to the serverless agent: %s", err),
+			timeout: isTimeoutError(err),
+		}
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		t.logger.Info("serverless agent has responded with ", resp.Status, ": ", string(respBody))
+
+		return serverlessSendError{
+			err:        fmt.Errorf("serverless agent has responded with %s", resp.Status),
+			statusCode: resp.StatusCode,
+		}
+	}
+
+	io.CopyN(ioutil.Discard, resp.Body, 1<<20)
+
+	return nil
+}
+
+// serverlessSendError is returned by the serverless transport if a request has failed
+type serverlessSendError struct {
+	err        error
+	timeout    bool
+	statusCode int
+}
+
+func (e serverlessSendError) Error() string { return e.err.Error() }
+
+// isRetryableServerlessError returns whether the request that failed with given error should be retried
+// immediately, i.e. it has timed out or the serverless acceptor has responded with 5xx
+func isRetryableServerlessError(err error) bool {
+	e, ok := err.(serverlessSendError)
+
+	return ok && (e.timeout || e.statusCode >= http.StatusInternalServerError)
+}
+
+// isRejectedServerlessPayload returns whether the serverless acceptor has rejected the payload with 4xx, in
+// which case there is no point in sending it again
+func isRejectedServerlessPayload(err error) bool {
+	e, ok := err.(serverlessSendError)
+
+	return ok && e.statusCode >= http.StatusBadRequest && e.statusCode < http.StatusInternalServerError
+}
+
+func isTimeoutError(err error) bool {
+	if err == context.DeadlineExceeded {
+		return true
+	}
+
+	e, ok := err.(net.Error)
+
+	return ok && e.Timeout()
+}
+
+func gzipPayload(payload []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+
+	zw := gzip.NewWriter(buf)
+	if _, err := zw.Write(payload); err != nil {
+		return nil, fmt.Errorf("failed to compress payload: %s", err)
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress payload: %s", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func setSpansFrom(spans []Span, from *fromS) {
+	if from == nil {
+		return
+	}
+
+	for i := range spans {
+		spans[i].From = from
+	}
+}
+
+// batchSize returns the size of a span batch encoded as a JSON array
+func batchSize(batch []json.RawMessage) int {
+	size := 1
+	for _, doc := range batch {
+		size += len(doc) + 1
+	}
+
+	return size
+}
+
+// rawSpans wraps encoded spans to be buffered or stored in the spool as is
+func rawSpans(batch []json.RawMessage) []Span {
+	spans := make([]Span, len(batch))
+	for i, doc := range batch {
+		spans[i].raw = doc
+	}
+
+	return spans
+}
@@ -0,0 +1,273 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/instana/go-sensor/acceptor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerlessTransport_SendBundle(t *testing.T) {
+	acc := newRecordingServerlessAcceptor()
+	defer acc.Close()
+
+	tr := newServerlessTransport(acc.URL, "testkey", nil, defaultLogger)
+	tr.Enqueue([]Span{
+		newTestLambdaSpan("arn:aws:lambda:us-east-2:123456789012:function:test:1"),
+		newTestLambdaSpan("arn:aws:lambda:us-east-2:123456789012:function:test:1"),
+	})
+
+	require.NoError(t, tr.SendBundle(
+		context.Background(),
+		"test-host",
+		newServerlessAgentFromS("test-entity", "aws"),
+		[]acceptor.PluginPayload{acceptor.NewAWSLambdaPluginPayload("test-entity")},
+	))
+
+	reqs := acc.Requests()
+	require.Len(t, reqs, 1)
+
+	req := reqs[0]
+	assert.Equal(t, "/bundle", req.Path)
+	assert.Equal(t, "test-host", req.Header.Get("X-Instana-Host"))
+	assert.Equal(t, "testkey", req.Header.Get("X-Instana-Key"))
+	assert.NotEmpty(t, req.Header.Get("X-Instana-Time"))
+	assert.Empty(t, req.Header.Get("Content-Encoding"))
+
+	var payload struct {
+		Metrics struct {
+			Plugins []struct {
+				EntityID string `json:"entityId"`
+			} `json:"plugins"`
+		} `json:"metrics"`
+		Spans []struct {
+			From *fromS `json:"f"`
+		} `json:"spans"`
+	}
+	require.NoError(t, json.Unmarshal(req.Body, &payload))
+
+	require.Len(t, payload.Metrics.Plugins, 1)
+	assert.Equal(t, "test-entity", payload.Metrics.Plugins[0].EntityID)
+
+	require.Len(t, payload.Spans, 2)
+	for _, sp := range payload.Spans {
+		assert.Equal(t, &fromS{EntityID: "test-entity", Hostless: true, CloudProvider: "aws"}, sp.From)
+	}
+
+	assert.Equal(t, 0, tr.QueueLen())
+}
+
+func TestServerlessTransport_SendBundle_SplitPayload(t *testing.T) {
+	acc := newRecordingServerlessAcceptor()
+	defer acc.Close()
+
+	sp := newTestLambdaSpan("arn:aws:lambda:us-east-2:123456789012:function:test:1")
+	data, err := json.Marshal(sp)
+	require.NoError(t, err)
+
+	tr := newServerlessTransport(acc.URL, "testkey", nil, defaultLogger)
+	// each batch fits 2 spans
+	tr.maxPayloadSize = 2*(len(data)+1) + 1
+	tr.Enqueue([]Span{sp, sp, sp, sp, sp})
+
+	require.NoError(t, tr.SendBundle(context.Background(), "test-host", nil, nil))
+
+	var numSpans int
+	for _, req := range acc.Requests() {
+		var spans []json.RawMessage
+
+		switch req.Path {
+		case "/bundle":
+			var payload struct {
+				Spans []json.RawMessage `json:"spans"`
+			}
+			require.NoError(t, json.Unmarshal(req.Body, &payload))
+
+			spans = payload.Spans
+		case "/traces":
+			require.NoError(t, json.Unmarshal(req.Body, &spans))
+		}
+
+		assert.True(t, len(spans) <= 2, "%s request contains %d spans", req.Path, len(spans))
+		numSpans += len(spans)
+	}
+
+	assert.Equal(t, 5, numSpans)
+}
+
+func TestServerlessTransport_SendSpans_Gzip(t *testing.T) {
+	acc := newRecordingServerlessAcceptor()
+	defer acc.Close()
+
+	tr := newServerlessTransport(acc.URL, "testkey", nil, defaultLogger)
+	tr.gzip = true
+	tr.Enqueue([]Span{newTestLambdaSpan("arn:aws:lambda:us-east-2:123456789012:function:test:1")})
+
+	require.NoError(t, tr.SendSpans(context.Background(), "test-host", nil))
+
+	reqs := acc.Requests()
+	require.Len(t, reqs, 1)
+
+	assert.Equal(t, "/traces", reqs[0].Path)
+	assert.Equal(t, "gzip", reqs[0].Header.Get("Content-Encoding"))
+
+	var spans []json.RawMessage
+	require.NoError(t, json.Unmarshal(reqs[0].Body, &spans))
+	assert.Len(t, spans, 1)
+}
+
+func TestServerlessTransport_Retry(t *testing.T) {
+	examples := map[string]func(w http.ResponseWriter){
+		"server error": func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		},
+		"timeout": func(w http.ResponseWriter) {
+			time.Sleep(200 * time.Millisecond)
+		},
+	}
+
+	for name, fail := range examples {
+		t.Run(name, func(t *testing.T) {
+			acc := newRecordingServerlessAcceptor()
+			defer acc.Close()
+
+			// fail the first attempt
+			acc.fail = fail
+			acc.numFailures = 1
+
+			tr := newServerlessTransport(acc.URL, "testkey", nil, defaultLogger)
+			tr.timeout = 100 * time.Millisecond
+			tr.retryDelay = time.Millisecond
+			tr.Enqueue([]Span{newTestLambdaSpan("arn:aws:lambda:us-east-2:123456789012:function:test:1")})
+
+			require.NoError(t, tr.SendSpans(context.Background(), "test-host", nil))
+
+			reqs := acc.Requests()
+			require.Len(t, reqs, 2)
+			assert.Equal(t, reqs[0].Body, reqs[1].Body)
+		})
+	}
+}
+
+func TestServerlessTransport_Retry_LimitExceeded(t *testing.T) {
+	acc := newRecordingServerlessAcceptor()
+	defer acc.Close()
+
+	acc.fail = func(w http.ResponseWriter) { w.WriteHeader(http.StatusInternalServerError) }
+	acc.numFailures = -1
+
+	tr := newServerlessTransport(acc.URL, "testkey", nil, defaultLogger)
+	tr.retryDelay = time.Millisecond
+	tr.Enqueue([]Span{newTestLambdaSpan("arn:aws:lambda:us-east-2:123456789012:function:test:1")})
+
+	require.Error(t, tr.SendBundle(context.Background(), "test-host", nil, nil))
+	assert.Len(t, acc.Requests(), 1+tr.maxRetries)
+
+	// undelivered spans are kept to be sent with the next request
+	assert.Equal(t, 1, tr.QueueLen())
+}
+
+func TestServerlessTransport_Rejected(t *testing.T) {
+	acc := newRecordingServerlessAcceptor()
+	defer acc.Close()
+
+	acc.fail = func(w http.ResponseWriter) { w.WriteHeader(http.StatusBadRequest) }
+	acc.numFailures = -1
+
+	tr := newServerlessTransport(acc.URL, "testkey", nil, defaultLogger)
+	tr.retryDelay = time.Millisecond
+	tr.Enqueue([]Span{newTestLambdaSpan("arn:aws:lambda:us-east-2:123456789012:function:test:1")})
+
+	require.Error(t, tr.SendSpans(context.Background(), "test-host", nil))
+
+	// rejected payloads are neither retried, nor kept to be sent later
+	assert.Len(t, acc.Requests(), 1)
+	assert.Equal(t, 0, tr.QueueLen())
+}
+
+func TestServerlessTransport_Enqueue_BufferFull(t *testing.T) {
+	tr := newServerlessTransport("http://localhost", "testkey", nil, defaultLogger)
+	tr.maxBufferedSpans = 2
+
+	tr.Enqueue([]Span{{Name: "span-1"}, {Name: "span-2"}})
+	tr.Enqueue([]Span{{Name: "span-3"}})
+
+	spans := tr.QueuedSpans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "span-2", spans[0].Name)
+	assert.Equal(t, "span-3", spans[1].Name)
+}
+
+type recordedServerlessRequest struct {
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// recordingServerlessAcceptor is a fake of the serverless acceptor that records received requests. The first
+// numFailures requests, or all of them if numFailures is negative, are responded using the fail function.
+type recordingServerlessAcceptor struct {
+	*httptest.Server
+
+	fail        func(w http.ResponseWriter)
+	numFailures int
+
+	mu   sync.Mutex
+	reqs []recordedServerlessRequest
+}
+
+func newRecordingServerlessAcceptor() *recordingServerlessAcceptor {
+	acc := &recordingServerlessAcceptor{}
+
+	acc.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			body = zr
+		}
+
+		data, err := ioutil.ReadAll(body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		acc.mu.Lock()
+		acc.reqs = append(acc.reqs, recordedServerlessRequest{r.URL.Path, r.Header, data})
+		fail := acc.fail != nil && (acc.numFailures < 0 || len(acc.reqs) <= acc.numFailures)
+		acc.mu.Unlock()
+
+		if fail {
+			acc.fail(w)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	return acc
+}
+
+func (acc *recordingServerlessAcceptor) Requests() []recordedServerlessRequest {
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+
+	return append([]recordedServerlessRequest(nil), acc.reqs...)
+}
@@ -27,6 +27,7 @@ type spanS struct {
 	Tags        ot.Tags
 	Logs        []ot.LogRecord
 	ErrorCount  int
+	Links       []SpanReference
 
 	tracer *tracerS
 	mu     sync.Mutex
@@ -77,7 +78,7 @@ func (r *spanS) FinishWithOptions(opts ot.FinishOptions) {
 	}
 
 	r.Duration = duration
-	if !r.context.Suppressed {
+	if !r.context.Suppressed && applySpanProcessors(r) {
 		if sensor.Agent().Ready() {
 			r.tracer.recorder.RecordSpan(r)
 		} else {
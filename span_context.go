@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/instana/go-sensor/w3ctrace"
+	ot "github.com/opentracing/opentracing-go"
 )
 
 // EUMCorrelationData represents the data sent by the Instana End-User Monitoring script
@@ -49,7 +50,8 @@ type SpanContext struct {
 	Correlation EUMCorrelationData
 }
 
-// NewRootSpanContext initializes a new root span context issuing a new trace ID
+// NewRootSpanContext initializes a new root span context issuing a new trace ID. The trace ID
+// is 128-bit long if the tracer has been configured to use 128-bit trace IDs, see TracerOptions.Use128BitTraceIDs
 func NewRootSpanContext() SpanContext {
 	spanID := randomID()
 
@@ -58,24 +60,37 @@ func NewRootSpanContext() SpanContext {
 		SpanID:  spanID,
 	}
 
+	if use128BitTraceIDs() {
+		c.TraceIDHi = randomID()
+	}
+
 	c.W3CContext = newW3CTraceContext(c)
 
 	return c
 }
 
+func use128BitTraceIDs() bool {
+	return sensor.tracerOptions().Use128BitTraceIDs
+}
+
 // NewSpanContext initializes a new child span context from its parent. It will
 // ignore the parent context if it contains neither Instana trace and span IDs
 // nor a W3C trace context
 func NewSpanContext(parent SpanContext) SpanContext {
 	var foreignTrace bool
 	if parent.TraceIDHi == 0 && parent.TraceID == 0 && parent.SpanID == 0 {
+		// baggage is propagated regardless of whether the trace is continued
+		baggage := parent.Baggage
+
 		parent = restoreFromW3CTraceContext(parent)
+		parent.Baggage = baggage
 		foreignTrace = !sensor.options.disableW3CTraceCorrelation
 	}
 
 	if parent.TraceIDHi == 0 && parent.TraceID == 0 && parent.SpanID == 0 {
 		c := NewRootSpanContext()
 		c.Suppressed = parent.Suppressed
+		c.Baggage = parent.Clone().Baggage
 
 		// preserve the W3C trace context even if it was not used
 		if !parent.W3CContext.IsZero() {
@@ -229,6 +244,38 @@ func (sc SpanContext) Clone() SpanContext {
 	return res
 }
 
+// newSpanReference returns a reference to the span identified by an Instana span context. A context that carries
+// only the W3C trace context is referenced using the traceparent IDs.
+func newSpanReference(c ot.SpanContext) (SpanReference, bool) {
+	sc, ok := c.(SpanContext)
+	if !ok {
+		return SpanReference{}, false
+	}
+
+	if (sc.TraceIDHi != 0 || sc.TraceID != 0) && sc.SpanID != 0 {
+		traceID := FormatID(sc.TraceID)
+		if sc.TraceIDHi != 0 {
+			traceID = FormatLongID(sc.TraceIDHi, sc.TraceID)
+		}
+
+		return SpanReference{
+			TraceID: traceID,
+			SpanID:  FormatID(sc.SpanID),
+		}, true
+	}
+
+	if sc.W3CContext.IsZero() {
+		return SpanReference{}, false
+	}
+
+	p := sc.W3CContext.Parent()
+
+	return SpanReference{
+		TraceID: p.TraceID,
+		SpanID:  p.ParentID,
+	}, true
+}
+
 func newW3CTraceContext(c SpanContext) w3ctrace.Context {
 	return w3ctrace.New(w3ctrace.Parent{
 		Version:  w3ctrace.Version_Max,
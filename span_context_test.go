@@ -27,6 +27,22 @@ func TestNewRootSpanContext(t *testing.T) {
 	}, c.W3CContext.Parent().Flags)
 }
 
+func TestNewRootSpanContext_128BitTraceIDs(t *testing.T) {
+	opts := &instana.Options{
+		AgentClient: alwaysReadyClient{},
+		Tracer:      instana.TracerOptions{Use128BitTraceIDs: true},
+	}
+	instana.NewTracerWithEverything(opts, instana.NewTestRecorder())
+	defer instana.ShutdownSensor()
+
+	c := instana.NewRootSpanContext()
+
+	assert.NotEmpty(t, c.TraceIDHi)
+	assert.NotEmpty(t, c.TraceID)
+	assert.Equal(t, c.SpanID, c.TraceID)
+	assert.Equal(t, instana.FormatLongID(c.TraceIDHi, c.TraceID), c.W3CContext.Parent().TraceID)
+}
+
 func TestNewSpanContext(t *testing.T) {
 	examples := map[string]instana.SpanContext{
 		"no w3c trace": {
@@ -0,0 +1,108 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Span filter attribute match types
+const (
+	// SpanFilterMatchStrict matches attribute values that are equal to one of filter values
+	SpanFilterMatchStrict = "strict"
+	// SpanFilterMatchStartsWith matches attribute values that start with one of filter values
+	SpanFilterMatchStartsWith = "startswith"
+	// SpanFilterMatchEndsWith matches attribute values that end with one of filter values
+	SpanFilterMatchEndsWith = "endswith"
+	// SpanFilterMatchContains matches attribute values that contain one of filter values
+	SpanFilterMatchContains = "contains"
+)
+
+// SpanFilter is a rule to exclude spans from being sent to the agent. A span matches the filter
+// if it matches all of its attributes.
+type SpanFilter struct {
+	Name       string                `json:"name"`
+	Attributes []SpanFilterAttribute `json:"attributes"`
+}
+
+// SpanFilterAttribute matches a span attribute against a list of values. Supported keys are
+// "type" for the span operation name, "kind" for the span kind ("entry", "exit" or "intermediate"),
+// and any span tag name. The MatchType is one of instana.SpanFilterMatch* values and defaults to
+// instana.SpanFilterMatchStrict.
+type SpanFilterAttribute struct {
+	Key       string   `json:"key"`
+	Values    []string `json:"values"`
+	MatchType string   `json:"match_type"`
+}
+
+// Match returns true if the span matches all filter attributes. A filter without attributes does not match any span.
+func (f SpanFilter) Match(span ProcessedSpan) bool {
+	if len(f.Attributes) == 0 {
+		return false
+	}
+
+	for _, attr := range f.Attributes {
+		if !attr.match(span) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (attr SpanFilterAttribute) match(span ProcessedSpan) bool {
+	var value string
+
+	switch attr.Key {
+	case "type":
+		value = span.Operation()
+	case "kind":
+		value = RegisteredSpanType(span.Operation()).extractData(span.span).Kind().String()
+	default:
+		v, ok := span.Tag(attr.Key)
+		if !ok {
+			return false
+		}
+
+		value = fmt.Sprint(v)
+	}
+
+	for _, s := range attr.Values {
+		if matchSpanFilterValue(attr.MatchType, value, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchSpanFilterValue(matchType, value, s string) bool {
+	switch strings.ToLower(matchType) {
+	case SpanFilterMatchStartsWith:
+		return strings.HasPrefix(value, s)
+	case SpanFilterMatchEndsWith:
+		return strings.HasSuffix(value, s)
+	case SpanFilterMatchContains:
+		return strings.Contains(value, s)
+	default:
+		return value == s
+	}
+}
+
+// spanFilteredOut returns true if the span is excluded by the span filters or belongs to a disabled instrumentation
+func spanFilteredOut(opts TracerOptions, span ProcessedSpan) bool {
+	for _, name := range opts.DisabledInstrumentations {
+		if span.Operation() == name {
+			return true
+		}
+	}
+
+	for _, f := range opts.SpanFilters {
+		if f.Match(span) {
+			return true
+		}
+	}
+
+	return false
+}
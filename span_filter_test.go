@@ -0,0 +1,83 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana_test
+
+import (
+	"testing"
+
+	instana "github.com/instana/go-sensor"
+	ot "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracerOptions_SpanFilters(t *testing.T) {
+	recorder := instana.NewTestRecorder()
+	tracer := instana.NewTracerWithEverything(&instana.Options{
+		AgentClient: alwaysReadyClient{},
+		Tracer: instana.TracerOptions{
+			SpanFilters: []instana.SpanFilter{
+				{
+					Name: "health checks",
+					Attributes: []instana.SpanFilterAttribute{
+						{Key: "type", Values: []string{"g.http"}},
+						{Key: "kind", Values: []string{"entry"}},
+						{Key: "http.path", Values: []string{"/health", "/ready"}, MatchType: instana.SpanFilterMatchStartsWith},
+					},
+				},
+				{
+					Name: "cache",
+					Attributes: []instana.SpanFilterAttribute{
+						{Key: "cache.key", Values: []string{"session"}, MatchType: instana.SpanFilterMatchContains},
+					},
+				},
+			},
+		},
+	}, recorder)
+	defer instana.ShutdownSensor()
+
+	tracer.StartSpan("g.http", ext.SpanKindRPCServer, ot.Tags{"http.path": "/healthz"}).Finish()
+	tracer.StartSpan("g.http", ext.SpanKindRPCServer, ot.Tags{"http.path": "/api"}).Finish()
+	tracer.StartSpan("g.http", ext.SpanKindRPCClient, ot.Tags{"http.path": "/health"}).Finish()
+	tracer.StartSpan("sdk", ot.Tags{"cache.key": "user:session:1"}).Finish()
+
+	spans := recorder.GetQueuedSpans()
+	require.Len(t, spans, 2)
+
+	assert.Equal(t, "/api", spans[0].Data.(instana.HTTPSpanData).Tags.Path)
+	assert.Equal(t, "/health", spans[1].Data.(instana.HTTPSpanData).Tags.Path)
+}
+
+func TestTracerOptions_DisabledInstrumentations(t *testing.T) {
+	recorder := instana.NewTestRecorder()
+	tracer := instana.NewTracerWithEverything(&instana.Options{
+		AgentClient: alwaysReadyClient{},
+		Tracer: instana.TracerOptions{
+			DisabledInstrumentations: []string{"kafka"},
+		},
+	}, recorder)
+	defer instana.ShutdownSensor()
+
+	tracer.StartSpan("kafka").Finish()
+	tracer.StartSpan("g.http").Finish()
+
+	spans := recorder.GetQueuedSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "g.http", spans[0].Name)
+}
+
+func TestSpanFilter_Match_NoAttributes(t *testing.T) {
+	recorder := instana.NewTestRecorder()
+	tracer := instana.NewTracerWithEverything(&instana.Options{
+		AgentClient: alwaysReadyClient{},
+		Tracer: instana.TracerOptions{
+			SpanFilters: []instana.SpanFilter{{Name: "empty"}},
+		},
+	}, recorder)
+	defer instana.ShutdownSensor()
+
+	tracer.StartSpan("sdk").Finish()
+
+	assert.Len(t, recorder.GetQueuedSpans(), 1)
+}
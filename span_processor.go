@@ -0,0 +1,199 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana
+
+import (
+	"os"
+	"time"
+	"unicode/utf8"
+
+	ot "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+// SpanProcessor is called for each finished span before it is passed to the recorder. Span processors
+// are configured via (instana.Options).SpanProcessors and are applied in the order they are listed. A processor
+// can modify span tags or drop the span completely, in which case the rest of the chain is not called.
+type SpanProcessor interface {
+	// Process is called for a finished span. It returns false if the span needs to be dropped.
+	Process(span ProcessedSpan) bool
+}
+
+// SpanProcessorFunc is an adapter that allows to use an ordinary function as a SpanProcessor
+type SpanProcessorFunc func(span ProcessedSpan) bool
+
+// Process calls f(span)
+func (f SpanProcessorFunc) Process(span ProcessedSpan) bool {
+	return f(span)
+}
+
+// ProcessedSpan provides span processors with access to the finished span data. The span is locked
+// while being processed, so a processor must not call any methods of the span itself.
+type ProcessedSpan struct {
+	span *spanS
+}
+
+// Operation returns span operation name
+func (s ProcessedSpan) Operation() string {
+	return s.span.Operation
+}
+
+// Context returns span context
+func (s ProcessedSpan) Context() SpanContext {
+	return s.span.context
+}
+
+// Duration returns span duration
+func (s ProcessedSpan) Duration() time.Duration {
+	return s.span.Duration
+}
+
+// ErrorCount returns the number of errors that occurred during the span execution
+func (s ProcessedSpan) ErrorCount() int {
+	return s.span.ErrorCount
+}
+
+// Tags returns a copy of span tags
+func (s ProcessedSpan) Tags() ot.Tags {
+	tags := make(ot.Tags, len(s.span.Tags))
+	for k, v := range s.span.Tags {
+		tags[k] = v
+	}
+
+	return tags
+}
+
+// Tag returns the value of a span tag and whether it is set
+func (s ProcessedSpan) Tag(key string) (interface{}, bool) {
+	v, ok := s.span.Tags[key]
+
+	return v, ok
+}
+
+// SetTag sets the value of a span tag. Unlike (ot.Span).SetTag() this method does not
+// update span error count or suppression flag.
+func (s ProcessedSpan) SetTag(key string, value interface{}) {
+	if s.span.Tags == nil {
+		s.span.Tags = ot.Tags{}
+	}
+
+	s.span.Tags[key] = value
+}
+
+// RemoveTag deletes a tag from the span
+func (s ProcessedSpan) RemoveTag(key string) {
+	delete(s.span.Tags, key)
+}
+
+// applySpanProcessors passes a finished span through the span filters and the span processors chain
+// configured for the global sensor. It returns false if the span needs to be dropped.
+func applySpanProcessors(span *spanS) bool {
+	if sensor == nil || sensor.options == nil {
+		return true
+	}
+
+	if spanFilteredOut(sensor.tracerOptions(), ProcessedSpan{span}) {
+		return false
+	}
+
+	for _, p := range sensor.options.SpanProcessors {
+		if !p.Process(ProcessedSpan{span}) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// DropSpans returns a span processor that drops all spans matching provided predicate
+func DropSpans(match func(span ProcessedSpan) bool) SpanProcessor {
+	return SpanProcessorFunc(func(span ProcessedSpan) bool {
+		return !match(span)
+	})
+}
+
+// DropHTTPServerSpans returns a span processor that drops the spans of incoming HTTP requests
+// to any of provided paths, e.g. health check and readiness probe endpoints
+func DropHTTPServerSpans(paths ...string) SpanProcessor {
+	index := make(map[string]struct{}, len(paths))
+	for _, p := range paths {
+		index[p] = struct{}{}
+	}
+
+	return DropSpans(func(span ProcessedSpan) bool {
+		if RegisteredSpanType(span.Operation()) != HTTPServerSpanType {
+			return false
+		}
+
+		path, ok := span.Tag("http.path")
+		if !ok {
+			return false
+		}
+
+		s, ok := path.(string)
+		if !ok {
+			return false
+		}
+
+		_, ok = index[s]
+
+		return ok
+	})
+}
+
+// AddGlobalTags returns a span processor that adds provided tags to each span. Tags that are already
+// set on a span are not overridden. For registered spans, such as instana.HTTPServerSpanType, the tags are
+// reported in the `sdk.custom` section of span data. AWS Lambda entry spans do not report custom tags.
+func AddGlobalTags(tags map[string]interface{}) SpanProcessor {
+	return SpanProcessorFunc(func(span ProcessedSpan) bool {
+		for k, v := range tags {
+			if _, ok := span.Tag(k); !ok {
+				span.SetTag(k, v)
+			}
+		}
+
+		return true
+	})
+}
+
+// AddInstanaTags returns a span processor that adds the tags provided via INSTANA_TAGS env variable
+// to each span. See AddGlobalTags() for details.
+func AddInstanaTags() SpanProcessor {
+	return AddGlobalTags(parseInstanaTags(os.Getenv("INSTANA_TAGS")))
+}
+
+// TruncateTag returns a span processor that truncates a string tag value to maxLen bytes. The value
+// is truncated at the UTF-8 character boundary. A negative maxLen is treated as 0.
+func TruncateTag(key string, maxLen int) SpanProcessor {
+	if maxLen < 0 {
+		maxLen = 0
+	}
+
+	return SpanProcessorFunc(func(span ProcessedSpan) bool {
+		v, ok := span.Tag(key)
+		if !ok {
+			return true
+		}
+
+		s, ok := v.(string)
+		if !ok || len(s) <= maxLen {
+			return true
+		}
+
+		// avoid cutting a multi-byte character in the middle
+		cut := maxLen
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+
+		span.SetTag(key, s[:cut])
+
+		return true
+	})
+}
+
+// TruncateDBStatements returns a span processor that truncates database queries collected
+// by database instrumentations to maxLen bytes
+func TruncateDBStatements(maxLen int) SpanProcessor {
+	return TruncateTag(string(ext.DBStatement), maxLen)
+}
@@ -0,0 +1,161 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	instana "github.com/instana/go-sensor"
+	ot "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpanProcessors(t *testing.T) {
+	recorder := instana.NewTestRecorder()
+	tracer := instana.NewTracerWithEverything(&instana.Options{
+		AgentClient: alwaysReadyClient{},
+		SpanProcessors: []instana.SpanProcessor{
+			instana.DropHTTPServerSpans("/healthz"),
+			instana.AddGlobalTags(map[string]interface{}{
+				"env":  "test",
+				"team": "backend",
+			}),
+			instana.TruncateDBStatements(10),
+		},
+	}, recorder)
+	defer instana.ShutdownSensor()
+
+	tracer.StartSpan("g.http", ext.SpanKindRPCServer, ot.Tags{"http.path": "/healthz"}).Finish()
+	tracer.StartSpan("g.http", ext.SpanKindRPCServer, ot.Tags{"http.path": "/api"}).Finish()
+	tracer.StartSpan("sdk", ot.Tags{
+		"team":                  "frontend",
+		string(ext.DBStatement): "SELECT * FROM users WHERE name = 'Jürgen'",
+	}).Finish()
+
+	spans := recorder.GetQueuedSpans()
+	require.Len(t, spans, 2)
+
+	assert.Equal(t, "g.http", spans[0].Name)
+	assert.Equal(t, "/api", spans[0].Data.(instana.HTTPSpanData).Tags.Path)
+
+	require.IsType(t, instana.SDKSpanData{}, spans[1].Data)
+	assert.Equal(t, ot.Tags{
+		"env":                   "test",
+		"team":                  "frontend",
+		string(ext.DBStatement): "SELECT * F",
+	}, spans[1].Data.(instana.SDKSpanData).Tags.Custom["tags"])
+}
+
+func TestSpanProcessors_DropSpans(t *testing.T) {
+	recorder := instana.NewTestRecorder()
+	tracer := instana.NewTracerWithEverything(&instana.Options{
+		AgentClient: alwaysReadyClient{},
+		SpanProcessors: []instana.SpanProcessor{
+			instana.DropSpans(func(span instana.ProcessedSpan) bool {
+				return span.Operation() == "dropped"
+			}),
+		},
+	}, recorder)
+	defer instana.ShutdownSensor()
+
+	tracer.StartSpan("dropped").Finish()
+	tracer.StartSpan("kept").Finish()
+
+	spans := recorder.GetQueuedSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "kept", spans[0].Data.(instana.SDKSpanData).Tags.Name)
+}
+
+func TestTruncateTag(t *testing.T) {
+	recorder := instana.NewTestRecorder()
+	tracer := instana.NewTracerWithEverything(&instana.Options{
+		AgentClient: alwaysReadyClient{},
+		SpanProcessors: []instana.SpanProcessor{
+			instana.TruncateTag("message", 5),
+			instana.TruncateTag("number", 1),
+		},
+	}, recorder)
+	defer instana.ShutdownSensor()
+
+	tracer.StartSpan("sdk", ot.Tags{
+		"message": strings.Repeat("ü", 3),
+		"number":  42,
+	}).Finish()
+
+	spans := recorder.GetQueuedSpans()
+	require.Len(t, spans, 1)
+
+	assert.Equal(t, ot.Tags{
+		"message": "üü",
+		"number":  42,
+	}, spans[0].Data.(instana.SDKSpanData).Tags.Custom["tags"])
+}
+
+func TestTruncateTag_NegativeMaxLen(t *testing.T) {
+	recorder := instana.NewTestRecorder()
+	tracer := instana.NewTracerWithEverything(&instana.Options{
+		AgentClient:    alwaysReadyClient{},
+		SpanProcessors: []instana.SpanProcessor{instana.TruncateTag("message", -1)},
+	}, recorder)
+	defer instana.ShutdownSensor()
+
+	tracer.StartSpan("sdk", ot.Tags{"message": "hello"}).Finish()
+
+	spans := recorder.GetQueuedSpans()
+	require.Len(t, spans, 1)
+
+	assert.Equal(t, ot.Tags{
+		"message": "",
+	}, spans[0].Data.(instana.SDKSpanData).Tags.Custom["tags"])
+}
+
+func TestAddInstanaTags(t *testing.T) {
+	os.Setenv("INSTANA_TAGS", "env=test,canary")
+	defer os.Unsetenv("INSTANA_TAGS")
+
+	recorder := instana.NewTestRecorder()
+	tracer := instana.NewTracerWithEverything(&instana.Options{
+		AgentClient:    alwaysReadyClient{},
+		SpanProcessors: []instana.SpanProcessor{instana.AddInstanaTags()},
+	}, recorder)
+	defer instana.ShutdownSensor()
+
+	tracer.StartSpan("sdk").Finish()
+
+	spans := recorder.GetQueuedSpans()
+	require.Len(t, spans, 1)
+
+	assert.Equal(t, ot.Tags{
+		"env":    "test",
+		"canary": nil,
+	}, spans[0].Data.(instana.SDKSpanData).Tags.Custom["tags"])
+}
+
+func TestAddGlobalTags_RegisteredSpan(t *testing.T) {
+	recorder := instana.NewTestRecorder()
+	tracer := instana.NewTracerWithEverything(&instana.Options{
+		AgentClient: alwaysReadyClient{},
+		SpanProcessors: []instana.SpanProcessor{
+			instana.AddGlobalTags(map[string]interface{}{"env": "test"}),
+		},
+	}, recorder)
+	defer instana.ShutdownSensor()
+
+	tracer.StartSpan("g.http", ext.SpanKindRPCServer, ot.Tags{
+		"http.method": "GET",
+	}).Finish()
+
+	spans := recorder.GetQueuedSpans()
+	require.Len(t, spans, 1)
+
+	require.IsType(t, instana.HTTPSpanData{}, spans[0].Data)
+	data := spans[0].Data.(instana.HTTPSpanData)
+
+	assert.Equal(t, "GET", data.Tags.Method)
+	require.NotNil(t, data.Custom)
+	assert.Equal(t, ot.Tags{"env": "test"}, ot.Tags(data.Custom.Tags))
+}
@@ -0,0 +1,107 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/instana/go-sensor/spool"
+)
+
+var errSpoolReplayLimitReached = errors.New("replay limit reached")
+
+// spanSpool persists the batches of spans that could not be delivered to the agent on disk, so that
+// they can be sent later once the agent becomes available, even after the process restart. All methods
+// of spanSpool are safe to call on a nil instance, which means that the spooling is disabled.
+type spanSpool struct {
+	s      *spool.Spool
+	logger LeveledLogger
+}
+
+func newSpanSpool(dir string, maxSize int64, logger LeveledLogger) (*spanSpool, error) {
+	s, err := spool.Open(dir, spool.Options{
+		MaxSize: maxSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &spanSpool{
+		s:      s,
+		logger: logger,
+	}, nil
+}
+
+// Store writes a batch of spans to the spool. It returns false if the spooling is disabled or
+// the spans could not be stored.
+func (sp *spanSpool) Store(spans []Span) bool {
+	if sp == nil {
+		return false
+	}
+
+	if len(spans) == 0 {
+		return true
+	}
+
+	data, err := json.Marshal(spans)
+	if err != nil {
+		sp.logger.Warn("failed to marshal spans to be spooled: ", err)
+		return false
+	}
+
+	if err := sp.s.Append(data); err != nil {
+		sp.logger.Warn("failed to spool ", len(spans), " span(s): ", err)
+		return false
+	}
+
+	sp.logger.Debug("spooled ", len(spans), " span(s) to be sent later")
+
+	return true
+}
+
+// ReplayNext passes the oldest stored batch of spans to the send function and removes it from the spool
+// if there was no error.
+func (sp *spanSpool) ReplayNext(send func([]Span) error) error {
+	return sp.replay(1, send)
+}
+
+// replay sends up to maxBatches of stored span batches. There is no limit if maxBatches is 0.
+func (sp *spanSpool) replay(maxBatches int, send func([]Span) error) error {
+	if sp == nil {
+		return nil
+	}
+
+	var n int
+	err := sp.s.Replay(func(record []byte) error {
+		if maxBatches > 0 && n == maxBatches {
+			return errSpoolReplayLimitReached
+		}
+		n++
+
+		var docs []json.RawMessage
+		if err := json.Unmarshal(record, &docs); err != nil {
+			sp.logger.Warn("failed to unmarshal spooled spans, skipping: ", err)
+			return nil
+		}
+
+		spans := make([]Span, len(docs))
+		for i, doc := range docs {
+			spans[i].raw = doc
+		}
+
+		if err := send(spans); err != nil {
+			return fmt.Errorf("failed to send spooled spans: %s", err)
+		}
+
+		sp.logger.Debug("sent ", len(spans), " spooled span(s)")
+
+		return nil
+	})
+	if err == errSpoolReplayLimitReached {
+		return nil
+	}
+
+	return err
+}
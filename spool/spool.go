@@ -0,0 +1,480 @@
+// (c) Copyright IBM Corp. 2023
+
+// Package spool provides a persistent append-only record queue backed by segment files on disk.
+// Each record is stored along with its length and CRC32 checksum, so that partially written or
+// corrupted records are detected and skipped on replay.
+//
+// The position of the first unprocessed record is persisted in a sidecar file next to the segments,
+// so that a re-opened spool continues the replay where it was interrupted. The records are delivered
+// at least once: a record that has been processed right before the process crashed can be replayed
+// again after a restart.
+package spool
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultMaxSegmentSize is the default maximum size of a segment file
+	DefaultMaxSegmentSize = 4 * 1024 * 1024
+	// DefaultMaxSize is the default maximum size of all segment files stored in spool directory
+	DefaultMaxSize = 64 * 1024 * 1024
+
+	segmentExt       = ".seg"
+	offsetFile       = "offset"
+	recordHeaderSize = 8
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrRecordTooLarge is returned when an attempt is made to store a record that does not fit into a segment
+var ErrRecordTooLarge = errors.New("record is too large")
+
+// Options contains the spool configuration
+type Options struct {
+	// MaxSegmentSize is the maximum size of a segment file in bytes. Once the active segment
+	// reaches this size, a new one is started
+	MaxSegmentSize int64
+	// MaxSize is the maximum size of all segment files in bytes. The oldest segments are
+	// removed to free up the space for new records once this limit is reached
+	MaxSize int64
+}
+
+func (opts *Options) setDefaults() {
+	if opts.MaxSegmentSize <= 0 {
+		opts.MaxSegmentSize = DefaultMaxSegmentSize
+	}
+
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultMaxSize
+	}
+
+	if opts.MaxSegmentSize > opts.MaxSize {
+		opts.MaxSegmentSize = opts.MaxSize
+	}
+}
+
+type segment struct {
+	Seq  uint64
+	Size int64
+}
+
+// Spool is a persistent FIFO queue of records stored in a directory
+type Spool struct {
+	dir  string
+	opts Options
+
+	mu       sync.Mutex
+	segments []segment // sorted oldest first, the last one is active
+	active   *os.File
+	// offset of the first unprocessed record in the oldest segment
+	readOffset int64
+	// number of records dropped due to segment eviction or corruption
+	dropped int
+}
+
+// Open opens or creates a spool in provided directory. The segments left by a previous
+// process using the same directory are picked up to be replayed.
+func Open(dir string, opts Options) (*Spool, error) {
+	opts.setDefaults()
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %s", err)
+	}
+
+	s := &Spool{
+		dir:  dir,
+		opts: opts,
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool directory: %s", err)
+	}
+
+	for _, fi := range entries {
+		if fi.IsDir() || !strings.HasSuffix(fi.Name(), segmentExt) {
+			continue
+		}
+
+		seq, err := strconv.ParseUint(strings.TrimSuffix(fi.Name(), segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		s.segments = append(s.segments, segment{Seq: seq, Size: fi.Size()})
+	}
+
+	sort.Slice(s.segments, func(i, j int) bool {
+		return s.segments[i].Seq < s.segments[j].Seq
+	})
+
+	if len(s.segments) > 0 {
+		s.readOffset = s.loadOffset(s.segments[0])
+	}
+
+	return s, nil
+}
+
+// Append stores a record in the active segment
+func (s *Spool) Append(record []byte) error {
+	size := int64(len(record)) + recordHeaderSize
+	if size > s.opts.MaxSegmentSize {
+		return ErrRecordTooLarge
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active == nil || s.segments[len(s.segments)-1].Size+size > s.opts.MaxSegmentSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	s.evict(size)
+
+	buf := make([]byte, size)
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(record)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.Checksum(record, crcTable))
+	copy(buf[recordHeaderSize:], record)
+
+	if _, err := s.active.Write(buf); err != nil {
+		return fmt.Errorf("failed to write spool record: %s", err)
+	}
+
+	s.segments[len(s.segments)-1].Size += size
+
+	return nil
+}
+
+// Replay calls fn for each stored record starting from the oldest one. A record is removed from
+// the spool once fn returns without an error. If fn returns an error, the replay is interrupted and
+// the record is retained to be replayed the next time. The lock is not held while fn is running, so
+// records can be stored concurrently. These records are kept in the spool until the next replay.
+//
+// The active segment is sealed only once all other segments have been replayed, so that a replay
+// failing on the oldest records does not start a new segment each time.
+func (s *Spool) Replay(fn func(record []byte) error) error {
+	s.mu.Lock()
+	if len(s.segments) == 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	last := s.segments[len(s.segments)-1].Seq
+	s.mu.Unlock()
+
+	for {
+		s.mu.Lock()
+		if len(s.segments) == 0 || s.segments[0].Seq > last {
+			s.mu.Unlock()
+			return nil
+		}
+
+		if len(s.segments) == 1 && s.active != nil {
+			if err := s.seal(); err != nil {
+				s.mu.Unlock()
+				return err
+			}
+		}
+
+		seg, offset := s.segments[0], s.readOffset
+		s.mu.Unlock()
+
+		if err := s.replaySegment(seg, offset, fn); err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		if len(s.segments) > 0 && s.segments[0].Seq == seg.Seq {
+			if err := os.Remove(s.segmentPath(seg.Seq)); err != nil && !os.IsNotExist(err) {
+				s.mu.Unlock()
+				return fmt.Errorf("failed to remove spool segment: %s", err)
+			}
+
+			s.segments = s.segments[1:]
+			s.resetOffset()
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Size returns the total size of stored segments in bytes
+func (s *Spool) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var size int64
+	for _, seg := range s.segments {
+		size += seg.Size
+	}
+
+	return size - s.readOffset
+}
+
+// Dropped returns the number of records that were removed from the spool without being
+// replayed, either because of the size limit or due to the data corruption
+func (s *Spool) Dropped() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.dropped
+}
+
+// Close closes the active segment file. The stored records remain on disk and can be replayed
+// once the spool is re-opened.
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active == nil {
+		return nil
+	}
+
+	err := s.active.Close()
+	s.active = nil
+
+	return err
+}
+
+// replaySegment passes the records stored in a sealed segment to fn starting from provided offset. The records
+// are read one by one, so that only the record being replayed is kept in memory. It returns nil once all records
+// have been processed, or the segment has been evicted while the records were replayed.
+func (s *Spool) replaySegment(seg segment, offset int64, fn func(record []byte) error) error {
+	f, err := os.Open(s.segmentPath(seg.Seq))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return fmt.Errorf("failed to open spool segment: %s", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read spool segment: %s", err)
+	}
+
+	r := bufio.NewReader(f)
+	for offset < seg.Size {
+		record, end := readRecord(r, offset, seg.Size)
+		if record != nil {
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+
+		ok, err := s.advance(seg, end, record == nil)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			// the segment has been evicted while fn was running and its records were counted as dropped
+			return nil
+		}
+
+		offset = end
+	}
+
+	return nil
+}
+
+// advance moves the read offset of a segment past a processed record and persists it. It returns false if
+// the segment is no longer the oldest one in the spool.
+func (s *Spool) advance(seg segment, offset int64, corrupted bool) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.segments) == 0 || s.segments[0].Seq != seg.Seq {
+		return false, nil
+	}
+
+	if corrupted {
+		s.dropped++
+	}
+	s.readOffset = offset
+
+	if err := s.saveOffset(seg); err != nil {
+		return true, fmt.Errorf("failed to store spool read offset: %s", err)
+	}
+
+	return true, nil
+}
+
+// loadOffset returns the persisted read offset if it belongs to provided segment, otherwise
+// the segment is replayed from the beginning
+func (s *Spool) loadOffset(seg segment) int64 {
+	data, err := ioutil.ReadFile(filepath.Join(s.dir, offsetFile))
+	if err != nil {
+		return 0
+	}
+
+	var seq uint64
+	var offset int64
+	if _, err := fmt.Sscanf(string(data), "%d %d", &seq, &offset); err != nil {
+		return 0
+	}
+
+	if seq != seg.Seq || offset < 0 || offset > seg.Size {
+		return 0
+	}
+
+	return offset
+}
+
+// saveOffset persists the read offset of the oldest segment. The offset is written to a temporary
+// file first to make sure that a crash does not leave a partially written offset behind. This
+// method is not thread-safe and needs to be called while holding the lock.
+func (s *Spool) saveOffset(seg segment) error {
+	path := filepath.Join(s.dir, offsetFile)
+
+	if err := ioutil.WriteFile(path+".tmp", []byte(fmt.Sprintf("%d %d", seg.Seq, s.readOffset)), 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+// resetOffset starts reading the oldest segment from the beginning. This method is not thread-safe
+// and needs to be called while holding the lock.
+func (s *Spool) resetOffset() {
+	s.readOffset = 0
+	os.Remove(filepath.Join(s.dir, offsetFile))
+}
+
+// readRecord reads the record stored at provided offset of a segment of given size. It returns the record
+// along with the offset of the next one. The record is nil if it's corrupted. If the record boundaries can't
+// be determined, the rest of the segment is skipped.
+func readRecord(r io.Reader, offset, size int64) ([]byte, int64) {
+	header := make([]byte, recordHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		// truncated record header, i.e. the process crashed while writing the record
+		return nil, size
+	}
+
+	n, checksum := binary.BigEndian.Uint32(header[0:4]), binary.BigEndian.Uint32(header[4:8])
+
+	end := offset + int64(n) + recordHeaderSize
+	if end > size {
+		// either the size header is corrupted or the record has not been fully written,
+		// in both cases the rest of the segment can't be read
+		return nil, size
+	}
+
+	record := make([]byte, n)
+	if _, err := io.ReadFull(r, record); err != nil {
+		return nil, size
+	}
+
+	if crc32.Checksum(record, crcTable) != checksum {
+		// the record is corrupted, but its boundaries are still intact
+		return nil, end
+	}
+
+	return record, end
+}
+
+// seal closes the active segment, so that new records are stored in a new one. This method is not
+// thread-safe and needs to be called while holding the lock.
+func (s *Spool) seal() error {
+	if s.active == nil {
+		return nil
+	}
+
+	if err := s.active.Close(); err != nil {
+		return fmt.Errorf("failed to close active spool segment: %s", err)
+	}
+	s.active = nil
+
+	return nil
+}
+
+// rotate closes the active segment and starts a new one. This method is not thread-safe
+// and needs to be called while holding the lock.
+func (s *Spool) rotate() error {
+	if err := s.seal(); err != nil {
+		return err
+	}
+
+	var seq uint64
+	if len(s.segments) > 0 {
+		seq = s.segments[len(s.segments)-1].Seq + 1
+	}
+
+	f, err := os.OpenFile(s.segmentPath(seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create spool segment: %s", err)
+	}
+
+	s.active = f
+	s.segments = append(s.segments, segment{Seq: seq})
+
+	return nil
+}
+
+// evict removes the oldest sealed segments until there is enough space to store size bytes. This method
+// is not thread-safe and needs to be called while holding the lock.
+func (s *Spool) evict(size int64) {
+	var total int64
+	for _, seg := range s.segments {
+		total += seg.Size
+	}
+
+	for total+size > s.opts.MaxSize && len(s.segments) > 1 {
+		oldest := s.segments[0]
+
+		if n, err := s.countRecords(oldest); err == nil {
+			s.dropped += n
+		}
+
+		os.Remove(s.segmentPath(oldest.Seq))
+
+		total -= oldest.Size
+		s.segments = s.segments[1:]
+		s.resetOffset()
+	}
+}
+
+// countRecords returns the number of unprocessed records in a segment
+func (s *Spool) countRecords(seg segment) (int, error) {
+	f, err := os.Open(s.segmentPath(seg.Seq))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var offset int64
+	if seg.Seq == s.segments[0].Seq {
+		offset = s.readOffset
+	}
+
+	header := make([]byte, recordHeaderSize)
+
+	var n int
+	for ; offset < seg.Size; n++ {
+		if _, err := f.ReadAt(header, offset); err != nil {
+			break
+		}
+
+		offset += int64(binary.BigEndian.Uint32(header[0:4])) + recordHeaderSize
+	}
+
+	return n, nil
+}
+
+func (s *Spool) segmentPath(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", seq, segmentExt))
+}
@@ -0,0 +1,295 @@
+// (c) Copyright IBM Corp. 2023
+
+package spool_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/instana/go-sensor/spool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpool_Replay(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	s, err := spool.Open(dir, spool.Options{MaxSegmentSize: 32})
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, s.Append([]byte(fmt.Sprintf("record %d", i))))
+	}
+
+	var replayed []string
+	require.NoError(t, s.Replay(func(record []byte) error {
+		replayed = append(replayed, string(record))
+		return nil
+	}))
+
+	assert.Equal(t, []string{"record 0", "record 1", "record 2", "record 3", "record 4"}, replayed)
+	assert.EqualValues(t, 0, s.Size())
+
+	// all segments are removed once replayed
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestSpool_Replay_Error(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	s, err := spool.Open(dir, spool.Options{MaxSegmentSize: 32})
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, s.Append([]byte(fmt.Sprintf("record %d", i))))
+	}
+
+	sendErr := errors.New("agent is not available")
+
+	var replayed []string
+	assert.Equal(t, sendErr, s.Replay(func(record []byte) error {
+		if len(replayed) == 3 {
+			return sendErr
+		}
+
+		replayed = append(replayed, string(record))
+
+		return nil
+	}))
+
+	assert.Equal(t, []string{"record 0", "record 1", "record 2"}, replayed)
+
+	require.NoError(t, s.Append([]byte("record 5")))
+
+	replayed = nil
+	require.NoError(t, s.Replay(func(record []byte) error {
+		replayed = append(replayed, string(record))
+		return nil
+	}))
+
+	assert.Equal(t, []string{"record 3", "record 4", "record 5"}, replayed)
+}
+
+func TestSpool_Replay_Append(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	s, err := spool.Open(dir, spool.Options{MaxSegmentSize: 32})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.Append([]byte(fmt.Sprintf("record %d", i))))
+	}
+
+	// the spool is not locked while the records are replayed
+	var replayed []string
+	require.NoError(t, s.Replay(func(record []byte) error {
+		replayed = append(replayed, string(record))
+		return s.Append([]byte("new " + string(record)))
+	}))
+
+	assert.Equal(t, []string{"record 0", "record 1", "record 2"}, replayed)
+
+	// records stored during the replay are kept until the next one
+	replayed = nil
+	require.NoError(t, s.Replay(func(record []byte) error {
+		replayed = append(replayed, string(record))
+		return nil
+	}))
+
+	assert.Equal(t, []string{"new record 0", "new record 1", "new record 2"}, replayed)
+}
+
+func TestSpool_Reopen(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	s, err := spool.Open(dir, spool.Options{})
+	require.NoError(t, err)
+
+	require.NoError(t, s.Append([]byte("record 0")))
+	require.NoError(t, s.Append([]byte("record 1")))
+	require.NoError(t, s.Close())
+
+	s, err = spool.Open(dir, spool.Options{})
+	require.NoError(t, err)
+
+	require.NoError(t, s.Append([]byte("record 2")))
+
+	var replayed []string
+	require.NoError(t, s.Replay(func(record []byte) error {
+		replayed = append(replayed, string(record))
+		return nil
+	}))
+
+	assert.Equal(t, []string{"record 0", "record 1", "record 2"}, replayed)
+}
+
+func TestSpool_Append_MaxSize(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	s, err := spool.Open(dir, spool.Options{
+		MaxSegmentSize: 16,
+		MaxSize:        40,
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, s.Append([]byte(fmt.Sprintf("rec%d", i))))
+	}
+
+	assert.EqualValues(t, 36, s.Size())
+	assert.Equal(t, 2, s.Dropped())
+
+	var replayed []string
+	require.NoError(t, s.Replay(func(record []byte) error {
+		replayed = append(replayed, string(record))
+		return nil
+	}))
+
+	assert.Equal(t, []string{"rec2", "rec3", "rec4"}, replayed)
+}
+
+func TestSpool_Append_TooLarge(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	s, err := spool.Open(dir, spool.Options{MaxSegmentSize: 16})
+	require.NoError(t, err)
+
+	assert.Equal(t, spool.ErrRecordTooLarge, s.Append(make([]byte, 9)))
+}
+
+func TestSpool_Replay_Corrupted(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	s, err := spool.Open(dir, spool.Options{})
+	require.NoError(t, err)
+
+	require.NoError(t, s.Append([]byte("record 0")))
+	require.NoError(t, s.Append([]byte("record 1")))
+	require.NoError(t, s.Append([]byte("record 2")))
+	require.NoError(t, s.Close())
+
+	segments, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+
+	data, err := ioutil.ReadFile(segments[0])
+	require.NoError(t, err)
+
+	// flip a byte in the second record payload
+	data[16+8] ^= 0xff
+	// and truncate the last one
+	data = data[:len(data)-2]
+
+	require.NoError(t, ioutil.WriteFile(segments[0], data, os.ModePerm))
+
+	s, err = spool.Open(dir, spool.Options{})
+	require.NoError(t, err)
+
+	var replayed []string
+	require.NoError(t, s.Replay(func(record []byte) error {
+		replayed = append(replayed, string(record))
+		return nil
+	}))
+
+	assert.Equal(t, []string{"record 0"}, replayed)
+	assert.Equal(t, 2, s.Dropped())
+}
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "spool")
+	require.NoError(t, err)
+
+	return dir
+}
+
+func TestSpool_Reopen_Offset(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	s, err := spool.Open(dir, spool.Options{})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.Append([]byte(fmt.Sprintf("record %d", i))))
+	}
+
+	sendErr := errors.New("agent is not available")
+
+	var replayed []string
+	assert.Equal(t, sendErr, s.Replay(func(record []byte) error {
+		if len(replayed) == 2 {
+			return sendErr
+		}
+
+		replayed = append(replayed, string(record))
+
+		return nil
+	}))
+
+	// the process exits without closing the spool
+	s, err = spool.Open(dir, spool.Options{})
+	require.NoError(t, err)
+
+	replayed = nil
+	require.NoError(t, s.Replay(func(record []byte) error {
+		replayed = append(replayed, string(record))
+		return nil
+	}))
+
+	// the records replayed before the restart are not sent again
+	assert.Equal(t, []string{"record 2"}, replayed)
+	assert.EqualValues(t, 0, s.Size())
+
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestSpool_Replay_Error_KeepsActiveSegment(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	s, err := spool.Open(dir, spool.Options{})
+	require.NoError(t, err)
+
+	sendErr := errors.New("agent is not available")
+	failingFn := func(record []byte) error { return sendErr }
+
+	// the active segment is sealed to replay its records, since there is nothing else to replay
+	require.NoError(t, s.Append([]byte("record 0")))
+	assert.Equal(t, sendErr, s.Replay(failingFn))
+
+	// the records stored after that are appended to a new active segment, which is kept open
+	// while the replay fails on the oldest one
+	for i := 1; i < 5; i++ {
+		require.NoError(t, s.Append([]byte(fmt.Sprintf("record %d", i))))
+		assert.Equal(t, sendErr, s.Replay(failingFn))
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	var replayed []string
+	require.NoError(t, s.Replay(func(record []byte) error {
+		replayed = append(replayed, string(record))
+		return nil
+	}))
+
+	assert.Equal(t, []string{"record 0", "record 1", "record 2", "record 3", "record 4"}, replayed)
+}
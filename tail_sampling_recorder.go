@@ -0,0 +1,291 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultTailSamplingDecisionWait is the default time to wait for trace spans before making the sampling decision
+	DefaultTailSamplingDecisionWait = 5 * time.Second
+	// DefaultTailSamplingMaxTraces is the default maximum number of traces awaiting for the sampling decision
+	DefaultTailSamplingMaxTraces = 10000
+	// DefaultTailSamplingMaxSpansPerTrace is the default maximum number of spans buffered per trace
+	DefaultTailSamplingMaxSpansPerTrace = 1000
+)
+
+// TailSamplingOptions configures the tail-based sampling. A trace is kept if any of its spans matches
+// at least one of the configured rules.
+type TailSamplingOptions struct {
+	// DecisionWait is the time since the first finished span of a trace after which the sampling decision is made
+	DecisionWait time.Duration
+	// MaxTraces is the maximum number of traces to buffer. Once this limit is reached, the oldest trace is
+	// evicted and the sampling decision is made for it based on spans buffered so far
+	MaxTraces int
+	// MaxSpansPerTrace is the maximum number of spans to buffer per trace. Any spans exceeding this limit are dropped
+	MaxSpansPerTrace int
+	// KeepErrored keeps the traces that contain at least one span with errors
+	KeepErrored bool
+	// SlowEntrySpanThreshold keeps the traces that contain an entry span which took longer than the threshold.
+	// The zero value disables this rule
+	SlowEntrySpanThreshold time.Duration
+	// SpanTypes keeps the traces that contain a span of any of the listed types, e.g. instana.RedisSpanType
+	SpanTypes []RegisteredSpanType
+}
+
+// DefaultTailSamplingOptions returns the default set of options to configure the tail-based sampling,
+// which keeps all traces that have errors
+func DefaultTailSamplingOptions() TailSamplingOptions {
+	return TailSamplingOptions{
+		DecisionWait:     DefaultTailSamplingDecisionWait,
+		MaxTraces:        DefaultTailSamplingMaxTraces,
+		MaxSpansPerTrace: DefaultTailSamplingMaxSpansPerTrace,
+		KeepErrored:      true,
+	}
+}
+
+func (opts *TailSamplingOptions) setDefaults() {
+	if opts.DecisionWait <= 0 {
+		opts.DecisionWait = DefaultTailSamplingDecisionWait
+	}
+
+	if opts.MaxTraces <= 0 {
+		opts.MaxTraces = DefaultTailSamplingMaxTraces
+	}
+
+	if opts.MaxSpansPerTrace <= 0 {
+		opts.MaxSpansPerTrace = DefaultTailSamplingMaxSpansPerTrace
+	}
+}
+
+// TailSamplingStats contains the tail-based sampling counters
+type TailSamplingStats struct {
+	// PendingTraces is the number of traces awaiting for the sampling decision
+	PendingTraces int
+	// KeptTraces is the number of traces passed to the underlying recorder
+	KeptTraces uint64
+	// DroppedTraces is the number of traces that did not match any of the rules
+	DroppedTraces uint64
+	// EvictedTraces is the number of traces for which the decision has been made before
+	// the decision wait time passed because of the buffer being full
+	EvictedTraces uint64
+	// DroppedSpans is the number of spans dropped because of exceeding the per-trace limit
+	DroppedSpans uint64
+}
+
+type traceKey struct {
+	TraceIDHi, TraceID int64
+}
+
+type pendingTrace struct {
+	Key       traceKey
+	Spans     []*spanS
+	Keep      bool
+	StartedAt time.Time
+}
+
+// TailSamplingRecorder is a span recorder that buffers finished spans grouped by their trace ID and
+// makes the sampling decision for the whole trace once all its spans are expected to be finished. The
+// spans of the kept traces are passed to the underlying SpanRecorder, while the rest is discarded.
+//
+// To make sure that no pending traces are lost on shutdown, pass this recorder via (instana.Options).Recorder,
+// so that it's closed by instana.ShutdownSensor(), or call (*instana.TailSamplingRecorder).Close() before exiting.
+// Closing the recorder stops the background goroutine that makes the sampling decisions and flushes pending traces.
+type TailSamplingRecorder struct {
+	// counters are placed first to ensure 64-bit alignment required by sync/atomic on 32-bit platforms
+	keptTraces    uint64
+	droppedTraces uint64
+	evictedTraces uint64
+	droppedSpans  uint64
+
+	next SpanRecorder
+	opts TailSamplingOptions
+	now  func() time.Time
+
+	mu      sync.Mutex
+	traces  map[traceKey]*list.Element
+	pending *list.List
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+var _ SpanRecorder = (*TailSamplingRecorder)(nil)
+
+// NewTailSamplingRecorder initializes a new tail-based sampling recorder on top of provided span recorder
+func NewTailSamplingRecorder(next SpanRecorder, opts TailSamplingOptions) *TailSamplingRecorder {
+	r := newTailSamplingRecorder(next, opts, time.Now)
+
+	go func() {
+		ticker := time.NewTicker(r.opts.DecisionWait / 4)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				r.decideExpired()
+			case <-r.done:
+				return
+			}
+		}
+	}()
+
+	return r
+}
+
+func newTailSamplingRecorder(next SpanRecorder, opts TailSamplingOptions, now func() time.Time) *TailSamplingRecorder {
+	opts.setDefaults()
+
+	return &TailSamplingRecorder{
+		next:    next,
+		opts:    opts,
+		now:     now,
+		traces:  make(map[traceKey]*list.Element),
+		pending: list.New(),
+		done:    make(chan struct{}),
+	}
+}
+
+// RecordSpan buffers a finished span until the sampling decision is made for its trace
+func (r *TailSamplingRecorder) RecordSpan(span *spanS) {
+	key := traceKey{span.context.TraceIDHi, span.context.TraceID}
+	keep := r.matches(span)
+
+	var evicted *pendingTrace
+
+	r.mu.Lock()
+
+	el, ok := r.traces[key]
+	if !ok {
+		if r.pending.Len() >= r.opts.MaxTraces {
+			evicted = r.remove(r.pending.Front())
+			atomic.AddUint64(&r.evictedTraces, 1)
+		}
+
+		el = r.pending.PushBack(&pendingTrace{
+			Key:       key,
+			StartedAt: r.now(),
+		})
+		r.traces[key] = el
+	}
+
+	tr := el.Value.(*pendingTrace)
+	tr.Keep = tr.Keep || keep
+
+	if len(tr.Spans) < r.opts.MaxSpansPerTrace {
+		tr.Spans = append(tr.Spans, span)
+	} else {
+		atomic.AddUint64(&r.droppedSpans, 1)
+	}
+
+	r.mu.Unlock()
+
+	if evicted != nil {
+		r.decide(evicted)
+	}
+}
+
+// Flush makes the sampling decision for all pending traces and flushes the underlying recorder
+func (r *TailSamplingRecorder) Flush(ctx context.Context) error {
+	r.mu.Lock()
+	var traces []*pendingTrace
+	for r.pending.Len() > 0 {
+		traces = append(traces, r.remove(r.pending.Front()))
+	}
+	r.mu.Unlock()
+
+	for _, tr := range traces {
+		r.decide(tr)
+	}
+
+	return r.next.Flush(ctx)
+}
+
+// Close stops the periodic sampling decisions for expired traces, then makes the sampling decision for
+// all pending traces and flushes the underlying recorder. The traces recorded after the recorder has been
+// closed are retained until Flush() or Close() is called again.
+func (r *TailSamplingRecorder) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.done)
+	})
+
+	return r.Flush(context.Background())
+}
+
+// Stats returns the tail-based sampling counters
+func (r *TailSamplingRecorder) Stats() TailSamplingStats {
+	r.mu.Lock()
+	pendingTraces := r.pending.Len()
+	r.mu.Unlock()
+
+	return TailSamplingStats{
+		PendingTraces: pendingTraces,
+		KeptTraces:    atomic.LoadUint64(&r.keptTraces),
+		DroppedTraces: atomic.LoadUint64(&r.droppedTraces),
+		EvictedTraces: atomic.LoadUint64(&r.evictedTraces),
+		DroppedSpans:  atomic.LoadUint64(&r.droppedSpans),
+	}
+}
+
+// decideExpired makes the sampling decision for all traces that have been waiting longer than
+// the configured decision wait time
+func (r *TailSamplingRecorder) decideExpired() {
+	deadline := r.now().Add(-r.opts.DecisionWait)
+
+	r.mu.Lock()
+	var traces []*pendingTrace
+	for el := r.pending.Front(); el != nil && !el.Value.(*pendingTrace).StartedAt.After(deadline); el = r.pending.Front() {
+		traces = append(traces, r.remove(el))
+	}
+	r.mu.Unlock()
+
+	for _, tr := range traces {
+		r.decide(tr)
+	}
+}
+
+// remove deletes a pending trace from the buffer. This method is not thread-safe and needs
+// to be called while holding the lock
+func (r *TailSamplingRecorder) remove(el *list.Element) *pendingTrace {
+	tr := r.pending.Remove(el).(*pendingTrace)
+	delete(r.traces, tr.Key)
+
+	return tr
+}
+
+func (r *TailSamplingRecorder) decide(tr *pendingTrace) {
+	if !tr.Keep {
+		atomic.AddUint64(&r.droppedTraces, 1)
+		return
+	}
+
+	atomic.AddUint64(&r.keptTraces, 1)
+
+	for _, span := range tr.Spans {
+		r.next.RecordSpan(span)
+	}
+}
+
+func (r *TailSamplingRecorder) matches(span *spanS) bool {
+	if r.opts.KeepErrored && span.ErrorCount > 0 {
+		return true
+	}
+
+	st := RegisteredSpanType(span.Operation)
+	for _, t := range r.opts.SpanTypes {
+		if st == t {
+			return true
+		}
+	}
+
+	if r.opts.SlowEntrySpanThreshold > 0 && span.Duration > r.opts.SlowEntrySpanThreshold {
+		return st.extractData(span).Kind() == EntrySpanKind
+	}
+
+	return false
+}
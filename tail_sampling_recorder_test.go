@@ -0,0 +1,192 @@
+// (c) Copyright IBM Corp. 2023
+
+package instana
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ot "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/stretchr/testify/assert"
+)
+
+type capturingRecorder struct {
+	spans   []*spanS
+	flushed int
+}
+
+func (r *capturingRecorder) RecordSpan(span *spanS) {
+	r.spans = append(r.spans, span)
+}
+
+func (r *capturingRecorder) Flush(context.Context) error {
+	r.flushed++
+	return nil
+}
+
+func newTailSamplingTestSpan(traceID int64, operation string) *spanS {
+	return &spanS{
+		Operation: operation,
+		context: SpanContext{
+			TraceID: traceID,
+			SpanID:  randomID(),
+		},
+	}
+}
+
+func TestTailSamplingRecorder_RecordSpan(t *testing.T) {
+	now := time.Now()
+
+	next := &capturingRecorder{}
+	r := newTailSamplingRecorder(next, TailSamplingOptions{
+		DecisionWait:           time.Second,
+		KeepErrored:            true,
+		SlowEntrySpanThreshold: time.Second,
+		SpanTypes:              []RegisteredSpanType{RedisSpanType},
+	}, func() time.Time { return now })
+
+	// trace 1 has an errored span
+	r.RecordSpan(newTailSamplingTestSpan(1, "sdk"))
+	errored := newTailSamplingTestSpan(1, "sdk")
+	errored.ErrorCount = 1
+	r.RecordSpan(errored)
+
+	// trace 2 has no errors and is fast
+	r.RecordSpan(newTailSamplingTestSpan(2, "sdk"))
+
+	// trace 3 has a slow entry span
+	slow := newTailSamplingTestSpan(3, string(HTTPServerSpanType))
+	slow.Duration = 2 * time.Second
+	r.RecordSpan(slow)
+
+	// trace 4 has a slow exit span
+	slowExit := newTailSamplingTestSpan(4, string(HTTPClientSpanType))
+	slowExit.Duration = 2 * time.Second
+	slowExit.Tags = ot.Tags{string(ext.SpanKind): ext.SpanKindRPCClientEnum}
+	r.RecordSpan(slowExit)
+
+	// trace 5 has a redis call
+	r.RecordSpan(newTailSamplingTestSpan(5, string(RedisSpanType)))
+
+	r.decideExpired()
+	assert.Empty(t, next.spans)
+	assert.Equal(t, TailSamplingStats{PendingTraces: 5}, r.Stats())
+
+	now = now.Add(time.Second)
+	r.decideExpired()
+
+	var traceIDs []int64
+	for _, sp := range next.spans {
+		traceIDs = append(traceIDs, sp.context.TraceID)
+	}
+
+	assert.Equal(t, []int64{1, 1, 3, 5}, traceIDs)
+	assert.Equal(t, TailSamplingStats{
+		KeptTraces:    3,
+		DroppedTraces: 2,
+	}, r.Stats())
+}
+
+func TestTailSamplingRecorder_RecordSpan_Limits(t *testing.T) {
+	next := &capturingRecorder{}
+	r := newTailSamplingRecorder(next, TailSamplingOptions{
+		MaxTraces:        2,
+		MaxSpansPerTrace: 2,
+		KeepErrored:      true,
+	}, time.Now)
+
+	for i := 0; i < 3; i++ {
+		sp := newTailSamplingTestSpan(1, "sdk")
+		sp.ErrorCount = 1
+		r.RecordSpan(sp)
+	}
+
+	r.RecordSpan(newTailSamplingTestSpan(2, "sdk"))
+	assert.Empty(t, next.spans)
+
+	// trace 1 is evicted and kept
+	r.RecordSpan(newTailSamplingTestSpan(3, "sdk"))
+	assert.Len(t, next.spans, 2)
+
+	assert.Equal(t, TailSamplingStats{
+		PendingTraces: 2,
+		KeptTraces:    1,
+		EvictedTraces: 1,
+		DroppedSpans:  1,
+	}, r.Stats())
+}
+
+func TestTailSamplingRecorder_Flush(t *testing.T) {
+	next := &capturingRecorder{}
+	r := newTailSamplingRecorder(next, DefaultTailSamplingOptions(), time.Now)
+
+	sp := newTailSamplingTestSpan(1, "sdk")
+	sp.ErrorCount = 1
+	r.RecordSpan(sp)
+	r.RecordSpan(newTailSamplingTestSpan(2, "sdk"))
+
+	assert.NoError(t, r.Flush(context.Background()))
+
+	assert.Equal(t, []*spanS{sp}, next.spans)
+	assert.Equal(t, 1, next.flushed)
+	assert.Equal(t, TailSamplingStats{
+		KeptTraces:    1,
+		DroppedTraces: 1,
+	}, r.Stats())
+}
+
+func TestTailSamplingRecorder_Close(t *testing.T) {
+	next := &capturingRecorder{}
+	r := NewTailSamplingRecorder(next, TailSamplingOptions{
+		DecisionWait: 20 * time.Millisecond,
+		KeepErrored:  true,
+	})
+
+	sp1 := newTailSamplingTestSpan(1, "sdk")
+	sp1.ErrorCount = 1
+	r.RecordSpan(sp1)
+
+	// the pending traces are flushed on close
+	assert.NoError(t, r.Close())
+	assert.Equal(t, []*spanS{sp1}, next.spans)
+
+	sp2 := newTailSamplingTestSpan(2, "sdk")
+	sp2.ErrorCount = 1
+	r.RecordSpan(sp2)
+
+	// no decisions are made once the recorder is closed
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 1, r.Stats().PendingTraces)
+
+	// closing the recorder twice is safe
+	assert.NoError(t, r.Close())
+	assert.Equal(t, []*spanS{sp1, sp2}, next.spans)
+}
+
+func TestShutdownSensor_FlushesTailSamplingRecorder(t *testing.T) {
+	next := &capturingRecorder{}
+	r := newTailSamplingRecorder(next, DefaultTailSamplingOptions(), time.Now)
+
+	InitSensor(&Options{
+		Recorder:    r,
+		AgentClient: alwaysReadyClient{},
+	})
+
+	sp := newTailSamplingTestSpan(1, "sdk")
+	sp.ErrorCount = 1
+	r.RecordSpan(sp)
+
+	ShutdownSensor()
+
+	assert.Equal(t, []*spanS{sp}, next.spans)
+	assert.Equal(t, 1, next.flushed)
+
+	// the periodic sampling decisions are stopped
+	select {
+	case <-r.done:
+	default:
+		t.Error("tail-based sampling recorder has not been closed")
+	}
+}
@@ -40,6 +40,8 @@ func NewTracerWithEverything(options *Options, recorder SpanRecorder) *tracerS {
 		recorder = NewRecorder()
 	}
 
+	sensor.Metrics().AddRecorder(recorder)
+
 	tracer := &tracerS{
 		recorder: recorder,
 	}
@@ -56,6 +58,13 @@ func (r *tracerS) Inject(spanContext ot.SpanContext, format interface{}, carrier
 		}
 
 		return injectTraceContext(sc, carrier)
+	case ot.Binary:
+		sc, ok := spanContext.(SpanContext)
+		if !ok {
+			return ot.ErrInvalidSpanContext
+		}
+
+		return injectBinaryTraceContext(sc, carrier)
 	}
 
 	return ot.ErrUnsupportedFormat
@@ -69,6 +78,13 @@ func (r *tracerS) Extract(format interface{}, carrier interface{}) (ot.SpanConte
 			return nil, err
 		}
 
+		return sc, nil
+	case ot.Binary:
+		sc, err := extractBinaryTraceContext(carrier)
+		if err != nil {
+			return nil, err
+		}
+
 		return sc, nil
 	}
 
@@ -93,13 +109,23 @@ func (r *tracerS) StartSpanWithOptions(operationName string, opts ot.StartSpanOp
 	var corrData EUMCorrelationData
 
 	sc := NewRootSpanContext()
-	for _, ref := range opts.References {
-		if ref.Type == ot.ChildOfRef || ref.Type == ot.FollowsFromRef {
-			if parent, ok := ref.ReferencedContext.(SpanContext); ok {
-				corrData = parent.Correlation
-				sc = NewSpanContext(parent)
-				break
-			}
+
+	parentRef := parentReference(opts.References)
+	if parentRef >= 0 {
+		parent := opts.References[parentRef].ReferencedContext.(SpanContext)
+		corrData = parent.Correlation
+		sc = NewSpanContext(parent)
+	}
+
+	// the rest of references are recorded as links, e.g. the messages processed by a batch consumer
+	var links []SpanReference
+	for i, ref := range opts.References {
+		if i == parentRef {
+			continue
+		}
+
+		if link, ok := newSpanReference(ref.ReferencedContext); ok {
+			links = append(links, link)
 		}
 	}
 
@@ -108,6 +134,21 @@ func (r *tracerS) StartSpanWithOptions(operationName string, opts ot.StartSpanOp
 		delete(opts.Tags, suppressTracingTag)
 	}
 
+	// make the sampling decision for a new trace, all traces are sampled unless there is a sampler configured
+	if sc.ParentID == 0 && !sc.Suppressed {
+		sc.Sampled = true
+
+		if sampler := r.Options().Sampler; sampler != nil {
+			sc.Sampled = sampler.ShouldSample(SamplingParameters{
+				TraceIDHi:     sc.TraceIDHi,
+				TraceID:       sc.TraceID,
+				OperationName: operationName,
+				Tags:          opts.Tags,
+			})
+			sc.Suppressed = !sc.Sampled
+		}
+	}
+
 	return &spanS{
 		context:     sc,
 		tracer:      r,
@@ -117,16 +158,34 @@ func (r *tracerS) StartSpanWithOptions(operationName string, opts ot.StartSpanOp
 		Duration:    -1,
 		Correlation: corrData,
 		Tags:        opts.Tags,
+		Links:       links,
 	}
 }
 
-// Options returns current tracer options
-func (r *tracerS) Options() TracerOptions {
-	if sensor.options == nil {
-		return DefaultTracerOptions()
+// parentReference returns the index of the reference to be used as a parent for the new span. A ChildOf
+// reference is preferred over a FollowsFrom one. If there is no reference to an Instana span context, -1
+// is returned.
+func parentReference(refs []ot.SpanReference) int {
+	ind := -1
+	for i, ref := range refs {
+		if _, ok := ref.ReferencedContext.(SpanContext); !ok {
+			continue
+		}
+
+		switch {
+		case ref.Type == ot.ChildOfRef:
+			return i
+		case ref.Type == ot.FollowsFromRef && ind < 0:
+			ind = i
+		}
 	}
 
-	return sensor.options.Tracer
+	return ind
+}
+
+// Options returns current tracer options
+func (r *tracerS) Options() TracerOptions {
+	return sensor.tracerOptions()
 }
 
 // Flush forces sending any queued finished spans to the agent
@@ -21,6 +21,26 @@ type TracerOptions struct {
 	//
 	// See https://www.instana.com/docs/setup_and_manage/host_agent/configuration/#capture-custom-http-headers for details
 	CollectableHTTPHeaders []string
+	// Sampler makes the head-based sampling decision for new traces started by this tracer. The decision
+	// is made once for the root span and is inherited by all its children. Traces continued from an upstream
+	// service keep the decision made there. If not set, all traces are recorded.
+	Sampler Sampler
+	// Use128BitTraceIDs makes the tracer issue 128-bit trace IDs for new traces instead of 64-bit ones. The full
+	// trace ID is then propagated downstream and reported to the agent. Traces continued from an upstream service
+	// keep the trace ID received from there. This option can also be enabled via INSTANA_128BIT_TRACE_IDS env variable.
+	Use128BitTraceIDs bool
+	// Propagators is the list of trace context propagators used to inject and extract the trace context, such as
+	// instana.PropagatorInstana, instana.PropagatorW3C, instana.PropagatorB3, instana.PropagatorB3Single and
+	// instana.PropagatorJaeger. The trace context is injected using all listed propagators, while the extraction
+	// prefers Instana headers over the 3rd-party ones. If not set, instana.DefaultPropagators are used. This option
+	// can also be set via INSTANA_PROPAGATORS env variable, i.e. INSTANA_PROPAGATORS=instana,w3c,b3
+	Propagators []string
+	// SpanFilters is the list of rules to exclude spans from being sent to the agent. A span is dropped if it matches
+	// all attributes of any rule. The filtering rules from the host agent configuration are appended to this list.
+	SpanFilters []SpanFilter
+	// DisabledInstrumentations is the list of span types, such as "kafka" or "g.sql", that are not sent to the agent.
+	// The instrumentations disabled in the host agent configuration are appended to this list.
+	DisabledInstrumentations []string
 }
 
 // DefaultTracerOptions returns the default set of options to configure a tracer
@@ -4,11 +4,13 @@
 package instana_test
 
 import (
+	"encoding/json"
 	"testing"
 
 	instana "github.com/instana/go-sensor"
 	ot "github.com/opentracing/opentracing-go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestTracerAPI(t *testing.T) {
@@ -70,6 +72,67 @@ type strangeContext struct{}
 
 func (c *strangeContext) ForeachBaggageItem(handler func(k, v string) bool) {}
 
+func TestTracer_StartSpan_Links(t *testing.T) {
+	recorder := instana.NewTestRecorder()
+	tracer := instana.NewTracerWithEverything(&instana.Options{AgentClient: alwaysReadyClient{}}, recorder)
+	defer instana.ShutdownSensor()
+
+	msg1 := instana.SpanContext{TraceID: 0x1, SpanID: 0x2}
+	msg2 := instana.SpanContext{TraceIDHi: 0x3, TraceID: 0x4, SpanID: 0x5}
+	parent := instana.SpanContext{TraceID: 0x6, SpanID: 0x7}
+
+	tracer.StartSpan("batch",
+		ot.FollowsFrom(msg1),
+		ot.FollowsFrom(msg2),
+		ot.ChildOf(parent),
+	).Finish()
+
+	spans := recorder.GetQueuedSpans()
+	require.Len(t, spans, 1)
+
+	span := spans[0]
+	assert.Equal(t, int64(0x6), span.TraceID)
+	assert.Equal(t, int64(0x7), span.ParentID)
+	assert.Equal(t, []instana.SpanReference{
+		{TraceID: "0000000000000001", SpanID: "0000000000000002"},
+		{TraceID: "00000000000000030000000000000004", SpanID: "0000000000000005"},
+	}, span.Links)
+
+	data, err := json.Marshal(span)
+	require.NoError(t, err)
+
+	var doc struct {
+		Links []map[string]string `json:"lk"`
+	}
+	require.NoError(t, json.Unmarshal(data, &doc))
+
+	assert.Equal(t, []map[string]string{
+		{"t": "0000000000000001", "s": "0000000000000002"},
+		{"t": "00000000000000030000000000000004", "s": "0000000000000005"},
+	}, doc.Links)
+}
+
+func TestTracer_StartSpan_FollowsFrom(t *testing.T) {
+	recorder := instana.NewTestRecorder()
+	tracer := instana.NewTracerWithEverything(&instana.Options{AgentClient: alwaysReadyClient{}}, recorder)
+	defer instana.ShutdownSensor()
+
+	tracer.StartSpan("batch",
+		ot.FollowsFrom(instana.SpanContext{TraceID: 0x1, SpanID: 0x2}),
+		ot.FollowsFrom(instana.SpanContext{TraceID: 0x3, SpanID: 0x4}),
+	).Finish()
+
+	spans := recorder.GetQueuedSpans()
+	require.Len(t, spans, 1)
+
+	// the first reference is used as a parent if there is no ChildOf one
+	assert.Equal(t, int64(0x1), spans[0].TraceID)
+	assert.Equal(t, int64(0x2), spans[0].ParentID)
+	assert.Equal(t, []instana.SpanReference{
+		{TraceID: "0000000000000003", SpanID: "0000000000000004"},
+	}, spans[0].Links)
+}
+
 func TestTracer_NonInstanaSpan(t *testing.T) {
 	tracer := instana.NewTracerWithEverything(&instana.Options{AgentClient: alwaysReadyClient{}}, nil)
 	defer instana.ShutdownSensor()
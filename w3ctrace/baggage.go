@@ -0,0 +1,242 @@
+// (c) Copyright IBM Corp. 2023
+
+package w3ctrace
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// BaggageHeader is the W3C baggage header name as defined by https://www.w3.org/TR/baggage/
+	BaggageHeader = "baggage"
+
+	// MaxBaggageMembers is the maximum number of list members propagated with `baggage`, as defined by
+	// https://www.w3.org/TR/baggage/#limits
+	MaxBaggageMembers = 64
+	// MaxBaggageSize is the maximum length of the `baggage` header value in bytes
+	MaxBaggageSize = 8192
+	// MaxBaggageMemberSize is the maximum length of a single list member in bytes
+	MaxBaggageMemberSize = 4096
+)
+
+// ErrBaggageNotFound is an error returned by w3ctrace.ExtractBaggage() if provided HTTP headers do not contain W3C baggage
+var ErrBaggageNotFound = errors.New("no w3c baggage")
+
+// BaggageMember is a key-value pair of the W3C baggage list with optional properties, i.e. `key=value;property=value`
+type BaggageMember struct {
+	Key   string
+	Value string
+	// Properties is the list of raw member properties in either `key` or `key=value` form
+	Properties []string
+}
+
+// Baggage is a list of members propagated with the W3C baggage header
+type Baggage []BaggageMember
+
+// ParseBaggage parses the value of `baggage` header. Malformed list members and members that exceed
+// the size limit are discarded, as well as any member beyond MaxBaggageMembers.
+func ParseBaggage(s string) Baggage {
+	var b Baggage
+
+	for _, item := range strings.Split(s, ",") {
+		if len(b) == MaxBaggageMembers {
+			break
+		}
+
+		item = strings.TrimSpace(item)
+		if item == "" || len(item) > MaxBaggageMemberSize {
+			continue
+		}
+
+		if m, ok := parseBaggageMember(item); ok {
+			b = append(b, m)
+		}
+	}
+
+	return b
+}
+
+// Member returns the baggage list member with given key
+func (b Baggage) Member(key string) (BaggageMember, bool) {
+	for _, m := range b {
+		if m.Key == key {
+			return m, true
+		}
+	}
+
+	return BaggageMember{}, false
+}
+
+// String returns the string representation of the baggage compatible with the `baggage` header format.
+// Member values are percent-encoded, members with invalid keys are omitted. Members that would make the
+// header value exceed the size limits are discarded.
+func (b Baggage) String() string {
+	var buf strings.Builder
+
+	var n int
+	for _, m := range b {
+		if n == MaxBaggageMembers {
+			break
+		}
+
+		s, ok := m.String()
+		if !ok || len(s) > MaxBaggageMemberSize {
+			continue
+		}
+
+		if buf.Len() > 0 {
+			if buf.Len()+1+len(s) > MaxBaggageSize {
+				continue
+			}
+
+			buf.WriteByte(',')
+		} else if len(s) > MaxBaggageSize {
+			continue
+		}
+
+		buf.WriteString(s)
+		n++
+	}
+
+	return buf.String()
+}
+
+// String returns the string representation of a baggage list member. It returns false
+// if the member key is not a valid token.
+func (m BaggageMember) String() (string, bool) {
+	if !isBaggageToken(m.Key) {
+		return "", false
+	}
+
+	var buf strings.Builder
+	buf.WriteString(m.Key)
+	buf.WriteByte('=')
+	buf.WriteString(escapeBaggageValue(m.Value))
+
+	for _, p := range m.Properties {
+		buf.WriteByte(';')
+		buf.WriteString(p)
+	}
+
+	return buf.String(), true
+}
+
+// ExtractBaggage extracts the W3C baggage from HTTP headers. Values of multiple `baggage` headers
+// are combined. Returns ErrBaggageNotFound if provided value doesn't contain a non-empty baggage header.
+func ExtractBaggage(headers http.Header) (Baggage, error) {
+	var values []string
+	for k, v := range headers {
+		if strings.EqualFold(k, BaggageHeader) {
+			values = append(values, v...)
+		}
+	}
+
+	b := ParseBaggage(strings.Join(values, ","))
+	if len(b) == 0 {
+		return nil, ErrBaggageNotFound
+	}
+
+	return b, nil
+}
+
+// InjectBaggage adds the W3C baggage header, overriding any previously set value. An empty baggage
+// is not injected.
+func InjectBaggage(b Baggage, headers http.Header) {
+	injectRawBaggage(b.String(), headers)
+}
+
+func injectRawBaggage(s string, headers http.Header) {
+	if s == "" {
+		return
+	}
+
+	// delete existing headers ignoring the header name case
+	for k := range headers {
+		if strings.EqualFold(k, BaggageHeader) {
+			delete(headers, k)
+		}
+	}
+
+	headers.Set(BaggageHeader, s)
+}
+
+func parseBaggageMember(s string) (BaggageMember, bool) {
+	props := strings.Split(s, ";")
+
+	ind := strings.IndexByte(props[0], '=')
+	if ind < 0 {
+		return BaggageMember{}, false
+	}
+
+	key := strings.TrimSpace(props[0][:ind])
+	if !isBaggageToken(key) {
+		return BaggageMember{}, false
+	}
+
+	value, err := url.PathUnescape(strings.TrimSpace(props[0][ind+1:]))
+	if err != nil {
+		return BaggageMember{}, false
+	}
+
+	m := BaggageMember{Key: key, Value: value}
+	for _, p := range props[1:] {
+		if p = strings.TrimSpace(p); p != "" {
+			m.Properties = append(m.Properties, p)
+		}
+	}
+
+	return m, true
+}
+
+// isBaggageToken checks whether s is a valid token as defined by https://www.rfc-editor.org/rfc/rfc7230#section-3.2.6
+func isBaggageToken(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// escapeBaggageValue percent-encodes all characters that are not allowed in a baggage value, see
+// https://www.w3.org/TR/baggage/#value
+func escapeBaggageValue(s string) string {
+	const hex = "0123456789ABCDEF"
+
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		if isBaggageOctet(c) && c != '%' {
+			buf.WriteByte(c)
+			continue
+		}
+
+		buf.WriteByte('%')
+		buf.WriteByte(hex[c>>4])
+		buf.WriteByte(hex[c&0xf])
+	}
+
+	return buf.String()
+}
+
+func isBaggageOctet(c byte) bool {
+	return c == 0x21 ||
+		(c >= 0x23 && c <= 0x2b) ||
+		(c >= 0x2d && c <= 0x3a) ||
+		(c >= 0x3c && c <= 0x5b) ||
+		(c >= 0x5d && c <= 0x7e)
+}
@@ -0,0 +1,161 @@
+// (c) Copyright IBM Corp. 2023
+
+package w3ctrace_test
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/instana/go-sensor/w3ctrace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBaggage(t *testing.T) {
+	examples := map[string]struct {
+		Value    string
+		Expected w3ctrace.Baggage
+	}{
+		"empty": {"", nil},
+		"single member": {
+			"tenant=acme",
+			w3ctrace.Baggage{{Key: "tenant", Value: "acme"}},
+		},
+		"with whitespaces and empty members": {
+			" tenant = acme ,, userId=alice ",
+			w3ctrace.Baggage{
+				{Key: "tenant", Value: "acme"},
+				{Key: "userId", Value: "alice"},
+			},
+		},
+		"with properties": {
+			"tenant=acme;ttl=60 ; internal,userId=alice",
+			w3ctrace.Baggage{
+				{Key: "tenant", Value: "acme", Properties: []string{"ttl=60", "internal"}},
+				{Key: "userId", Value: "alice"},
+			},
+		},
+		"percent-encoded": {
+			"name=John%20Doe%2C%20Jr.,expr=a%3Db",
+			w3ctrace.Baggage{
+				{Key: "name", Value: "John Doe, Jr."},
+				{Key: "expr", Value: "a=b"},
+			},
+		},
+		"malformed members": {
+			"novalue,in valid=key,bad=%zz,tenant=acme",
+			w3ctrace.Baggage{{Key: "tenant", Value: "acme"}},
+		},
+	}
+
+	for name, example := range examples {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, example.Expected, w3ctrace.ParseBaggage(example.Value))
+		})
+	}
+}
+
+func TestParseBaggage_Limits(t *testing.T) {
+	t.Run("too many members", func(t *testing.T) {
+		var members []string
+		for i := 0; i < w3ctrace.MaxBaggageMembers+10; i++ {
+			members = append(members, "key"+strconv.Itoa(i)+"=value")
+		}
+
+		b := w3ctrace.ParseBaggage(strings.Join(members, ","))
+		require.Len(t, b, w3ctrace.MaxBaggageMembers)
+		assert.Equal(t, "key0", b[0].Key)
+	})
+
+	t.Run("oversized member", func(t *testing.T) {
+		b := w3ctrace.ParseBaggage("large=" + strings.Repeat("x", w3ctrace.MaxBaggageMemberSize) + ",tenant=acme")
+		assert.Equal(t, w3ctrace.Baggage{{Key: "tenant", Value: "acme"}}, b)
+	})
+}
+
+func TestBaggage_String(t *testing.T) {
+	b := w3ctrace.Baggage{
+		{Key: "tenant", Value: "acme", Properties: []string{"ttl=60"}},
+		{Key: "name", Value: "John Doe, Jr.; 100%"},
+		{Key: "invalid key", Value: "value"},
+	}
+
+	assert.Equal(t, "tenant=acme;ttl=60,name=John%20Doe%2C%20Jr.%3B%20100%25", b.String())
+	assert.Equal(t, b[:2], w3ctrace.ParseBaggage(b.String()))
+}
+
+func TestBaggage_String_Limits(t *testing.T) {
+	var b w3ctrace.Baggage
+	for i := 0; i < 3; i++ {
+		b = append(b, w3ctrace.BaggageMember{
+			Key:   "key" + strconv.Itoa(i),
+			Value: strings.Repeat("x", w3ctrace.MaxBaggageMemberSize-20),
+		})
+	}
+	b = append(b, w3ctrace.BaggageMember{Key: "tenant", Value: "acme"})
+
+	s := b.String()
+	assert.LessOrEqual(t, len(s), w3ctrace.MaxBaggageSize)
+
+	parsed := w3ctrace.ParseBaggage(s)
+	require.Len(t, parsed, 3)
+	assert.Equal(t, "key0", parsed[0].Key)
+	assert.Equal(t, "key1", parsed[1].Key)
+	assert.Equal(t, "tenant", parsed[2].Key)
+}
+
+func TestBaggage_Member(t *testing.T) {
+	b := w3ctrace.ParseBaggage("tenant=acme;ttl=60,userId=alice")
+
+	m, ok := b.Member("tenant")
+	require.True(t, ok)
+	assert.Equal(t, w3ctrace.BaggageMember{Key: "tenant", Value: "acme", Properties: []string{"ttl=60"}}, m)
+
+	_, ok = b.Member("region")
+	assert.False(t, ok)
+}
+
+func TestExtractBaggage(t *testing.T) {
+	headers := http.Header{}
+	// set raw headers to preserve header name case
+	headers["BAGGAGE"] = []string{"tenant=acme", "userId=alice"}
+
+	b, err := w3ctrace.ExtractBaggage(headers)
+	require.NoError(t, err)
+
+	assert.Equal(t, w3ctrace.Baggage{
+		{Key: "tenant", Value: "acme"},
+		{Key: "userId", Value: "alice"},
+	}, b)
+}
+
+func TestExtractBaggage_NoBaggage(t *testing.T) {
+	_, err := w3ctrace.ExtractBaggage(http.Header{
+		"Baggage": {" , "},
+	})
+	assert.Equal(t, w3ctrace.ErrBaggageNotFound, err)
+}
+
+func TestInjectBaggage(t *testing.T) {
+	headers := http.Header{
+		"baggage": {"userId=alice"},
+	}
+
+	w3ctrace.InjectBaggage(w3ctrace.Baggage{{Key: "tenant", Value: "acme corp"}}, headers)
+
+	assert.Equal(t, http.Header{
+		"Baggage": {"tenant=acme%20corp"},
+	}, headers)
+}
+
+func TestInjectBaggage_Empty(t *testing.T) {
+	headers := http.Header{
+		"Baggage": {"userId=alice"},
+	}
+
+	w3ctrace.InjectBaggage(nil, headers)
+
+	assert.Equal(t, http.Header{"Baggage": {"userId=alice"}}, headers)
+}
@@ -33,6 +33,8 @@ var (
 type Context struct {
 	RawParent string
 	RawState  string
+	// RawBaggage is the value of W3C baggage header propagated along with the trace context
+	RawBaggage string
 }
 
 // New initializes a new W3C trace context from given parent
@@ -65,6 +67,10 @@ func Extract(headers http.Header) (Context, error) {
 		}
 	}
 
+	if b, err := ExtractBaggage(headers); err == nil {
+		tr.RawBaggage = b.String()
+	}
+
 	if tr.RawParent == "" {
 		return tr, ErrContextNotFound
 	}
@@ -72,7 +78,7 @@ func Extract(headers http.Header) (Context, error) {
 	return tr, nil
 }
 
-// Inject adds the w3c trace context headers, overriding any previously set values
+// Inject adds the w3c trace context and baggage headers, overriding any previously set values
 func Inject(trCtx Context, headers http.Header) {
 	// delete existing headers ignoring the header name case
 	for k := range headers {
@@ -85,6 +91,8 @@ func Inject(trCtx Context, headers http.Header) {
 	if trCtx.RawState != "" {
 		headers.Set(TraceStateHeader, trCtx.RawState)
 	}
+
+	injectRawBaggage(trCtx.RawBaggage, headers)
 }
 
 // State parses RawState and returns the corresponding list.
@@ -92,6 +100,11 @@ func (c Context) State() State {
 	return ParseState(c.RawState)
 }
 
+// Baggage parses RawBaggage and returns the corresponding list.
+func (c Context) Baggage() Baggage {
+	return ParseBaggage(c.RawBaggage)
+}
+
 // Parent parses RawParent and returns the corresponding list.
 // It silently discards malformed value. To check errors use ParseParent().
 func (c Context) Parent() Parent {
@@ -144,3 +144,37 @@ func TestContext_IsZero(t *testing.T) {
 		})
 	}
 }
+
+func TestExtract_WithBaggage(t *testing.T) {
+	headers := http.Header{}
+	headers.Set(w3ctrace.TraceParentHeader, exampleTraceParent)
+	headers.Set(w3ctrace.BaggageHeader, "tenant = acme;ttl=60, userId=alice")
+
+	tr, err := w3ctrace.Extract(headers)
+	require.NoError(t, err)
+
+	assert.Equal(t, w3ctrace.Context{
+		RawParent:  exampleTraceParent,
+		RawBaggage: "tenant=acme;ttl=60,userId=alice",
+	}, tr)
+
+	m, ok := tr.Baggage().Member("tenant")
+	require.True(t, ok)
+	assert.Equal(t, "acme", m.Value)
+}
+
+func TestInject_WithBaggage(t *testing.T) {
+	headers := http.Header{
+		"baggage": {"userId=alice"},
+	}
+
+	w3ctrace.Inject(w3ctrace.Context{
+		RawParent:  exampleTraceParent,
+		RawBaggage: "tenant=acme",
+	}, headers)
+
+	assert.Equal(t, http.Header{
+		"Traceparent": {exampleTraceParent},
+		"Baggage":     {"tenant=acme"},
+	}, headers)
+}